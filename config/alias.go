@@ -0,0 +1,99 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Alias declares that the setting Old was renamed to New. Both are dotted
+// keys.
+type Alias struct {
+	Old string
+	New string
+}
+
+// UnpackWithAliases unpacks the configuration into to like Unpack, after
+// applying the aliases: the value of a deprecated key is copied to the key
+// replacing it. If both keys are set the new one wins. The returned warnings
+// report every deprecated key in use and every conflict, they are meant to be
+// logged by the caller. c is not modified.
+func (c *C) UnpackWithAliases(to interface{}, aliases ...Alias) (warnings []string, err error) {
+	resolved, warnings, err := c.ApplyAliases(aliases...)
+	if err != nil {
+		return warnings, err
+	}
+	return warnings, resolved.Unpack(to)
+}
+
+// ApplyAliases returns a copy of the configuration with the deprecated keys
+// of the aliases moved to their new names. See UnpackWithAliases.
+func (c *C) ApplyAliases(aliases ...Alias) (resolved *C, warnings []string, err error) {
+	resolved, err = MergeConfigs(c)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, alias := range aliases {
+		hasOld, err := resolved.Has(alias.Old, -1)
+		if err != nil || !hasOld {
+			continue
+		}
+		hasNew, err := resolved.Has(alias.New, -1)
+		if err != nil {
+			return nil, warnings, fmt.Errorf("checking setting '%s': %w", alias.New, err)
+		}
+
+		if hasNew {
+			warnings = append(warnings, fmt.Sprintf("setting '%s' is deprecated and ignored because '%s' is set, remove '%s'", alias.Old, alias.New, alias.Old))
+		} else {
+			warnings = append(warnings, fmt.Sprintf("setting '%s' is deprecated, use '%s' instead", alias.Old, alias.New))
+
+			var content map[string]interface{}
+			if err := resolved.Unpack(&content); err != nil {
+				return nil, warnings, err
+			}
+			value, _ := lookupPath(content, alias.Old)
+			if err := resolved.Merge(map[string]interface{}{alias.New: value}); err != nil {
+				return nil, warnings, fmt.Errorf("renaming setting '%s' to '%s': %w", alias.Old, alias.New, err)
+			}
+		}
+
+		if _, err := resolved.Remove(alias.Old, -1); err != nil {
+			return nil, warnings, fmt.Errorf("removing deprecated setting '%s': %w", alias.Old, err)
+		}
+	}
+	return resolved, warnings, nil
+}
+
+// lookupPath returns the value at the dotted key in the unpacked
+// configuration.
+func lookupPath(m map[string]interface{}, key string) (interface{}, bool) {
+	var v interface{} = m
+	for _, k := range strings.Split(key, ".") {
+		sub, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if v, ok = sub[k]; !ok {
+			return nil, false
+		}
+	}
+	return v, true
+}
@@ -0,0 +1,80 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnpackWithAliases(t *testing.T) {
+	type settings struct {
+		Output struct {
+			Hosts   []string `config:"hosts"`
+			Timeout int      `config:"timeout"`
+		} `config:"output"`
+	}
+	aliases := []Alias{
+		{Old: "output.host", New: "output.hosts"},
+		{Old: "output.timeout_seconds", New: "output.timeout"},
+	}
+
+	unpack := func(t *testing.T, yaml string) (settings, []string) {
+		t.Helper()
+		c, err := NewConfigWithYAML([]byte(yaml), "test")
+		require.NoError(t, err)
+		var s settings
+		warnings, err := c.UnpackWithAliases(&s, aliases...)
+		require.NoError(t, err)
+		return s, warnings
+	}
+
+	t.Run("old key only", func(t *testing.T) {
+		s, warnings := unpack(t, "output.host: [localhost:9200]\noutput.timeout_seconds: 30")
+		assert.Equal(t, []string{"localhost:9200"}, s.Output.Hosts)
+		assert.Equal(t, 30, s.Output.Timeout)
+		require.Len(t, warnings, 2)
+		assert.Contains(t, warnings[0], "'output.host' is deprecated, use 'output.hosts' instead")
+	})
+
+	t.Run("new key only", func(t *testing.T) {
+		s, warnings := unpack(t, "output.hosts: [localhost:9200]\noutput.timeout: 30")
+		assert.Equal(t, []string{"localhost:9200"}, s.Output.Hosts)
+		assert.Equal(t, 30, s.Output.Timeout)
+		assert.Empty(t, warnings)
+	})
+
+	t.Run("both set", func(t *testing.T) {
+		s, warnings := unpack(t, "output.timeout_seconds: 10\noutput.timeout: 30")
+		assert.Equal(t, 30, s.Output.Timeout)
+		require.Len(t, warnings, 1)
+		assert.Contains(t, warnings[0], "ignored because 'output.timeout' is set")
+	})
+
+	t.Run("original not modified", func(t *testing.T) {
+		c := MustNewConfigFrom("output.timeout_seconds: 10")
+		_, _, err := c.ApplyAliases(aliases...)
+		require.NoError(t, err)
+		assert.True(t, c.HasField("output"))
+		has, err := c.Has("output.timeout_seconds", -1)
+		require.NoError(t, err)
+		assert.True(t, has)
+	})
+}
@@ -47,6 +47,14 @@ var (
 		ucfg.VarExp,
 	}
 
+	// unresolvedOpts does not resolve environment variables, references
+	// that can not be resolved from the configuration are kept as ${name}.
+	unresolvedOpts = []ucfg.Option{
+		ucfg.PathSep("."),
+		ucfg.VarExp,
+		ucfg.ResolveNOOP,
+	}
+
 	maskList = str.MakeSet(
 		"password",
 		"passphrase",
@@ -0,0 +1,145 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package config
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// ByteSize is a number of bytes that unpacks from a size with unit, like
+// "512KB", "10MiB" or "1.5 GB". KB, MB, GB and TB are powers of 1000, KiB,
+// MiB, GiB and TiB powers of 1024. Units are case-insensitive. Bare numbers
+// are bytes.
+//
+// time.Duration fields unpack natively from strings like "500ms", "5s" or
+// "2h". Bare numbers are seconds.
+type ByteSize uint64
+
+// Byte size units.
+const (
+	Byte ByteSize = 1
+
+	KB ByteSize = 1000 * Byte
+	MB ByteSize = 1000 * KB
+	GB ByteSize = 1000 * MB
+	TB ByteSize = 1000 * GB
+
+	KiB ByteSize = 1024 * Byte
+	MiB ByteSize = 1024 * KiB
+	GiB ByteSize = 1024 * MiB
+	TiB ByteSize = 1024 * GiB
+)
+
+var byteSizeUnits = map[string]ByteSize{
+	"":    Byte,
+	"b":   Byte,
+	"kb":  KB,
+	"mb":  MB,
+	"gb":  GB,
+	"tb":  TB,
+	"kib": KiB,
+	"mib": MiB,
+	"gib": GiB,
+	"tib": TiB,
+}
+
+// ParseByteSize parses a size with an optional unit, see ByteSize.
+func ParseByteSize(s string) (ByteSize, error) {
+	s = strings.TrimSpace(s)
+	i := strings.IndexFunc(s, func(r rune) bool {
+		return !unicode.IsDigit(r) && r != '.'
+	})
+	if i < 0 {
+		i = len(s)
+	}
+	number, unit := s[:i], strings.ToLower(strings.TrimSpace(s[i:]))
+
+	if number == "" {
+		return 0, fmt.Errorf("invalid byte size '%s': missing number", s)
+	}
+	multiplier, ok := byteSizeUnits[unit]
+	if !ok {
+		return 0, fmt.Errorf("invalid byte size '%s': unknown unit '%s'", s, s[i:])
+	}
+
+	if n, err := strconv.ParseUint(number, 10, 64); err == nil {
+		if n > math.MaxUint64/uint64(multiplier) {
+			return 0, fmt.Errorf("invalid byte size '%s': overflows uint64", s)
+		}
+		return ByteSize(n) * multiplier, nil
+	}
+	f, err := strconv.ParseFloat(number, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid byte size '%s': %w", s, err)
+	}
+	size := f * float64(multiplier)
+	if size >= math.MaxUint64 {
+		return 0, fmt.Errorf("invalid byte size '%s': overflows uint64", s)
+	}
+	return ByteSize(size), nil
+}
+
+// Unpack sets the size from a string with unit or a number of bytes.
+func (b *ByteSize) Unpack(v interface{}) error {
+	switch val := v.(type) {
+	case string:
+		size, err := ParseByteSize(val)
+		if err != nil {
+			return err
+		}
+		*b = size
+	case int64:
+		if val < 0 {
+			return fmt.Errorf("invalid byte size %d: must not be negative", val)
+		}
+		*b = ByteSize(val)
+	case uint64:
+		*b = ByteSize(val)
+	case float64:
+		if val < 0 {
+			return fmt.Errorf("invalid byte size %v: must not be negative", val)
+		}
+		*b = ByteSize(val)
+	default:
+		return fmt.Errorf("invalid byte size of type %T", v)
+	}
+	return nil
+}
+
+// Bytes returns the size in bytes.
+func (b ByteSize) Bytes() uint64 {
+	return uint64(b)
+}
+
+// String returns the size in the largest binary unit it is a multiple of, so
+// it unpacks to the same value.
+func (b ByteSize) String() string {
+	for _, u := range []struct {
+		size ByteSize
+		name string
+	}{{TiB, "TiB"}, {GiB, "GiB"}, {MiB, "MiB"}, {KiB, "KiB"}} {
+		if b >= u.size && b%u.size == 0 {
+			return strconv.FormatUint(uint64(b/u.size), 10) + u.name
+		}
+	}
+	return strconv.FormatUint(uint64(b), 10) + "B"
+}
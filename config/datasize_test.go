@@ -0,0 +1,99 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnpackUnits(t *testing.T) {
+	type settings struct {
+		FlushTimeout time.Duration `config:"flush_timeout"`
+		MaxSize      ByteSize      `config:"max_size"`
+	}
+
+	tests := map[string]struct {
+		input   map[string]interface{}
+		want    settings
+		wantErr string
+	}{
+		"strings": {
+			input: map[string]interface{}{"flush_timeout": "500ms", "max_size": "10MiB"},
+			want:  settings{FlushTimeout: 500 * time.Millisecond, MaxSize: 10 * MiB},
+		},
+		"decimal units": {
+			input: map[string]interface{}{"flush_timeout": "2h", "max_size": "1.5 GB"},
+			want:  settings{FlushTimeout: 2 * time.Hour, MaxSize: 1500 * MB},
+		},
+		"bare numbers": {
+			input: map[string]interface{}{"flush_timeout": 5, "max_size": 4096},
+			want:  settings{FlushTimeout: 5 * time.Second, MaxSize: 4096},
+		},
+		"malformed size": {
+			input:   map[string]interface{}{"max_size": "10XB"},
+			wantErr: "max_size",
+		},
+		"malformed duration": {
+			input:   map[string]interface{}{"flush_timeout": "5 parsecs"},
+			wantErr: "flush_timeout",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			var got settings
+			err := MustNewConfigFrom(tc.input).Unpack(&got)
+			if tc.wantErr != "" {
+				require.ErrorContains(t, err, tc.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestParseByteSize(t *testing.T) {
+	for in, want := range map[string]ByteSize{
+		"0":      0,
+		"512":    512,
+		"512b":   512,
+		"1kb":    1000,
+		"1KiB":   1024,
+		"2 MiB":  2 * MiB,
+		"3GiB":   3 * GiB,
+		"1TB":    TB,
+		"0.5KiB": 512,
+	} {
+		got, err := ParseByteSize(in)
+		require.NoError(t, err, in)
+		assert.Equal(t, want, got, in)
+	}
+
+	for _, in := range []string{"", "MiB", "10 bytes", "1.2.3MB", "-1KB", "99999999999TiB"} {
+		_, err := ParseByteSize(in)
+		assert.Error(t, err, in)
+	}
+
+	assert.Equal(t, "10MiB", (10 * MiB).String())
+	assert.Equal(t, "1000B", KB.String())
+}
@@ -19,6 +19,7 @@ package config
 
 import (
 	"flag"
+	"fmt"
 	"strings"
 
 	ucfg "github.com/elastic/go-ucfg"
@@ -195,6 +196,47 @@ func NewSettingsFlag(def *C) *SettingsFlag {
 	return (*SettingsFlag)(tmp)
 }
 
+// NewConfigFromFlags returns the configuration set by args, a list of
+// `key=value` assignments as accepted by SettingsFlag, e.g. the values of
+// repeated -E flags. Keys can be dotted, values are parsed like in YAML, e.g.
+// `output.hosts=["a", "b"]`. A key without value is set to true. Settings
+// assigned multiple times keep the last value.
+func NewConfigFromFlags(args []string) (*C, error) {
+	cfg := NewConfig()
+	f := NewSettingsFlag(cfg)
+	for _, arg := range args {
+		key, _, _ := strings.Cut(arg, "=")
+		if strings.TrimSpace(key) == "" || strings.ContainsAny(key, " \t") {
+			return nil, fmt.Errorf("invalid setting '%s': expected key=value", arg)
+		}
+		if err := f.Set(arg); err != nil {
+			return nil, fmt.Errorf("invalid setting '%s': %w", arg, err)
+		}
+	}
+	return cfg, nil
+}
+
+// OverlayFlags returns a copy of base with the settings assigned by args, see
+// NewConfigFromFlags, merged over it. Lists set by args replace the lists of
+// base. base is not modified.
+func OverlayFlags(base *C, args []string) (*C, error) {
+	overlay, err := NewConfigFromFlags(args)
+	if err != nil {
+		return nil, err
+	}
+	merged, err := MergeConfigs(base)
+	if err != nil {
+		return nil, err
+	}
+	if err := removeReplacedLists(merged, overlay); err != nil {
+		return nil, err
+	}
+	if err := merged.Merge(overlay); err != nil {
+		return nil, err
+	}
+	return merged, nil
+}
+
 func (f *SettingsFlag) access() *cfgflag.FlagValue {
 	return (*cfgflag.FlagValue)(f)
 }
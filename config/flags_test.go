@@ -166,6 +166,74 @@ func TestOverwriteFlag(t *testing.T) {
 	assert.Equal(t, "overwrite", final)
 }
 
+func TestOverlayFlags(t *testing.T) {
+	base := MustNewConfigFrom(map[string]interface{}{
+		"output.elasticsearch.hosts":    []string{"localhost:9200"},
+		"output.elasticsearch.username": "elastic",
+		"logging.level":                 "info",
+	})
+
+	merged, err := OverlayFlags(base, []string{
+		"output.elasticsearch.hosts=['es-1:9200', 'es-2:9200']",
+		"logging.level=debug",
+		"logging.to_stderr",
+		"queue.mem.events=4096",
+		"logging.level=warning",
+	})
+	require.NoError(t, err)
+
+	var result struct {
+		Output struct {
+			Elasticsearch struct {
+				Hosts    []string `config:"hosts"`
+				Username string   `config:"username"`
+			} `config:"elasticsearch"`
+		} `config:"output"`
+		Logging struct {
+			Level    string `config:"level"`
+			ToStderr bool   `config:"to_stderr"`
+		} `config:"logging"`
+		Queue struct {
+			Mem struct {
+				Events int `config:"events"`
+			} `config:"mem"`
+		} `config:"queue"`
+	}
+	require.NoError(t, merged.Unpack(&result))
+
+	assert.Equal(t, []string{"es-1:9200", "es-2:9200"}, result.Output.Elasticsearch.Hosts)
+	assert.Equal(t, "elastic", result.Output.Elasticsearch.Username)
+	assert.Equal(t, "warning", result.Logging.Level, "the last assignment wins")
+	assert.True(t, result.Logging.ToStderr)
+	assert.Equal(t, 4096, result.Queue.Mem.Events)
+
+	level, err := base.String("logging.level", -1)
+	require.NoError(t, err)
+	assert.Equal(t, "info", level, "base is not modified")
+}
+
+func TestOverlayFlagsReplacesLists(t *testing.T) {
+	base := MustNewConfigFrom(map[string]interface{}{
+		"output.elasticsearch.hosts": []string{"es-1:9200", "es-2:9200", "es-3:9200"},
+	})
+
+	merged, err := OverlayFlags(base, []string{"output.elasticsearch.hosts=['es-4:9200']"})
+	require.NoError(t, err)
+
+	var result struct {
+		Hosts []string `config:"output.elasticsearch.hosts"`
+	}
+	require.NoError(t, merged.Unpack(&result))
+	assert.Equal(t, []string{"es-4:9200"}, result.Hosts)
+}
+
+func TestNewConfigFromFlagsInvalid(t *testing.T) {
+	for _, arg := range []string{"=value", "", "bad key=1"} {
+		_, err := NewConfigFromFlags([]string{"a=1", arg})
+		assert.ErrorContains(t, err, fmt.Sprintf("invalid setting '%s'", arg))
+	}
+}
+
 // capture stderr and return captured string
 func withStderr(fn func()) (string, error) {
 	stderr := os.Stderr
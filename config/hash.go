@@ -0,0 +1,102 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/elastic/elastic-agent-libs/str"
+)
+
+// HashOption configures how Hash computes a fingerprint.
+type HashOption func(*hashOptions)
+
+type hashOptions struct {
+	exclude str.Set
+}
+
+// HashExcludeFields excludes all settings with one of the given names, at any
+// depth, from the hash. Names are matched case-insensitively. This allows
+// secrets to be rotated without changing the fingerprint of a configuration.
+func HashExcludeFields(names ...string) HashOption {
+	return func(o *hashOptions) {
+		for _, name := range names {
+			o.exclude.Add(strings.ToLower(name))
+		}
+	}
+}
+
+// HashExcludePrivate excludes the settings that DebugString masks when
+// filtering private data (password, hosts, etc.) from the hash.
+func HashExcludePrivate() HashOption {
+	return func(o *hashOptions) {
+		for name := range maskList {
+			o.exclude.Add(name)
+		}
+	}
+}
+
+// Hash returns a stable SHA-256 hex digest of the configuration content.
+// Configurations with the same settings and values have the same hash, no
+// matter the order or formatting they were created from. All settings are
+// included unless excluded by options.
+func (c *C) Hash(opts ...HashOption) (string, error) {
+	o := hashOptions{exclude: str.Set{}}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	content, err := toGeneric(c)
+	if err != nil {
+		return "", fmt.Errorf("unpacking config for hashing: %w", err)
+	}
+	if len(o.exclude) > 0 {
+		removeFields(content, o.exclude)
+	}
+
+	// encoding/json writes map keys in sorted order and formats numbers
+	// independent of their Go type, which makes the encoding canonical.
+	b, err := json.Marshal(content)
+	if err != nil {
+		return "", fmt.Errorf("encoding config for hashing: %w", err)
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func removeFields(c interface{}, names str.Set) {
+	switch cfg := c.(type) {
+	case map[string]interface{}:
+		for k, v := range cfg {
+			if names.Has(strings.ToLower(k)) {
+				delete(cfg, k)
+				continue
+			}
+			removeFields(v, names)
+		}
+
+	case []interface{}:
+		for _, elem := range cfg {
+			removeFields(elem, names)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHash(t *testing.T) {
+	hash := func(t *testing.T, yaml string, opts ...HashOption) string {
+		t.Helper()
+		c, err := NewConfigWithYAML([]byte(yaml), "test")
+		require.NoError(t, err)
+		h, err := c.Hash(opts...)
+		require.NoError(t, err)
+		return h
+	}
+
+	a := hash(t, `
+output.elasticsearch:
+  hosts: ["localhost:9200"]
+  password: secret
+  bulk_max_size: 50
+logging.level: info
+`)
+	b := hash(t, `
+logging:
+  level: info
+output:
+  elasticsearch:
+    bulk_max_size: 50
+    password: secret
+    hosts:
+      - localhost:9200
+`)
+	c := hash(t, `
+output.elasticsearch:
+  hosts: ["localhost:9200"]
+  password: secret
+  bulk_max_size: 100
+logging.level: info
+`)
+
+	assert.Len(t, a, 64)
+	assert.Equal(t, a, b)
+	assert.NotEqual(t, a, c)
+
+	t.Run("excluded fields", func(t *testing.T) {
+		original := hash(t, `
+output.elasticsearch:
+  hosts: ["localhost:9200"]
+  password: secret
+  bulk_max_size: 50
+logging.level: info
+`, HashExcludeFields("Password"))
+		rotated := hash(t, `
+output.elasticsearch:
+  hosts: ["localhost:9200"]
+  password: rotated
+  bulk_max_size: 50
+logging.level: info
+`, HashExcludeFields("Password"))
+		assert.Equal(t, original, rotated)
+		assert.NotEqual(t, a, original)
+
+		assert.Equal(t,
+			hash(t, "output.elasticsearch: {password: x, bulk_max_size: 50}", HashExcludePrivate()),
+			hash(t, "output.elasticsearch.bulk_max_size: 50"))
+	})
+}
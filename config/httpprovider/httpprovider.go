@@ -0,0 +1,131 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Package httpprovider implements a config.Provider loading the configuration
+// from an HTTP endpoint.
+package httpprovider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+
+	"github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/transport/httpcommon"
+)
+
+// maxBodySize limits the size of the configuration read from the endpoint.
+const maxBodySize = 10 * 1024 * 1024
+
+// Config configures the HTTP provider.
+type Config struct {
+	// URL of the endpoint serving the YAML configuration.
+	URL string `config:"url" yaml:"url"`
+
+	// Headers holds headers to include in the request, like Authorization.
+	Headers map[string]string `config:"headers" yaml:"headers,omitempty"`
+
+	Transport httpcommon.HTTPTransportSettings `config:",inline" yaml:",inline"`
+}
+
+// DefaultConfig returns the default configuration of the HTTP provider.
+func DefaultConfig() Config {
+	return Config{
+		Transport: httpcommon.DefaultHTTPTransportSettings(),
+	}
+}
+
+// Validate checks that the URL is set.
+func (c *Config) Validate() error {
+	if c.URL == "" {
+		return errors.New("url is required")
+	}
+	return nil
+}
+
+// Provider loads the configuration with a GET request.
+type Provider struct {
+	url     string
+	source  string
+	headers map[string]string
+	client  *http.Client
+}
+
+var _ config.Provider = (*Provider)(nil)
+
+// New creates a Provider. TLS, proxy and timeouts are configured by
+// cfg.Transport.
+func New(cfg Config, opts ...httpcommon.TransportOption) (*Provider, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	u, err := url.Parse(cfg.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid url: %w", err)
+	}
+
+	client, err := cfg.Transport.Client(opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create HTTP client: %w", err)
+	}
+
+	return &Provider{
+		url:     cfg.URL,
+		source:  u.Redacted(),
+		headers: cfg.Headers,
+		client:  client,
+	}, nil
+}
+
+// Load fetches the configuration. Any response but 200 OK is an error.
+func (p *Provider) Load(ctx context.Context) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range p.headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxBodySize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	if len(body) > maxBodySize {
+		return nil, fmt.Errorf("configuration exceeds %d bytes", maxBodySize)
+	}
+	return body, nil
+}
+
+// String returns the URL of the endpoint with the password redacted.
+func (p *Provider) String() string {
+	return p.source
+}
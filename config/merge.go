@@ -0,0 +1,363 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package config
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/elastic/go-ucfg"
+)
+
+// MergePolicy defines how a merge resolves a key that holds scalar values of
+// different types in the two configurations, e.g. `port: "9200"` and
+// `port: 9200`.
+type MergePolicy int
+
+const (
+	// MergeLastWins lets the value merged last overwrite the existing value,
+	// whatever its type. This is the behaviour of Merge.
+	MergeLastWins MergePolicy = iota
+	// MergeStrict fails the merge if a scalar would be overwritten by a value
+	// of a different type. The error names the path and both types.
+	MergeStrict
+	// MergeCoerce converts the merged value to the type of the existing value.
+	// The merge fails if the value can not be converted.
+	MergeCoerce
+)
+
+// String returns the name of the policy.
+func (p MergePolicy) String() string {
+	switch p {
+	case MergeLastWins:
+		return "last-wins"
+	case MergeStrict:
+		return "strict"
+	case MergeCoerce:
+		return "coerce"
+	default:
+		return fmt.Sprintf("MergePolicy(%d)", int(p))
+	}
+}
+
+// MergeConfigsWithPolicy merges the configs together, resolving scalar type
+// conflicts according to policy.
+func MergeConfigsWithPolicy(policy MergePolicy, cfgs ...*C) (*C, error) {
+	config := NewConfig()
+	for _, c := range cfgs {
+		if err := config.MergeWithPolicy(c, policy); err != nil {
+			return nil, err
+		}
+	}
+	return config, nil
+}
+
+// MergeWithPolicy merges the parameter into the C object, resolving scalar type
+// conflicts according to policy.
+func (c *C) MergeWithPolicy(from interface{}, policy MergePolicy) error {
+	if policy == MergeLastWins {
+		return c.Merge(from)
+	}
+
+	overlay, ok := from.(*C)
+	if !ok {
+		var err error
+		if overlay, err = NewConfigFrom(from); err != nil {
+			return err
+		}
+	}
+
+	base, err := toGeneric(c)
+	if err != nil {
+		return err
+	}
+	values, err := toGeneric(overlay)
+	if err != nil {
+		return err
+	}
+
+	values, err = resolveConflicts("", base, values, policy)
+	if err != nil {
+		return err
+	}
+	return c.Merge(values)
+}
+
+// toGeneric unpacks the configuration into plain maps and slices. Integers
+// are returned as int64, if they fit, as go-ucfg keeps unsigned integers
+// parsed from YAML as uint64 but integers set from code as int64.
+func toGeneric(c *C) (interface{}, error) {
+	return unpackGeneric(c, configOpts)
+}
+
+// unpackGeneric works like toGeneric, but unpacks with opts.
+func unpackGeneric(c *C, opts []ucfg.Option) (interface{}, error) {
+	if c.IsArray() {
+		var arr []interface{}
+		if err := c.access().Unpack(&arr, opts...); err != nil {
+			return nil, err
+		}
+		return normalizeNumbers(arr), nil
+	}
+	var m map[string]interface{}
+	if err := c.access().Unpack(&m, opts...); err != nil {
+		return nil, err
+	}
+	return normalizeNumbers(m), nil
+}
+
+// normalizeNumbers converts the integers in v to int64, unless they overflow
+// it. Maps and slices are modified in place.
+func normalizeNumbers(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, e := range v {
+			v[k] = normalizeNumbers(e)
+		}
+		return v
+	case []interface{}:
+		for i, e := range v {
+			v[i] = normalizeNumbers(e)
+		}
+		return v
+	case int:
+		return int64(v)
+	case int8:
+		return int64(v)
+	case int16:
+		return int64(v)
+	case int32:
+		return int64(v)
+	case uint:
+		if uint64(v) <= math.MaxInt64 {
+			return int64(v)
+		}
+	case uint8:
+		return int64(v)
+	case uint16:
+		return int64(v)
+	case uint32:
+		return int64(v)
+	case uint64:
+		if v <= math.MaxInt64 {
+			return int64(v)
+		}
+	}
+	return v
+}
+
+// resolveConflicts walks overlay and applies policy to every scalar that is
+// also set in base with a different type. It returns the, possibly converted,
+// overlay.
+func resolveConflicts(path string, base, overlay interface{}, policy MergePolicy) (interface{}, error) {
+	switch o := overlay.(type) {
+	case map[string]interface{}:
+		b, ok := base.(map[string]interface{})
+		if !ok {
+			return overlay, nil
+		}
+		for k, v := range o {
+			bv, exists := b[k]
+			if !exists {
+				continue
+			}
+			resolved, err := resolveConflicts(joinPath(path, k), bv, v, policy)
+			if err != nil {
+				return nil, err
+			}
+			o[k] = resolved
+		}
+		return o, nil
+
+	case []interface{}:
+		b, ok := base.([]interface{})
+		if !ok {
+			return overlay, nil
+		}
+		for i, v := range o {
+			if i >= len(b) {
+				break
+			}
+			resolved, err := resolveConflicts(joinPath(path, strconv.Itoa(i)), b[i], v, policy)
+			if err != nil {
+				return nil, err
+			}
+			o[i] = resolved
+		}
+		return o, nil
+	}
+
+	baseType, overlayType := scalarType(base), scalarType(overlay)
+	if baseType == "" || overlayType == "" || baseType == overlayType {
+		return overlay, nil
+	}
+
+	if policy == MergeStrict {
+		return nil, fmt.Errorf("type conflict merging '%s': %s can not be overwritten by %s", path, baseType, overlayType)
+	}
+
+	converted, err := coerceScalar(overlay, base)
+	if err != nil {
+		return nil, fmt.Errorf("can not coerce '%s' from %s to %s: %w", path, overlayType, baseType, err)
+	}
+	return converted, nil
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// scalarType returns the name of the scalar type of v, or an empty string if v
+// is not a scalar.
+func scalarType(v interface{}) string {
+	switch v.(type) {
+	case bool:
+		return "bool"
+	case string:
+		return "string"
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+		return "number"
+	default:
+		return ""
+	}
+}
+
+// coerceScalar converts v to the scalar type of target.
+func coerceScalar(v, target interface{}) (interface{}, error) {
+	s := fmt.Sprint(v)
+	switch target.(type) {
+	case string:
+		return s, nil
+	case bool:
+		return strconv.ParseBool(s)
+	case float32, float64:
+		return strconv.ParseFloat(s, 64)
+	case uint, uint8, uint16, uint32, uint64:
+		return strconv.ParseUint(s, 0, 64)
+	default:
+		return strconv.ParseInt(s, 0, 64)
+	}
+}
+
+// MergeConfigListByKey merges two lists of configurations, like inputs or
+// modules defined in multiple files. Overlay entries whose value at key equals
+// the value of base entries are deep-merged into each of these entries, the
+// overlay winning on conflicts. All other overlay entries, including entries
+// without key, are appended. Base entries are kept as they are, even if they
+// share the same value at key. The order of the base entries is preserved and
+// neither list is modified.
+func MergeConfigListByKey(base, overlay []*C, key string) ([]*C, error) {
+	merged := make([]*C, 0, len(base)+len(overlay))
+	index := map[string][]int{}
+
+	for _, c := range base {
+		id, ok, err := identity(c, key)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			index[id] = append(index[id], len(merged))
+		}
+
+		clone, err := MergeConfigs(c)
+		if err != nil {
+			return nil, err
+		}
+		merged = append(merged, clone)
+	}
+
+	for _, c := range overlay {
+		id, ok, err := identity(c, key)
+		if err != nil {
+			return nil, err
+		}
+		if matches := index[id]; ok && len(matches) > 0 {
+			for _, i := range matches {
+				if err := merged[i].Merge(c); err != nil {
+					return nil, fmt.Errorf("merging entries with %s '%s': %w", key, id, err)
+				}
+			}
+			continue
+		}
+
+		clone, err := MergeConfigs(c)
+		if err != nil {
+			return nil, err
+		}
+		merged = append(merged, clone)
+	}
+	return merged, nil
+}
+
+// identity returns the value at key as a string. ok is false if c is nil or
+// the key is not set.
+func identity(c *C, key string) (id string, ok bool, err error) {
+	if c == nil {
+		return "", false, nil
+	}
+	if has, err := c.Has(key, -1); err != nil || !has {
+		return "", false, nil //nolint:nilerr // entries without key are appended
+	}
+	id, err = c.String(key, -1)
+	if err != nil {
+		return "", false, fmt.Errorf("reading %s: %w", key, err)
+	}
+	return id, true, nil
+}
+
+// removeReplacedLists removes from c the lists that are set by overlay, so
+// that merging overlay replaces them instead of merging them index-wise.
+func removeReplacedLists(c, overlay *C) error {
+	values, err := unpackGeneric(overlay, unresolvedOpts)
+	if err != nil {
+		return err
+	}
+	m, ok := values.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	return removeLists(c, "", m)
+}
+
+func removeLists(c *C, path string, values map[string]interface{}) error {
+	for k, v := range values {
+		fullPath := k
+		if path != "" {
+			fullPath = path + "." + k
+		}
+		switch v := v.(type) {
+		case map[string]interface{}:
+			if err := removeLists(c, fullPath, v); err != nil {
+				return err
+			}
+		case []interface{}:
+			// Has fails if a parent of the path is missing.
+			if ok, err := c.Has(fullPath, -1); err != nil || !ok {
+				continue
+			}
+			if _, err := c.Remove(fullPath, -1); err != nil {
+				return fmt.Errorf("replacing %s: %w", fullPath, err)
+			}
+		}
+	}
+	return nil
+}
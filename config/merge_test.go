@@ -0,0 +1,164 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMergeWithPolicy(t *testing.T) {
+	overlay := func(t *testing.T, policy MergePolicy) (*C, error) {
+		t.Helper()
+		base := MustNewConfigFrom("output.port: 9200\noutput.host: localhost")
+		err := base.MergeWithPolicy(MustNewConfigFrom(`output.port: "9201"`), policy)
+		return base, err
+	}
+
+	t.Run("strict", func(t *testing.T) {
+		_, err := overlay(t, MergeStrict)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "output.port")
+		assert.Contains(t, err.Error(), "number")
+		assert.Contains(t, err.Error(), "string")
+	})
+
+	t.Run("last-wins", func(t *testing.T) {
+		c, err := overlay(t, MergeLastWins)
+		require.NoError(t, err)
+
+		v, err := c.String("output.port", -1)
+		require.NoError(t, err)
+		assert.Equal(t, "9201", v)
+	})
+
+	t.Run("coerce", func(t *testing.T) {
+		c, err := overlay(t, MergeCoerce)
+		require.NoError(t, err)
+
+		var out map[string]interface{}
+		require.NoError(t, c.Unpack(&out))
+		output, ok := out["output"].(map[string]interface{})
+		require.True(t, ok)
+		assert.EqualValues(t, 9201, output["port"])
+		assert.Equal(t, "localhost", output["host"])
+	})
+
+	t.Run("coerce fails on incompatible value", func(t *testing.T) {
+		base := MustNewConfigFrom("port: 9200")
+		err := base.MergeWithPolicy(MustNewConfigFrom("port: abc"), MergeCoerce)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "port")
+	})
+
+	t.Run("same types are merged", func(t *testing.T) {
+		c, err := MergeConfigsWithPolicy(MergeStrict,
+			MustNewConfigFrom("port: 9200\nhosts: [a, b]"),
+			MustNewConfigFrom("port: 9201\nhosts: [c]\nssl.enabled: true"),
+		)
+		require.NoError(t, err)
+
+		port, err := c.Int("port", -1)
+		require.NoError(t, err)
+		assert.EqualValues(t, 9201, port)
+		enabled, err := c.Bool("ssl.enabled", -1)
+		require.NoError(t, err)
+		assert.True(t, enabled)
+	})
+}
+
+func TestMergeConfigListByKey(t *testing.T) {
+	list := func(t *testing.T, yaml string) []*C {
+		t.Helper()
+		c, err := NewConfigWithYAML([]byte(yaml), "test")
+		require.NoError(t, err)
+		var entries []*C
+		require.NoError(t, c.Unpack(&entries))
+		return entries
+	}
+
+	base := list(t, `
+- id: logs
+  type: filestream
+  paths: [/var/log/*.log]
+- id: metrics
+  type: system/metrics
+  period: 10s
+`)
+	overlay := list(t, `
+- id: metrics
+  period: 30s
+- id: audit
+  type: auditd
+- type: journald
+`)
+
+	merged, err := MergeConfigListByKey(base, overlay, "id")
+	require.NoError(t, err)
+
+	var actual []map[string]interface{}
+	for _, c := range merged {
+		var m map[string]interface{}
+		require.NoError(t, c.Unpack(&m))
+		actual = append(actual, m)
+	}
+
+	require.Len(t, actual, 4)
+	assert.Equal(t, "logs", actual[0]["id"])
+	assert.Equal(t, "metrics", actual[1]["id"])
+	assert.Equal(t, "system/metrics", actual[1]["type"])
+	assert.Equal(t, "30s", actual[1]["period"])
+	assert.Equal(t, "audit", actual[2]["id"])
+	assert.Equal(t, "journald", actual[3]["type"])
+
+	period, err := base[1].String("period", -1)
+	require.NoError(t, err)
+	assert.Equal(t, "10s", period, "base entries must not be modified")
+
+	t.Run("duplicate base entries are kept", func(t *testing.T) {
+		base := list(t, `
+- id: logs
+  paths: [/var/log/a.log]
+- id: logs
+  paths: [/var/log/b.log]
+- id: metrics
+`)
+		overlay := list(t, `
+- id: metrics
+  period: 30s
+`)
+
+		merged, err := MergeConfigListByKey(base, overlay, "id")
+		require.NoError(t, err)
+		require.Len(t, merged, 3)
+
+		for i, want := range []string{"/var/log/a.log", "/var/log/b.log"} {
+			path, err := merged[i].String("paths", 0)
+			require.NoError(t, err)
+			assert.Equal(t, want, path)
+			count, err := merged[i].CountField("paths")
+			require.NoError(t, err)
+			assert.Equal(t, 1, count)
+		}
+		period, err := merged[2].String("period", -1)
+		require.NoError(t, err)
+		assert.Equal(t, "30s", period)
+	})
+}
@@ -0,0 +1,282 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package config
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MergeCheckOption configures MergeChecked.
+type MergeCheckOption func(*mergeCheckOptions)
+
+type mergeCheckOptions struct {
+	strict bool
+}
+
+// MergeCheckStrict reports the overlaid settings that have no corresponding
+// field in the target struct as errors. They are ignored by default.
+func MergeCheckStrict() MergeCheckOption {
+	return func(o *mergeCheckOptions) {
+		o.strict = true
+	}
+}
+
+var (
+	durationType        = reflect.TypeOf(time.Duration(0))
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// MergeChecked merges overlay into base after checking that every setting of
+// overlay can be unpacked into the corresponding field of against, a struct
+// or a pointer to a struct. Fields are named like Unpack names them. All
+// mismatches are reported, named by their dotted path, and base is only
+// modified if there are none. Fields of types unpacking themselves, with an
+// Unpack or UnmarshalText method, are not checked.
+func MergeChecked(base, overlay *C, against interface{}, opts ...MergeCheckOption) error {
+	var o mergeCheckOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	t := reflect.TypeOf(against)
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return fmt.Errorf("can not check settings against %T, a struct is required", against)
+	}
+
+	values, err := toGeneric(overlay)
+	if err != nil {
+		return err
+	}
+	if errs := checkValue("", values, t, o); len(errs) > 0 {
+		return fmt.Errorf("type check of merged settings failed: %w", errors.Join(errs...))
+	}
+	return base.Merge(overlay)
+}
+
+func checkValue(path string, v interface{}, t reflect.Type, o mergeCheckOptions) []error {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if v == nil || selfUnpacking(t) {
+		return nil
+	}
+
+	mismatch := func() []error {
+		return []error{fmt.Errorf("'%s': expected %s, got %s", path, t.Kind(), describeValue(v))}
+	}
+
+	switch t.Kind() {
+	case reflect.Interface:
+		return nil
+	case reflect.Struct:
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return mismatch()
+		}
+		return checkStruct(path, m, t, o)
+	case reflect.Map:
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return mismatch()
+		}
+		var errs []error
+		for _, k := range sortedKeys(m) {
+			errs = append(errs, checkValue(joinPath(path, k), m[k], t.Elem(), o)...)
+		}
+		return errs
+	case reflect.Slice, reflect.Array:
+		arr, ok := v.([]interface{})
+		if !ok {
+			// A single value is unpacked into a slice of one element.
+			return checkValue(path, v, t.Elem(), o)
+		}
+		var errs []error
+		for i, item := range arr {
+			errs = append(errs, checkValue(joinPath(path, strconv.Itoa(i)), item, t.Elem(), o)...)
+		}
+		return errs
+	}
+
+	if !scalarFits(v, t.Kind()) {
+		return mismatch()
+	}
+	return nil
+}
+
+func checkStruct(path string, m map[string]interface{}, t reflect.Type, o mergeCheckOptions) []error {
+	fields := map[string]reflect.Type{}
+	var inline []reflect.Type
+	collectFields(t, fields, &inline)
+
+	var errs []error
+	for _, k := range sortedKeys(m) {
+		fieldType, ok := fields[k]
+		if !ok {
+			for _, it := range inline {
+				if it.Kind() == reflect.Map {
+					fieldType, ok = it.Elem(), true
+					break
+				}
+			}
+		}
+		if !ok {
+			if o.strict {
+				errs = append(errs, fmt.Errorf("'%s': no such setting in %s", joinPath(path, k), t))
+			}
+			continue
+		}
+		errs = append(errs, checkValue(joinPath(path, k), m[k], fieldType, o)...)
+	}
+	return errs
+}
+
+// collectFields adds the types of the fields of the struct t by their setting
+// name to fields, descending into inline structs. Inline maps are added to
+// inline.
+func collectFields(t reflect.Type, fields map[string]reflect.Type, inline *[]reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+
+		name, opts, _ := strings.Cut(f.Tag.Get("config"), ",")
+		if strings.Contains(opts, "ignore") {
+			continue
+		}
+		if strings.Contains(opts, "inline") || (f.Anonymous && name == "") {
+			ft := f.Type
+			for ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			switch ft.Kind() {
+			case reflect.Struct:
+				collectFields(ft, fields, inline)
+			case reflect.Map:
+				*inline = append(*inline, ft)
+			}
+			continue
+		}
+
+		if name == "" {
+			name = strings.ToLower(f.Name)
+		}
+		fields[name] = f.Type
+	}
+}
+
+// selfUnpacking returns true if values of type t unpack themselves.
+func selfUnpacking(t reflect.Type) bool {
+	if t == durationType {
+		return true
+	}
+	pt := reflect.PtrTo(t)
+	if _, ok := pt.MethodByName("Unpack"); ok {
+		return true
+	}
+	return pt.Implements(textUnmarshalerType)
+}
+
+// scalarFits returns true if v can be unpacked into a value of kind k. Like
+// Unpack, strings are parsed into numbers and booleans.
+func scalarFits(v interface{}, k reflect.Kind) bool {
+	if _, ok := v.(map[string]interface{}); ok {
+		return false
+	}
+	if _, ok := v.([]interface{}); ok {
+		return false
+	}
+
+	s, isString := v.(string)
+	switch k {
+	case reflect.String:
+		return true
+	case reflect.Bool:
+		if isString {
+			_, err := strconv.ParseBool(s)
+			return err == nil
+		}
+		_, ok := v.(bool)
+		return ok
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if isString {
+			_, err := strconv.ParseInt(s, 0, 64)
+			return err == nil
+		}
+		return isInteger(v)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if isString {
+			_, err := strconv.ParseUint(s, 0, 64)
+			return err == nil
+		}
+		return isInteger(v)
+	case reflect.Float32, reflect.Float64:
+		if isString {
+			_, err := strconv.ParseFloat(s, 64)
+			return err == nil
+		}
+		return scalarType(v) == "number"
+	default:
+		return true
+	}
+}
+
+func isInteger(v interface{}) bool {
+	switch n := v.(type) {
+	case float32:
+		return float64(n) == float64(int64(n))
+	case float64:
+		return n == float64(int64(n))
+	default:
+		return scalarType(v) == "number"
+	}
+}
+
+func describeValue(v interface{}) string {
+	switch v.(type) {
+	case map[string]interface{}:
+		return "dictionary"
+	case []interface{}:
+		return "array"
+	case string:
+		return fmt.Sprintf("string %q", v)
+	}
+	if t := scalarType(v); t != "" {
+		return fmt.Sprintf("%s %v", t, v)
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
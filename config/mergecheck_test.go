@@ -0,0 +1,103 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type mergeCheckOutput struct {
+	Hosts   []string      `config:"hosts"`
+	Port    int           `config:"port"`
+	Timeout time.Duration `config:"timeout"`
+	Size    ByteSize      `config:"size"`
+	TLS     struct {
+		Enabled *bool `config:"enabled"`
+	} `config:"ssl"`
+	Headers map[string]string `config:"headers"`
+}
+
+type mergeCheckTarget struct {
+	Output mergeCheckOutput `config:"output"`
+	Name   string
+}
+
+func TestMergeChecked(t *testing.T) {
+	base := MustNewConfigFrom(map[string]interface{}{
+		"output.port":  9200,
+		"output.hosts": []string{"localhost"},
+	})
+
+	t.Run("valid overlay is merged", func(t *testing.T) {
+		overlay := MustNewConfigFrom(map[string]interface{}{
+			"output.port":        "9300",
+			"output.hosts":       "es:9200",
+			"output.timeout":     "10s",
+			"output.size":        "10MiB",
+			"output.ssl.enabled": true,
+			"output.headers":     map[string]interface{}{"X-Foo": "bar"},
+			"name":               "agent",
+			"unknown":            1,
+		})
+
+		merged, err := MergeConfigs(base)
+		require.NoError(t, err)
+		require.NoError(t, MergeChecked(merged, overlay, &mergeCheckTarget{}))
+
+		port, err := merged.String("output.port", -1)
+		require.NoError(t, err)
+		assert.Equal(t, "9300", port)
+	})
+
+	t.Run("type mismatches are reported with their path", func(t *testing.T) {
+		overlay := MustNewConfigFrom(map[string]interface{}{
+			"output.port":        "not-a-port",
+			"output.ssl.enabled": "maybe",
+			"output.headers":     []interface{}{"a"},
+		})
+
+		merged, err := MergeConfigs(base)
+		require.NoError(t, err)
+		err = MergeChecked(merged, overlay, mergeCheckTarget{})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `'output.port': expected int, got string "not-a-port"`)
+		assert.Contains(t, err.Error(), `'output.ssl.enabled': expected bool, got string "maybe"`)
+		assert.Contains(t, err.Error(), `'output.headers': expected map, got array`)
+
+		port, err := merged.Int("output.port", -1)
+		require.NoError(t, err)
+		assert.EqualValues(t, 9200, port, "base is not modified")
+	})
+
+	t.Run("unknown settings fail in strict mode", func(t *testing.T) {
+		overlay := MustNewConfigFrom(map[string]interface{}{
+			"output.prot": 9300,
+		})
+
+		merged, err := MergeConfigs(base)
+		require.NoError(t, err)
+		require.NoError(t, MergeChecked(merged, overlay, &mergeCheckTarget{}))
+
+		err = MergeChecked(merged, overlay, &mergeCheckTarget{}, MergeCheckStrict())
+		assert.ErrorContains(t, err, "'output.prot': no such setting in config.mergeCheckOutput")
+	})
+}
@@ -0,0 +1,76 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ProfilesKey is the key of the settings holding the profiles applied by
+// ApplyProfile.
+const ProfilesKey = "profiles"
+
+// ApplyProfile returns the effective configuration for the profile name. The
+// profiles are defined under the profiles key, e.g.
+//
+//	output.hosts: ["localhost:9200"]
+//	profiles:
+//	  prod:
+//	    output.hosts: ["prod:9200"]
+//
+// The profile is deep-merged over the configuration, its values winning on
+// conflicts. Lists set by the profile replace the lists of the configuration. The profiles key is not part of the result and c is not
+// modified. An error listing the available profiles is returned if the
+// profile does not exist.
+func (c *C) ApplyProfile(name string) (*C, error) {
+	var available []string
+	var profile *C
+	if c.HasField(ProfilesKey) {
+		profiles, err := c.Child(ProfilesKey, -1)
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %w", ProfilesKey, err)
+		}
+		available = profiles.GetFields()
+		if profiles.HasField(name) {
+			if profile, err = profiles.Child(name, -1); err != nil {
+				return nil, fmt.Errorf("reading profile '%s': %w", name, err)
+			}
+		}
+	}
+	if profile == nil {
+		sort.Strings(available)
+		return nil, fmt.Errorf("unknown profile '%s', available profiles: [%s]", name, strings.Join(available, ", "))
+	}
+
+	effective, err := MergeConfigs(c)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := effective.Remove(ProfilesKey, -1); err != nil {
+		return nil, fmt.Errorf("removing %s: %w", ProfilesKey, err)
+	}
+	if err := removeReplacedLists(effective, profile); err != nil {
+		return nil, fmt.Errorf("applying profile '%s': %w", name, err)
+	}
+	if err := effective.Merge(profile); err != nil {
+		return nil, fmt.Errorf("applying profile '%s': %w", name, err)
+	}
+	return effective, nil
+}
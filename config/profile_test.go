@@ -0,0 +1,91 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplyProfile(t *testing.T) {
+	cfg, err := NewConfigWithYAML([]byte(`
+output:
+  hosts: ["localhost:9200", "localhost:9201"]
+  timeout: 10
+logging.level: info
+name: agent
+profiles:
+  dev:
+    logging.level: debug
+  prod:
+    output:
+      hosts: ["prod-1:9200", "prod-2:9200"]
+    logging.level: warning
+  single:
+    output.hosts: ["single:9200"]
+`), "test")
+	require.NoError(t, err)
+
+	type settings struct {
+		Output struct {
+			Hosts   []string `config:"hosts"`
+			Timeout int      `config:"timeout"`
+		} `config:"output"`
+		Logging struct {
+			Level string `config:"level"`
+		} `config:"logging"`
+		Name string `config:"name"`
+	}
+
+	prod, err := cfg.ApplyProfile("prod")
+	require.NoError(t, err)
+	var s settings
+	require.NoError(t, prod.Unpack(&s))
+	assert.Equal(t, []string{"prod-1:9200", "prod-2:9200"}, s.Output.Hosts)
+	assert.Equal(t, 10, s.Output.Timeout, "base values survive")
+	assert.Equal(t, "warning", s.Logging.Level)
+	assert.Equal(t, "agent", s.Name)
+	assert.False(t, prod.HasField(ProfilesKey), "profiles are not part of the result")
+
+	dev, err := cfg.ApplyProfile("dev")
+	require.NoError(t, err)
+	s = settings{}
+	require.NoError(t, dev.Unpack(&s))
+	assert.Equal(t, []string{"localhost:9200", "localhost:9201"}, s.Output.Hosts)
+	assert.Equal(t, "debug", s.Logging.Level)
+
+	assert.True(t, cfg.HasField(ProfilesKey), "the configuration is not modified")
+	level, err := cfg.String("logging.level", -1)
+	require.NoError(t, err)
+	assert.Equal(t, "info", level)
+
+	single, err := cfg.ApplyProfile("single")
+	require.NoError(t, err)
+	s = settings{}
+	require.NoError(t, single.Unpack(&s))
+	assert.Equal(t, []string{"single:9200"}, s.Output.Hosts, "lists are replaced")
+	assert.Equal(t, 10, s.Output.Timeout)
+
+	_, err = cfg.ApplyProfile("staging")
+	assert.EqualError(t, err, "unknown profile 'staging', available profiles: [dev, prod, single]")
+
+	_, err = NewConfig().ApplyProfile("prod")
+	assert.EqualError(t, err, "unknown profile 'prod', available profiles: []")
+}
@@ -0,0 +1,87 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package config
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	ucfg "github.com/elastic/go-ucfg"
+	"github.com/elastic/go-ucfg/yaml"
+)
+
+var (
+	// ErrProviderFetch is returned by NewConfigFromProvider if the provider
+	// failed to load the configuration.
+	ErrProviderFetch = errors.New("failed to fetch configuration")
+	// ErrProviderParse is returned by NewConfigFromProvider if the loaded
+	// configuration is not valid YAML.
+	ErrProviderParse = errors.New("failed to parse configuration")
+)
+
+// Provider loads a raw YAML configuration from a source such as a file, an
+// HTTP endpoint or a key-value store. A Provider implementing fmt.Stringer is
+// recorded as the source of the configuration.
+type Provider interface {
+	Load(ctx context.Context) ([]byte, error)
+}
+
+// ProviderFunc adapts a function to the Provider interface.
+type ProviderFunc func(ctx context.Context) ([]byte, error)
+
+// Load calls f(ctx).
+func (f ProviderFunc) Load(ctx context.Context) ([]byte, error) {
+	return f(ctx)
+}
+
+// FileProvider loads the configuration from a file.
+type FileProvider string
+
+// Load reads the file.
+func (p FileProvider) Load(_ context.Context) ([]byte, error) {
+	return os.ReadFile(string(p))
+}
+
+// String returns the path of the file.
+func (p FileProvider) String() string {
+	return string(p)
+}
+
+// NewConfigFromProvider loads a YAML configuration from p and parses it. The
+// returned error wraps ErrProviderFetch if loading failed and ErrProviderParse
+// if the configuration could not be parsed.
+func NewConfigFromProvider(ctx context.Context, p Provider, opts ...ucfg.Option) (*C, error) {
+	source := "provider"
+	if s, ok := p.(fmt.Stringer); ok {
+		source = s.String()
+	}
+
+	in, err := p.Load(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%w from %s: %w", ErrProviderFetch, source, err)
+	}
+
+	options := append([]ucfg.Option{ucfg.MetaData(ucfg.Meta{Source: source})}, configOpts...)
+	c, err := yaml.NewConfig(in, append(options, opts...)...)
+	if err != nil {
+		return nil, fmt.Errorf("%w from %s: %w", ErrProviderParse, source, err)
+	}
+	return fromConfig(c), nil
+}
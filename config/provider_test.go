@@ -0,0 +1,82 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package config
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewConfigFromProvider(t *testing.T) {
+	p := ProviderFunc(func(context.Context) ([]byte, error) {
+		return []byte("output:\n  hosts: [a, b]\n  timeout: 5\n"), nil
+	})
+
+	c, err := NewConfigFromProvider(context.Background(), p)
+	require.NoError(t, err)
+
+	var out struct {
+		Output struct {
+			Hosts   []string `config:"hosts"`
+			Timeout int      `config:"timeout"`
+		} `config:"output"`
+	}
+	require.NoError(t, c.Unpack(&out))
+	assert.Equal(t, []string{"a", "b"}, out.Output.Hosts)
+	assert.Equal(t, 5, out.Output.Timeout)
+}
+
+func TestNewConfigFromProviderErrors(t *testing.T) {
+	t.Run("fetch", func(t *testing.T) {
+		cause := errors.New("connection refused")
+		p := ProviderFunc(func(context.Context) ([]byte, error) { return nil, cause })
+
+		_, err := NewConfigFromProvider(context.Background(), p)
+		assert.ErrorIs(t, err, ErrProviderFetch)
+		assert.ErrorIs(t, err, cause)
+		assert.NotErrorIs(t, err, ErrProviderParse)
+	})
+
+	t.Run("parse", func(t *testing.T) {
+		p := ProviderFunc(func(context.Context) ([]byte, error) { return []byte("a: [b"), nil })
+
+		_, err := NewConfigFromProvider(context.Background(), p)
+		assert.ErrorIs(t, err, ErrProviderParse)
+		assert.NotErrorIs(t, err, ErrProviderFetch)
+	})
+}
+
+func TestFileProvider(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yml")
+	require.NoError(t, os.WriteFile(path, []byte("name: test\n"), 0o600))
+
+	c, err := NewConfigFromProvider(context.Background(), FileProvider(path))
+	require.NoError(t, err)
+	name, err := c.String("name", -1)
+	require.NoError(t, err)
+	assert.Equal(t, "test", name)
+
+	_, err = NewConfigFromProvider(context.Background(), FileProvider(path+".missing"))
+	assert.ErrorIs(t, err, ErrProviderFetch)
+}
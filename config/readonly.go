@@ -0,0 +1,162 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package config
+
+import (
+	"errors"
+
+	ucfg "github.com/elastic/go-ucfg"
+)
+
+// ErrReadOnly is returned when modifying a read-only configuration.
+var ErrReadOnly = errors.New("configuration is read-only")
+
+// ReadOnlyC is a read-only view of a configuration. Reads see the current
+// content of the underlying configuration, all modifications fail with
+// ErrReadOnly. It allows handing out a configuration without cloning it.
+type ReadOnlyC struct {
+	c *C
+}
+
+// ReadOnly returns a read-only view of the configuration.
+func (c *C) ReadOnly() *ReadOnlyC {
+	return &ReadOnlyC{c: c}
+}
+
+// Clone returns a modifiable deep copy of the configuration.
+func (r *ReadOnlyC) Clone() (*C, error) {
+	return MergeConfigs(r.c)
+}
+
+// Unpack unpacks a copy of the configuration into to, so that *C fields of
+// to do not share, and can not modify, the underlying configuration.
+func (r *ReadOnlyC) Unpack(to interface{}) error {
+	clone, err := r.Clone()
+	if err != nil {
+		return err
+	}
+	return clone.Unpack(to)
+}
+
+func (r *ReadOnlyC) Path() string {
+	return r.c.Path()
+}
+
+func (r *ReadOnlyC) PathOf(field string) string {
+	return r.c.PathOf(field)
+}
+
+func (r *ReadOnlyC) Has(name string, idx int) (bool, error) {
+	return r.c.Has(name, idx)
+}
+
+func (r *ReadOnlyC) HasField(name string) bool {
+	return r.c.HasField(name)
+}
+
+func (r *ReadOnlyC) CountField(name string) (int, error) {
+	return r.c.CountField(name)
+}
+
+func (r *ReadOnlyC) Bool(name string, idx int) (bool, error) {
+	return r.c.Bool(name, idx)
+}
+
+func (r *ReadOnlyC) String(name string, idx int) (string, error) {
+	return r.c.String(name, idx)
+}
+
+func (r *ReadOnlyC) Int(name string, idx int) (int64, error) {
+	return r.c.Int(name, idx)
+}
+
+func (r *ReadOnlyC) Float(name string, idx int) (float64, error) {
+	return r.c.Float(name, idx)
+}
+
+// Child returns a read-only view of the sub-configuration.
+func (r *ReadOnlyC) Child(name string, idx int) (*ReadOnlyC, error) {
+	sub, err := r.c.Child(name, idx)
+	if err != nil {
+		return nil, err
+	}
+	return sub.ReadOnly(), nil
+}
+
+func (r *ReadOnlyC) IsDict() bool {
+	return r.c.IsDict()
+}
+
+func (r *ReadOnlyC) IsArray() bool {
+	return r.c.IsArray()
+}
+
+// FlattenedKeys return a sorted flattened views of the set keys in the configuration.
+func (r *ReadOnlyC) FlattenedKeys() []string {
+	return r.c.FlattenedKeys()
+}
+
+// Enabled return the configured enabled value or true by default.
+func (r *ReadOnlyC) Enabled() bool {
+	return r.c.Enabled()
+}
+
+// GetFields returns the list of fields in the configuration.
+func (r *ReadOnlyC) GetFields() []string {
+	return r.c.GetFields()
+}
+
+// Merge always fails with ErrReadOnly.
+func (r *ReadOnlyC) Merge(interface{}) error {
+	return ErrReadOnly
+}
+
+// MergeWithOpts always fails with ErrReadOnly.
+func (r *ReadOnlyC) MergeWithOpts(interface{}, ...ucfg.Option) error {
+	return ErrReadOnly
+}
+
+// Remove always fails with ErrReadOnly.
+func (r *ReadOnlyC) Remove(string, int) (bool, error) {
+	return false, ErrReadOnly
+}
+
+// SetBool always fails with ErrReadOnly.
+func (r *ReadOnlyC) SetBool(string, int, bool) error {
+	return ErrReadOnly
+}
+
+// SetInt always fails with ErrReadOnly.
+func (r *ReadOnlyC) SetInt(string, int, int64) error {
+	return ErrReadOnly
+}
+
+// SetFloat always fails with ErrReadOnly.
+func (r *ReadOnlyC) SetFloat(string, int, float64) error {
+	return ErrReadOnly
+}
+
+// SetString always fails with ErrReadOnly.
+func (r *ReadOnlyC) SetString(string, int, string) error {
+	return ErrReadOnly
+}
+
+// SetChild always fails with ErrReadOnly.
+func (r *ReadOnlyC) SetChild(string, int, *C) error {
+	return ErrReadOnly
+}
@@ -0,0 +1,76 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadOnly(t *testing.T) {
+	original := MustNewConfigFrom("output.hosts: [localhost:9200]\noutput.timeout: 30")
+	ro := original.ReadOnly()
+
+	timeout, err := ro.Int("output.timeout", -1)
+	require.NoError(t, err)
+	assert.Equal(t, int64(30), timeout)
+
+	var settings struct {
+		Output struct {
+			Hosts []string `config:"hosts"`
+		} `config:"output"`
+	}
+	require.NoError(t, ro.Unpack(&settings))
+	assert.Equal(t, []string{"localhost:9200"}, settings.Output.Hosts)
+
+	err = ro.Merge(map[string]interface{}{"output.timeout": 60})
+	assert.ErrorIs(t, err, ErrReadOnly)
+	assert.ErrorIs(t, ro.SetString("output.timeout", -1, "60"), ErrReadOnly)
+
+	output, err := ro.Child("output", -1)
+	require.NoError(t, err)
+	assert.ErrorIs(t, output.SetInt("timeout", -1, 60), ErrReadOnly)
+
+	timeout, err = original.Int("output.timeout", -1)
+	require.NoError(t, err)
+	assert.Equal(t, int64(30), timeout, "the original must not be modified")
+
+	clone, err := ro.Clone()
+	require.NoError(t, err)
+	require.NoError(t, clone.SetInt("output.timeout", -1, 60))
+	timeout, err = ro.Int("output.timeout", -1)
+	require.NoError(t, err)
+	assert.Equal(t, int64(30), timeout)
+}
+
+func TestReadOnlyUnpackSubConfig(t *testing.T) {
+	original := MustNewConfigFrom("output.timeout: 30")
+	ro := original.ReadOnly()
+
+	var settings struct {
+		Output *C `config:"output"`
+	}
+	require.NoError(t, ro.Unpack(&settings))
+	require.NoError(t, settings.Output.SetInt("timeout", -1, 60))
+
+	timeout, err := original.Int("output.timeout", -1)
+	require.NoError(t, err)
+	assert.Equal(t, int64(30), timeout, "the original must not be modified")
+}
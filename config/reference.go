@@ -0,0 +1,210 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package config
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	ucfg "github.com/elastic/go-ucfg"
+	"github.com/elastic/go-ucfg/yaml"
+)
+
+// rawConfigOpts parse a configuration without variable expansion, the
+// references are kept as written.
+var rawConfigOpts = []ucfg.Option{
+	ucfg.PathSep("."),
+}
+
+// NewConfigFromWithReferences creates a new C object from the given input,
+// like NewConfigFrom, resolving the references between settings while
+// loading.
+//
+// References of the form ${name} or ${name:default} in string values are
+// replaced. name is an environment variable or the dotted path of another
+// setting, environment variables take precedence. References in a value are
+// resolved from left to right, a value consisting of a single reference takes
+// the type of the referenced setting. "$${" escapes a reference. Cyclic and
+// unresolvable references are errors.
+func NewConfigFromWithReferences(from interface{}) (*C, error) {
+	var (
+		raw *ucfg.Config
+		err error
+	)
+	if str, ok := from.(string); ok {
+		raw, err = yaml.NewConfig([]byte(str), rawConfigOpts...)
+	} else {
+		raw, err = ucfg.NewFrom(from, rawConfigOpts...)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if raw.IsArray() {
+		return nil, fmt.Errorf("references can only be resolved in dictionaries")
+	}
+
+	var m map[string]interface{}
+	if err := raw.Unpack(&m, rawConfigOpts...); err != nil {
+		return nil, err
+	}
+
+	r := &referenceResolver{
+		root:     m,
+		resolved: map[string]interface{}{},
+		visiting: map[string]bool{},
+	}
+	resolved, err := r.resolveValue("", m)
+	if err != nil {
+		return nil, err
+	}
+	return NewConfigFrom(resolved)
+}
+
+type referenceResolver struct {
+	root     map[string]interface{}
+	resolved map[string]interface{} // resolved settings by path
+	visiting map[string]bool
+	stack    []string // settings being resolved, to report cycles
+}
+
+func (r *referenceResolver) resolveValue(path string, v interface{}) (interface{}, error) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		out := make(map[string]interface{}, len(v))
+		for _, k := range keys {
+			resolved, err := r.resolvePath(joinPath(path, k), v[k])
+			if err != nil {
+				return nil, err
+			}
+			out[k] = resolved
+		}
+		return out, nil
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, item := range v {
+			resolved, err := r.resolveValue(joinPath(path, fmt.Sprint(i)), item)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = resolved
+		}
+		return out, nil
+	case string:
+		return r.resolveString(path, v)
+	default:
+		return v, nil
+	}
+}
+
+// resolvePath resolves the setting at path, whose raw value is v. Resolved
+// settings are cached.
+func (r *referenceResolver) resolvePath(path string, v interface{}) (interface{}, error) {
+	if resolved, ok := r.resolved[path]; ok {
+		return resolved, nil
+	}
+	if r.visiting[path] {
+		cycle := r.stack
+		if i := indexOf(r.stack, path); i >= 0 {
+			cycle = r.stack[i:]
+		}
+		return nil, fmt.Errorf("cyclic reference: %s -> %s", strings.Join(cycle, " -> "), path)
+	}
+
+	r.visiting[path] = true
+	r.stack = append(r.stack, path)
+	resolved, err := r.resolveValue(path, v)
+	r.stack = r.stack[:len(r.stack)-1]
+	delete(r.visiting, path)
+	if err != nil {
+		return nil, err
+	}
+	r.resolved[path] = resolved
+	return resolved, nil
+}
+
+func (r *referenceResolver) resolveString(path, s string) (interface{}, error) {
+	var b strings.Builder
+	rest := s
+	for {
+		i := strings.Index(rest, "${")
+		if i < 0 {
+			b.WriteString(rest)
+			break
+		}
+		if i > 0 && rest[i-1] == '$' {
+			// Escaped, "$${" is kept for the variable expansion of go-ucfg.
+			b.WriteString(rest[:i+2])
+			rest = rest[i+2:]
+			continue
+		}
+		end := strings.IndexByte(rest[i:], '}')
+		if end < 0 {
+			return nil, fmt.Errorf("unterminated reference in '%s': %s", path, s)
+		}
+		end += i
+
+		value, err := r.lookup(path, rest[i+2:end])
+		if err != nil {
+			return nil, err
+		}
+		if i == 0 && end == len(rest)-1 && b.Len() == 0 {
+			// The value is a single reference, keep the type.
+			return value, nil
+		}
+		switch value.(type) {
+		case map[string]interface{}, []interface{}:
+			return nil, fmt.Errorf("reference '%s' in '%s' is not a single value", rest[i+2:end], path)
+		}
+		b.WriteString(rest[:i])
+		fmt.Fprint(&b, value)
+		rest = rest[end+1:]
+	}
+	return b.String(), nil
+}
+
+// lookup resolves the reference ref, found in the setting at path.
+func (r *referenceResolver) lookup(path, ref string) (interface{}, error) {
+	name, def, hasDefault := strings.Cut(ref, ":")
+	if value, ok := os.LookupEnv(name); ok {
+		return value, nil
+	}
+	if raw, ok := lookupPath(r.root, name); ok {
+		return r.resolvePath(name, raw)
+	}
+	if hasDefault {
+		return def, nil
+	}
+	return nil, fmt.Errorf("can not resolve reference '%s' in '%s': no such setting or environment variable", name, path)
+}
+
+func indexOf(s []string, v string) int {
+	for i, e := range s {
+		if e == v {
+			return i
+		}
+	}
+	return -1
+}
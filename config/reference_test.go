@@ -0,0 +1,124 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewConfigFromWithReferences(t *testing.T) {
+	t.Run("simple reference", func(t *testing.T) {
+		resolved, err := NewConfigFromWithReferences(map[string]interface{}{
+			"name":  "agent",
+			"label": "${name}-1",
+			"port":  9200,
+			"copy":  "${port}",
+		})
+		require.NoError(t, err)
+
+		label, err := resolved.String("label", -1)
+		require.NoError(t, err)
+		assert.Equal(t, "agent-1", label)
+
+		port, err := resolved.Int("copy", -1)
+		require.NoError(t, err)
+		assert.EqualValues(t, 9200, port)
+	})
+
+	t.Run("nested path reference", func(t *testing.T) {
+		resolved, err := NewConfigFromWithReferences(map[string]interface{}{
+			"paths": map[string]interface{}{
+				"home": "${paths.root}/agent",
+				"root": "/opt",
+			},
+			"log_path": "${paths.home}/logs",
+			"files":    []interface{}{"${log_path}/agent.log"},
+		})
+		require.NoError(t, err)
+
+		logPath, err := resolved.String("log_path", -1)
+		require.NoError(t, err)
+		assert.Equal(t, "/opt/agent/logs", logPath)
+
+		file, err := resolved.String("files", 0)
+		require.NoError(t, err)
+		assert.Equal(t, "/opt/agent/logs/agent.log", file)
+	})
+
+	t.Run("cyclic reference", func(t *testing.T) {
+		_, err := NewConfigFromWithReferences(map[string]interface{}{
+			"a": "${b}",
+			"b": "x-${c}",
+			"c": "${a}",
+		})
+		require.Error(t, err)
+		assert.Equal(t, "cyclic reference: a -> b -> c -> a", err.Error())
+	})
+
+	t.Run("unresolvable reference", func(t *testing.T) {
+		_, err := NewConfigFromWithReferences(map[string]interface{}{
+			"log_path": "${paths.data}/logs",
+		})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "can not resolve reference 'paths.data' in 'log_path'")
+	})
+
+	t.Run("YAML input", func(t *testing.T) {
+		resolved, err := NewConfigFromWithReferences(`
+paths.home: /opt/agent
+log_path: "${paths.home}/logs"
+escaped: "$${paths.home}"
+`)
+		require.NoError(t, err)
+
+		logPath, err := resolved.String("log_path", -1)
+		require.NoError(t, err)
+		assert.Equal(t, "/opt/agent/logs", logPath)
+
+		escaped, err := resolved.String("escaped", -1)
+		require.NoError(t, err)
+		assert.Equal(t, "${paths.home}", escaped)
+	})
+
+	t.Run("default value", func(t *testing.T) {
+		resolved, err := NewConfigFromWithReferences(map[string]interface{}{
+			"log_path": "${paths.data:/tmp}/logs",
+		})
+		require.NoError(t, err)
+
+		logPath, err := resolved.String("log_path", -1)
+		require.NoError(t, err)
+		assert.Equal(t, "/tmp/logs", logPath)
+	})
+
+	t.Run("environment variables take precedence", func(t *testing.T) {
+		t.Setenv("name", "from-env")
+		resolved, err := NewConfigFromWithReferences(map[string]interface{}{
+			"name":  "from-config",
+			"label": "${name}",
+		})
+		require.NoError(t, err)
+
+		label, err := resolved.String("label", -1)
+		require.NoError(t, err)
+		assert.Equal(t, "from-env", label)
+	})
+}
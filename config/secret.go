@@ -0,0 +1,89 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package config
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// SecretRefPrefix starts the string values that reference a secret. A
+// reference has the form secret://<resolver>/<token>, e.g.
+// secret://vault/kibana/token.
+const SecretRefPrefix = "secret://"
+
+// SecretResolver returns the current value of the secret identified by token.
+type SecretResolver func(token string) (string, error)
+
+var secretResolvers = struct {
+	sync.RWMutex
+	m map[string]SecretResolver
+}{m: map[string]SecretResolver{}}
+
+// RegisterSecretResolver registers the resolver for the secret references
+// naming it. A resolver registered with the same name is replaced. A nil
+// resolver removes the registration.
+func RegisterSecretResolver(name string, resolver SecretResolver) {
+	secretResolvers.Lock()
+	defer secretResolvers.Unlock()
+	if resolver == nil {
+		delete(secretResolvers.m, name)
+		return
+	}
+	secretResolvers.m[name] = resolver
+}
+
+// ResolveString works like String, but if the value is a secret reference it
+// returns the value of the secret. The secret is resolved on every call and
+// never stored in the configuration, so successive calls observe rotated
+// secrets. Other accessors, like String and Unpack, return the reference.
+func (c *C) ResolveString(name string, idx int) (string, error) {
+	value, err := c.String(name, idx)
+	if err != nil {
+		return "", err
+	}
+	return ResolveSecret(value)
+}
+
+// ResolveSecret returns the value of the secret referenced by value, or value
+// itself if it is not a secret reference.
+func ResolveSecret(value string) (string, error) {
+	ref, ok := strings.CutPrefix(value, SecretRefPrefix)
+	if !ok {
+		return value, nil
+	}
+
+	name, token, ok := strings.Cut(ref, "/")
+	if !ok || name == "" || token == "" {
+		return "", fmt.Errorf("invalid secret reference '%s', expected %s<resolver>/<token>", value, SecretRefPrefix)
+	}
+
+	secretResolvers.RLock()
+	resolver, ok := secretResolvers.m[name]
+	secretResolvers.RUnlock()
+	if !ok {
+		return "", fmt.Errorf("no resolver registered for secret reference '%s'", value)
+	}
+
+	secret, err := resolver(token)
+	if err != nil {
+		return "", fmt.Errorf("resolving secret reference '%s': %w", value, err)
+	}
+	return secret, nil
+}
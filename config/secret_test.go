@@ -0,0 +1,70 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package config
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveString(t *testing.T) {
+	var calls int
+	RegisterSecretResolver("test", func(token string) (string, error) {
+		if token != "kibana/token" {
+			return "", errors.New("unknown secret")
+		}
+		calls++
+		return fmt.Sprintf("token-%d", calls), nil
+	})
+	t.Cleanup(func() { RegisterSecretResolver("test", nil) })
+
+	cfg := MustNewConfigFrom(map[string]interface{}{
+		"api_key": "secret://test/kibana/token",
+		"host":    "localhost:5601",
+		"missing": "secret://test/other",
+		"unknown": "secret://vault/kibana/token",
+		"invalid": "secret://test",
+	})
+
+	for i := 1; i <= 3; i++ {
+		value, err := cfg.ResolveString("api_key", -1)
+		require.NoError(t, err)
+		assert.Equal(t, fmt.Sprintf("token-%d", i), value)
+	}
+
+	raw, err := cfg.String("api_key", -1)
+	require.NoError(t, err)
+	assert.Equal(t, "secret://test/kibana/token", raw)
+
+	host, err := cfg.ResolveString("host", -1)
+	require.NoError(t, err)
+	assert.Equal(t, "localhost:5601", host)
+
+	_, err = cfg.ResolveString("missing", -1)
+	assert.ErrorContains(t, err, "resolving secret reference 'secret://test/other': unknown secret")
+
+	_, err = cfg.ResolveString("unknown", -1)
+	assert.ErrorContains(t, err, "no resolver registered")
+
+	_, err = cfg.ResolveString("invalid", -1)
+	assert.ErrorContains(t, err, "invalid secret reference")
+}
@@ -0,0 +1,73 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package config
+
+import (
+	"errors"
+	"fmt"
+)
+
+// TransformHook is run after a configuration has been unpacked and receives
+// the populated value. Unlike validation, hooks may modify the value, e.g. to
+// fill defaults computed from other settings or to normalize values.
+type TransformHook func(to interface{}) error
+
+// Transform returns a TransformHook for values of type *T. The hook fails if it
+// is applied to a value of another type.
+func Transform[T any](fn func(*T) error) TransformHook {
+	return func(to interface{}) error {
+		v, ok := to.(*T)
+		if !ok {
+			return fmt.Errorf("transform for %T applied to %T", (*T)(nil), to)
+		}
+		return fn(v)
+	}
+}
+
+// Transforms is an ordered list of TransformHooks. The zero value is ready to
+// use.
+type Transforms struct {
+	hooks []TransformHook
+}
+
+// Register appends hooks to the list, they run in registration order.
+func (t *Transforms) Register(hooks ...TransformHook) {
+	t.hooks = append(t.hooks, hooks...)
+}
+
+// Apply runs all hooks on to in registration order. Every hook runs, even if
+// a previous one failed, the errors are joined.
+func (t *Transforms) Apply(to interface{}) error {
+	var errs []error
+	for i, hook := range t.hooks {
+		if err := hook(to); err != nil {
+			errs = append(errs, fmt.Errorf("transform %d: %w", i, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// UnpackWithTransforms unpacks the configuration into to like Unpack and then
+// applies the hooks in order. The hooks do not run if unpacking fails.
+func (c *C) UnpackWithTransforms(to interface{}, hooks ...TransformHook) error {
+	if err := c.Unpack(to); err != nil {
+		return err
+	}
+	t := Transforms{hooks: hooks}
+	return t.Apply(to)
+}
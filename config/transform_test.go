@@ -0,0 +1,106 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package config
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type transformTestConfig struct {
+	Host string `config:"host"`
+	Name string `config:"name"`
+}
+
+func TestUnpackWithTransforms(t *testing.T) {
+	var order []string
+	defaultName := Transform(func(c *transformTestConfig) error {
+		order = append(order, "name")
+		if c.Name == "" {
+			c.Name = "output-" + c.Host
+		}
+		return nil
+	})
+	normalize := Transform(func(c *transformTestConfig) error {
+		order = append(order, "normalize")
+		c.Name = strings.ToLower(c.Name)
+		return nil
+	})
+
+	t.Run("derived field", func(t *testing.T) {
+		order = nil
+		cfg := MustNewConfigFrom(map[string]interface{}{"host": "LOCALHOST"})
+
+		var c transformTestConfig
+		require.NoError(t, cfg.UnpackWithTransforms(&c, defaultName, normalize))
+		assert.Equal(t, "LOCALHOST", c.Host)
+		assert.Equal(t, "output-localhost", c.Name)
+		assert.Equal(t, []string{"name", "normalize"}, order)
+	})
+
+	t.Run("explicit value", func(t *testing.T) {
+		cfg := MustNewConfigFrom(map[string]interface{}{"host": "localhost", "name": "Main"})
+
+		var c transformTestConfig
+		require.NoError(t, cfg.UnpackWithTransforms(&c, defaultName, normalize))
+		assert.Equal(t, "main", c.Name)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		order = nil
+		errNoHost := errors.New("host is required")
+		requireHost := Transform(func(c *transformTestConfig) error {
+			order = append(order, "host")
+			if c.Host == "" {
+				return errNoHost
+			}
+			return nil
+		})
+		wrongType := Transform(func(*Alias) error { return nil })
+
+		var transforms Transforms
+		transforms.Register(requireHost, wrongType)
+		transforms.Register(defaultName)
+
+		var c transformTestConfig
+		require.NoError(t, NewConfig().Unpack(&c))
+		err := transforms.Apply(&c)
+		require.Error(t, err)
+		assert.ErrorIs(t, err, errNoHost)
+		assert.ErrorContains(t, err, "transform 0: host is required")
+		assert.ErrorContains(t, err, "transform 1: transform for *config.Alias applied to *config.transformTestConfig")
+		assert.Equal(t, []string{"host", "name"}, order, "all hooks run in registration order")
+	})
+
+	t.Run("unpack error", func(t *testing.T) {
+		called := false
+		hook := func(interface{}) error {
+			called = true
+			return nil
+		}
+		cfg := MustNewConfigFrom(map[string]interface{}{"host": map[string]interface{}{"a": 1}})
+
+		var c transformTestConfig
+		require.Error(t, cfg.UnpackWithTransforms(&c, hook))
+		assert.False(t, called)
+	})
+}
@@ -0,0 +1,122 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// YAMLOption configures how ToYAML writes a configuration.
+type YAMLOption func(*yamlOptions)
+
+type yamlOptions struct {
+	redactPrivate bool
+}
+
+// YAMLRedactPrivate masks the values of the settings that DebugString masks
+// when filtering private data (password, hosts, etc.).
+func YAMLRedactPrivate() YAMLOption {
+	return func(o *yamlOptions) {
+		o.redactPrivate = true
+	}
+}
+
+// ToYAML writes the configuration as canonical YAML: keys are sorted and
+// scalars are formatted independent of how the configuration was created.
+// Environment variables are not resolved, references like ${SECRET} are
+// written as is, so they are neither exported nor required to be set.
+// Parsing the output with NewConfigWithYAML yields an equal configuration,
+// unless private settings are redacted.
+func (c *C) ToYAML(opts ...YAMLOption) ([]byte, error) {
+	var o yamlOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	content, err := unpackGeneric(c, unresolvedOpts)
+	if err != nil {
+		return nil, fmt.Errorf("unpacking config for YAML export: %w", err)
+	}
+	if o.redactPrivate {
+		ApplyLoggingMask(content)
+	}
+
+	// yaml.v3 writes map keys in sorted order.
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(wrapFloats(content)); err != nil {
+		return nil, fmt.Errorf("encoding config as YAML: %w", err)
+	}
+	if err := enc.Close(); err != nil {
+		return nil, fmt.Errorf("encoding config as YAML: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// yamlFloat is written with a decimal point or exponent, so that integral
+// values like 1.0 are parsed back as floats and not as integers.
+type yamlFloat float64
+
+func (f yamlFloat) MarshalYAML() (interface{}, error) {
+	v := float64(f)
+	var s string
+	switch {
+	case math.IsInf(v, 1):
+		s = ".inf"
+	case math.IsInf(v, -1):
+		s = "-.inf"
+	case math.IsNaN(v):
+		s = ".nan"
+	default:
+		s = strconv.FormatFloat(v, 'g', -1, 64)
+		if !strings.ContainsAny(s, ".e") {
+			s += ".0"
+		}
+	}
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!float", Value: s}, nil
+}
+
+// wrapFloats replaces the floats in v by yamlFloat. Maps and slices are
+// modified in place.
+func wrapFloats(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, e := range v {
+			v[k] = wrapFloats(e)
+		}
+		return v
+	case []interface{}:
+		for i, e := range v {
+			v[i] = wrapFloats(e)
+		}
+		return v
+	case float32:
+		return yamlFloat(v)
+	case float64:
+		return yamlFloat(v)
+	default:
+		return v
+	}
+}
@@ -0,0 +1,120 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func yamlTestConfig(t *testing.T) *C {
+	t.Helper()
+	c := NewConfig()
+	require.NoError(t, c.SetString("output.elasticsearch.hosts", 0, "https://es1:9200"))
+	require.NoError(t, c.SetString("output.elasticsearch.hosts", 1, "https://es2:9200"))
+	require.NoError(t, c.SetString("output.elasticsearch.password", -1, "changeme"))
+	require.NoError(t, c.SetInt("output.elasticsearch.bulk_max_size", -1, 50))
+	require.NoError(t, c.SetBool("logging.to_stderr", -1, true))
+	require.NoError(t, c.SetString("agent.name", -1, "agent-1"))
+	require.NoError(t, c.SetFloat("agent.sample_rate", -1, 0.25))
+	require.NoError(t, c.SetString("agent.version", -1, "8.12"))
+	return c
+}
+
+func TestToYAMLRoundTrip(t *testing.T) {
+	c := yamlTestConfig(t)
+
+	out, err := c.ToYAML()
+	require.NoError(t, err)
+
+	parsed, err := NewConfigWithYAML(out, "test")
+	require.NoError(t, err)
+
+	want, err := toGeneric(c)
+	require.NoError(t, err)
+	got, err := toGeneric(parsed)
+	require.NoError(t, err)
+	assert.Equal(t, want, got)
+
+	again, err := parsed.ToYAML()
+	require.NoError(t, err)
+	assert.Equal(t, string(out), string(again), "output must be stable")
+}
+
+func TestToYAMLGolden(t *testing.T) {
+	tests := map[string][]YAMLOption{
+		"canonical.yml": nil,
+		"redacted.yml":  {YAMLRedactPrivate()},
+	}
+
+	for name, opts := range tests {
+		t.Run(name, func(t *testing.T) {
+			out, err := yamlTestConfig(t).ToYAML(opts...)
+			require.NoError(t, err)
+
+			golden := filepath.Join("testdata", name)
+			want, err := os.ReadFile(golden)
+			require.NoError(t, err)
+			assert.Equal(t, string(want), string(out))
+		})
+	}
+}
+
+func TestToYAMLReferences(t *testing.T) {
+	t.Setenv("YAML_TEST_SECRET", "s3cr3t")
+
+	c, err := NewConfigWithYAML([]byte(`
+output.elasticsearch.password: ${YAML_TEST_SECRET}
+output.elasticsearch.api_key: ${YAML_TEST_UNSET}
+`), "test")
+	require.NoError(t, err)
+
+	out, err := c.ToYAML()
+	require.NoError(t, err)
+	assert.NotContains(t, string(out), "s3cr3t")
+	assert.Contains(t, string(out), "password: ${YAML_TEST_SECRET}")
+	assert.Contains(t, string(out), "api_key: ${YAML_TEST_UNSET}")
+
+	parsed, err := NewConfigWithYAML(out, "test")
+	require.NoError(t, err)
+	password, err := parsed.String("output.elasticsearch.password", -1)
+	require.NoError(t, err)
+	assert.Equal(t, "s3cr3t", password)
+}
+
+func TestToYAMLFloats(t *testing.T) {
+	c := NewConfig()
+	require.NoError(t, c.SetFloat("ratio", -1, 1.0))
+	require.NoError(t, c.SetFloat("big", -1, 1e21))
+	require.NoError(t, c.SetInt("count", -1, 1))
+
+	out, err := c.ToYAML()
+	require.NoError(t, err)
+	assert.Equal(t, "big: 1e+21\ncount: 1\nratio: 1.0\n", string(out))
+
+	parsed, err := NewConfigWithYAML(out, "test")
+	require.NoError(t, err)
+	var got map[string]interface{}
+	require.NoError(t, parsed.Unpack(&got))
+	assert.IsType(t, float64(0), got["ratio"])
+	assert.IsType(t, float64(0), got["big"])
+}
@@ -0,0 +1,165 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package file
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Glob walks root and returns the paths of the files matching any of the
+// include patterns and none of the exclude patterns. All files are included
+// if includes is empty. The returned paths are joined with root and sorted.
+//
+// Patterns are matched against the slash separated path relative to root.
+// They use the syntax of path.Match for every path segment, and a `**`
+// segment matches zero or more directories. For example `**/*.yml` matches
+// YAML files at any depth and `inputs.d/*.yml` only those in inputs.d.
+// Directories matching an exclude pattern are not walked.
+//
+// Symlinks to directories are followed. A symlink pointing to one of its
+// parent directories is skipped to prevent loops.
+func Glob(root string, includes, excludes []string) ([]string, error) {
+	inc, err := compileGlobs(includes)
+	if err != nil {
+		return nil, err
+	}
+	exc, err := compileGlobs(excludes)
+	if err != nil {
+		return nil, err
+	}
+
+	g := globber{
+		includes: inc,
+		excludes: exc,
+		parents:  map[string]struct{}{},
+	}
+	if err := g.walk(root, ""); err != nil {
+		return nil, err
+	}
+
+	sort.Strings(g.matches)
+	return g.matches, nil
+}
+
+type globber struct {
+	includes [][]string
+	excludes [][]string
+	// parents holds the resolved paths of the directories being walked.
+	parents map[string]struct{}
+	matches []string
+}
+
+func (g *globber) walk(dir, rel string) error {
+	real, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return err
+	}
+	if _, loop := g.parents[real]; loop {
+		return nil
+	}
+	g.parents[real] = struct{}{}
+	defer delete(g.parents, real)
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	for _, e := range entries {
+		name := filepath.Join(dir, e.Name())
+		relName := path.Join(rel, e.Name())
+
+		isDir := e.IsDir()
+		if e.Type()&fs.ModeSymlink != 0 {
+			info, err := os.Stat(name)
+			if err != nil {
+				// Ignore dangling symlinks.
+				continue
+			}
+			isDir = info.IsDir()
+		}
+
+		if isDir {
+			if matchAny(g.excludes, relName) {
+				continue
+			}
+			if err := g.walk(name, relName); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if (len(g.includes) == 0 || matchAny(g.includes, relName)) && !matchAny(g.excludes, relName) {
+			g.matches = append(g.matches, name)
+		}
+	}
+	return nil
+}
+
+// compileGlobs splits the patterns into segments and validates them.
+func compileGlobs(patterns []string) ([][]string, error) {
+	compiled := make([][]string, 0, len(patterns))
+	for _, p := range patterns {
+		segments := strings.Split(path.Clean(filepath.ToSlash(p)), "/")
+		for _, s := range segments {
+			if _, err := path.Match(s, ""); err != nil {
+				return nil, fmt.Errorf("invalid pattern '%s': %w", p, err)
+			}
+		}
+		compiled = append(compiled, segments)
+	}
+	return compiled, nil
+}
+
+func matchAny(patterns [][]string, name string) bool {
+	segments := strings.Split(name, "/")
+	for _, p := range patterns {
+		if matchSegments(p, segments) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		// The pattern has been validated by compileGlobs.
+		if ok, _ := path.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
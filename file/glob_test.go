@@ -0,0 +1,119 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package file
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGlob(t *testing.T) {
+	root := t.TempDir()
+	for _, name := range []string{
+		"agent.yml",
+		"agent.yml.bak",
+		"inputs.d/system.yml",
+		"inputs.d/nginx.yml",
+		"inputs.d/disabled/old.yml",
+		"logs/agent.log",
+		"logs/agent.log.1",
+		"logs/archive/2023/agent.log.9",
+	} {
+		p := filepath.Join(root, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(p), 0o755))
+		require.NoError(t, os.WriteFile(p, nil, 0o600))
+	}
+
+	abs := func(names ...string) []string {
+		out := make([]string, len(names))
+		for i, n := range names {
+			out[i] = filepath.Join(root, filepath.FromSlash(n))
+		}
+		return out
+	}
+
+	tests := map[string]struct {
+		includes []string
+		excludes []string
+		want     []string
+	}{
+		"top level only": {
+			includes: []string{"*.yml"},
+			want:     abs("agent.yml"),
+		},
+		"recursive": {
+			includes: []string{"**/*.yml"},
+			want:     abs("agent.yml", "inputs.d/disabled/old.yml", "inputs.d/nginx.yml", "inputs.d/system.yml"),
+		},
+		"recursive in directory": {
+			includes: []string{"logs/**/agent.log*"},
+			want:     abs("logs/agent.log", "logs/agent.log.1", "logs/archive/2023/agent.log.9"),
+		},
+		"exclude file": {
+			includes: []string{"inputs.d/*.yml"},
+			excludes: []string{"**/nginx.yml"},
+			want:     abs("inputs.d/system.yml"),
+		},
+		"exclude directory": {
+			includes: []string{"**/*.yml"},
+			excludes: []string{"inputs.d/disabled"},
+			want:     abs("agent.yml", "inputs.d/nginx.yml", "inputs.d/system.yml"),
+		},
+		"multiple includes": {
+			includes: []string{"*.bak", "logs/*.log"},
+			want:     abs("agent.yml.bak", "logs/agent.log"),
+		},
+		"no includes matches all": {
+			excludes: []string{"inputs.d/**", "logs/**"},
+			want:     abs("agent.yml", "agent.yml.bak"),
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := Glob(root, tc.includes, tc.excludes)
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestGlobSymlinkLoop(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("creating symlinks requires extra privileges on Windows")
+	}
+
+	root := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "a", "b"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "a", "b", "file.yml"), nil, 0o600))
+	require.NoError(t, os.Symlink(filepath.Join(root, "a"), filepath.Join(root, "a", "b", "loop")))
+
+	got, err := Glob(root, []string{"**/*.yml"}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(root, "a", "b", "file.yml")}, got)
+}
+
+func TestGlobInvalidPattern(t *testing.T) {
+	_, err := Glob(t.TempDir(), []string{"[a-"}, nil)
+	assert.Error(t, err)
+}
@@ -21,11 +21,9 @@ import (
 	"bytes"
 	"context"
 	"encoding/base64"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
-	"io/ioutil"
 	"mime"
 	"mime/multipart"
 	"net/http"
@@ -33,6 +31,8 @@ import (
 	"net/url"
 	"path"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/elastic/elastic-agent-libs/config"
 	"github.com/elastic/elastic-agent-libs/logp"
@@ -50,14 +50,65 @@ type Connection struct {
 	APIKey       string
 	ServiceToken string
 	Headers      http.Header
+	// APIVersion is sent as the Elastic-Api-Version header unless empty or
+	// already set in the request headers.
+	APIVersion string
 
 	HTTP    *http.Client
 	Version version.V
+
+	jsonCodec      JSONCodec
+	warningHandler WarningHandler
+	hosts          *hostPool // set if multiple hosts are configured
+	retry          *RetryConfig
+	rateLimit      *RateLimitConfig
+	versionProbe   *versionProbe // set if the version is read on first use
+	signer         Signer
+	interceptors   []Interceptor
+	tracer         Tracer
+
+	// transport is the base transport of HTTP, if created by the client.
+	transport               *http.Transport
+	expectContinueThreshold int64
 }
 
 type Client struct {
 	Connection
 	log *logp.Logger
+
+	etags *etagCache
+
+	checkMinVersion bool
+
+	versionPath  string
+	versionKnown bool // the version was set with WithServerVersion
+	lazyVersion  bool
+
+	warmupMu sync.Mutex
+	warm     bool
+
+	actionPollInterval time.Duration
+}
+
+// ClientOption configures optional behaviour of a Client.
+type ClientOption func(*Client)
+
+// WithAPIVersion overrides the Elastic-Api-Version sent with every request. An
+// empty version omits the header.
+func WithAPIVersion(version string) ClientOption {
+	return func(client *Client) {
+		client.Connection.APIVersion = version
+	}
+}
+
+// WithLogger sets the logger of the client. By default the client logs with
+// the "kibana" selector.
+func WithLogger(log *logp.Logger) ClientOption {
+	return func(client *Client) {
+		if log != nil {
+			client.log = log
+		}
+	}
 }
 
 func addToURL(_url, _path string, params url.Values) string {
@@ -68,7 +119,7 @@ func addToURL(_url, _path string, params url.Values) string {
 	return strings.Join([]string{_url, _path, "?", params.Encode()}, "")
 }
 
-func extractError(result []byte) error {
+func extractError(codec JSONCodec, result []byte) error {
 	var kibanaResult struct {
 		Message    string
 		Attributes struct {
@@ -80,7 +131,7 @@ func extractError(result []byte) error {
 			}
 		}
 	}
-	if err := json.Unmarshal(result, &kibanaResult); err != nil {
+	if err := codec.Unmarshal(result, &kibanaResult); err != nil {
 		return fmt.Errorf("error extracting JSON for error response: %w", err)
 	}
 	var errs []error
@@ -97,7 +148,7 @@ func extractError(result []byte) error {
 	return nil
 }
 
-func extractMessage(result []byte) error {
+func extractMessage(codec JSONCodec, result []byte) error {
 	var kibanaResult struct {
 		Success bool
 		Errors  []struct {
@@ -112,7 +163,7 @@ func extractMessage(result []byte) error {
 			}
 		}
 	}
-	if err := json.Unmarshal(result, &kibanaResult); err != nil {
+	if err := codec.Unmarshal(result, &kibanaResult); err != nil {
 		return nil //nolint:nilerr // we suppress some malformed errors on purpose
 	}
 
@@ -128,58 +179,111 @@ func extractMessage(result []byte) error {
 }
 
 // NewKibanaClient builds and returns a new Kibana client
-func NewKibanaClient(cfg *config.C, binaryName, version, commit, buildtime string) (*Client, error) {
+func NewKibanaClient(cfg *config.C, binaryName, version, commit, buildtime string, opts ...ClientOption) (*Client, error) {
 	config := DefaultClientConfig()
 	if err := cfg.Unpack(&config); err != nil {
 		return nil, err
 	}
 
-	return NewClientWithConfig(&config, binaryName, version, commit, buildtime)
+	return NewClientWithConfig(&config, binaryName, version, commit, buildtime, opts...)
 }
 
-// NewClientWithConfig creates and returns a kibana client using the given config
-func NewClientWithConfig(config *ClientConfig, binaryName, version, commit, buildtime string) (*Client, error) {
-	return NewClientWithConfigDefault(config, 5601, binaryName, version, commit, buildtime)
+// NewKibanaClientWithBuildInfo builds and returns a new Kibana client, using
+// the build information for the User-Agent header.
+func NewKibanaClientWithBuildInfo(cfg *config.C, binaryName string, info version.BuildInfo, opts ...ClientOption) (*Client, error) {
+	return NewKibanaClient(cfg, binaryName, info.FullVersion(), info.Commit, info.BuildTime, opts...)
+}
+
+// NewClientWithConfig creates and returns a kibana client using the given config.
+// Secret references in the username, password, api_key and service_token
+// settings, see config.ResolveSecret, are resolved once when the client is
+// created.
+func NewClientWithConfig(config *ClientConfig, binaryName, version, commit, buildtime string, opts ...ClientOption) (*Client, error) {
+	return NewClientWithConfigDefault(config, 5601, binaryName, version, commit, buildtime, opts...)
+}
+
+// clientCredentials holds the credentials of a ClientConfig, with secret
+// references resolved.
+type clientCredentials struct {
+	username, password, apiKey, serviceToken string
+}
+
+func resolveCredentials(cfg *ClientConfig) (clientCredentials, error) {
+	var creds clientCredentials
+	for _, setting := range []struct {
+		name  string
+		value string
+		out   *string
+	}{
+		{"username", cfg.Username, &creds.username},
+		{"password", cfg.Password, &creds.password},
+		{"api_key", cfg.APIKey, &creds.apiKey},
+		{"service_token", cfg.ServiceToken, &creds.serviceToken},
+	} {
+		value, err := config.ResolveSecret(setting.value)
+		if err != nil {
+			return creds, fmt.Errorf("resolving the Kibana %s: %w", setting.name, err)
+		}
+		*setting.out = value
+	}
+	return creds, nil
 }
 
 // NewClientWithConfigDefault creates and returns a kibana client using the given config
-func NewClientWithConfigDefault(config *ClientConfig, defaultPort int, binaryName, version, commit, buildtime string) (*Client, error) {
+func NewClientWithConfigDefault(config *ClientConfig, defaultPort int, binaryName, version, commit, buildtime string, opts ...ClientOption) (*Client, error) {
 	if err := config.Validate(); err != nil {
 		return nil, err
 	}
 
 	p := config.Path
-	if config.SpaceID != "" {
+	if config.SpaceID != "" && config.SpaceID != DefaultSpaceID {
 		p = path.Join(p, "s", config.SpaceID)
 	}
-	kibanaURL, err := MakeURL(config.Protocol, p, config.Host, defaultPort)
+
+	creds, err := resolveCredentials(config)
 	if err != nil {
-		return nil, fmt.Errorf("invalid Kibana host: %w", err)
+		return nil, err
 	}
+	username := creds.username
+	password := creds.password
 
-	u, err := url.Parse(kibanaURL)
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse the Kibana URL: %w", err)
+	hosts := config.Hosts
+	if len(hosts) == 0 {
+		hosts = []string{config.Host}
 	}
+	kibanaURLs := make([]string, 0, len(hosts))
+	for _, host := range hosts {
+		kibanaURL, err := MakeURL(config.Protocol, p, host, defaultPort)
+		if err != nil {
+			return nil, fmt.Errorf("invalid Kibana host: %w", err)
+		}
 
-	username := config.Username
-	password := config.Password
+		u, err := url.Parse(kibanaURL)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse the Kibana URL: %w", err)
+		}
 
-	if u.User != nil {
-		username = u.User.Username()
-		password, _ = u.User.Password()
-		u.User = nil
+		if u.User != nil {
+			username = u.User.Username()
+			password, _ = u.User.Password()
+			u.User = nil
 
-		if config.APIKey != "" && (username != "" || password != "") {
-			return nil, fmt.Errorf("cannot set api_key with username/password in Kibana URL")
-		}
+			if config.APIKey != "" && (username != "" || password != "") {
+				return nil, fmt.Errorf("cannot set api_key with username/password in Kibana URL")
+			}
+			if config.ServiceToken != "" && (username != "" || password != "") {
+				return nil, fmt.Errorf("cannot set service_token with username/password in Kibana URL")
+			}
 
-		// Re-write URL without credentials.
-		kibanaURL = u.String()
+			// Re-write URL without credentials.
+			kibanaURL = u.String()
+		}
+		kibanaURLs = append(kibanaURLs, kibanaURL)
 	}
+	kibanaURL := kibanaURLs[0]
 
 	log := logp.NewLogger("kibana")
-	log.Infof("Kibana url: %s", kibanaURL)
+	log.Infof("Kibana url: %s", strings.Join(kibanaURLs, ", "))
 
 	headers := make(http.Header)
 	for k, v := range config.Headers {
@@ -190,7 +294,11 @@ func NewClientWithConfigDefault(config *ClientConfig, defaultPort int, binaryNam
 		binaryName = "Libbeat"
 	}
 	userAgent := useragent.UserAgent(binaryName, version, commit, buildtime)
-	rt, err := config.Transport.Client(httpcommon.WithHeaderRoundTripper(map[string]string{"User-Agent": userAgent}))
+	var transport *http.Transport
+	rt, err := config.Transport.Client(
+		httpcommon.WithHeaderRoundTripper(map[string]string{"User-Agent": userAgent}),
+		httpcommon.WithTransportFunc(func(t *http.Transport) { transport = t }),
+	)
 	if err != nil {
 		return nil, err
 	}
@@ -200,16 +308,30 @@ func NewClientWithConfigDefault(config *ClientConfig, defaultPort int, binaryNam
 			URL:          kibanaURL,
 			Username:     username,
 			Password:     password,
-			APIKey:       config.APIKey,
-			ServiceToken: config.ServiceToken,
+			APIKey:       creds.apiKey,
+			ServiceToken: creds.serviceToken,
 			Headers:      headers,
+			APIVersion:   config.APIVersion,
 			HTTP:         rt,
+			transport:    transport,
 		},
-		log: log,
+		log:         log,
+		versionPath: statusAPI,
+	}
+	if len(kibanaURLs) > 1 {
+		client.Connection.hosts = newHostPool(kibanaURLs)
+	}
+	WithRateLimit(config.RateLimit)(client)
+	for _, opt := range opts {
+		opt(client)
 	}
 
-	if !config.IgnoreVersion {
-		if err = client.readVersion(); err != nil {
+	switch {
+	case client.versionKnown, config.IgnoreVersion:
+	case client.lazyVersion:
+		client.Connection.versionProbe = &versionProbe{probe: client.readVersion}
+	default:
+		if err = client.readVersion(context.Background()); err != nil {
 			return nil, fmt.Errorf("fail to get the Kibana version: %w", err)
 		}
 	}
@@ -217,25 +339,45 @@ func NewClientWithConfigDefault(config *ClientConfig, defaultPort int, binaryNam
 	return client, nil
 }
 
+// Request sends a request to Kibana and returns the status code and the body
+// of the response.
 func (conn *Connection) Request(method, extraPath string,
 	params url.Values, headers http.Header, body io.Reader) (int, []byte, error) {
 
-	resp, err := conn.Send(method, extraPath, params, headers, body)
+	return conn.RequestWithContext(context.Background(), method, extraPath, params, headers, body)
+}
+
+// RequestWithContext sends a request to Kibana with the given context and
+// returns the status code and the body of the response.
+func (conn *Connection) RequestWithContext(ctx context.Context, method, extraPath string,
+	params url.Values, headers http.Header, body io.Reader) (int, []byte, error) {
+
+	resp, err := conn.SendWithContext(ctx, method, extraPath, params, headers, body)
 	if err != nil {
 		return 0, nil, fmt.Errorf("fail to execute the HTTP %s request: %w", method, err)
 	}
+	return conn.readResponse(resp)
+}
+
+// readResponse reads and closes the body of resp. Kibana errors in the body
+// are returned as *APIError.
+func (conn *Connection) readResponse(resp *http.Response) (int, []byte, error) {
 	defer resp.Body.Close()
 
-	result, err := ioutil.ReadAll(resp.Body)
+	if err := checkAuthChallenge(resp); err != nil {
+		return resp.StatusCode, nil, err
+	}
+
+	result, err := readBody(resp)
 	if err != nil {
 		return 0, nil, fmt.Errorf("fail to read response: %w", err)
 	}
 
 	var retError error
 	if resp.StatusCode >= 300 {
-		retError = extractError(result)
+		retError = newAPIError(conn.codec(), resp.StatusCode, result)
 	} else {
-		retError = extractMessage(result)
+		retError = extractMessage(conn.codec(), result)
 	}
 	return resp.StatusCode, result, retError
 }
@@ -248,10 +390,84 @@ func (conn *Connection) Send(method, extraPath string,
 }
 
 // SendWithContext sends an application/json request to Kibana with appropriate kbn headers and the given context.
+// If multiple hosts are configured the request fails over to the next host on
+// connection errors, and on 5xx responses if the method does not change state,
+// like GET. If retries are enabled, transient
+// failures are retried and a *RetryError is returned once they are exhausted.
+// If the client reads the Kibana version lazily, the version is read before the
+// first request. If a Tracer is set, the call is traced.
 func (conn *Connection) SendWithContext(ctx context.Context, method, extraPath string,
 	params url.Values, headers http.Header, body io.Reader) (*http.Response, error) {
 
-	reqURL := addToURL(conn.URL, extraPath, params)
+	if err := conn.versionProbe.run(ctx); err != nil {
+		return nil, err
+	}
+
+	ctx, end := conn.startSpan(ctx, method, extraPath)
+	resp, err := conn.sendRequest(ctx, method, extraPath, params, headers, body)
+	end(resp, err)
+	return resp, err
+}
+
+// sendRequest sends the request, retrying it if enabled.
+func (conn *Connection) sendRequest(ctx context.Context, method, extraPath string,
+	params url.Values, headers http.Header, body io.Reader) (*http.Response, error) {
+
+	if conn.retry == nil && conn.rateLimit == nil && conn.hosts == nil {
+		return conn.sendTo(ctx, conn.URL, method, extraPath, params, headers, body)
+	}
+
+	// The request may be sent multiple times, the body is buffered once for
+	// all the retry, rate limit and failover attempts.
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = io.ReadAll(body); err != nil {
+			return nil, fmt.Errorf("fail to read the request body: %w", err)
+		}
+	}
+
+	if conn.retry != nil {
+		return conn.sendWithRetry(ctx, method, extraPath, params, headers, payload)
+	}
+	return conn.send(ctx, method, extraPath, params, headers, payload)
+}
+
+// send sends the request, waiting for and retrying rate limited requests if
+// enabled.
+func (conn *Connection) send(ctx context.Context, method, extraPath string,
+	params url.Values, headers http.Header, payload []byte) (*http.Response, error) {
+
+	if conn.rateLimit != nil {
+		return conn.sendWithRateLimit(ctx, method, extraPath, params, headers, payload)
+	}
+	return conn.sendOnce(ctx, method, extraPath, params, headers, payload)
+}
+
+// sendOnce sends the request once, failing over between hosts if configured.
+func (conn *Connection) sendOnce(ctx context.Context, method, extraPath string,
+	params url.Values, headers http.Header, payload []byte) (*http.Response, error) {
+
+	if conn.hosts != nil {
+		return conn.sendWithFailover(ctx, method, extraPath, params, headers, payload)
+	}
+	return conn.sendTo(ctx, conn.URL, method, extraPath, params, headers, payloadReader(payload))
+}
+
+// payloadReader returns a reader of the buffered request body, or nil if the
+// request has no body.
+func payloadReader(payload []byte) io.Reader {
+	if payload == nil {
+		return nil
+	}
+	return bytes.NewReader(payload)
+}
+
+// sendTo sends the request to the Kibana instance at baseURL.
+func (conn *Connection) sendTo(ctx context.Context, baseURL, method, extraPath string,
+	params url.Values, headers http.Header, body io.Reader) (*http.Response, error) {
+
+	reqURL := addToURL(baseURL, extraPath, params)
 
 	req, err := http.NewRequestWithContext(ctx, method, reqURL, body)
 	if err != nil {
@@ -278,10 +494,34 @@ func (conn *Connection) SendWithContext(ctx context.Context, method, extraPath s
 	if contentType != "multipart/form-data" && contentType != "application/ndjson" {
 		req.Header.Set("Content-Type", "application/json")
 	}
-	req.Header.Set("Accept", "application/json")
+	if req.Header.Get("Accept") != "text/event-stream" {
+		req.Header.Set("Accept", "application/json")
+	}
 	req.Header.Set("kbn-xsrf", "1")
+	if conn.APIVersion != "" && req.Header.Get(elasticAPIVersionHeaderKey) == "" {
+		req.Header.Set(elasticAPIVersionHeaderKey, conn.APIVersion)
+	}
+	conn.setExpectContinue(req)
+	for _, i := range conn.interceptors {
+		if err := i.OnRequest(req); err != nil {
+			return nil, fmt.Errorf("fail to intercept the HTTP %s request: %w", method, err)
+		}
+	}
+	if conn.signer != nil {
+		if err := conn.signer.Sign(req); err != nil {
+			return nil, fmt.Errorf("fail to sign the HTTP %s request: %w", method, err)
+		}
+	}
 
-	return conn.RoundTrip(req)
+	resp, err := conn.RoundTrip(req)
+	for i := len(conn.interceptors) - 1; i >= 0; i-- {
+		resp, err = conn.interceptors[i].OnResponse(req, resp, err)
+	}
+	if err == nil {
+		conn.handleWarnings(method+" "+extraPath, resp)
+		recordResponseMetadata(ctx, resp)
+	}
+	return resp, err
 }
 
 func addHeaders(out, in http.Header) {
@@ -294,10 +534,18 @@ func addHeaders(out, in http.Header) {
 
 // Implements RoundTrip interface
 func (conn *Connection) RoundTrip(r *http.Request) (*http.Response, error) {
+	if isStreaming(r.Context()) && conn.HTTP.Timeout > 0 {
+		// The dial is still bounded by the dialer of the transport.
+		httpClient := *conn.HTTP
+		httpClient.Timeout = 0
+		return httpClient.Do(r)
+	}
 	return conn.HTTP.Do(r)
 }
 
-func (client *Client) readVersion() error {
+// readVersion reads the Kibana version from the status API, or the path set
+// with WithVersionPath.
+func (client *Client) readVersion(ctx context.Context) error {
 	type kibanaVersionResponse struct {
 		Name    string `json:"name"`
 		Version struct {
@@ -306,23 +554,29 @@ func (client *Client) readVersion() error {
 		} `json:"version"`
 	}
 
-	code, result, err := client.Connection.Request("GET", statusAPI, nil, nil, nil)
+	// The request must not go through SendWithContext, it would run the
+	// lazy version probe again.
+	resp, err := client.Connection.sendRequest(ctx, http.MethodGet, client.versionPath, nil, nil, nil)
+	if err != nil {
+		return fmt.Errorf("HTTP GET request to %s%s fails: %w", client.Connection.URL, client.versionPath, err)
+	}
+	code, result, err := client.Connection.readResponse(resp)
 	if err != nil {
-		return fmt.Errorf("HTTP GET request to %s/api/status fails: %w (status=%d). Response: %s",
-			client.Connection.URL, err, code, truncateString(result))
+		return fmt.Errorf("HTTP GET request to %s%s fails: %w (status=%d). Response: %s",
+			client.Connection.URL, client.versionPath, err, code, truncateString(result))
 	}
 	if code >= 400 {
-		return fmt.Errorf("HTTP GET request to %s/api/status fails: status=%d. Response: %s",
-			client.Connection.URL, code, truncateString(result))
+		return fmt.Errorf("HTTP GET request to %s%s fails: status=%d. Response: %s",
+			client.Connection.URL, client.versionPath, code, truncateString(result))
 	}
 
 	var versionString string
 
 	var kibanaVersion kibanaVersionResponse
-	err = json.Unmarshal(result, &kibanaVersion)
+	err = client.codec().Unmarshal(result, &kibanaVersion)
 	if err != nil {
-		return fmt.Errorf("fail to unmarshal the response from GET %s/api/status. Response: %s. Kibana status api returns: %w",
-			client.Connection.URL, truncateString(result), err)
+		return fmt.Errorf("fail to unmarshal the response from GET %s%s. Response: %s. Kibana status api returns: %w",
+			client.Connection.URL, client.versionPath, truncateString(result), err)
 	}
 
 	versionString = kibanaVersion.Version.Number
@@ -342,14 +596,25 @@ func (client *Client) readVersion() error {
 }
 
 // GetVersion returns the version read from kibana. The version is not set if
-// IgnoreVersion was set when creating the client.
-func (client *Client) GetVersion() version.V { return client.Version }
+// IgnoreVersion was set when creating the client. If the version is read
+// lazily and was not read yet, it is read now; the version is not set if that
+// fails.
+func (client *Client) GetVersion() version.V {
+	_ = client.Connection.versionProbe.run(context.Background())
+	return client.Version
+}
 
 // KibanaIsServerless returns true if we're talking to a serverless instance.
 // Right now we don't have an API to tell us if we're running against serverless or not, so this actual implementation is something of a hack.
 // see https://github.com/elastic/kibana/pull/164850
 func (client *Client) KibanaIsServerless() (bool, error) {
-	ret, _, err := client.Connection.Request("GET", "/api/saved_objects/_find", nil, nil, nil)
+	return client.KibanaIsServerlessWithContext(context.Background())
+}
+
+// KibanaIsServerlessWithContext is like KibanaIsServerless, using the given
+// context for the request.
+func (client *Client) KibanaIsServerlessWithContext(ctx context.Context) (bool, error) {
+	ret, _, err := client.Connection.RequestWithContext(ctx, "GET", "/api/saved_objects/_find", nil, nil, nil)
 	if ret > 300 && strings.Contains(err.Error(), "not available with the current configuration") {
 		return true, nil
 	} else if err != nil {
@@ -358,7 +623,15 @@ func (client *Client) KibanaIsServerless() (bool, error) {
 	return false, nil
 }
 
+// ImportMultiPartFormFile uploads contents as an NDJSON file to the Kibana
+// import API at url.
 func (client *Client) ImportMultiPartFormFile(url string, params url.Values, filename string, contents string) error {
+	return client.ImportMultiPartFormFileWithContext(context.Background(), url, params, filename, contents)
+}
+
+// ImportMultiPartFormFileWithContext is like ImportMultiPartFormFile, using
+// the given context for the requests.
+func (client *Client) ImportMultiPartFormFileWithContext(ctx context.Context, url string, params url.Values, filename string, contents string) error {
 	buf := &bytes.Buffer{}
 	w := multipart.NewWriter(buf)
 
@@ -379,10 +652,10 @@ func (client *Client) ImportMultiPartFormFile(url string, params url.Values, fil
 	// On serverless, special header is required to talk to this endpoint
 	sendHeaders := http.Header{}
 	sendHeaders.Add("Content-Type", w.FormDataContentType())
-	if serverless, _ := client.KibanaIsServerless(); serverless {
+	if serverless, _ := client.KibanaIsServerlessWithContext(ctx); serverless {
 		sendHeaders.Add("x-elastic-internal-origin", "elastic-agent-libs")
 	}
-	statusCode, response, err := client.Connection.Request("POST", url, params, sendHeaders, buf)
+	statusCode, response, err := client.Connection.RequestWithContext(ctx, "POST", url, params, sendHeaders, buf)
 	if err != nil {
 		return fmt.Errorf("returned %d to import file: %w. Response: %s", statusCode, err, response)
 	}
@@ -19,18 +19,40 @@ package kibana
 
 import (
 	"fmt"
+	"regexp"
 
+	"github.com/elastic/elastic-agent-libs/config"
 	"github.com/elastic/elastic-agent-libs/transport/httpcommon"
 )
 
 const elasticAPIVersionHeaderKey = "Elastic-Api-Version"
-const elasticAPIDefaultVersion = "2023-10-31"
+
+// DefaultAPIVersion is the Elastic-Api-Version sent by default. It is the
+// first version of the versioned Kibana APIs, so it is accepted by all stacks
+// that version their APIs.
+const DefaultAPIVersion = "2023-10-31"
+
+// DefaultSpaceID is the ID of the Kibana space that is used when no space is
+// configured.
+const DefaultSpaceID = "default"
+
+// spaceIDPattern matches the IDs accepted by Kibana for spaces.
+var spaceIDPattern = regexp.MustCompile(`^[a-z0-9_-]+$`)
 
 // ClientConfig to connect to Kibana
 type ClientConfig struct {
-	Protocol     string `config:"protocol" yaml:"protocol,omitempty"`
-	Host         string `config:"host" yaml:"host,omitempty"`
-	Path         string `config:"path" yaml:"path,omitempty"`
+	Protocol string `config:"protocol" yaml:"protocol,omitempty"`
+	// Host is the Kibana host. The host setting also accepts a list of hosts,
+	// which is read into Hosts, Host is set to the first one.
+	Host string `config:"host" yaml:"host,omitempty"`
+	// Hosts lists multiple Kibana hosts and replaces Host if set. Requests
+	// are sent to the first healthy host and fail over to the next one on
+	// connection errors. Requests that do not change state, like GET, also
+	// fail over on 5xx responses.
+	Hosts []string `config:",ignore" yaml:"hosts,omitempty"`
+	Path  string   `config:"path" yaml:"path,omitempty"`
+	// SpaceID prefixes all API paths with /s/{space.id} to target a Kibana
+	// space. The default space does not need a prefix.
 	SpaceID      string `config:"space.id" yaml:"space.id,omitempty"`
 	Username     string `config:"username" yaml:"username,omitempty"`
 	Password     string `config:"password" yaml:"password,omitempty"`
@@ -40,6 +62,15 @@ type ClientConfig struct {
 	// Headers holds headers to include in every request sent to Kibana.
 	Headers map[string]string `config:"headers" yaml:"headers,omitempty"`
 
+	// APIVersion is sent as the Elastic-Api-Version header required by the
+	// versioned Kibana APIs. An empty value omits the header, for stacks that
+	// do not support it. A version set in Headers takes precedence.
+	APIVersion string `config:"api_version" yaml:"api_version,omitempty"`
+
+	// RateLimit configures how requests rejected with 429 Too Many Requests
+	// are retried. It is disabled by default.
+	RateLimit RateLimitConfig `config:"rate_limit" yaml:"rate_limit,omitempty"`
+
 	IgnoreVersion bool
 
 	Transport httpcommon.HTTPTransportSettings `config:",inline" yaml:",inline"`
@@ -56,15 +87,62 @@ func DefaultClientConfig() ClientConfig {
 		Password:     "",
 		APIKey:       "",
 		ServiceToken: "",
+		APIVersion:   DefaultAPIVersion,
 		Transport:    httpcommon.DefaultHTTPTransportSettings(),
-		Headers:      map[string]string{elasticAPIVersionHeaderKey: elasticAPIDefaultVersion},
 	}
 }
 
+// clientConfig has the fields of ClientConfig without its Unpack method.
+type clientConfig ClientConfig
+
+// Unpack reads the configuration from the settings in v. The host setting
+// accepts a single host or a list of hosts. An empty configuration keeps the
+// current values.
+func (c *ClientConfig) Unpack(v interface{}) error {
+	if v == nil {
+		return nil
+	}
+	settings, ok := v.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("the Kibana configuration must be a dictionary, got %T", v)
+	}
+
+	c.Hosts = nil
+	if list, ok := settings["host"].([]interface{}); ok {
+		if len(list) == 0 {
+			return fmt.Errorf("host must list at least one Kibana host")
+		}
+		for _, h := range list {
+			host, ok := h.(string)
+			if !ok {
+				return fmt.Errorf("invalid Kibana host %v: hosts must be strings", h)
+			}
+			c.Hosts = append(c.Hosts, host)
+		}
+		delete(settings, "host")
+		c.Host = c.Hosts[0]
+	}
+
+	cfg, err := config.NewConfigFrom(settings)
+	if err != nil {
+		return err
+	}
+	return cfg.Unpack((*clientConfig)(c))
+}
+
 func (c *ClientConfig) Validate() error {
 	if c.APIKey != "" && (c.Username != "" || c.Password != "") {
 		return fmt.Errorf("cannot set both api_key and username/password")
 	}
+	if c.ServiceToken != "" && (c.Username != "" || c.Password != "") {
+		return fmt.Errorf("cannot set both service_token and username/password")
+	}
+	if c.ServiceToken != "" && c.APIKey != "" {
+		return fmt.Errorf("cannot set both service_token and api_key")
+	}
+	if c.SpaceID != "" && !spaceIDPattern.MatchString(c.SpaceID) {
+		return fmt.Errorf("invalid space.id '%s': only lowercase letters, numbers, '_' and '-' are allowed", c.SpaceID)
+	}
 
 	return nil
 }
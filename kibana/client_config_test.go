@@ -59,6 +59,32 @@ func TestClientConfigValdiate(t *testing.T) {
 			APIKey:   "apiKey",
 		},
 		err: fmt.Errorf("cannot set both api_key and username/password"),
+	}, {
+		name: "password and service_token",
+		c: &ClientConfig{
+			Password:     "pass",
+			ServiceToken: "service_token",
+		},
+		err: fmt.Errorf("cannot set both service_token and username/password"),
+	}, {
+		name: "api_key and service_token",
+		c: &ClientConfig{
+			APIKey:       "apiKey",
+			ServiceToken: "service_token",
+		},
+		err: fmt.Errorf("cannot set both service_token and api_key"),
+	}, {
+		name: "space",
+		c: &ClientConfig{
+			SpaceID: "team_a-1",
+		},
+		err: nil,
+	}, {
+		name: "invalid space",
+		c: &ClientConfig{
+			SpaceID: "Team A",
+		},
+		err: fmt.Errorf("invalid space.id 'Team A': only lowercase letters, numbers, '_' and '-' are allowed"),
 	}}
 
 	for _, tt := range tests {
@@ -18,16 +18,22 @@
 package kibana
 
 import (
+	"context"
+	"encoding/base64"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
 	"github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/useragent"
+	"github.com/elastic/elastic-agent-libs/version"
 )
 
 const (
@@ -59,6 +65,25 @@ func assertConnection(t *testing.T, URL string, expectedStatusCode int) {
 	assert.Error(t, err)
 }
 
+func TestRequestWithContextCanceled(t *testing.T) {
+	done := make(chan struct{})
+	kibanaTS := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-done
+	}))
+	defer kibanaTS.Close()
+	defer close(done)
+
+	conn := Connection{
+		URL:  kibanaTS.URL,
+		HTTP: http.DefaultClient,
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_, _, err := conn.RequestWithContext(ctx, http.MethodGet, "/api/status", nil, nil, nil)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
 func TestErrorBadJson(t *testing.T) {
 	kibanaTS := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusGone)
@@ -116,6 +141,79 @@ func TestServiceToken(t *testing.T) {
 	assert.NoError(t, err)
 }
 
+func TestNewKibanaClientAuthorization(t *testing.T) {
+	var authorization string
+	kibanaTS := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization = r.Header.Get("Authorization")
+		_, _ = w.Write([]byte(`{"version":{"number":"1.2.3-beta","build_snapshot":true}}`))
+	}))
+	defer kibanaTS.Close()
+
+	for name, tc := range map[string]struct {
+		settings string
+		expected string
+	}{
+		"api key":       {settings: "api_key: id:secret", expected: "ApiKey " + base64.StdEncoding.EncodeToString([]byte("id:secret"))},
+		"service token": {settings: "service_token: token", expected: "Bearer token"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := NewKibanaClient(config.MustNewConfigFrom(fmt.Sprintf(`
+host: %s
+%s
+`, kibanaTS.Listener.Addr().String(), tc.settings)), binaryName, v, commit, buildTime)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, authorization)
+		})
+	}
+
+	_, err := NewKibanaClient(config.MustNewConfigFrom(fmt.Sprintf(`
+host: http://user:pass@%s
+service_token: token
+`, kibanaTS.Listener.Addr().String())), binaryName, v, commit, buildTime)
+	assert.ErrorContains(t, err, "cannot set service_token with username/password in Kibana URL")
+}
+
+func TestNewKibanaClientSecretReferences(t *testing.T) {
+	config.RegisterSecretResolver("test", func(token string) (string, error) {
+		return "resolved-" + token, nil
+	})
+	t.Cleanup(func() { config.RegisterSecretResolver("test", nil) })
+
+	var authorization string
+	kibanaTS := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization = r.Header.Get("Authorization")
+		_, _ = w.Write([]byte(`{"version":{"number":"1.2.3-beta","build_snapshot":true}}`))
+	}))
+	defer kibanaTS.Close()
+
+	for name, tc := range map[string]struct {
+		settings string
+		expected string
+	}{
+		"username and password": {
+			settings: "username: secret://test/user\npassword: secret://test/pass",
+			expected: "Basic " + base64.StdEncoding.EncodeToString([]byte("resolved-user:resolved-pass")),
+		},
+		"api key":       {settings: "api_key: secret://test/key", expected: "ApiKey " + base64.StdEncoding.EncodeToString([]byte("resolved-key"))},
+		"service token": {settings: "service_token: secret://test/token", expected: "Bearer resolved-token"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := NewKibanaClient(config.MustNewConfigFrom(fmt.Sprintf(`
+host: %s
+%s
+`, kibanaTS.Listener.Addr().String(), tc.settings)), binaryName, v, commit, buildTime)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, authorization)
+		})
+	}
+
+	_, err := NewKibanaClient(config.MustNewConfigFrom(fmt.Sprintf(`
+host: %s
+api_key: secret://unknown/key
+`, kibanaTS.Listener.Addr().String())), binaryName, v, commit, buildTime)
+	assert.ErrorContains(t, err, "resolving the Kibana api_key")
+}
+
 func TestNewKibanaClientWithSpace(t *testing.T) {
 	var (
 		testSpace      = "test-space"
@@ -165,6 +263,41 @@ headers:
 
 }
 
+func TestNewKibanaClientSpaceFleetPaths(t *testing.T) {
+	ctx, cn := context.WithCancel(context.Background())
+	defer cn()
+
+	var paths []string
+	kibanaTS := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		if strings.HasSuffix(r.URL.Path, statusAPI) {
+			_, _ = w.Write([]byte(`{"version":{"number":"8.11.0"}}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"item":{"id":"policy-id"}}`))
+	}))
+	defer kibanaTS.Close()
+
+	for space, prefix := range map[string]string{
+		"team-a":       "/s/team-a",
+		DefaultSpaceID: "",
+	} {
+		paths = nil
+		client, err := NewKibanaClient(config.MustNewConfigFrom(fmt.Sprintf(`
+host: %s
+space.id: %s
+`, kibanaTS.Listener.Addr().String(), space)), binaryName, v, commit, buildTime)
+		require.NoError(t, err)
+
+		_, err = client.GetPolicy(ctx, "policy-id")
+		require.NoError(t, err)
+		assert.Equal(t, []string{prefix + statusAPI, prefix + fmt.Sprintf(fleetAgentPolicyAPI, "policy-id")}, paths, "space %s", space)
+	}
+
+	_, err := NewKibanaClient(config.MustNewConfigFrom(`space.id: "../admin"`), binaryName, v, commit, buildTime)
+	assert.ErrorContains(t, err, "invalid space.id")
+}
+
 func TestNewKibanaClientWithMultipartData(t *testing.T) {
 	var requests []*http.Request
 	kibanaTS := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -193,3 +326,61 @@ headers:
 	assert.Equal(t, []string{"multipart/form-data; boundary=46bea21be603a2c2ea6f51571a5e1baf5ea3be8ebd7101199320607b36ff"}, requests[1].Header.Values("Content-Type"))
 
 }
+
+func TestAPIVersionHeader(t *testing.T) {
+	ctx, cn := context.WithCancel(context.Background())
+	defer cn()
+
+	for name, tc := range map[string]struct {
+		opts     []ClientOption
+		expected []string
+	}{
+		"default":    {expected: []string{DefaultAPIVersion}},
+		"configured": {opts: []ClientOption{WithAPIVersion("2023-11-01")}, expected: []string{"2023-11-01"}},
+		"suppressed": {opts: []ClientOption{WithAPIVersion("")}, expected: nil},
+	} {
+		t.Run(name, func(t *testing.T) {
+			var header []string
+			handler := func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path == fleetAgentsAPI {
+					header = r.Header.Values(elasticAPIVersionHeaderKey)
+					_, _ = w.Write(fleetListAgentsResponse)
+				}
+			}
+
+			client, err := createTestServerAndClient(handler, tc.opts...)
+			require.NoError(t, err)
+
+			_, err = client.ListAgents(ctx, ListAgentsRequest{})
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, header)
+		})
+	}
+}
+
+func TestNewKibanaClientWithBuildInfo(t *testing.T) {
+	var userAgent string
+	kibanaTS := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userAgent = r.Header.Get("User-Agent")
+		_, _ = w.Write([]byte(`{"version":{"number":"1.2.3-beta","build_snapshot":true}}`))
+	}))
+	defer kibanaTS.Close()
+
+	info := version.MustNewBuildInfo("8.12.0-SNAPSHOT", commit, buildTime)
+	_, err := NewKibanaClientWithBuildInfo(config.MustNewConfigFrom(fmt.Sprintf(`
+protocol: http
+host: %s
+`, kibanaTS.Listener.Addr().String())), binaryName, info)
+	require.NoError(t, err)
+
+	assert.Equal(t, useragent.UserAgent(binaryName, "8.12.0-SNAPSHOT", commit, buildTime), userAgent)
+}
+
+func TestNewKibanaClientInvalidLocalAddress(t *testing.T) {
+	_, err := NewKibanaClient(config.MustNewConfigFrom(`
+protocol: http
+host: localhost:5601
+local_address: 192.0.2.1
+`), binaryName, v, commit, buildTime)
+	require.ErrorContains(t, err, "192.0.2.1")
+}
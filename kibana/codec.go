@@ -0,0 +1,49 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package kibana
+
+import "encoding/json"
+
+// JSONCodec encodes request bodies and decodes response bodies. It allows
+// replacing encoding/json with a faster implementation without this package
+// depending on it.
+type JSONCodec interface {
+	Marshal(v any) ([]byte, error)
+	Unmarshal(data []byte, v any) error
+}
+
+type stdJSONCodec struct{}
+
+func (stdJSONCodec) Marshal(v any) ([]byte, error) { return json.Marshal(v) }
+
+func (stdJSONCodec) Unmarshal(data []byte, v any) error { return json.Unmarshal(data, v) }
+
+// WithJSONCodec sets the codec used for all JSON request and response bodies.
+// The default uses encoding/json.
+func WithJSONCodec(codec JSONCodec) ClientOption {
+	return func(client *Client) {
+		client.Connection.jsonCodec = codec
+	}
+}
+
+func (conn *Connection) codec() JSONCodec {
+	if conn.jsonCodec == nil {
+		return stdJSONCodec{}
+	}
+	return conn.jsonCodec
+}
@@ -0,0 +1,90 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package kibana
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type recordingCodec struct {
+	marshaled   []any
+	unmarshaled []any
+}
+
+func (c *recordingCodec) Marshal(v any) ([]byte, error) {
+	c.marshaled = append(c.marshaled, v)
+	return json.Marshal(v)
+}
+
+func (c *recordingCodec) Unmarshal(data []byte, v any) error {
+	c.unmarshaled = append(c.unmarshaled, v)
+	return json.Unmarshal(data, v)
+}
+
+func TestWithJSONCodec(t *testing.T) {
+	ctx, cn := context.WithCancel(context.Background())
+	defer cn()
+
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case fleetAgentPoliciesAPI:
+			_, _ = w.Write(fleetCreatePolicyResponse)
+		}
+	}
+
+	codec := &recordingCodec{}
+	client, err := createTestServerAndClient(handler, WithJSONCodec(codec))
+	require.NoError(t, err)
+
+	codec.marshaled, codec.unmarshaled = nil, nil
+	req := AgentPolicy{Name: "test policy", Namespace: "default"}
+	_, err = client.CreatePolicy(ctx, req)
+	require.NoError(t, err)
+
+	require.Len(t, codec.marshaled, 1)
+	require.Equal(t, req, codec.marshaled[0])
+	require.Len(t, codec.unmarshaled, 1)
+	require.IsType(t, &policyResp{}, codec.unmarshaled[0])
+}
+
+func TestWithJSONCodecSavedObjects(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"success":true,"successCount":1}`))
+	}
+
+	codec := &recordingCodec{}
+	client, err := createTestServerAndClient(handler, WithJSONCodec(codec))
+	require.NoError(t, err)
+
+	codec.marshaled, codec.unmarshaled = nil, nil
+	managed := true
+	_, err = client.ImportSavedObjects(context.Background(), ImportSavedObjectsRequest{
+		Objects: strings.NewReader(`{"type":"dashboard","id":"d1","attributes":{}}` + "\n"),
+		Managed: &managed,
+	})
+	require.NoError(t, err)
+
+	require.Len(t, codec.marshaled, 1, "the managed flag must be set with the codec")
+	require.Len(t, codec.unmarshaled, 2)
+}
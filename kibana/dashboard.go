@@ -0,0 +1,158 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package kibana
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// ImportDashboardRequest is the request for ImportDashboard.
+type ImportDashboardRequest struct {
+	// Path is an NDJSON file or a directory. All the .ndjson files of a
+	// directory are imported.
+	Path string
+	// Objects is the NDJSON to import if Path is empty.
+	Objects []byte
+	// IgnoreMissingReferences imports objects referencing objects that
+	// neither exist in Kibana nor are part of the import.
+	IgnoreMissingReferences bool
+}
+
+// ImportDashboardResponse is the response of ImportDashboard.
+type ImportDashboardResponse struct {
+	// Objects are the imported objects, with the IDs they have in Kibana.
+	Objects []SavedObjectRef
+}
+
+// IDs returns the IDs of the imported objects of the given type.
+func (r ImportDashboardResponse) IDs(objectType string) []string {
+	var ids []string
+	for _, o := range r.Objects {
+		if o.Type == objectType {
+			ids = append(ids, o.ID)
+		}
+	}
+	return ids
+}
+
+// ImportDashboard imports a dashboard and the objects it references, like
+// index patterns and visualizations. Objects conflicting with existing ones
+// are overwritten, an ambiguous conflict overwrites the first matching
+// object. An error is returned if objects could still not be imported, for
+// example because of missing references, together with the objects that were
+// imported.
+func (client *Client) ImportDashboard(ctx context.Context, request ImportDashboardRequest) (r ImportDashboardResponse, err error) {
+	objects := request.Objects
+	if request.Path != "" {
+		if objects, err = readNDJSON(request.Path); err != nil {
+			return r, err
+		}
+	}
+	if len(bytes.TrimSpace(objects)) == 0 {
+		return r, errors.New("no saved objects to import")
+	}
+
+	resp, err := client.sendSavedObjects(ctx, savedObjectsImportAPI, nil, bytes.NewReader(objects), nil)
+	if err != nil {
+		return r, err
+	}
+	r.add(resp.SuccessResults)
+
+	var retries []savedObjectRetry
+	var unresolved []SavedObjectImportError
+	for _, e := range resp.Errors {
+		retry := savedObjectRetry{Type: e.Type, ID: e.ID}
+		switch {
+		case e.Error.Type == "conflict":
+			retry.Overwrite = true
+			retry.DestinationID = e.Error.DestinationID
+		case e.Error.Type == "ambiguous_conflict" && len(e.Error.Destinations) > 0:
+			retry.Overwrite = true
+			retry.DestinationID = e.Error.Destinations[0].ID
+		case e.Error.Type == "missing_references" && request.IgnoreMissingReferences:
+			retry.IgnoreMissingReferences = true
+		default:
+			unresolved = append(unresolved, e)
+			continue
+		}
+		retries = append(retries, retry)
+	}
+
+	if len(retries) > 0 {
+		resp, err = client.sendSavedObjects(ctx, savedObjectsResolveImportErrorsAPI, nil, bytes.NewReader(objects), retries)
+		if err != nil {
+			return r, err
+		}
+		r.add(resp.SuccessResults)
+		unresolved = append(unresolved, resp.Errors...)
+	}
+
+	if len(unresolved) > 0 {
+		return r, importErrors(unresolved)
+	}
+	return r, nil
+}
+
+func (r *ImportDashboardResponse) add(results []SavedObjectImportResult) {
+	for _, result := range results {
+		id := result.ID
+		if result.DestinationID != "" {
+			id = result.DestinationID
+		}
+		r.Objects = append(r.Objects, SavedObjectRef{Type: result.Type, ID: id})
+	}
+}
+
+// readNDJSON reads the NDJSON file at path, or concatenates the .ndjson files
+// of the directory at path in lexical order.
+func readNDJSON(path string) ([]byte, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading saved objects: %w", err)
+	}
+	if !info.IsDir() {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("reading saved objects: %w", err)
+		}
+		return b, nil
+	}
+
+	files, err := filepath.Glob(filepath.Join(path, "*.ndjson"))
+	if err != nil {
+		return nil, fmt.Errorf("listing saved objects in %s: %w", path, err)
+	}
+	sort.Strings(files)
+
+	var buf bytes.Buffer
+	for _, file := range files {
+		b, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("reading saved objects: %w", err)
+		}
+		buf.Write(bytes.TrimSpace(b))
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes(), nil
+}
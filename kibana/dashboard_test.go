@@ -0,0 +1,124 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package kibana
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestImportDashboard(t *testing.T) {
+	ctx, cn := context.WithCancel(context.Background())
+	defer cn()
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "1-index-pattern.ndjson"), []byte(`{"type":"index-pattern","id":"logs-*"}`), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "2-dashboard.ndjson"), []byte(`{"type":"visualization","id":"vis"}
+{"type":"dashboard","id":"overview"}
+{"type":"dashboard","id":"broken"}
+`), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "README.md"), []byte(`ignored`), 0o600))
+
+	expectedObjects := `{"type":"index-pattern","id":"logs-*"}
+{"type":"visualization","id":"vis"}
+{"type":"dashboard","id":"overview"}
+{"type":"dashboard","id":"broken"}
+`
+
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		file, _, err := r.FormFile("file")
+		require.NoError(t, err)
+		b, err := io.ReadAll(file)
+		require.NoError(t, err)
+		require.Equal(t, expectedObjects, string(b))
+
+		switch r.URL.Path {
+		case savedObjectsImportAPI:
+			require.Empty(t, r.FormValue("retries"))
+			_, _ = w.Write([]byte(`{"success":false,"successCount":1,
+				"successResults":[{"type":"visualization","id":"vis"}],
+				"errors":[
+					{"type":"index-pattern","id":"logs-*","error":{"type":"conflict"}},
+					{"type":"dashboard","id":"overview","error":{"type":"ambiguous_conflict","destinations":[{"id":"overview-copy","title":"Overview"},{"id":"other"}]}},
+					{"type":"dashboard","id":"broken","error":{"type":"missing_references","references":[{"type":"visualization","id":"missing"}]}}
+				]}`))
+		case savedObjectsResolveImportErrorsAPI:
+			var retries []savedObjectRetry
+			require.NoError(t, json.Unmarshal([]byte(r.FormValue("retries")), &retries))
+			require.Equal(t, []savedObjectRetry{
+				{Type: "index-pattern", ID: "logs-*", Overwrite: true},
+				{Type: "dashboard", ID: "overview", Overwrite: true, DestinationID: "overview-copy"},
+			}, retries)
+			_, _ = w.Write([]byte(`{"success":true,"successCount":2,"successResults":[
+				{"type":"index-pattern","id":"logs-*","overwrite":true},
+				{"type":"dashboard","id":"overview","destinationId":"overview-copy","overwrite":true}
+			]}`))
+		default:
+			t.Errorf("unexpected request to %s", r.URL.Path)
+		}
+	}
+
+	client, err := createTestServerAndClient(handler)
+	require.NoError(t, err)
+
+	resp, err := client.ImportDashboard(ctx, ImportDashboardRequest{Path: dir})
+	require.ErrorContains(t, err, "dashboard broken: missing_references")
+	require.Equal(t, []SavedObjectRef{
+		{Type: "visualization", ID: "vis"},
+		{Type: "index-pattern", ID: "logs-*"},
+		{Type: "dashboard", ID: "overview-copy"},
+	}, resp.Objects)
+	require.Equal(t, []string{"overview-copy"}, resp.IDs("dashboard"))
+}
+
+func TestImportDashboardIgnoreMissingReferences(t *testing.T) {
+	ctx, cn := context.WithCancel(context.Background())
+	defer cn()
+
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case savedObjectsImportAPI:
+			_, _ = w.Write([]byte(`{"success":false,"errors":[{"type":"dashboard","id":"overview","error":{"type":"missing_references","references":[{"type":"index-pattern","id":"missing"}]}}]}`))
+		case savedObjectsResolveImportErrorsAPI:
+			var retries []savedObjectRetry
+			require.NoError(t, json.Unmarshal([]byte(r.FormValue("retries")), &retries))
+			require.Equal(t, []savedObjectRetry{{Type: "dashboard", ID: "overview", IgnoreMissingReferences: true}}, retries)
+			_, _ = w.Write([]byte(`{"success":true,"successCount":1,"successResults":[{"type":"dashboard","id":"overview"}]}`))
+		}
+	}
+
+	client, err := createTestServerAndClient(handler)
+	require.NoError(t, err)
+
+	resp, err := client.ImportDashboard(ctx, ImportDashboardRequest{
+		Objects:                 []byte(`{"type":"dashboard","id":"overview"}`),
+		IgnoreMissingReferences: true,
+	})
+	require.NoError(t, err)
+	require.Equal(t, []string{"overview"}, resp.IDs("dashboard"))
+
+	_, err = client.ImportDashboard(ctx, ImportDashboardRequest{Path: filepath.Join(t.TempDir(), "missing.ndjson")})
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
@@ -0,0 +1,229 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package kibana
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// ErrConcurrentModification is returned when an update is rejected because
+// the object was modified since it was read.
+var ErrConcurrentModification = errors.New("concurrent modification")
+
+// ErrAgentActive is returned when deleting an active agent without forcing
+// it.
+var ErrAgentActive = errors.New("agent is active")
+
+// ErrPartialBulkFailure is returned when a bulk agent action failed for some
+// of the agents. The returned error is a *PartialBulkFailureError.
+var ErrPartialBulkFailure = errors.New("bulk action failed for some agents")
+
+// PartialBulkFailureError is returned by bulk agent actions that failed for
+// some of the agents. It matches ErrPartialBulkFailure with errors.Is.
+type PartialBulkFailureError struct {
+	Result BulkResult
+}
+
+func (e *PartialBulkFailureError) Error() string {
+	return fmt.Sprintf("%s: %d of %d agents failed", ErrPartialBulkFailure,
+		len(e.Result.Failed), len(e.Result.Failed)+len(e.Result.Succeeded))
+}
+
+func (e *PartialBulkFailureError) Unwrap() error {
+	return ErrPartialBulkFailure
+}
+
+// ErrUnexpectedAuthChallenge is returned when Kibana API requests are answered
+// with an HTML page, usually the login page of an authenticating proxy, instead
+// of JSON. The returned error is an *AuthChallengeError.
+var ErrUnexpectedAuthChallenge = errors.New("unexpected authentication challenge")
+
+// AuthChallengeError is returned when a request to a JSON endpoint of Kibana
+// is answered with an HTML page, with a success or redirect status code. It
+// matches ErrUnexpectedAuthChallenge with errors.Is.
+type AuthChallengeError struct {
+	StatusCode int
+	// URL is the URL of the page returned or, for redirects, its location.
+	URL string
+}
+
+func (e *AuthChallengeError) Error() string {
+	return fmt.Sprintf("%s: Kibana returned an HTML page from %s (status code %d) instead of JSON, "+
+		"a proxy between the client and Kibana probably intercepted the request to authenticate it, "+
+		"check that the Kibana host points to Kibana and that the proxy lets API requests through",
+		ErrUnexpectedAuthChallenge, e.URL, e.StatusCode)
+}
+
+func (e *AuthChallengeError) Unwrap() error {
+	return ErrUnexpectedAuthChallenge
+}
+
+// checkAuthChallenge returns an *AuthChallengeError if resp is an HTML page
+// returned with a success or redirect status code.
+func checkAuthChallenge(resp *http.Response) error {
+	if resp.StatusCode < 200 || resp.StatusCode >= 400 {
+		return nil
+	}
+	mediaType, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if err != nil || mediaType != "text/html" {
+		return nil
+	}
+
+	var location string
+	if loc, err := resp.Location(); err == nil {
+		location = loc.String()
+	} else if resp.Request != nil && resp.Request.URL != nil {
+		location = resp.Request.URL.String()
+	}
+	return &AuthChallengeError{StatusCode: resp.StatusCode, URL: location}
+}
+
+// APIError is returned when Kibana answers a request with an error status.
+// Use errors.As to inspect it, or the IsNotFound, IsConflict and similar
+// helpers to check the status code.
+type APIError struct {
+	StatusCode int
+	// Code is the error reported by Kibana in the error field of the body,
+	// e.g. "Not Found" or "Conflict". It is empty if the body is not a Kibana
+	// error.
+	Code string
+	// Message is the message reported by Kibana, if any.
+	Message string
+	// Err holds the error details reported by Kibana, if any.
+	Err error
+	// Body is the decoded body of the response, it is not truncated.
+	Body []byte
+}
+
+func newAPIError(codec JSONCodec, statusCode int, body []byte) *APIError {
+	apiErr := &APIError{StatusCode: statusCode, Err: errorFromBody(codec, body), Body: body}
+	if json.Valid(body) {
+		var kibanaErr struct {
+			Error   string `json:"error"`
+			Message string `json:"message"`
+		}
+		if err := codec.Unmarshal(body, &kibanaErr); err == nil {
+			apiErr.Code = kibanaErr.Error
+			apiErr.Message = kibanaErr.Message
+		}
+	}
+	return apiErr
+}
+
+// errorFromBody extracts the error message from an error response. Bodies
+// that are not JSON, e.g. errors returned by a proxy, are returned as text,
+// truncated if they are long.
+func errorFromBody(codec JSONCodec, body []byte) error {
+	if json.Valid(body) {
+		return extractError(codec, body)
+	}
+	if text := strings.TrimSpace(truncateString(body)); text != "" {
+		return errors.New(text)
+	}
+	return nil
+}
+
+// readBody reads the whole body of resp. Gzip encoded bodies that were not
+// decompressed by the transport, because the request set Accept-Encoding
+// itself or the server ignored it, are decompressed.
+func readBody(resp *http.Response) ([]byte, error) {
+	if resp.Uncompressed || !strings.EqualFold(resp.Header.Get("Content-Encoding"), "gzip") {
+		return io.ReadAll(resp.Body)
+	}
+
+	b, err := io.ReadAll(resp.Body)
+	if err != nil || len(b) == 0 {
+		return b, err
+	}
+	gz, err := gzip.NewReader(bytes.NewReader(b))
+	if err != nil {
+		return nil, fmt.Errorf("decompressing response body: %w", err)
+	}
+	defer gz.Close()
+	b, err = io.ReadAll(gz)
+	if err != nil {
+		return nil, fmt.Errorf("decompressing response body: %w", err)
+	}
+	return b, nil
+}
+
+// bodyReader returns the body of resp, decompressed like in readBody. The
+// caller must close it; closing it does not close resp.Body.
+func bodyReader(resp *http.Response) (io.ReadCloser, error) {
+	if resp.Uncompressed || !strings.EqualFold(resp.Header.Get("Content-Encoding"), "gzip") {
+		return io.NopCloser(resp.Body), nil
+	}
+	gz, err := gzip.NewReader(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("decompressing response body: %w", err)
+	}
+	return gz, nil
+}
+
+func (e *APIError) Error() string {
+	if e.Err == nil {
+		return fmt.Sprintf("Kibana API returned status code %d", e.StatusCode)
+	}
+	return e.Err.Error()
+}
+
+func (e *APIError) Unwrap() error {
+	return e.Err
+}
+
+// IsNotFound returns true if err is an APIError with status 404 Not Found.
+func IsNotFound(err error) bool {
+	return hasStatus(err, func(code int) bool { return code == http.StatusNotFound })
+}
+
+// IsConflict returns true if err is an APIError with status 409 Conflict.
+func IsConflict(err error) bool {
+	return hasStatus(err, func(code int) bool { return code == http.StatusConflict })
+}
+
+// IsRateLimited returns true if err is an APIError with status 429 Too Many
+// Requests.
+func IsRateLimited(err error) bool {
+	return hasStatus(err, func(code int) bool { return code == http.StatusTooManyRequests })
+}
+
+// IsUnauthorized returns true if err is an APIError with status 401
+// Unauthorized or 403 Forbidden.
+func IsUnauthorized(err error) bool {
+	return hasStatus(err, func(code int) bool {
+		return code == http.StatusUnauthorized || code == http.StatusForbidden
+	})
+}
+
+// IsServerError returns true if err is an APIError with a 5xx status.
+func IsServerError(err error) bool {
+	return hasStatus(err, func(code int) bool { return code >= 500 && code <= 599 })
+}
+
+func hasStatus(err error, match func(int) bool) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && match(apiErr.StatusCode)
+}
@@ -0,0 +1,180 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package kibana
+
+import (
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestErrorPredicates(t *testing.T) {
+	predicates := map[string]func(error) bool{
+		"IsNotFound":     IsNotFound,
+		"IsConflict":     IsConflict,
+		"IsRateLimited":  IsRateLimited,
+		"IsUnauthorized": IsUnauthorized,
+		"IsServerError":  IsServerError,
+	}
+
+	tests := map[string]struct {
+		err      error
+		expected string
+	}{
+		"not found":    {err: &APIError{StatusCode: http.StatusNotFound}, expected: "IsNotFound"},
+		"conflict":     {err: &APIError{StatusCode: http.StatusConflict}, expected: "IsConflict"},
+		"rate limited": {err: &APIError{StatusCode: http.StatusTooManyRequests}, expected: "IsRateLimited"},
+		"unauthorized": {err: &APIError{StatusCode: http.StatusUnauthorized}, expected: "IsUnauthorized"},
+		"forbidden":    {err: &APIError{StatusCode: http.StatusForbidden}, expected: "IsUnauthorized"},
+		"server error": {err: &APIError{StatusCode: http.StatusBadGateway}, expected: "IsServerError"},
+		"wrapped":      {err: fmt.Errorf("getting policy: %w", &APIError{StatusCode: http.StatusNotFound}), expected: "IsNotFound"},
+		"bad request":  {err: &APIError{StatusCode: http.StatusBadRequest}},
+		"context":      {err: context.Canceled},
+		"plain error":  {err: errors.New("404 not found")},
+		"nil":          {err: nil},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			for predicate, fn := range predicates {
+				assert.Equal(t, predicate == tc.expected, fn(tc.err), predicate)
+			}
+		})
+	}
+}
+
+func TestAPIErrorFromResponse(t *testing.T) {
+	ctx, cn := context.WithCancel(context.Background())
+	defer cn()
+
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte(`{"statusCode":404,"error":"Not Found","message":"Agent policy missing not found"}`))
+	}
+
+	client, err := createTestServerAndClient(handler)
+	require.NoError(t, err)
+
+	_, err = client.GetPolicy(ctx, "missing")
+	require.Error(t, err)
+	assert.True(t, IsNotFound(err))
+	assert.EqualError(t, err, "Agent policy missing not found")
+
+	var apiErr *APIError
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusNotFound, apiErr.StatusCode)
+	assert.Equal(t, "Not Found", apiErr.Code)
+	assert.Equal(t, "Agent policy missing not found", apiErr.Message)
+}
+
+func TestAPIErrorConflict(t *testing.T) {
+	ctx, cn := context.WithCancel(context.Background())
+	defer cn()
+
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusConflict)
+		_, _ = w.Write([]byte(`{"statusCode":409,"error":"Conflict","message":"Cannot delete policy with enrolled agents"}`))
+	}
+
+	client, err := createTestServerAndClient(handler)
+	require.NoError(t, err)
+
+	err = client.DeletePolicy(ctx, "policy-id")
+	assert.True(t, IsConflict(err))
+	assert.False(t, IsNotFound(err))
+
+	var apiErr *APIError
+	require.ErrorAs(t, fmt.Errorf("deleting policy: %w", err), &apiErr)
+	assert.Equal(t, http.StatusConflict, apiErr.StatusCode)
+	assert.Equal(t, "Conflict", apiErr.Code)
+	assert.Equal(t, "Cannot delete policy with enrolled agents", apiErr.Message)
+	assert.Contains(t, string(apiErr.Body), `"statusCode":409`)
+}
+
+func TestAPIErrorFromEncodedResponse(t *testing.T) {
+	ctx, cn := context.WithCancel(context.Background())
+	defer cn()
+
+	proxyError := strings.Repeat("upstream connect error or disconnect/reset before headers. ", 20)
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case fmt.Sprintf(fleetAgentPolicyAPI, "gzip"):
+			w.Header().Set("Content-Encoding", "gzip")
+			w.WriteHeader(http.StatusNotFound)
+			gz := gzip.NewWriter(w)
+			_, _ = gz.Write([]byte(`{"statusCode":404,"error":"Not Found","message":"Agent policy gzip not found"}`))
+			_ = gz.Close()
+		case fmt.Sprintf(fleetAgentPolicyAPI, "chunked"):
+			w.Header().Set("Content-Type", "text/plain")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			for _, chunk := range strings.SplitAfter(proxyError, ". ") {
+				_, _ = w.Write([]byte(chunk))
+				w.(http.Flusher).Flush()
+			}
+		}
+	}
+
+	client, err := createTestServerAndClient(handler)
+	require.NoError(t, err)
+	// Setting Accept-Encoding disables the transparent decompression of the
+	// transport.
+	client.Connection.Headers.Set("Accept-Encoding", "gzip")
+
+	_, err = client.GetPolicy(ctx, "gzip")
+	assert.True(t, IsNotFound(err))
+	assert.EqualError(t, err, "Agent policy gzip not found")
+
+	_, err = client.GetPolicy(ctx, "chunked")
+	assert.True(t, IsServerError(err))
+	var apiErr *APIError
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, proxyError, string(apiErr.Body), "the body must be kept in full")
+	assert.True(t, strings.HasPrefix(err.Error(), "upstream connect error"), err.Error())
+	assert.True(t, strings.HasSuffix(err.Error(), "... (truncated)"), err.Error())
+}
+
+func TestAPIErrorFromEmptyResponse(t *testing.T) {
+	for name, body := range map[string]string{
+		"empty":      "",
+		"whitespace": " \n",
+	} {
+		t.Run(name, func(t *testing.T) {
+			handler := func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "text/plain")
+				w.WriteHeader(http.StatusInternalServerError)
+				_, _ = w.Write([]byte(body))
+			}
+
+			client, err := createTestServerAndClient(handler)
+			require.NoError(t, err)
+
+			code, _, err := client.Connection.Request(http.MethodGet, "/api/test", nil, nil, nil)
+			assert.Equal(t, http.StatusInternalServerError, code)
+			require.Error(t, err)
+			assert.True(t, IsServerError(err))
+			assert.EqualError(t, err, "Kibana API returned status code 500")
+		})
+	}
+}
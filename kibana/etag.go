@@ -0,0 +1,173 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package kibana
+
+import (
+	"container/list"
+	"context"
+	"net/http"
+	"net/url"
+	"sync"
+)
+
+// WithETagCache enables conditional GET requests. The client remembers the
+// ETag and the body of up to size GET responses and sends If-None-Match on
+// subsequent identical requests. When Kibana answers with 304 Not Modified the
+// cached body is decoded instead of a new one. A size <= 0 disables the cache.
+//
+// The cache holds the raw body and not the decoded value on purpose: decoded
+// values hold slices, maps and pointers that are owned by the caller, sharing
+// them between calls would let a caller modify the results of other calls.
+// Decoding the cached body costs about as much as a deep copy and keeps
+// every result independent, the 304 still saves Kibana from building and
+// sending the response.
+func WithETagCache(size int) ClientOption {
+	return func(client *Client) {
+		if size <= 0 {
+			client.etags = nil
+			return
+		}
+		client.etags = newETagCache(size)
+	}
+}
+
+// etagCache is a bounded LRU cache of the bodies of GET responses keyed by the path
+// and query of the request. The host is not part of the key, so responses are
+// shared between the hosts the client fails over to.
+type etagCache struct {
+	mu    sync.Mutex
+	size  int
+	ll    *list.List
+	items map[string]*list.Element
+}
+
+type etagEntry struct {
+	key  string
+	etag string
+	body []byte
+}
+
+func newETagCache(size int) *etagCache {
+	return &etagCache{
+		size:  size,
+		ll:    list.New(),
+		items: make(map[string]*list.Element, size),
+	}
+}
+
+func (c *etagCache) get(key string) (etagEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.items[key]
+	if !ok {
+		return etagEntry{}, false
+	}
+	c.ll.MoveToFront(elem)
+	return *elem.Value.(*etagEntry), true
+}
+
+func (c *etagCache) put(key, etag string, body []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.items[key]; ok {
+		c.ll.MoveToFront(elem)
+		entry := elem.Value.(*etagEntry)
+		entry.etag = etag
+		entry.body = body
+		return
+	}
+
+	c.items[key] = c.ll.PushFront(&etagEntry{key: key, etag: etag, body: body})
+	for c.ll.Len() > c.size {
+		oldest := c.ll.Back()
+		c.ll.Remove(oldest)
+		delete(c.items, oldest.Value.(*etagEntry).key)
+	}
+}
+
+func (c *etagCache) remove(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.items[key]; ok {
+		c.ll.Remove(elem)
+		delete(c.items, key)
+	}
+}
+
+// sendGet sends a GET request to Kibana. If the ETag cache is enabled and
+// holds a response for the same URL, the request is made conditional.
+func (client *Client) sendGet(ctx context.Context, extraPath string, params url.Values) (*http.Response, error) {
+	var headers http.Header
+	if client.etags != nil {
+		if key, err := etagCacheKey(addToURL(client.Connection.URL, extraPath, params)); err == nil {
+			if entry, ok := client.etags.get(key); ok {
+				headers = http.Header{"If-None-Match": []string{entry.etag}}
+			}
+		}
+	}
+
+	return client.Connection.SendWithContext(ctx, http.MethodGet, extraPath, params, headers, nil)
+}
+
+// readCachedJSONResponse works like readJSONResponse, but serves 304 Not
+// Modified responses from the ETag cache and records the body of responses
+// carrying an ETag. Cached bodies are decoded again for every call, see
+// WithETagCache.
+func (client *Client) readCachedJSONResponse(r *http.Response, v any) error {
+	if client.etags == nil || r.Request == nil {
+		return client.readJSONResponse(r, v)
+	}
+
+	key, err := etagCacheKey(r.Request.URL.String())
+	if err != nil {
+		return client.readJSONResponse(r, v)
+	}
+
+	if r.StatusCode == http.StatusNotModified {
+		if entry, ok := client.etags.get(key); ok {
+			return client.decodeJSON(entry.body, v)
+		}
+	}
+
+	b, err := client.readJSONBody(r)
+	if err == nil {
+		err = client.decodeJSON(b, v)
+	}
+	if err != nil {
+		client.etags.remove(key)
+		return err
+	}
+
+	if etag := r.Header.Get("ETag"); etag != "" {
+		client.etags.put(key, etag, b)
+	} else {
+		client.etags.remove(key)
+	}
+	return nil
+}
+
+func etagCacheKey(rawURL string) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+	return u.RequestURI(), nil
+}
@@ -0,0 +1,135 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package kibana
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestETagCacheGetPolicy(t *testing.T) {
+	const id = "elastic-agent-managed-ep"
+
+	ctx, cn := context.WithCancel(context.Background())
+	defer cn()
+
+	var ifNoneMatch []string
+	etag := `"v1"`
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case fmt.Sprintf(fleetAgentPolicyAPI, id):
+			ifNoneMatch = append(ifNoneMatch, r.Header.Get("If-None-Match"))
+			if r.Header.Get("If-None-Match") == etag {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			w.Header().Set("ETag", etag)
+			_, _ = w.Write(fleetGetPolicyResponse)
+		}
+	}
+
+	client, err := createTestServerAndClient(handler, WithETagCache(10))
+	require.NoError(t, err)
+
+	first, err := client.GetPolicy(ctx, id)
+	require.NoError(t, err)
+	require.Equal(t, id, first.ID)
+
+	second, err := client.GetPolicy(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, first, second)
+
+	// The resource changed, the new version must replace the cached one.
+	etag = `"v2"`
+	third, err := client.GetPolicy(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, first, third)
+
+	assert.Equal(t, []string{"", `"v1"`, `"v1"`}, ifNoneMatch)
+	entry, ok := client.etags.get(fmt.Sprintf(fleetAgentPolicyAPI, id))
+	require.True(t, ok)
+	assert.Equal(t, `"v2"`, entry.etag)
+}
+
+func TestETagCacheDecodesPerCall(t *testing.T) {
+	const path = "/api/fleet/agent_policies/policy-id"
+
+	ctx, cn := context.WithCancel(context.Background())
+	defer cn()
+
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		_, _ = w.Write([]byte(`{"item":{"id":"policy-id","name":"test"}}`))
+	}
+
+	client, err := createTestServerAndClient(handler, WithETagCache(10))
+	require.NoError(t, err)
+
+	get := func(v any) {
+		t.Helper()
+		resp, err := client.sendGet(ctx, path, nil)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.NoError(t, client.readCachedJSONResponse(resp, v))
+	}
+
+	var typed struct {
+		Item struct {
+			ID string `json:"id"`
+		} `json:"item"`
+	}
+	get(&typed)
+	assert.Equal(t, "policy-id", typed.Item.ID)
+
+	// A cached response can be decoded into another type.
+	var generic map[string]interface{}
+	get(&generic)
+	require.Contains(t, generic, "item")
+	generic["item"].(map[string]interface{})["name"] = "modified"
+
+	var again map[string]interface{}
+	get(&again)
+	assert.Equal(t, "test", again["item"].(map[string]interface{})["name"], "callers must not share the cached value")
+}
+
+func TestETagCacheEviction(t *testing.T) {
+	c := newETagCache(2)
+	c.put("a", "1", []byte("1"))
+	c.put("b", "2", []byte("2"))
+
+	// Touch a so b becomes the least recently used entry.
+	_, ok := c.get("a")
+	require.True(t, ok)
+
+	c.put("c", "3", []byte("3"))
+	_, ok = c.get("b")
+	assert.False(t, ok)
+	_, ok = c.get("a")
+	assert.True(t, ok)
+	_, ok = c.get("c")
+	assert.True(t, ok)
+}
@@ -0,0 +1,274 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package kibana
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// fleetAgentEventsAPI is not part of the documented Fleet API, see
+	// StreamAgentEventsRequest.Path.
+	fleetAgentEventsAPI = "/api/fleet/agents/events"
+
+	defaultEventsReconnectDelay = time.Second
+	maxEventsReconnectDelay     = 30 * time.Second
+)
+
+// StreamAgentEventsRequest selects the agent events to stream
+type StreamAgentEventsRequest struct {
+	// Kuery filters the agents whose events are streamed
+	Kuery string
+	// LastEventID resumes the stream after the event with this ID
+	LastEventID string
+	// Path of the streaming endpoint, /api/fleet/agents/events if not set.
+	// Fleet does not document a streaming agent events endpoint, the path
+	// must match the endpoint exposed by the Kibana deployment.
+	Path string
+}
+
+// AgentEvent is an agent status change sent by Kibana
+type AgentEvent struct {
+	// ID is the server-sent event ID, used to resume the stream
+	ID string `json:"-"`
+	// Type is the server-sent event type, "message" if not set
+	Type      string    `json:"-"`
+	AgentID   string    `json:"agent_id"`
+	Status    string    `json:"status"`
+	Message   string    `json:"message,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+	// Raw is the undecoded event data
+	Raw json.RawMessage `json:"-"`
+}
+
+// errStreamDropped is returned by readAgentEvents if the connection was lost.
+var errStreamDropped = errors.New("agent event stream dropped")
+
+// StreamAgentEvents streams agent status changes from the Kibana server-sent
+// events endpoint and calls fn for every event, in order. The stream is
+// reconnected with backoff if the connection drops or fails with a transient
+// error, resuming after the last event received. It returns when ctx is
+// done, when Kibana ends the stream with 204 No Content, or with the first
+// error returned by fn.
+//
+// The stream is not limited by the timeout of the HTTP client, which only
+// bounds connecting and waiting for the response headers.
+//
+// The endpoint is not provided by all Kibana versions, a 404 is returned as an
+// *APIError.
+func (client *Client) StreamAgentEvents(ctx context.Context, request StreamAgentEventsRequest, fn func(AgentEvent) error) error {
+	lastID := request.LastEventID
+	delay := defaultEventsReconnectDelay
+	failures := 0
+
+	for {
+		received, err := client.streamAgentEventsOnce(ctx, request, &lastID, &delay, fn)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		var cbErr *callbackError
+		if errors.As(err, &cbErr) {
+			return cbErr.err
+		}
+		if err == nil {
+			// 204 No Content, the server asks to stop.
+			return nil
+		}
+		if !isTransientStreamError(err) {
+			return err
+		}
+
+		if received {
+			failures = 0
+		}
+		wait := delay << failures
+		if wait > maxEventsReconnectDelay || wait <= 0 {
+			wait = maxEventsReconnectDelay
+		}
+		failures++
+		client.log.Debugw("Reconnecting to the agent event stream", "error", err, "delay", wait)
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+type streamingKey struct{}
+
+// withStreaming marks the requests sent with ctx as streams, they are sent
+// without the overall timeout of the HTTP client.
+func withStreaming(ctx context.Context) context.Context {
+	return context.WithValue(ctx, streamingKey{}, true)
+}
+
+func isStreaming(ctx context.Context) bool {
+	streaming, _ := ctx.Value(streamingKey{}).(bool)
+	return streaming
+}
+
+// callbackError wraps errors returned by the event callback, they end the
+// stream.
+type callbackError struct {
+	err error
+}
+
+func (e *callbackError) Error() string { return e.err.Error() }
+
+// isTransientStreamError returns true for connection errors, dropped streams
+// and 429 or 5xx responses.
+func isTransientStreamError(err error) bool {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return isRetryableStatus(apiErr.StatusCode) || apiErr.StatusCode >= http.StatusInternalServerError
+	}
+	var urlErr *url.Error
+	return errors.Is(err, errStreamDropped) || errors.As(err, &urlErr)
+}
+
+// streamAgentEventsOnce opens the stream and reads events until it ends. It
+// updates lastID and the reconnect delay requested by the server, and reports
+// whether any event was received.
+func (client *Client) streamAgentEventsOnce(ctx context.Context, request StreamAgentEventsRequest, lastID *string, delay *time.Duration, fn func(AgentEvent) error) (received bool, err error) {
+	path := request.Path
+	if path == "" {
+		path = fleetAgentEventsAPI
+	}
+	q := make(url.Values)
+	if request.Kuery != "" {
+		q.Add("kuery", request.Kuery)
+	}
+	headers := http.Header{
+		"Accept":        []string{"text/event-stream"},
+		"Cache-Control": []string{"no-cache"},
+	}
+	if *lastID != "" {
+		headers.Set("Last-Event-ID", *lastID)
+	}
+
+	// The client timeout would cut the stream, it only bounds waiting for
+	// the response headers.
+	ctx, cancel := context.WithCancel(withStreaming(ctx))
+	defer cancel()
+	var headerTimer *time.Timer
+	if timeout := client.Connection.HTTP.Timeout; timeout > 0 {
+		headerTimer = time.AfterFunc(timeout, cancel)
+	}
+	resp, err := client.Connection.SendWithContext(ctx, http.MethodGet, path, q, headers, nil)
+	if headerTimer != nil {
+		headerTimer.Stop()
+	}
+	if err != nil {
+		return false, fmt.Errorf("error calling agent events API: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNoContent:
+		return false, nil
+	default:
+		b, _ := readBody(resp)
+		return false, newAPIError(client.codec(), resp.StatusCode, b)
+	}
+
+	return client.readAgentEvents(resp.Body, lastID, delay, fn)
+}
+
+// readAgentEvents decodes server-sent events from r, see
+// https://html.spec.whatwg.org/multipage/server-sent-events.html#event-stream-interpretation
+func (client *Client) readAgentEvents(r io.Reader, lastID *string, delay *time.Duration, fn func(AgentEvent) error) (received bool, err error) {
+	reader := bufio.NewReader(r)
+	var (
+		eventType string
+		data      strings.Builder
+		hasData   bool
+		// id is only committed to lastID when the event is dispatched, a
+		// partially received event must be sent again after reconnecting.
+		id = *lastID
+	)
+
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			// An incomplete event at the end of the stream is discarded.
+			return received, fmt.Errorf("%w: %w", errStreamDropped, err)
+		}
+		line = strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r")
+
+		if line == "" {
+			// A blank line dispatches the event.
+			*lastID = id
+			if hasData {
+				event := AgentEvent{ID: id, Type: eventType, Raw: json.RawMessage(data.String())}
+				if event.Type == "" {
+					event.Type = "message"
+				}
+				if err := client.codec().Unmarshal(event.Raw, &event); err != nil {
+					return received, fmt.Errorf("decoding agent event %q: %w", event.ID, err)
+				}
+				received = true
+				if err := fn(event); err != nil {
+					return received, &callbackError{err: err}
+				}
+			}
+			eventType, hasData = "", false
+			data.Reset()
+			continue
+		}
+		if strings.HasPrefix(line, ":") {
+			// Comment, used as keep-alive.
+			continue
+		}
+
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "event":
+			eventType = value
+		case "data":
+			if hasData {
+				data.WriteByte('\n')
+			}
+			data.WriteString(value)
+			hasData = true
+		case "id":
+			if !strings.ContainsRune(value, 0) {
+				id = value
+			}
+		case "retry":
+			if ms, err := strconv.Atoi(value); err == nil && ms >= 0 {
+				*delay = time.Duration(ms) * time.Millisecond
+			}
+		}
+	}
+}
@@ -0,0 +1,168 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package kibana
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStreamAgentEvents(t *testing.T) {
+	var lastEventIDs []string
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != fleetAgentEventsAPI {
+			return
+		}
+		assert.Equal(t, "text/event-stream", r.Header.Get("Accept"))
+		lastEventIDs = append(lastEventIDs, r.Header.Get("Last-Event-ID"))
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		switch r.Header.Get("Last-Event-ID") {
+		case "":
+			// Two events, then the connection drops in the middle of the
+			// third one.
+			fmt.Fprint(w, "retry: 10\n\n")
+			fmt.Fprint(w, ": keep-alive\n\n")
+			fmt.Fprint(w, "id: 1\nevent: status\ndata: {\"agent_id\":\"agent-1\",\"status\":\"online\"}\n\n")
+			fmt.Fprint(w, "id: 2\nevent: status\ndata: {\"agent_id\":\"agent-2\",\n")
+			fmt.Fprint(w, "data: \"status\":\"updating\"}\n\n")
+			fmt.Fprint(w, "id: 3\ndata: {\"agent_id\":")
+		case "2":
+			fmt.Fprint(w, "id: 3\r\ndata: {\"agent_id\":\"agent-1\",\"status\":\"offline\"}\r\n\r\n")
+		default:
+			// No more events.
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}
+
+	client, err := createTestServerAndClient(handler)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var events []AgentEvent
+	err = client.StreamAgentEvents(ctx, StreamAgentEventsRequest{}, func(e AgentEvent) error {
+		events = append(events, e)
+		return nil
+	})
+	require.NoError(t, err)
+
+	require.Len(t, events, 3)
+	for i, want := range []struct{ id, typ, agent, status string }{
+		{"1", "status", "agent-1", "online"},
+		{"2", "status", "agent-2", "updating"},
+		{"3", "message", "agent-1", "offline"},
+	} {
+		assert.Equal(t, want.id, events[i].ID)
+		assert.Equal(t, want.typ, events[i].Type)
+		assert.Equal(t, want.agent, events[i].AgentID)
+		assert.Equal(t, want.status, events[i].Status)
+	}
+	assert.Equal(t, []string{"", "2", "3"}, lastEventIDs, "the stream must resume after the last event")
+}
+
+func TestStreamAgentEventsClientTimeout(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/custom/events" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if r.Header.Get("Last-Event-ID") != "" {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		// The events are sent slower than the client timeout.
+		for i := 1; i <= 3; i++ {
+			fmt.Fprintf(w, "id: %d\ndata: {\"agent_id\":\"agent-%d\"}\n\n", i, i)
+			w.(http.Flusher).Flush()
+			time.Sleep(60 * time.Millisecond)
+		}
+	}
+
+	client, err := createTestServerAndClient(handler)
+	require.NoError(t, err)
+	client.Connection.HTTP.Timeout = 100 * time.Millisecond
+
+	var ids []string
+	err = client.StreamAgentEvents(context.Background(), StreamAgentEventsRequest{Path: "/api/custom/events"}, func(e AgentEvent) error {
+		ids = append(ids, e.ID)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"1", "2", "3"}, ids)
+}
+
+func TestStreamAgentEventsStops(t *testing.T) {
+	t.Run("callback error", func(t *testing.T) {
+		client, err := createTestServerAndClient(func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, "data: {\"agent_id\":\"agent-1\"}\n\ndata: {\"agent_id\":\"agent-2\"}\n\n")
+		})
+		require.NoError(t, err)
+
+		stop := errors.New("stop")
+		calls := 0
+		err = client.StreamAgentEvents(context.Background(), StreamAgentEventsRequest{}, func(AgentEvent) error {
+			calls++
+			return stop
+		})
+		assert.ErrorIs(t, err, stop)
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		client, err := createTestServerAndClient(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNotFound)
+		})
+		require.NoError(t, err)
+
+		err = client.StreamAgentEvents(context.Background(), StreamAgentEventsRequest{}, func(AgentEvent) error { return nil })
+		assert.True(t, IsNotFound(err))
+	})
+
+	t.Run("context cancelled", func(t *testing.T) {
+		client, err := createTestServerAndClient(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		})
+		require.NoError(t, err)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		err = client.StreamAgentEvents(ctx, StreamAgentEventsRequest{}, func(AgentEvent) error { return nil })
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+
+	t.Run("malformed event", func(t *testing.T) {
+		client, err := createTestServerAndClient(func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, "data: not json\n\n")
+		})
+		require.NoError(t, err)
+
+		err = client.StreamAgentEvents(context.Background(), StreamAgentEventsRequest{}, func(AgentEvent) error { return nil })
+		assert.ErrorContains(t, err, "decoding agent event")
+		assert.False(t, strings.Contains(err.Error(), "dropped"))
+	})
+}
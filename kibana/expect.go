@@ -0,0 +1,57 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package kibana
+
+import (
+	"net/http"
+	"time"
+)
+
+// defaultExpectContinueTimeout is used by WithExpectContinue if no timeout is
+// given.
+const defaultExpectContinueTimeout = time.Second
+
+// WithExpectContinue sends requests with a body larger than threshold bytes,
+// or of unknown size, with an `Expect: 100-continue` header. The body is only
+// sent once Kibana accepted the request, so a request rejected early, e.g.
+// with 413 Request Entity Too Large or 401 Unauthorized, does not upload the
+// body. Servers that do not answer with 100 Continue receive the body after
+// timeout. A timeout <= 0 uses the default of one second.
+func WithExpectContinue(threshold int64, timeout time.Duration) ClientOption {
+	return func(client *Client) {
+		if timeout <= 0 {
+			timeout = defaultExpectContinueTimeout
+		}
+		client.Connection.expectContinueThreshold = threshold
+		if client.Connection.transport != nil {
+			client.Connection.transport.ExpectContinueTimeout = timeout
+		}
+	}
+}
+
+// setExpectContinue sets the Expect header on requests with a body larger
+// than the threshold.
+func (conn *Connection) setExpectContinue(req *http.Request) {
+	if conn.expectContinueThreshold <= 0 || req.Body == nil || req.Body == http.NoBody {
+		return
+	}
+	// NewRequest sets the length for in-memory bodies, it is 0 if unknown.
+	if req.ContentLength > conn.expectContinueThreshold || req.ContentLength == 0 {
+		req.Header.Set("Expect", "100-continue")
+	}
+}
@@ -0,0 +1,101 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package kibana
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingReader is a body of unknown size that counts the bytes read.
+type countingReader struct {
+	r io.Reader
+	n atomic.Int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n.Add(int64(n))
+	return n, err
+}
+
+func TestExpectContinue(t *testing.T) {
+	const size = 8 << 20
+
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/reject":
+			// Reject without reading the body.
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+		case "/api/accept":
+			n, _ := io.Copy(io.Discard, r.Body)
+			assert.EqualValues(t, size, n)
+		}
+	}
+
+	client, err := createTestServerAndClient(handler, WithExpectContinue(1024, 5*time.Second))
+	require.NoError(t, err)
+
+	t.Run("rejected before upload", func(t *testing.T) {
+		body := &countingReader{r: bytes.NewReader(make([]byte, size))}
+		resp, err := client.Connection.SendWithContext(context.Background(), http.MethodPost, "/api/reject", nil, nil, body)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusRequestEntityTooLarge, resp.StatusCode)
+		assert.Less(t, body.n.Load(), int64(size), "the body must not be uploaded")
+	})
+
+	t.Run("accepted", func(t *testing.T) {
+		start := time.Now()
+		resp, err := client.Connection.SendWithContext(context.Background(), http.MethodPost, "/api/accept", nil, nil, bytes.NewReader(make([]byte, size)))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Less(t, time.Since(start), 5*time.Second, "the body must be sent on 100 Continue, not after the timeout")
+	})
+}
+
+func TestExpectContinueThreshold(t *testing.T) {
+	conn := Connection{expectContinueThreshold: 10}
+	for name, tc := range map[string]struct {
+		body   io.Reader
+		expect bool
+	}{
+		"no body":      {body: nil, expect: false},
+		"small body":   {body: bytes.NewReader(make([]byte, 10)), expect: false},
+		"large body":   {body: bytes.NewReader(make([]byte, 11)), expect: true},
+		"unknown size": {body: &countingReader{r: bytes.NewReader(nil)}, expect: true},
+	} {
+		t.Run(name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodPost, "http://localhost", tc.body)
+			require.NoError(t, err)
+			conn.setExpectContinue(req)
+			assert.Equal(t, tc.expect, req.Header.Get("Expect") == "100-continue")
+		})
+	}
+}
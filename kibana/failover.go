@@ -0,0 +1,156 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package kibana
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+const (
+	// hostMaxFailures is the number of consecutive failures after which a
+	// host is considered unhealthy.
+	hostMaxFailures = 3
+	// hostCooldown is the time an unhealthy host is skipped.
+	hostCooldown = 30 * time.Second
+)
+
+// hostPool tracks the health of the configured Kibana hosts.
+type hostPool struct {
+	mu    sync.Mutex
+	hosts []*hostState
+	now   func() time.Time
+}
+
+type hostState struct {
+	url            string
+	failures       int
+	unhealthyUntil time.Time
+}
+
+func newHostPool(urls []string) *hostPool {
+	p := &hostPool{now: time.Now}
+	for _, u := range urls {
+		p.hosts = append(p.hosts, &hostState{url: u})
+	}
+	return p
+}
+
+// order returns the host URLs in the order they should be tried: healthy
+// hosts in configuration order, followed by the hosts in cooldown.
+func (p *hostPool) order() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := p.now()
+	healthy := make([]string, 0, len(p.hosts))
+	var cooling []string
+	for _, h := range p.hosts {
+		if now.Before(h.unhealthyUntil) {
+			cooling = append(cooling, h.url)
+		} else {
+			healthy = append(healthy, h.url)
+		}
+	}
+	return append(healthy, cooling...)
+}
+
+func (p *hostPool) success(url string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if h := p.find(url); h != nil {
+		h.failures = 0
+		h.unhealthyUntil = time.Time{}
+	}
+}
+
+func (p *hostPool) failure(url string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if h := p.find(url); h != nil {
+		h.failures++
+		if h.failures >= hostMaxFailures {
+			h.unhealthyUntil = p.now().Add(hostCooldown)
+		}
+	}
+}
+
+func (p *hostPool) find(url string) *hostState {
+	for _, h := range p.hosts {
+		if h.url == url {
+			return h
+		}
+	}
+	return nil
+}
+
+// sendWithFailover tries the hosts in order until one returns a response that
+// is not a 5xx. Requests with a method that changes state, like POST, are only
+// sent to the next host if the connection to the host failed, as a host that
+// received the request may have processed it. The response or error of the
+// last host tried is returned if all fail.
+func (conn *Connection) sendWithFailover(ctx context.Context, method, extraPath string,
+	params url.Values, headers http.Header, payload []byte) (*http.Response, error) {
+
+	hosts := conn.hosts.order()
+	for i, host := range hosts {
+		resp, err := conn.sendTo(ctx, host, method, extraPath, params, headers, payloadReader(payload))
+		if err == nil && resp.StatusCode < http.StatusInternalServerError {
+			conn.hosts.success(host)
+			return resp, nil
+		}
+		if ctx.Err() != nil {
+			return resp, err
+		}
+
+		conn.hosts.failure(host)
+		if i == len(hosts)-1 || !(isSafeMethod(method) || isDialError(err)) {
+			return resp, err
+		}
+		if resp != nil {
+			_, _ = io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+	}
+	return nil, fmt.Errorf("no Kibana host configured")
+}
+
+// isSafeMethod returns true for the methods that do not change state on the
+// server, see RFC 9110 section 9.2.1. Such requests can be sent again.
+func isSafeMethod(method string) bool {
+	switch method {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return true
+	default:
+		return false
+	}
+}
+
+// isDialError returns true if err occurred while connecting to the host,
+// before the request was sent.
+func isDialError(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
@@ -0,0 +1,191 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package kibana
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+
+	"github.com/elastic/elastic-agent-libs/config"
+)
+
+func TestFailoverToNextHost(t *testing.T) {
+	ctx, cn := context.WithCancel(context.Background())
+	defer cn()
+
+	var failing atomic.Int64
+	unavailable := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		failing.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer unavailable.Close()
+
+	var created atomic.Int64
+	healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case statusAPI:
+			_, _ = w.Write([]byte(`{"version":{"number":"1.2.3-beta","build_snapshot":true}}`))
+		case fleetAgentPoliciesAPI:
+			created.Add(1)
+			_, _ = w.Write(fleetCreatePolicyResponse)
+		default:
+			w.Header().Set("ETag", `"v1"`)
+			if r.Header.Get("If-None-Match") == `"v1"` {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			_, _ = w.Write(fleetGetPolicyResponse)
+		}
+	}))
+	defer healthy.Close()
+
+	client, err := NewKibanaClient(config.MustNewConfigFrom(fmt.Sprintf(`
+protocol: http
+host: [%s, %s]
+`, unavailable.Listener.Addr().String(), healthy.Listener.Addr().String())), binaryName, v, commit, buildTime, WithETagCache(10))
+	require.NoError(t, err, "the status check must succeed via the second host")
+	assert.Equal(t, "1.2.3-beta-SNAPSHOT", client.Version.String())
+
+	// A create may have been processed by a host that answered with a 5xx,
+	// it is not sent to the next host.
+	_, err = client.CreatePolicy(ctx, AgentPolicy{Name: "test"})
+	assert.True(t, IsServerError(err))
+	assert.Zero(t, created.Load())
+
+	for i := 1; i < hostMaxFailures; i++ {
+		_, err = client.GetPolicy(ctx, "policy-id")
+		require.NoError(t, err)
+	}
+
+	// The first host is in cooldown after repeated failures and not tried anymore.
+	tried := failing.Load()
+	assert.Equal(t, int64(hostMaxFailures), tried)
+	_, err = client.CreatePolicy(ctx, AgentPolicy{Name: "test"})
+	require.NoError(t, err)
+	assert.Equal(t, tried, failing.Load())
+	assert.EqualValues(t, 1, created.Load())
+
+	_, ok := client.etags.get(fmt.Sprintf(fleetAgentPolicyAPI, "policy-id"))
+	assert.True(t, ok, "responses of the host failed over to must be cached")
+}
+
+func TestFailoverOnConnectionError(t *testing.T) {
+	ctx, cn := context.WithCancel(context.Background())
+	defer cn()
+
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+
+	var bodies []string
+	healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body [64]byte
+		n, _ := r.Body.Read(body[:])
+		bodies = append(bodies, string(body[:n]))
+		_, _ = w.Write(fleetCreatePolicyResponse)
+	}))
+	defer healthy.Close()
+
+	client, err := NewKibanaClient(config.MustNewConfigFrom(fmt.Sprintf(`
+protocol: http
+host: [%s, %s]
+ignoreversion: true
+`, down.Listener.Addr().String(), healthy.Listener.Addr().String())), binaryName, v, commit, buildTime)
+	require.NoError(t, err)
+
+	_, err = client.CreatePolicy(ctx, AgentPolicy{Name: "test"})
+	require.NoError(t, err, "a create that could not connect is sent to the next host")
+	require.Len(t, bodies, 1)
+	assert.Contains(t, bodies[0], `"name":"test"`, "the body must be replayed to the next host")
+}
+
+func TestClientConfigHostList(t *testing.T) {
+	cfg := DefaultClientConfig()
+	require.NoError(t, config.MustNewConfigFrom(`
+host: [kibana1:5601, kibana2:5601]
+space.id: ops
+`).Unpack(&cfg))
+	assert.Equal(t, []string{"kibana1:5601", "kibana2:5601"}, cfg.Hosts)
+	assert.Equal(t, "kibana1:5601", cfg.Host)
+	assert.Equal(t, "ops", cfg.SpaceID)
+
+	cfg = DefaultClientConfig()
+	require.NoError(t, config.MustNewConfigFrom(`host: kibana:5601`).Unpack(&cfg))
+	assert.Empty(t, cfg.Hosts)
+	assert.Equal(t, "kibana:5601", cfg.Host)
+
+	cfg = DefaultClientConfig()
+	require.Error(t, config.MustNewConfigFrom(`host: []`).Unpack(&cfg))
+}
+
+func TestClientConfigUnpackEmpty(t *testing.T) {
+	for name, in := range map[string]string{
+		"empty":      ``,
+		"empty dict": `{}`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			cfg := DefaultClientConfig()
+			require.NoError(t, config.MustNewConfigFrom(in).Unpack(&cfg))
+			assert.Equal(t, DefaultClientConfig(), cfg)
+		})
+	}
+
+	t.Run("empty kibana section", func(t *testing.T) {
+		settings := struct {
+			Kibana ClientConfig `config:"kibana"`
+		}{Kibana: DefaultClientConfig()}
+		require.NoError(t, config.MustNewConfigFrom(`kibana: {}`).Unpack(&settings))
+		assert.Equal(t, DefaultClientConfig(), settings.Kibana)
+	})
+}
+
+func TestClientConfigMarshalHosts(t *testing.T) {
+	cfg := DefaultClientConfig()
+	cfg.Hosts = []string{"kibana1:5601", "kibana2:5601"}
+	out, err := yaml.Marshal(cfg)
+	require.NoError(t, err)
+	assert.Contains(t, string(out), "hosts:\n- kibana1:5601\n- kibana2:5601\n")
+}
+
+func TestHostPool(t *testing.T) {
+	now := time.Now()
+	p := newHostPool([]string{"http://a", "http://b"})
+	p.now = func() time.Time { return now }
+
+	for i := 0; i < hostMaxFailures; i++ {
+		assert.Equal(t, []string{"http://a", "http://b"}, p.order())
+		p.failure("http://a")
+	}
+	assert.Equal(t, []string{"http://b", "http://a"}, p.order(), "unhealthy hosts are tried last")
+
+	now = now.Add(hostCooldown)
+	assert.Equal(t, []string{"http://a", "http://b"}, p.order(), "hosts are retried after the cooldown")
+
+	p.failure("http://a")
+	assert.Equal(t, []string{"http://b", "http://a"}, p.order())
+	p.success("http://a")
+	assert.Equal(t, []string{"http://a", "http://b"}, p.order(), "a success resets the health")
+}
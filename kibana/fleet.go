@@ -21,29 +21,61 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/elastic/elastic-agent-libs/mapstr"
 	"github.com/elastic/elastic-agent-libs/upgrade/details"
 )
 
 const (
 	fleetAgentAPI                = "/api/fleet/agents/%s"
+	fleetAgentActionsAPI         = "/api/fleet/agents/%s/actions"
+	fleetAgentActionCancelAPI    = "/api/fleet/agents/actions/%s/cancel"
+	fleetAgentActionStatusAPI    = "/api/fleet/agents/action_status"
+	fleetAgentDiagnosticsAPI     = "/api/fleet/agents/%s/request_diagnostics"
+	fleetAgentFileAPI            = "/api/fleet/agents/files/%s/%s"
+	fleetAgentUploadsAPI         = "/api/fleet/agents/%s/uploads"
 	fleetAgentPoliciesAPI        = "/api/fleet/agent_policies"
 	fleetAgentPolicyAPI          = "/api/fleet/agent_policies/%s"
+	fleetAgentPolicyCopyAPI      = "/api/fleet/agent_policies/%s/copy"
+	fleetAgentPolicyDownloadAPI  = "/api/fleet/agent_policies/%s/download"
+	fleetAgentPolicyFullAPI      = "/api/fleet/agent_policies/%s/full"
+	fleetAgentStatusAPI          = "/api/fleet/agent_status"
 	fleetAgentsAPI               = "/api/fleet/agents"
+	fleetAgentsBulkReassignAPI   = "/api/fleet/agents/bulk_reassign"
+	fleetAgentsBulkUnenrollAPI   = "/api/fleet/agents/bulk_unenroll"
+	fleetAgentsBulkUpdateTagsAPI = "/api/fleet/agents/bulk_update_agent_tags"
+	fleetAgentsBulkUpgradeAPI    = "/api/fleet/agents/bulk_upgrade"
 	fleetAgentsDeleteAPI         = "/api/fleet/agent_policies/delete"
 	fleetEnrollmentAPIKeysAPI    = "/api/fleet/enrollment_api_keys" //nolint:gosec // no API key being leaked here
+	fleetEPMPackagesAPI          = "/api/fleet/epm/packages"
 	fleetFleetServerHostAPI      = "/api/fleet/fleet_server_hosts/%s"
 	fleetFleetServerHostsAPI     = "/api/fleet/fleet_server_hosts"
+	fleetOutputAPI               = "/api/fleet/outputs/%s"
+	fleetOutputHealthAPI         = "/api/fleet/outputs/%s/health"
+	fleetOutputsAPI              = "/api/fleet/outputs"
 	fleetPackagePoliciesAPI      = "/api/fleet/package_policies"
+	fleetPackagePolicyAPI        = "/api/fleet/package_policies/%s"
+	fleetProxiesAPI              = "/api/fleet/proxies"
+	fleetProxyAPI                = "/api/fleet/proxies/%s"
+	fleetSettingsAPI             = "/api/fleet/settings"
+	fleetSetupAPI                = "/api/fleet/setup"
+	fleetAgentsSetupAPI          = "/api/fleet/agents/setup"
 	fleetUnEnrollAgentAPI        = "/api/fleet/agents/%s/unenroll"
 	fleetUninstallTokensAPI      = "/api/fleet/uninstall_tokens" //nolint:gosec // NOT the "Potential hardcoded credentials"
+	fleetReassignAgentAPI        = "/api/fleet/agents/%s/reassign"
 	fleetUpgradeAgentAPI         = "/api/fleet/agents/%s/upgrade"
 	fleetAgentDownloadSourcesAPI = "/api/fleet/agent_download_sources"
+	fleetAgentDownloadSourceAPI  = "/api/fleet/agent_download_sources/%s"
 )
 
 //
@@ -111,6 +143,12 @@ type AgentPolicyUpdateRequest struct {
 	InactivityTImeout  int                       `json:"inactivity_timeout,omitempty"`
 	AgentFeatures      []map[string]interface{}  `json:"agent_features,omitempty"`
 	IsProtected        *bool                     `json:"is_protected,omitempty"` // Optional bool for compatibility with the older pre 8.9.0 stack
+	// ExpectedRevision is the revision of the policy the update is based on, as
+	// returned by GetPolicy. If set, the policy is read before the update, which
+	// fails with ErrConcurrentModification if the revision differs. The check
+	// is best effort: Fleet has no conditional update of agent policies, a
+	// change made between the read and the update is overwritten.
+	ExpectedRevision int `json:"-"`
 }
 
 // Constant booleans for convenience for dealing with *bool
@@ -128,7 +166,7 @@ type policyResp struct {
 
 // CreatePolicy creates a new agent policy with the given config
 func (client *Client) CreatePolicy(ctx context.Context, request AgentPolicy) (r PolicyResponse, err error) {
-	reqBody, err := json.Marshal(request)
+	reqBody, err := client.codec().Marshal(request)
 	if err != nil {
 		return r, fmt.Errorf("unable to marshal create policy request into JSON: %w", err)
 	}
@@ -139,7 +177,7 @@ func (client *Client) CreatePolicy(ctx context.Context, request AgentPolicy) (r
 	}
 	defer resp.Body.Close()
 	var polResp policyResp
-	err = readJSONResponse(resp, &polResp)
+	err = client.readJSONResponse(resp, &polResp)
 	return polResp.Item, err
 }
 
@@ -150,18 +188,92 @@ type DownloadSource struct {
 	ProxyID   interface{} `json:"proxy_id"`
 }
 
+// DownloadSourceItem is an agent binary download source as returned by Fleet
+type DownloadSourceItem struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Host      string `json:"host"`
+	IsDefault bool   `json:"is_default"`
+	ProxyID   string `json:"proxy_id"`
+}
+
 type DownloadSourceResponse struct {
-	Item struct {
-		ID        string `json:"id"`
-		Name      string `json:"name"`
-		Host      string `json:"host"`
-		IsDefault bool   `json:"is_default"`
-		ProxyID   string `json:"proxy_id"`
-	} `json:"item"`
+	Item DownloadSourceItem `json:"item"`
+}
+
+// ListDownloadSourcesResponse is the JSON response for ListDownloadSources
+type ListDownloadSourcesResponse struct {
+	Items   []DownloadSourceItem `json:"items"`
+	Total   int                  `json:"total"`
+	Page    int                  `json:"page"`
+	PerPage int                  `json:"perPage"`
+}
+
+// ListDownloadSources returns the agent binary download sources
+func (client *Client) ListDownloadSources(ctx context.Context) (r ListDownloadSourcesResponse, err error) {
+	if err := client.requireVersion("ListDownloadSources", minVersionDownloadSources); err != nil {
+		return r, err
+	}
+
+	resp, err := client.sendGet(ctx, fleetAgentDownloadSourcesAPI, nil)
+	if err != nil {
+		return r, fmt.Errorf("error calling list download sources API: %w", err)
+	}
+	defer resp.Body.Close()
+
+	err = client.readCachedJSONResponse(resp, &r)
+	return r, err
+}
+
+// UpdateDownloadSource replaces the agent binary download source with the
+// given ID
+func (client *Client) UpdateDownloadSource(ctx context.Context, id string, source DownloadSource) (r DownloadSourceResponse, err error) {
+	if err := client.requireVersion("UpdateDownloadSource", minVersionDownloadSources); err != nil {
+		return r, err
+	}
+
+	reqBody, err := client.codec().Marshal(source)
+	if err != nil {
+		return r, fmt.Errorf("unable to marshal DownloadSource into JSON: %w", err)
+	}
+
+	apiURL := fmt.Sprintf(fleetAgentDownloadSourceAPI, id)
+	resp, err := client.Connection.SendWithContext(ctx, http.MethodPut, apiURL, nil, nil, bytes.NewReader(reqBody))
+	if err != nil {
+		return r, fmt.Errorf("error calling update download source API: %w", err)
+	}
+	defer resp.Body.Close()
+
+	err = client.readJSONResponse(resp, &r)
+	return r, err
+}
+
+// DeleteDownloadSource deletes the agent binary download source with the
+// given ID. Fleet refuses to delete the default source.
+func (client *Client) DeleteDownloadSource(ctx context.Context, id string) error {
+	if err := client.requireVersion("DeleteDownloadSource", minVersionDownloadSources); err != nil {
+		return err
+	}
+
+	apiURL := fmt.Sprintf(fleetAgentDownloadSourceAPI, id)
+	resp, err := client.Connection.SendWithContext(ctx, http.MethodDelete, apiURL, nil, nil, nil)
+	if err != nil {
+		return fmt.Errorf("error calling delete download source API: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var deleteResp struct {
+		ID string `json:"id"`
+	}
+	return client.readJSONResponse(resp, &deleteResp)
 }
 
 func (client *Client) CreateDownloadSource(ctx context.Context, source DownloadSource) (DownloadSourceResponse, error) {
-	reqBody, err := json.Marshal(source)
+	if err := client.requireVersion("CreateDownloadSource", minVersionDownloadSources); err != nil {
+		return DownloadSourceResponse{}, err
+	}
+
+	reqBody, err := client.codec().Marshal(source)
 	if err != nil {
 		return DownloadSourceResponse{},
 			fmt.Errorf("unable to marshal DownloadSource into JSON: %w", err)
@@ -181,23 +293,29 @@ func (client *Client) CreateDownloadSource(ctx context.Context, source DownloadS
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		var respBody string
-		if bs, err := io.ReadAll(resp.Body); err != nil {
-			respBody = "could not read response body"
-		} else {
-			respBody = string(bs)
+		bs, err := readBody(resp)
+		respBody := string(bs)
+		if err != nil {
+			respBody = "could not read response body: " + err.Error()
 		}
 
 		client.log.Errorw(
 			"could not create download source, kibana returned "+resp.Status,
 			"http.response.body.content", respBody)
+		apiErr := newAPIError(client.codec(), resp.StatusCode, bs)
+		apiErr.Err = fmt.Errorf("could not create download source, kibana returned %s. response body: %s",
+			resp.Status, respBody)
+		return DownloadSourceResponse{}, apiErr
+	}
+
+	respBody, err := readBody(resp)
+	if err != nil {
 		return DownloadSourceResponse{},
-			fmt.Errorf("could not create download source, kibana returned %s. response body: %s: %w",
-				resp.Status, respBody, err)
+			fmt.Errorf("failed reading download source response: %w", err)
 	}
 
 	body := DownloadSourceResponse{}
-	if err = json.NewDecoder(resp.Body).Decode(&body); err != nil {
+	if err = client.codec().Unmarshal(respBody, &body); err != nil {
 		return DownloadSourceResponse{},
 			fmt.Errorf("failed parsing download source response: %w", err)
 	}
@@ -208,23 +326,33 @@ func (client *Client) CreateDownloadSource(ctx context.Context, source DownloadS
 // GetPolicy returns the requested ID
 func (client *Client) GetPolicy(ctx context.Context, id string) (r PolicyResponse, err error) {
 	apiURL := fmt.Sprintf(fleetAgentPolicyAPI, id)
-	resp, err := client.Connection.SendWithContext(ctx, http.MethodGet, apiURL, nil, nil, nil)
+	resp, err := client.sendGet(ctx, apiURL, nil)
 	if err != nil {
 		return r, fmt.Errorf("error calling get policy API: %w", err)
 	}
 	defer resp.Body.Close()
 	var polResp policyResp
-	err = readJSONResponse(resp, &polResp)
+	err = client.readCachedJSONResponse(resp, &polResp)
 	return polResp.Item, err
 }
 
 // UpdatePolicy updates an existing agent policy.
 func (client *Client) UpdatePolicy(ctx context.Context, id string, request AgentPolicyUpdateRequest) (r PolicyResponse, err error) {
-	reqBody, err := json.Marshal(request)
+	reqBody, err := client.codec().Marshal(request)
 	if err != nil {
 		return r, fmt.Errorf("unable to marshal update policy request into JSON: %w", err)
 	}
 
+	if request.ExpectedRevision > 0 {
+		current, err := client.GetPolicy(ctx, id)
+		if err != nil {
+			return r, fmt.Errorf("error reading the revision of policy %s: %w", id, err)
+		}
+		if current.Revision != request.ExpectedRevision {
+			return r, fmt.Errorf("%w: policy %s is at revision %d, expected %d", ErrConcurrentModification, id, current.Revision, request.ExpectedRevision)
+		}
+	}
+
 	apiURL := fmt.Sprintf(fleetAgentPolicyAPI, id)
 	resp, err := client.Connection.SendWithContext(ctx, http.MethodPut, apiURL, nil, nil, bytes.NewReader(reqBody))
 	if err != nil {
@@ -232,7 +360,7 @@ func (client *Client) UpdatePolicy(ctx context.Context, id string, request Agent
 	}
 	defer resp.Body.Close()
 	var polResp policyResp
-	err = readJSONResponse(resp, &polResp)
+	err = client.readJSONResponse(resp, &polResp)
 	return polResp.Item, err
 }
 
@@ -244,7 +372,7 @@ func (client *Client) DeletePolicy(ctx context.Context, id string) error {
 		AgentPolicyID: id,
 	}
 
-	reqBody, err := json.Marshal(delRequest)
+	reqBody, err := client.codec().Marshal(delRequest)
 	if err != nil {
 		return fmt.Errorf("unable to marshal delete policy request into JSON: %w", err)
 	}
@@ -255,15 +383,299 @@ func (client *Client) DeletePolicy(ctx context.Context, id string) error {
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode != http.StatusOK {
-		respBody, err := io.ReadAll(resp.Body)
+		respBody, err := readBody(resp)
 		if err != nil {
 			return fmt.Errorf("unable to delete policy; API returned status code [%d] and error reading response: %w", resp.StatusCode, err)
 		}
-		return fmt.Errorf("unable to delete policy; API returned status code [%d] and body [%s]", resp.StatusCode, string(respBody))
+		apiErr := newAPIError(client.codec(), resp.StatusCode, respBody)
+		apiErr.Err = fmt.Errorf("unable to delete policy; API returned status code [%d] and body [%s]", resp.StatusCode, truncateString(respBody))
+		return apiErr
 	}
 	return nil
 }
 
+//
+// Copy Policy
+//
+
+// CopyPolicyRequest is the JSON request for copying an agent policy
+type CopyPolicyRequest struct {
+	// Name of the new policy, it must be unique.
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+}
+
+// CopyPolicy creates a new agent policy with the settings and integrations of
+// the policy with the given ID
+func (client *Client) CopyPolicy(ctx context.Context, id string, request CopyPolicyRequest) (r PolicyResponse, err error) {
+	reqBody, err := client.codec().Marshal(request)
+	if err != nil {
+		return r, fmt.Errorf("unable to marshal copy policy request into JSON: %w", err)
+	}
+
+	apiURL := fmt.Sprintf(fleetAgentPolicyCopyAPI, id)
+	resp, err := client.Connection.SendWithContext(ctx, http.MethodPost, apiURL, nil, nil, bytes.NewReader(reqBody))
+	if err != nil {
+		return r, fmt.Errorf("error calling copy policy API: %w", err)
+	}
+	defer resp.Body.Close()
+	var polResp policyResp
+	err = client.readJSONResponse(resp, &polResp)
+	return polResp.Item, err
+}
+
+//
+// Get Full Policy
+//
+
+// GetPolicyFullRequest selects the agent policy to render
+type GetPolicyFullRequest struct {
+	ID string
+	// Standalone renders the policy for an agent not managed by Fleet: the
+	// outputs include placeholders for credentials and the fleet section is
+	// left out.
+	Standalone bool
+	// Kubernetes renders the policy as a manifest to deploy the agent on
+	// Kubernetes. Only supported by GetPolicyFullYAML.
+	Kubernetes bool
+}
+
+func (request GetPolicyFullRequest) query() url.Values {
+	var q url.Values
+	if request.Standalone || request.Kubernetes {
+		q = make(url.Values)
+	}
+	if request.Standalone {
+		q.Add("standalone", "true")
+	}
+	if request.Kubernetes {
+		q.Add("kubernetes", "true")
+	}
+	return q
+}
+
+// GetPolicyFull returns the agent policy as sent to the agents, with its
+// outputs and the inputs of all its integrations
+func (client *Client) GetPolicyFull(ctx context.Context, request GetPolicyFullRequest) (r mapstr.M, err error) {
+	if request.Kubernetes {
+		return r, errors.New("kubernetes manifests are only returned as YAML, use GetPolicyFullYAML")
+	}
+
+	apiURL := fmt.Sprintf(fleetAgentPolicyFullAPI, request.ID)
+	resp, err := client.sendGet(ctx, apiURL, request.query())
+	if err != nil {
+		return r, fmt.Errorf("error calling get full policy API: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var fullResp struct {
+		Item mapstr.M `json:"item"`
+	}
+	err = client.readCachedJSONResponse(resp, &fullResp)
+	return fullResp.Item, err
+}
+
+// GetPolicyFullYAML returns the agent policy as sent to the agents, in the
+// YAML form downloaded from the Fleet UI. It can be used as the
+// configuration of a standalone agent.
+func (client *Client) GetPolicyFullYAML(ctx context.Context, request GetPolicyFullRequest) ([]byte, error) {
+	apiURL := fmt.Sprintf(fleetAgentPolicyDownloadAPI, request.ID)
+	resp, err := client.Connection.SendWithContext(ctx, http.MethodGet, apiURL, request.query(), nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error calling download policy API: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if err := checkAuthChallenge(resp); err != nil {
+		return nil, err
+	}
+	body, err := readBody(resp)
+	if err != nil {
+		return nil, fmt.Errorf("reading response body: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, newAPIError(client.codec(), resp.StatusCode, body)
+	}
+	return body, nil
+}
+
+//
+// List Policies
+//
+
+// ListPoliciesRequest filters and paginates the agent policies to list
+type ListPoliciesRequest struct {
+	// Kuery is a KQL query on the ingest-agent-policies saved objects,
+	// e.g. `ingest-agent-policies.name:"my policy"`
+	Kuery   string
+	Page    int
+	PerPage int
+}
+
+// ListPoliciesResponse is the JSON response for ListPolicies
+type ListPoliciesResponse struct {
+	Items   []PolicyResponse `json:"items"`
+	Total   int              `json:"total"`
+	Page    int              `json:"page"`
+	PerPage int              `json:"perPage"`
+}
+
+// ListPolicies returns the agent policies matching the request
+func (client *Client) ListPolicies(ctx context.Context, request ListPoliciesRequest) (r ListPoliciesResponse, err error) {
+	q := make(url.Values)
+	if request.Kuery != "" {
+		q.Add("kuery", request.Kuery)
+	}
+	if request.Page > 0 {
+		q.Add("page", strconv.Itoa(request.Page))
+	}
+	if request.PerPage > 0 {
+		q.Add("perPage", strconv.Itoa(request.PerPage))
+	}
+
+	resp, err := client.Connection.SendWithContext(ctx, http.MethodGet, fleetAgentPoliciesAPI, q, nil, nil)
+	if err != nil {
+		return r, fmt.Errorf("error calling list policies API: %w", err)
+	}
+	defer resp.Body.Close()
+
+	err = client.readJSONResponse(resp, &r)
+	return r, err
+}
+
+//
+// Ensure Policy
+//
+
+// EnsurePolicyRequest is the desired state of an agent policy, which is
+// identified by its name. Optional fields left empty are not managed: they are
+// not compared, and keep the value Kibana has.
+type EnsurePolicyRequest struct {
+	AgentPolicy
+}
+
+// EnsurePolicy makes sure that a policy with the requested name exists with
+// the requested settings. The policy is created if it does not exist, and
+// updated if its managed fields differ from the request. It returns the
+// policy and whether it was created. Updates are based on the revision read,
+// so they fail with ErrConcurrentModification if the policy changed in the
+// meantime, see AgentPolicyUpdateRequest.ExpectedRevision.
+func (client *Client) EnsurePolicy(ctx context.Context, request EnsurePolicyRequest) (r PolicyResponse, created bool, err error) {
+	existing, found, err := client.findPolicyByName(ctx, request.Name)
+	if err != nil {
+		return r, false, err
+	}
+
+	if !found {
+		r, err = client.CreatePolicy(ctx, request.AgentPolicy)
+		if err != nil {
+			return r, false, fmt.Errorf("creating policy %q: %w", request.Name, err)
+		}
+		return r, true, nil
+	}
+
+	if !client.policyDrifted(existing, request.AgentPolicy) {
+		return existing, false, nil
+	}
+
+	update := AgentPolicyUpdateRequest{
+		Name:               request.Name,
+		Namespace:          request.Namespace,
+		Description:        request.Description,
+		MonitoringEnabled:  request.MonitoringEnabled,
+		DataOutputID:       request.DataOutputID,
+		MonitoringOutputID: request.MonitoringOutputID,
+		FleetServerHostID:  request.FleetServerHostID,
+		DownloadSourceID:   request.DownloadSourceID,
+		UnenrollTimeout:    request.UnenrollTimeout,
+		InactivityTImeout:  request.InactivityTImeout,
+		AgentFeatures:      request.AgentFeatures,
+		IsProtected:        &request.IsProtected,
+		ExpectedRevision:   existing.Revision,
+	}
+	r, err = client.UpdatePolicy(ctx, existing.ID, update)
+	if err != nil {
+		return r, false, fmt.Errorf("updating policy %q: %w", request.Name, err)
+	}
+	return r, false, nil
+}
+
+// findPolicyByName returns the policy with exactly the given name. The kuery
+// narrows the search, but matches on analyzed text, so the names of the
+// results are checked again.
+func (client *Client) findPolicyByName(ctx context.Context, name string) (r PolicyResponse, found bool, err error) {
+	resp, err := client.ListPolicies(ctx, ListPoliciesRequest{
+		Kuery:   NewKuery().Field("ingest-agent-policies.name", name).String(),
+		PerPage: 100,
+	})
+	if err != nil {
+		return r, false, fmt.Errorf("looking up policy %q: %w", name, err)
+	}
+
+	for _, p := range resp.Items {
+		if p.Name != name {
+			continue
+		}
+		if found {
+			return r, false, fmt.Errorf("multiple policies named %q", name)
+		}
+		r, found = p, true
+	}
+	return r, found, nil
+}
+
+// policyDrifted returns true if a managed field of the existing policy differs
+// from the desired state
+func (client *Client) policyDrifted(existing PolicyResponse, desired AgentPolicy) bool {
+	changed := func(want, have string) bool { return want != "" && want != have }
+	changedInt := func(want, have int) bool { return want != 0 && want != have }
+
+	if existing.Name != desired.Name || existing.Namespace != desired.Namespace || existing.IsProtected != desired.IsProtected {
+		return true
+	}
+	if changed(desired.Description, existing.Description) ||
+		changed(desired.DataOutputID, existing.DataOutputID) ||
+		changed(desired.MonitoringOutputID, existing.MonitoringOutputID) ||
+		changed(desired.FleetServerHostID, existing.FleetServerHostID) ||
+		changed(desired.DownloadSourceID, existing.DownloadSourceID) ||
+		changedInt(desired.UnenrollTimeout, existing.UnenrollTimeout) ||
+		changedInt(desired.InactivityTImeout, existing.InactivityTImeout) {
+		return true
+	}
+	if desired.MonitoringEnabled != nil && !equalMonitoring(desired.MonitoringEnabled, existing.MonitoringEnabled) {
+		return true
+	}
+	if desired.AgentFeatures != nil {
+		// Compare the encoded features, decoded numbers do not have the type
+		// they were created with.
+		want, errWant := client.codec().Marshal(desired.AgentFeatures)
+		have, errHave := client.codec().Marshal(existing.AgentFeatures)
+		if errWant != nil || errHave != nil || !bytes.Equal(want, have) {
+			return true
+		}
+	}
+	return false
+}
+
+func equalMonitoring(a, b []MonitoringEnabledOption) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	set := make(map[MonitoringEnabledOption]int, len(a))
+	for _, o := range a {
+		set[o]++
+	}
+	for _, o := range b {
+		set[o]--
+	}
+	for _, n := range set {
+		if n != 0 {
+			return false
+		}
+	}
+	return true
+}
+
 //
 // Create Enrollment API Key
 //
@@ -286,12 +698,12 @@ type CreateEnrollmentAPIKeyResponse struct {
 
 // CreateEnrollmentAPIKey creates an enrollment API key
 func (client *Client) CreateEnrollmentAPIKey(ctx context.Context, request CreateEnrollmentAPIKeyRequest) (r CreateEnrollmentAPIKeyResponse, err error) {
-	reqBody, err := json.Marshal(request)
+	reqBody, err := client.codec().Marshal(request)
 	if err != nil {
 		return r, fmt.Errorf("unable to marshal create enrollment API key request into JSON: %w", err)
 	}
 
-	resp, err := client.Connection.SendWithContext(ctx, http.MethodPost, fleetEnrollmentAPIKeysAPI, nil, nil, bytes.NewReader(reqBody))
+	resp, err := client.Connection.SendWithContext(ctx, http.MethodPost, client.fleetPath(endpointEnrollmentAPIKeys), nil, nil, bytes.NewReader(reqBody))
 	if err != nil {
 		return r, fmt.Errorf("error calling create enrollment API key API: %w", err)
 	}
@@ -300,10 +712,185 @@ func (client *Client) CreateEnrollmentAPIKey(ctx context.Context, request Create
 	var enrollResp struct {
 		Item CreateEnrollmentAPIKeyResponse `json:"item"`
 	}
-	err = readJSONResponse(resp, &enrollResp)
+	err = client.readJSONResponse(resp, &enrollResp)
 	return enrollResp.Item, err
 }
 
+//
+// List, Get and Delete Enrollment API Keys
+//
+
+// EnrollmentAPIKey is an enrollment API key as returned by Fleet
+type EnrollmentAPIKey struct {
+	Active    bool   `json:"active"`
+	APIKey    string `json:"api_key"`
+	APIKeyID  string `json:"api_key_id"`
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	PolicyID  string `json:"policy_id"`
+	CreatedAt string `json:"created_at"`
+}
+
+// ListEnrollmentAPIKeysRequest filters and paginates the enrollment API keys
+// to list
+type ListEnrollmentAPIKeysRequest struct {
+	// Kuery is a KQL query on the keys, e.g. `policy_id:"abc"`
+	Kuery   string
+	Page    int
+	PerPage int
+}
+
+// ListEnrollmentAPIKeysResponse is the JSON response for ListEnrollmentAPIKeys
+type ListEnrollmentAPIKeysResponse struct {
+	Items   []EnrollmentAPIKey `json:"items"`
+	Total   int                `json:"total"`
+	Page    int                `json:"page"`
+	PerPage int                `json:"perPage"`
+}
+
+// ListEnrollmentAPIKeys returns the enrollment API keys matching the request
+func (client *Client) ListEnrollmentAPIKeys(ctx context.Context, request ListEnrollmentAPIKeysRequest) (r ListEnrollmentAPIKeysResponse, err error) {
+	q := make(url.Values)
+	if request.Kuery != "" {
+		q.Add("kuery", request.Kuery)
+	}
+	if request.Page > 0 {
+		q.Add("page", strconv.Itoa(request.Page))
+	}
+	if request.PerPage > 0 {
+		q.Add("perPage", strconv.Itoa(request.PerPage))
+	}
+
+	resp, err := client.sendGet(ctx, client.fleetPath(endpointEnrollmentAPIKeys), q)
+	if err != nil {
+		return r, fmt.Errorf("error calling list enrollment API keys API: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// Kibana 7.x returns the keys in list instead of items.
+	var listResp struct {
+		ListEnrollmentAPIKeysResponse
+		List []EnrollmentAPIKey `json:"list"`
+	}
+	if err = client.readCachedJSONResponse(resp, &listResp); err != nil {
+		return r, err
+	}
+	r = listResp.ListEnrollmentAPIKeysResponse
+	if r.Items == nil {
+		r.Items = listResp.List
+	}
+	return r, nil
+}
+
+// GetEnrollmentAPIKey returns the enrollment API key with the given ID
+func (client *Client) GetEnrollmentAPIKey(ctx context.Context, id string) (r EnrollmentAPIKey, err error) {
+	apiURL, err := url.JoinPath(client.fleetPath(endpointEnrollmentAPIKeys), id)
+	if err != nil {
+		return r, err
+	}
+
+	resp, err := client.sendGet(ctx, apiURL, nil)
+	if err != nil {
+		return r, fmt.Errorf("error calling get enrollment API key API: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var keyResp struct {
+		Item EnrollmentAPIKey `json:"item"`
+	}
+	err = client.readCachedJSONResponse(resp, &keyResp)
+	return keyResp.Item, err
+}
+
+// DeleteEnrollmentAPIKey revokes the enrollment API key with the given ID.
+// Agents can no longer enroll with it, Fleet keeps it as inactive.
+func (client *Client) DeleteEnrollmentAPIKey(ctx context.Context, id string) error {
+	apiURL, err := url.JoinPath(client.fleetPath(endpointEnrollmentAPIKeys), id)
+	if err != nil {
+		return err
+	}
+
+	resp, err := client.Connection.SendWithContext(ctx, http.MethodDelete, apiURL, nil, nil, nil)
+	if err != nil {
+		return fmt.Errorf("error calling delete enrollment API key API: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var deleteResp struct {
+		Action string `json:"action"`
+	}
+	return client.readJSONResponse(resp, &deleteResp)
+}
+
+//
+// Create Enrollment API Keys For Policies
+//
+
+// defaultEnrollmentAPIKeysConcurrency is the number of enrollment API keys
+// created in parallel by CreateEnrollmentAPIKeysForPolicies if not set.
+const defaultEnrollmentAPIKeysConcurrency = 4
+
+// CreateEnrollmentAPIKeysForPoliciesRequest lists the policies to create an
+// enrollment API key for
+type CreateEnrollmentAPIKeysForPoliciesRequest struct {
+	PolicyIDs []string
+	// Concurrency is the maximum number of keys created in parallel, 4 if not
+	// set.
+	Concurrency int
+}
+
+// CreateEnrollmentAPIKeysForPolicies creates one enrollment API key for each
+// of the given policies, in parallel, and returns them by policy ID. The
+// creation of every key is attempted even if some of them fail, the keys
+// created are returned together with the errors joined. Requests go through
+// the client transport like any other, so a configured rate limit applies.
+func (client *Client) CreateEnrollmentAPIKeysForPolicies(ctx context.Context, request CreateEnrollmentAPIKeysForPoliciesRequest) (map[string]CreateEnrollmentAPIKeyResponse, error) {
+	concurrency := request.Concurrency
+	if concurrency <= 0 {
+		concurrency = defaultEnrollmentAPIKeysConcurrency
+	}
+
+	policyIDs := make([]string, 0, len(request.PolicyIDs))
+	seen := make(map[string]struct{}, len(request.PolicyIDs))
+	for _, id := range request.PolicyIDs {
+		if _, ok := seen[id]; !ok {
+			seen[id] = struct{}{}
+			policyIDs = append(policyIDs, id)
+		}
+	}
+
+	keys := make([]CreateEnrollmentAPIKeyResponse, len(policyIDs))
+	errs := make([]error, len(policyIDs))
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, id := range policyIDs {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, id string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			key, err := client.CreateEnrollmentAPIKey(ctx, CreateEnrollmentAPIKeyRequest{PolicyID: id})
+			if err != nil {
+				errs[i] = fmt.Errorf("policy %s: %w", id, err)
+				return
+			}
+			keys[i] = key
+		}(i, id)
+	}
+	wg.Wait()
+
+	r := make(map[string]CreateEnrollmentAPIKeyResponse, len(policyIDs))
+	for i, id := range policyIDs {
+		if errs[i] == nil {
+			r[id] = keys[i]
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return r, fmt.Errorf("error creating enrollment API keys: %w", err)
+	}
+	return r, nil
+}
+
 //
 // List Agents
 //
@@ -317,13 +904,66 @@ type AgentCommon struct {
 		Version string `json:"version"`
 	} `json:"agent"`
 	LocalMetadata struct {
+		Elastic struct {
+			Agent struct {
+				ID          string `json:"id"`
+				Version     string `json:"version"`
+				Snapshot    bool   `json:"snapshot"`
+				Upgradeable bool   `json:"upgradeable"`
+				LogLevel    string `json:"log_level"`
+			} `json:"agent"`
+		} `json:"elastic"`
 		Host struct {
-			Hostname string `json:"hostname"`
+			Hostname     string   `json:"hostname"`
+			Name         string   `json:"name"`
+			ID           string   `json:"id"`
+			Architecture string   `json:"architecture"`
+			IP           []string `json:"ip"`
 		} `json:"host"`
+		OS struct {
+			Name     string `json:"name"`
+			Platform string `json:"platform"`
+			Family   string `json:"family"`
+			Version  string `json:"version"`
+			Kernel   string `json:"kernel"`
+			Full     string `json:"full"`
+		} `json:"os"`
 	} `json:"local_metadata"`
 	PolicyID       string               `json:"policy_id"`
 	PolicyRevision int                  `json:"policy_revision"`
 	UpgradeDetails *AgentUpgradeDetails `json:"upgrade_details"`
+
+	// Type is PERMANENT, EPHEMERAL or TEMPORARY.
+	Type               string    `json:"type,omitempty"`
+	Tags               []string  `json:"tags,omitempty"`
+	EnrolledAt         time.Time `json:"enrolled_at"`
+	LastCheckin        time.Time `json:"last_checkin"`
+	LastCheckinStatus  string    `json:"last_checkin_status,omitempty"`
+	LastCheckinMessage string    `json:"last_checkin_message,omitempty"`
+	// UnhealthyReason lists the parts of an unhealthy agent that are failing,
+	// e.g. input or output.
+	UnhealthyReason []string `json:"unhealthy_reason,omitempty"`
+	// Components is the state of the components run by the agent, as last
+	// reported on check-in.
+	Components []AgentComponent `json:"components,omitempty"`
+}
+
+// AgentComponent is the state of a component run by an agent
+type AgentComponent struct {
+	ID      string               `json:"id"`
+	Type    string               `json:"type"`
+	Status  string               `json:"status"`
+	Message string               `json:"message"`
+	Units   []AgentComponentUnit `json:"units,omitempty"`
+}
+
+// AgentComponentUnit is the state of an input or output unit of a component
+type AgentComponentUnit struct {
+	ID      string         `json:"id"`
+	Type    string         `json:"type"`
+	Status  string         `json:"status"`
+	Message string         `json:"message"`
+	Payload map[string]any `json:"payload,omitempty"`
 }
 
 type AgentUpgradeDetails struct {
@@ -345,45 +985,178 @@ type AgentExisting struct {
 	AgentCommon `json:",inline"`
 }
 
-// ListAgentsRequest is currently unused
+// ListAgentsRequest filters and paginates the agents to list
 type ListAgentsRequest struct {
-	// For future use
+	// Kuery is a KQL query on the agents, e.g. `status:online`
+	Kuery   string
+	Page    int
+	PerPage int
 }
 
 // ListAgentsResponse is a list of agents returned by the API
 type ListAgentsResponse struct {
-	Items []AgentExisting `json:"items"`
+	Items   []AgentExisting `json:"items"`
+	Total   int             `json:"total"`
+	Page    int             `json:"page"`
+	PerPage int             `json:"perPage"`
 }
 
 // ListAgents returns a list of agents known to Kibana
-func (client *Client) ListAgents(ctx context.Context, _ ListAgentsRequest) (r ListAgentsResponse, err error) {
-	resp, err := client.Connection.SendWithContext(ctx, http.MethodGet, fleetAgentsAPI, nil, nil, nil)
+func (client *Client) ListAgents(ctx context.Context, request ListAgentsRequest) (r ListAgentsResponse, err error) {
+	var q url.Values
+	if request.Kuery != "" || request.Page > 0 || request.PerPage > 0 {
+		q = make(url.Values)
+	}
+	if request.Kuery != "" {
+		q.Add("kuery", request.Kuery)
+	}
+	if request.Page > 0 {
+		q.Add("page", strconv.Itoa(request.Page))
+	}
+	if request.PerPage > 0 {
+		q.Add("perPage", strconv.Itoa(request.PerPage))
+	}
+
+	resp, err := client.sendGet(ctx, fleetAgentsAPI, q)
 	if err != nil {
 		return r, fmt.Errorf("error calling list agents API: %w", err)
 	}
 	defer resp.Body.Close()
 
-	err = readJSONResponse(resp, &r)
+	err = client.readCachedJSONResponse(resp, &r)
 	return r, err
 }
 
-//
-// Get Agent
-//
+// HasNextPage returns true if there are agents after this page
+func (r ListAgentsResponse) HasNextPage() bool {
+	return len(r.Items) > 0 && r.Page > 0 && r.PerPage > 0 && r.Page*r.PerPage < r.Total
+}
 
-// GetAgentRequest contains the ID used for fetching agent data
-type GetAgentRequest struct {
-	ID string
+// NextPage returns the request for the page following this response. ok is
+// false if this is the last page.
+func (r ListAgentsResponse) NextPage(request ListAgentsRequest) (next ListAgentsRequest, ok bool) {
+	if !r.HasNextPage() {
+		return request, false
+	}
+	request.Page = r.Page + 1
+	request.PerPage = r.PerPage
+	return request, true
 }
 
-// GetAgentResponse is the JSON response for GetAgent
-type GetAgentResponse AgentExisting
+// ForEachAgent calls fn for every agent matching the request, fetching one
+// page after the other, starting at request.Page. It stops at the first error
+// returned by fn or by the list agents API.
+func (client *Client) ForEachAgent(ctx context.Context, request ListAgentsRequest, fn func(AgentExisting) error) error {
+	for {
+		resp, err := client.ListAgents(ctx, request)
+		if err != nil {
+			return err
+		}
+		for _, agent := range resp.Items {
+			if err := fn(agent); err != nil {
+				return err
+			}
+		}
 
-// GetAgent fetches data for an agent
-func (client *Client) GetAgent(ctx context.Context, request GetAgentRequest) (r GetAgentResponse, err error) {
-	apiURL := fmt.Sprintf(fleetAgentAPI, request.ID)
+		var ok bool
+		if request, ok = resp.NextPage(request); !ok {
+			return nil
+		}
+	}
+}
 
-	resp, err := client.Connection.SendWithContext(ctx, http.MethodGet, apiURL, nil, nil, nil)
+//
+// Get Agents By IDs
+//
+
+// defaultGetAgentsChunkSize is the number of agent IDs queried per request by
+// GetAgentsByIDs, it keeps the kuery short enough for Kibana.
+const defaultGetAgentsChunkSize = 100
+
+// GetAgentsByIDsRequest lists the IDs of the agents to fetch
+type GetAgentsByIDsRequest struct {
+	IDs []string
+	// ChunkSize is the number of IDs queried per request, 100 if not set.
+	ChunkSize int
+}
+
+// GetAgentsByIDsResponse contains the agents found, in the order of the
+// requested IDs, and the IDs of the agents that were not found
+type GetAgentsByIDsResponse struct {
+	Items   []AgentExisting
+	Missing []string
+}
+
+// GetAgentsByIDs fetches the agents with the given IDs using the list agents
+// API. The IDs are queried in chunks, one request per chunk. Duplicate IDs are
+// only returned once.
+func (client *Client) GetAgentsByIDs(ctx context.Context, request GetAgentsByIDsRequest) (r GetAgentsByIDsResponse, err error) {
+	chunkSize := request.ChunkSize
+	if chunkSize <= 0 {
+		chunkSize = defaultGetAgentsChunkSize
+	}
+
+	ids := make([]string, 0, len(request.IDs))
+	seen := make(map[string]struct{}, len(request.IDs))
+	for _, id := range request.IDs {
+		if _, ok := seen[id]; !ok {
+			seen[id] = struct{}{}
+			ids = append(ids, id)
+		}
+	}
+
+	found := make(map[string]AgentExisting, len(ids))
+	for start := 0; start < len(ids); start += chunkSize {
+		end := start + chunkSize
+		if end > len(ids) {
+			end = len(ids)
+		}
+		chunk := ids[start:end]
+
+		quoted := make([]string, len(chunk))
+		for i, id := range chunk {
+			quoted[i] = QuoteKQL(id)
+		}
+		agents, err := client.ListAgents(ctx, ListAgentsRequest{
+			Kuery:   fmt.Sprintf("agent.id:(%s)", strings.Join(quoted, " or ")),
+			PerPage: len(chunk),
+		})
+		if err != nil {
+			return r, fmt.Errorf("error listing agents %d to %d: %w", start, end-1, err)
+		}
+		for _, agent := range agents.Items {
+			found[agent.ID] = agent
+		}
+	}
+
+	for _, id := range ids {
+		if agent, ok := found[id]; ok {
+			r.Items = append(r.Items, agent)
+		} else {
+			r.Missing = append(r.Missing, id)
+		}
+	}
+	return r, nil
+}
+
+//
+// Get Agent
+//
+
+// GetAgentRequest contains the ID used for fetching agent data
+type GetAgentRequest struct {
+	ID string
+}
+
+// GetAgentResponse is the JSON response for GetAgent
+type GetAgentResponse AgentExisting
+
+// GetAgent fetches the full document of an agent, including its local
+// metadata and the state of its components
+func (client *Client) GetAgent(ctx context.Context, request GetAgentRequest) (r GetAgentResponse, err error) {
+	apiURL := fmt.Sprintf(fleetAgentAPI, request.ID)
+
+	resp, err := client.sendGet(ctx, apiURL, nil)
 	if err != nil {
 		return r, fmt.Errorf("error calling get agent API: %w", err)
 	}
@@ -392,10 +1165,209 @@ func (client *Client) GetAgent(ctx context.Context, request GetAgentRequest) (r
 	var agentResp struct {
 		Item GetAgentResponse `json:"item"`
 	}
-	err = readJSONResponse(resp, &agentResp)
+	err = client.readCachedJSONResponse(resp, &agentResp)
+	return agentResp.Item, err
+}
+
+//
+// Update Agent Tags
+//
+
+// UpdateAgentTagsRequest is the request for replacing the tags of an agent
+type UpdateAgentTagsRequest struct {
+	ID string `json:"-"`
+	// Tags replace the tags of the agent, an empty list removes them all.
+	Tags []string `json:"tags"`
+}
+
+// UpdateAgentTags replaces the tags of an agent and returns the updated agent.
+// Use BulkUpdateAgentTags to add or remove tags of many agents.
+func (client *Client) UpdateAgentTags(ctx context.Context, request UpdateAgentTagsRequest) (r GetAgentResponse, err error) {
+	if request.Tags == nil {
+		request.Tags = []string{}
+	}
+	reqBody, err := client.codec().Marshal(request)
+	if err != nil {
+		return r, fmt.Errorf("unable to marshal update agent tags request into JSON: %w", err)
+	}
+
+	apiURL := fmt.Sprintf(fleetAgentAPI, request.ID)
+	resp, err := client.Connection.SendWithContext(ctx, http.MethodPut, apiURL, nil, nil, bytes.NewReader(reqBody))
+	if err != nil {
+		return r, fmt.Errorf("error calling update agent API: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var agentResp struct {
+		Item GetAgentResponse `json:"item"`
+	}
+	err = client.readJSONResponse(resp, &agentResp)
 	return agentResp.Item, err
 }
 
+//
+// Agent Status Summary
+//
+
+// GetAgentStatusSummaryRequest filters the agents to count
+type GetAgentStatusSummaryRequest struct {
+	// PolicyID only counts the agents of this policy
+	PolicyID string
+	// Kuery is a KQL query on the agents, e.g. `tags:ci`
+	Kuery string
+}
+
+// AgentStatusSummary is the number of agents in each status. Error counts the
+// unhealthy agents, in error or degraded.
+type AgentStatusSummary struct {
+	Total      int `json:"total"`
+	Online     int `json:"online"`
+	Error      int `json:"error"`
+	Offline    int `json:"offline"`
+	Updating   int `json:"updating"`
+	Inactive   int `json:"inactive"`
+	Unenrolled int `json:"unenrolled"`
+	Other      int `json:"other"`
+	// Active is the number of enrolled agents, All includes unenrolled agents.
+	Active int `json:"active"`
+	All    int `json:"all"`
+}
+
+// GetAgentStatusSummary returns the number of agents in each status
+func (client *Client) GetAgentStatusSummary(ctx context.Context, request GetAgentStatusSummaryRequest) (r AgentStatusSummary, err error) {
+	var q url.Values
+	if request.PolicyID != "" || request.Kuery != "" {
+		q = make(url.Values)
+	}
+	if request.PolicyID != "" {
+		q.Add("policyId", request.PolicyID)
+	}
+	if request.Kuery != "" {
+		q.Add("kuery", request.Kuery)
+	}
+
+	resp, err := client.sendGet(ctx, client.fleetPath(endpointAgentStatus), q)
+	if err != nil {
+		return r, fmt.Errorf("error calling agent status API: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var statusResp struct {
+		Results AgentStatusSummary `json:"results"`
+	}
+	err = client.readCachedJSONResponse(resp, &statusResp)
+	return statusResp.Results, err
+}
+
+//
+// Wait For Agent Status
+//
+
+// AgentStatus is the status of an agent as reported by Fleet
+type AgentStatus string
+
+const (
+	AgentStatusOnline     AgentStatus = "online"
+	AgentStatusOffline    AgentStatus = "offline"
+	AgentStatusUpdating   AgentStatus = "updating"
+	AgentStatusEnrolling  AgentStatus = "enrolling"
+	AgentStatusDegraded   AgentStatus = "degraded"
+	AgentStatusError      AgentStatus = "error"
+	AgentStatusInactive   AgentStatus = "inactive"
+	AgentStatusUnenrolled AgentStatus = "unenrolled"
+)
+
+// defaultAgentStatusPollInterval is how often WaitForAgentStatus and
+// WaitForAgentUpgrade poll the agent if no interval is set.
+const defaultAgentStatusPollInterval = 5 * time.Second
+
+// WaitForAgentStatusOptions configures WaitForAgentStatus and
+// WaitForAgentUpgrade
+type WaitForAgentStatusOptions struct {
+	// Interval between two polls, five seconds if not set.
+	Interval time.Duration
+	// Timeout limits the overall wait in addition to the context, no limit
+	// if not set.
+	Timeout time.Duration
+	// FailOn lists statuses that end the wait with an error, e.g.
+	// AgentStatusError or AgentStatusDegraded.
+	FailOn []AgentStatus
+}
+
+// WaitForAgentStatus polls the agent with the given ID until it reports the
+// desired status and returns it. It fails if the agent reports one of the
+// FailOn statuses, if the agent can not be read, or if ctx is done or the
+// timeout expired before, the error includes the last status observed.
+func (client *Client) WaitForAgentStatus(ctx context.Context, agentID string, desired AgentStatus, opts WaitForAgentStatusOptions) (r GetAgentResponse, err error) {
+	return client.waitForAgent(ctx, agentID, string(desired), opts, func(agent GetAgentResponse) bool {
+		return AgentStatus(agent.Status) == desired
+	})
+}
+
+// WaitForAgentUpgrade polls the agent with the given ID until it runs the
+// given version and is online, and returns it. A -SNAPSHOT suffix of the
+// version is ignored, agents do not report it. It fails like
+// WaitForAgentStatus.
+func (client *Client) WaitForAgentUpgrade(ctx context.Context, agentID string, version string, opts WaitForAgentStatusOptions) (r GetAgentResponse, err error) {
+	version = strings.TrimSuffix(version, "-SNAPSHOT")
+	return client.waitForAgent(ctx, agentID, "upgraded to "+version, opts, func(agent GetAgentResponse) bool {
+		return AgentStatus(agent.Status) == AgentStatusOnline &&
+			strings.TrimSuffix(agent.Agent.Version, "-SNAPSHOT") == version
+	})
+}
+
+// waitForAgent polls the agent until done returns true for it. desired
+// describes the awaited state in errors.
+func (client *Client) waitForAgent(ctx context.Context, agentID string, desired string, opts WaitForAgentStatusOptions, done func(GetAgentResponse) bool) (r GetAgentResponse, err error) {
+	interval := opts.Interval
+	if interval <= 0 {
+		interval = defaultAgentStatusPollInterval
+	}
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var last GetAgentResponse
+	timeoutErr := func() error {
+		lastStatus := last.Status
+		if lastStatus == "" {
+			lastStatus = "unknown"
+		}
+		return fmt.Errorf("waiting for agent %s to be %s, last status %s: %w", agentID, desired, lastStatus, ctx.Err())
+	}
+	for {
+		agent, err := client.GetAgent(ctx, GetAgentRequest{ID: agentID})
+		if err != nil {
+			if ctx.Err() != nil {
+				return last, timeoutErr()
+			}
+			return last, fmt.Errorf("waiting for agent %s to be %s: %w", agentID, desired, err)
+		}
+		last = agent
+
+		if done(agent) {
+			return agent, nil
+		}
+		status := AgentStatus(agent.Status)
+		for _, failure := range opts.FailOn {
+			if status == failure {
+				return agent, fmt.Errorf("agent %s is %s while waiting for it to be %s", agentID, status, desired)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return last, timeoutErr()
+		case <-ticker.C:
+		}
+	}
+}
+
 //
 // Unenroll Agent
 //
@@ -413,7 +1385,7 @@ type UnEnrollAgentResponse struct {
 
 // UnEnrollAgent removes the agent from fleet
 func (client *Client) UnEnrollAgent(ctx context.Context, request UnEnrollAgentRequest) (r UnEnrollAgentResponse, err error) {
-	reqBody, err := json.Marshal(request)
+	reqBody, err := client.codec().Marshal(request)
 	if err != nil {
 		return r, fmt.Errorf("unable to marshal unenroll agent request into JSON: %w", err)
 	}
@@ -425,10 +1397,57 @@ func (client *Client) UnEnrollAgent(ctx context.Context, request UnEnrollAgentRe
 	}
 	defer resp.Body.Close()
 
-	err = readJSONResponse(resp, &r)
+	err = client.readJSONResponse(resp, &r)
 	return r, err
 }
 
+//
+// Delete Agent
+//
+
+// DeleteAgentRequest contains the ID of the agent to delete
+type DeleteAgentRequest struct {
+	ID string
+	// Force deletes the agent even if it is active. Without it, deleting an
+	// active agent fails with ErrAgentActive.
+	Force bool
+	// IgnoreNotFound treats an agent that does not exist as deleted.
+	IgnoreNotFound bool
+}
+
+// DeleteAgent deletes the agent record from Fleet. Unlike UnEnrollAgent the
+// agent is removed, it is meant to clean up agents that no longer check in.
+func (client *Client) DeleteAgent(ctx context.Context, request DeleteAgentRequest) error {
+	if !request.Force {
+		agent, err := client.GetAgent(ctx, GetAgentRequest{ID: request.ID})
+		if err != nil {
+			if request.IgnoreNotFound && IsNotFound(err) {
+				return nil
+			}
+			return err
+		}
+		if agent.Active {
+			return fmt.Errorf("%w: agent %s has status %s, set Force to delete it", ErrAgentActive, request.ID, agent.Status)
+		}
+	}
+
+	apiURL := fmt.Sprintf(fleetAgentAPI, request.ID)
+	resp, err := client.Connection.SendWithContext(ctx, http.MethodDelete, apiURL, nil, nil, nil)
+	if err != nil {
+		return fmt.Errorf("error calling delete agent API: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var deleteResp struct {
+		Action string `json:"action"`
+	}
+	err = client.readJSONResponse(resp, &deleteResp)
+	if request.IgnoreNotFound && IsNotFound(err) {
+		return nil
+	}
+	return err
+}
+
 //
 // Upgrade Agent
 //
@@ -448,7 +1467,7 @@ type UpgradeAgentResponse struct {
 
 // UpgradeAgent upgrades the requested agent
 func (client *Client) UpgradeAgent(ctx context.Context, request UpgradeAgentRequest) (r UpgradeAgentResponse, err error) {
-	reqBody, err := json.Marshal(request)
+	reqBody, err := client.codec().Marshal(request)
 	if err != nil {
 		return r, fmt.Errorf("unable to marshal upgrade agent request into JSON: %w", err)
 	}
@@ -460,74 +1479,1226 @@ func (client *Client) UpgradeAgent(ctx context.Context, request UpgradeAgentRequ
 	}
 	defer resp.Body.Close()
 
-	err = readJSONResponse(resp, &r)
+	err = client.readJSONResponse(resp, &r)
 	return r, err
 }
 
 //
-// List Fleet Server Hosts
+// Reassign Agent
 //
 
-// FleetServerHost handles JSON data for fleet server info
-type FleetServerHost struct {
-	ID              string   `json:"id"`
-	Name            string   `json:"name"`
-	IsDefault       bool     `json:"is_default"`
-	HostURLs        []string `json:"host_urls"`
-	IsPreconfigured bool     `json:"is_preconfigured"`
+// ReassignAgentRequest is the JSON request for reassigning an agent to
+// another policy
+type ReassignAgentRequest struct {
+	ID       string `json:"-"` // ID is not part of the request body send to the Fleet API
+	PolicyID string `json:"policy_id"`
 }
 
-// ListFleetServerHostsRequest is currently unused
-type ListFleetServerHostsRequest struct {
+// ReassignAgentResponse is currently unused
+type ReassignAgentResponse struct {
 	// For future use
 }
 
-// ListFleetServerHostsResponse is the JSON response for ListFleetServerHosts
-type ListFleetServerHostsResponse struct {
-	Items []FleetServerHost `json:"items"`
+// ReassignAgent moves the requested agent to another policy
+func (client *Client) ReassignAgent(ctx context.Context, request ReassignAgentRequest) (r ReassignAgentResponse, err error) {
+	reqBody, err := client.codec().Marshal(request)
+	if err != nil {
+		return r, fmt.Errorf("unable to marshal reassign agent request into JSON: %w", err)
+	}
+
+	apiURL := fmt.Sprintf(fleetReassignAgentAPI, request.ID)
+	resp, err := client.Connection.SendWithContext(ctx, http.MethodPost, apiURL, nil, nil, bytes.NewReader(reqBody))
+	if err != nil {
+		return r, fmt.Errorf("error calling reassign agent API: %w", err)
+	}
+	defer resp.Body.Close()
+
+	err = client.readJSONResponse(resp, &r)
+	return r, err
 }
 
-// ListFleetServerHosts returns a list of fleet server hosts
-func (client *Client) ListFleetServerHosts(ctx context.Context, _ ListFleetServerHostsRequest) (r ListFleetServerHostsResponse, err error) {
-	resp, err := client.Connection.SendWithContext(ctx, http.MethodGet, fleetFleetServerHostsAPI, nil, nil, nil)
+//
+// Agent Diagnostics
+//
+
+// DiagnosticsStatus is the status of a diagnostics upload
+type DiagnosticsStatus string
+
+// Diagnostics upload statuses reported by Fleet
+const (
+	DiagnosticsStatusReady          DiagnosticsStatus = "READY"
+	DiagnosticsStatusAwaitingUpload DiagnosticsStatus = "AWAITING_UPLOAD"
+	DiagnosticsStatusInProgress     DiagnosticsStatus = "IN_PROGRESS"
+	DiagnosticsStatusFailed         DiagnosticsStatus = "FAILED"
+	DiagnosticsStatusExpired        DiagnosticsStatus = "EXPIRED"
+	DiagnosticsStatusDeleted        DiagnosticsStatus = "DELETED"
+)
+
+// RequestDiagnosticsRequest is the JSON request for collecting the
+// diagnostics of an agent
+type RequestDiagnosticsRequest struct {
+	ID string `json:"-"` // ID is not part of the request body send to the Fleet API
+	// AdditionalMetrics adds optional data to the archive, e.g. "CPU" for a
+	// CPU profile.
+	AdditionalMetrics []string `json:"additional_metrics,omitempty"`
+}
+
+// RequestDiagnosticsResponse is the JSON response for RequestDiagnostics
+type RequestDiagnosticsResponse struct {
+	ActionID string `json:"actionId"`
+}
+
+// AgentDiagnostics is a diagnostics archive uploaded, or to be uploaded, by
+// an agent
+type AgentDiagnostics struct {
+	ID         string            `json:"id"`
+	Name       string            `json:"name"`
+	FilePath   string            `json:"filePath"`
+	CreateTime string            `json:"createTime"`
+	Status     DiagnosticsStatus `json:"status"`
+	ActionID   string            `json:"actionId"`
+	Error      string            `json:"error,omitempty"`
+}
+
+// RequestDiagnostics asks the requested agent to collect its diagnostics and
+// upload them. The upload is listed by ListAgentDiagnostics and can be
+// downloaded with DownloadDiagnostics once its status is READY.
+func (client *Client) RequestDiagnostics(ctx context.Context, request RequestDiagnosticsRequest) (r RequestDiagnosticsResponse, err error) {
+	reqBody, err := client.codec().Marshal(request)
 	if err != nil {
-		return r, fmt.Errorf("error calling list fleet server hosts API: %w", err)
+		return r, fmt.Errorf("unable to marshal request diagnostics request into JSON: %w", err)
+	}
+
+	apiURL := fmt.Sprintf(fleetAgentDiagnosticsAPI, request.ID)
+	resp, err := client.Connection.SendWithContext(ctx, http.MethodPost, apiURL, nil, nil, bytes.NewReader(reqBody))
+	if err != nil {
+		return r, fmt.Errorf("error calling request diagnostics API: %w", err)
+	}
+	defer resp.Body.Close()
+
+	err = client.readJSONResponse(resp, &r)
+	return r, err
+}
+
+// ListAgentDiagnostics lists the diagnostics uploads of the agent with the
+// given ID
+func (client *Client) ListAgentDiagnostics(ctx context.Context, agentID string) (r []AgentDiagnostics, err error) {
+	apiURL := fmt.Sprintf(fleetAgentUploadsAPI, agentID)
+	resp, err := client.Connection.SendWithContext(ctx, http.MethodGet, apiURL, nil, nil, nil)
+	if err != nil {
+		return r, fmt.Errorf("error calling list agent uploads API: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var uploadsResp struct {
+		Items []AgentDiagnostics `json:"items"`
+	}
+	err = client.readJSONResponse(resp, &uploadsResp)
+	return uploadsResp.Items, err
+}
+
+// DownloadDiagnostics writes the zip archive of an uploaded diagnostics to w.
+// The archive is streamed, it is not held in memory.
+func (client *Client) DownloadDiagnostics(ctx context.Context, diagnostics AgentDiagnostics, w io.Writer) error {
+	if diagnostics.Status != DiagnosticsStatusReady {
+		return fmt.Errorf("diagnostics %s can not be downloaded, status is %s", diagnostics.ID, diagnostics.Status)
+	}
+
+	apiURL := fmt.Sprintf(fleetAgentFileAPI, url.PathEscape(diagnostics.ID), url.PathEscape(diagnostics.Name))
+	resp, err := client.Connection.SendWithContext(ctx, http.MethodGet, apiURL, nil, nil, nil)
+	if err != nil {
+		return fmt.Errorf("error calling agent file API: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if err := checkAuthChallenge(resp); err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		body, err := readBody(resp)
+		if err != nil {
+			return fmt.Errorf("reading response body: %w", err)
+		}
+		return newAPIError(client.codec(), resp.StatusCode, body)
+	}
+
+	body, err := bodyReader(resp)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+	if _, err := io.Copy(w, body); err != nil {
+		return fmt.Errorf("downloading diagnostics %s: %w", diagnostics.ID, err)
+	}
+	return nil
+}
+
+//
+// Bulk Agent Actions
+//
+
+// BulkResult is the outcome of a bulk agent action. Recent Kibana versions
+// run bulk actions asynchronously and only return the action ID, the progress
+// can then be followed with GetActionStatus. Older versions, and some
+// synchronous code paths, report the result of every agent inline.
+type BulkResult struct {
+	ActionID string
+	// Succeeded lists the agents the action was applied to, sorted by ID.
+	Succeeded []string
+	// Failed maps the ID of every agent the action failed for to the error
+	// reported by Kibana.
+	Failed map[string]string
+}
+
+// BulkAgents selects the agents of a bulk action, either by ID or with a KQL
+// query. Exactly one of them must be set.
+type BulkAgents struct {
+	IDs []string
+	// Kuery is a KQL query on the agents, e.g. `policy_id:abc and status:online`
+	Kuery string
+}
+
+func (a BulkAgents) value() (any, error) {
+	switch {
+	case len(a.IDs) > 0 && a.Kuery != "":
+		return nil, errors.New("agent IDs and kuery can not be used together")
+	case len(a.IDs) > 0:
+		return a.IDs, nil
+	case a.Kuery != "":
+		return a.Kuery, nil
+	default:
+		return nil, errors.New("no agents selected, set agent IDs or a kuery")
+	}
+}
+
+// BulkUnEnrollAgentsRequest is the JSON request for unenrolling agents
+type BulkUnEnrollAgentsRequest struct {
+	Agents BulkAgents `json:"-"`
+	Revoke bool       `json:"revoke,omitempty"`
+	Force  bool       `json:"force,omitempty"`
+}
+
+// BulkUpgradeAgentsRequest is the JSON request for upgrading agents
+type BulkUpgradeAgentsRequest struct {
+	Agents                 BulkAgents `json:"-"`
+	Version                string     `json:"version"`
+	SourceURI              string     `json:"source_uri,omitempty"`
+	Force                  bool       `json:"force,omitempty"`
+	RolloutDurationSeconds int        `json:"rollout_duration_seconds,omitempty"`
+}
+
+// BulkReassignAgentsRequest is the JSON request for reassigning agents to a
+// policy
+type BulkReassignAgentsRequest struct {
+	Agents   BulkAgents `json:"-"`
+	PolicyID string     `json:"policy_id"`
+}
+
+// BulkUpdateAgentTagsRequest is the JSON request for updating the tags of
+// agents
+type BulkUpdateAgentTagsRequest struct {
+	Agents       BulkAgents `json:"-"`
+	TagsToAdd    []string   `json:"tagsToAdd,omitempty"`
+	TagsToRemove []string   `json:"tagsToRemove,omitempty"`
+}
+
+// BulkUnEnrollAgents unenrolls the requested agents. If the action failed for
+// some agents the result is returned with a *PartialBulkFailureError.
+func (client *Client) BulkUnEnrollAgents(ctx context.Context, request BulkUnEnrollAgentsRequest) (BulkResult, error) {
+	return client.sendBulkAction(ctx, "bulk unenroll", fleetAgentsBulkUnenrollAPI, request.Agents, request)
+}
+
+// BulkUpgradeAgents upgrades the requested agents. If the action failed for
+// some agents the result is returned with a *PartialBulkFailureError.
+func (client *Client) BulkUpgradeAgents(ctx context.Context, request BulkUpgradeAgentsRequest) (BulkResult, error) {
+	return client.sendBulkAction(ctx, "bulk upgrade", fleetAgentsBulkUpgradeAPI, request.Agents, request)
+}
+
+// BulkReassignAgents reassigns the requested agents to another policy. If the
+// action failed for some agents the result is returned with a
+// *PartialBulkFailureError.
+func (client *Client) BulkReassignAgents(ctx context.Context, request BulkReassignAgentsRequest) (BulkResult, error) {
+	return client.sendBulkAction(ctx, "bulk reassign", fleetAgentsBulkReassignAPI, request.Agents, request)
+}
+
+// BulkUpdateAgentTags adds and removes tags of the requested agents. If the
+// action failed for some agents the result is returned with a
+// *PartialBulkFailureError.
+func (client *Client) BulkUpdateAgentTags(ctx context.Context, request BulkUpdateAgentTagsRequest) (BulkResult, error) {
+	return client.sendBulkAction(ctx, "bulk update agent tags", fleetAgentsBulkUpdateTagsAPI, request.Agents, request)
+}
+
+func (client *Client) sendBulkAction(ctx context.Context, name, apiURL string, agents BulkAgents, request any) (r BulkResult, err error) {
+	selected, err := agents.value()
+	if err != nil {
+		return r, fmt.Errorf("invalid %s request: %w", name, err)
+	}
+
+	// The agents are either a list of IDs or a query, add them to the body
+	// of the typed request.
+	var body map[string]any
+	reqBody, err := client.codec().Marshal(request)
+	if err == nil {
+		err = client.codec().Unmarshal(reqBody, &body)
+	}
+	if err == nil {
+		body["agents"] = selected
+		reqBody, err = client.codec().Marshal(body)
+	}
+	if err != nil {
+		return r, fmt.Errorf("unable to marshal %s request into JSON: %w", name, err)
+	}
+
+	resp, err := client.Connection.SendWithContext(ctx, http.MethodPost, apiURL, nil, nil, bytes.NewReader(reqBody))
+	if err != nil {
+		return r, fmt.Errorf("error calling %s API: %w", name, err)
+	}
+	defer resp.Body.Close()
+
+	var respBody map[string]any
+	if err := client.readJSONResponse(resp, &respBody); err != nil {
+		return r, err
+	}
+
+	r = parseBulkResult(respBody)
+	if len(r.Failed) > 0 {
+		return r, &PartialBulkFailureError{Result: r}
+	}
+	return r, nil
+}
+
+// parseBulkResult reads the action ID and the per agent results from a bulk
+// action response. Per agent results are either listed in items, or keyed by
+// agent ID at the top level.
+func parseBulkResult(body map[string]any) BulkResult {
+	r := BulkResult{Failed: map[string]string{}}
+	add := func(id string, result map[string]any) {
+		success, ok := result["success"].(bool)
+		if !ok {
+			return
+		}
+		if success {
+			r.Succeeded = append(r.Succeeded, id)
+			return
+		}
+		msg, _ := result["error"].(string)
+		if msg == "" {
+			msg = "unknown error"
+		}
+		r.Failed[id] = msg
+	}
+
+	for key, value := range body {
+		switch key {
+		case "actionId":
+			r.ActionID, _ = value.(string)
+		case "items":
+			items, _ := value.([]any)
+			for _, item := range items {
+				if result, ok := item.(map[string]any); ok {
+					if id, _ := result["id"].(string); id != "" {
+						add(id, result)
+					}
+				}
+			}
+		default:
+			if result, ok := value.(map[string]any); ok {
+				add(key, result)
+			}
+		}
+	}
+
+	sort.Strings(r.Succeeded)
+	return r
+}
+
+//
+// Agent Actions
+//
+
+// AgentActionType is the type of an action dispatched to an agent
+type AgentActionType string
+
+const (
+	// AgentActionSettings changes agent settings, e.g. the log level
+	AgentActionSettings AgentActionType = "SETTINGS"
+	// AgentActionPolicyReassign reassigns the agent to another policy
+	AgentActionPolicyReassign AgentActionType = "POLICY_REASSIGN"
+	// AgentActionUnenroll unenrolls the agent
+	AgentActionUnenroll AgentActionType = "UNENROLL"
+	// AgentActionUpgrade upgrades the agent
+	AgentActionUpgrade AgentActionType = "UPGRADE"
+	// AgentActionCancel cancels another action, see CancelAction
+	AgentActionCancel AgentActionType = "CANCEL"
+)
+
+// AgentActionPayload is the typed action sent to an agent. Data holds the
+// action specific payload and is encoded as JSON.
+type AgentActionPayload struct {
+	Type AgentActionType `json:"type"`
+	Data interface{}     `json:"data,omitempty"`
+}
+
+// CreateAgentActionRequest is the JSON request for creating an agent action
+type CreateAgentActionRequest struct {
+	AgentID string             `json:"-"` // AgentID is not part of the request body send to the Fleet API
+	Action  AgentActionPayload `json:"action"`
+}
+
+// AgentAction is an action dispatched to an agent
+type AgentAction struct {
+	ID        string          `json:"id"`
+	AgentID   string          `json:"agent_id"`
+	Type      AgentActionType `json:"type"`
+	Data      json.RawMessage `json:"data,omitempty"`
+	Status    string          `json:"status,omitempty"`
+	CreatedAt time.Time       `json:"created_at"`
+	SentAt    *time.Time      `json:"sent_at,omitempty"`
+}
+
+// CreateAgentAction dispatches an action to the requested agent and returns
+// the created action
+func (client *Client) CreateAgentAction(ctx context.Context, request CreateAgentActionRequest) (r AgentAction, err error) {
+	reqBody, err := client.codec().Marshal(request)
+	if err != nil {
+		return r, fmt.Errorf("unable to marshal create agent action request into JSON: %w", err)
+	}
+
+	apiURL := fmt.Sprintf(fleetAgentActionsAPI, request.AgentID)
+	resp, err := client.Connection.SendWithContext(ctx, http.MethodPost, apiURL, nil, nil, bytes.NewReader(reqBody))
+	if err != nil {
+		return r, fmt.Errorf("error calling create agent action API: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var actionResp struct {
+		Item AgentAction `json:"item"`
+	}
+	err = client.readJSONResponse(resp, &actionResp)
+	return actionResp.Item, err
+}
+
+// GetAgentActions lists the recent actions of the requested agent
+func (client *Client) GetAgentActions(ctx context.Context, agentID string) (r []AgentAction, err error) {
+	apiURL := fmt.Sprintf(fleetAgentActionsAPI, agentID)
+	resp, err := client.Connection.SendWithContext(ctx, http.MethodGet, apiURL, nil, nil, nil)
+	if err != nil {
+		return r, fmt.Errorf("error calling list agent actions API: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var actionsResp struct {
+		Items []AgentAction `json:"items"`
+	}
+	err = client.readJSONResponse(resp, &actionsResp)
+	return actionsResp.Items, err
+}
+
+//
+// Agent Action Status
+//
+
+// Action states reported by the action status API
+const (
+	ActionStatusInProgress    = "IN_PROGRESS"
+	ActionStatusComplete      = "COMPLETE"
+	ActionStatusRolloutPassed = "ROLLOUT_PASSED"
+	ActionStatusExpired       = "EXPIRED"
+	ActionStatusCancelled     = "CANCELLED"
+	ActionStatusFailed        = "FAILED"
+)
+
+const defaultActionPollInterval = 5 * time.Second
+
+// ActionStatus is the progress of an action, like a bulk upgrade, across the
+// agents it targets
+type ActionStatus struct {
+	ActionID string `json:"actionId"`
+	Type     string `json:"type"`
+	Status   string `json:"status"`
+	// AgentsTargeted is the number of agents the action was dispatched to
+	AgentsTargeted int `json:"nbAgentsActioned"`
+	// AgentsCreated is the number of agents the action was created for so far
+	AgentsCreated int `json:"nbAgentsActionCreated"`
+	// AgentsAcked is the number of agents that acknowledged the action
+	AgentsAcked int `json:"nbAgentsAck"`
+	// AgentsFailed is the number of agents that failed to run the action
+	AgentsFailed   int    `json:"nbAgentsFailed"`
+	CreationTime   string `json:"creationTime,omitempty"`
+	CompletionTime string `json:"completionTime,omitempty"`
+}
+
+// AgentsInProgress returns the number of targeted agents that have neither
+// acknowledged nor failed the action yet
+func (s ActionStatus) AgentsInProgress() int {
+	if n := s.AgentsTargeted - s.AgentsAcked - s.AgentsFailed; n > 0 {
+		return n
+	}
+	return 0
+}
+
+// IsTerminal returns true if the action will not make further progress
+func (s ActionStatus) IsTerminal() bool {
+	switch s.Status {
+	case ActionStatusComplete, ActionStatusRolloutPassed, ActionStatusExpired, ActionStatusCancelled, ActionStatusFailed:
+		return true
+	default:
+		return false
+	}
+}
+
+// WithActionPollInterval sets how often StreamActionProgress and
+// WaitForAction poll the action status. A value <= 0 uses the default of five
+// seconds.
+func WithActionPollInterval(interval time.Duration) ClientOption {
+	return func(client *Client) {
+		client.actionPollInterval = interval
+	}
+}
+
+// ListActionStatusRequest paginates the actions to list
+type ListActionStatusRequest struct {
+	Page    int
+	PerPage int
+}
+
+// ListActionStatusResponse is the JSON response for ListActionStatus
+type ListActionStatusResponse struct {
+	Items []ActionStatus `json:"items"`
+}
+
+// ListActionStatus returns the status of the recent agent actions, newest
+// first
+func (client *Client) ListActionStatus(ctx context.Context, request ListActionStatusRequest) (r ListActionStatusResponse, err error) {
+	var q url.Values
+	if request.Page > 0 || request.PerPage > 0 {
+		q = make(url.Values)
+	}
+	if request.Page > 0 {
+		q.Add("page", strconv.Itoa(request.Page))
+	}
+	if request.PerPage > 0 {
+		q.Add("perPage", strconv.Itoa(request.PerPage))
+	}
+
+	resp, err := client.Connection.SendWithContext(ctx, http.MethodGet, fleetAgentActionStatusAPI, q, nil, nil)
+	if err != nil {
+		return r, fmt.Errorf("error calling action status API: %w", err)
+	}
+	defer resp.Body.Close()
+
+	err = client.readJSONResponse(resp, &r)
+	return r, err
+}
+
+// GetActionStatus returns the status of the action with the given ID
+func (client *Client) GetActionStatus(ctx context.Context, actionID string) (r ActionStatus, err error) {
+	statusResp, err := client.ListActionStatus(ctx, ListActionStatusRequest{PerPage: 1000})
+	if err != nil {
+		return r, err
+	}
+
+	for _, item := range statusResp.Items {
+		if item.ActionID == actionID {
+			return item, nil
+		}
+	}
+	return r, &APIError{StatusCode: http.StatusNotFound, Err: fmt.Errorf("action %s not found", actionID)}
+}
+
+// CancelAction cancels the in-flight action with the given ID, like a
+// scheduled upgrade. Agents that did not run the action yet will not run it.
+// It returns the cancel action created by Fleet.
+func (client *Client) CancelAction(ctx context.Context, actionID string) (r AgentAction, err error) {
+	apiURL := fmt.Sprintf(fleetAgentActionCancelAPI, actionID)
+	resp, err := client.Connection.SendWithContext(ctx, http.MethodPost, apiURL, nil, nil, nil)
+	if err != nil {
+		return r, fmt.Errorf("error calling cancel action API: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var actionResp struct {
+		Item AgentAction `json:"item"`
+	}
+	err = client.readJSONResponse(resp, &actionResp)
+	return actionResp.Item, err
+}
+
+// StreamActionProgress polls the status of the action with the given ID and
+// calls fn every time it changed, starting with the first status received.
+// It returns nil once the action reached a terminal state, or an error if ctx
+// is done or the status could not be read.
+//
+// fn is called synchronously from the poll loop, which does not poll again
+// until fn returned. fn must therefore return quickly and hand off any long
+// running work, like rendering, to another goroutine.
+func (client *Client) StreamActionProgress(ctx context.Context, actionID string, fn func(ActionStatus)) error {
+	_, err := client.pollActionStatus(ctx, actionID, fn)
+	return err
+}
+
+// WaitForAction polls the status of the action with the given ID until it
+// reached a terminal state and returns the final status
+func (client *Client) WaitForAction(ctx context.Context, actionID string) (ActionStatus, error) {
+	return client.pollActionStatus(ctx, actionID, nil)
+}
+
+func (client *Client) pollActionStatus(ctx context.Context, actionID string, fn func(ActionStatus)) (ActionStatus, error) {
+	interval := client.actionPollInterval
+	if interval <= 0 {
+		interval = defaultActionPollInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var last ActionStatus
+	for first := true; ; first = false {
+		status, err := client.GetActionStatus(ctx, actionID)
+		if err != nil {
+			return last, err
+		}
+
+		if fn != nil && (first || status != last) {
+			fn(status)
+		}
+		last = status
+		if status.IsTerminal() {
+			return status, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return last, fmt.Errorf("waiting for action %s: %w", actionID, ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+//
+// List Agent Activity
+//
+
+// ListAgentActivityRequest filters and paginates the agent activity to list
+type ListAgentActivityRequest struct {
+	Page    int
+	PerPage int
+	// Since only lists the actions created since the given time, if set.
+	Since time.Time
+	// Until only lists the actions created before the given time, if set.
+	// Kibana has no such filter, the actions are filtered by the client.
+	Until time.Time
+}
+
+// ListAgentActivityResponse is the JSON response for ListAgentActivity
+type ListAgentActivityResponse struct {
+	Items []ActionStatus `json:"items"`
+}
+
+// CreatedAt returns the time the action was created at
+func (s ActionStatus) CreatedAt() (time.Time, error) {
+	return time.Parse(time.RFC3339Nano, s.CreationTime)
+}
+
+// ListAgentActivity returns the actions taken on agents, most recent first,
+// as shown in the agent activity feed of Fleet
+func (client *Client) ListAgentActivity(ctx context.Context, request ListAgentActivityRequest) (r ListAgentActivityResponse, err error) {
+	q := make(url.Values)
+	if request.Page > 0 {
+		q.Add("page", strconv.Itoa(request.Page))
+	}
+	if request.PerPage > 0 {
+		q.Add("perPage", strconv.Itoa(request.PerPage))
+	}
+	if !request.Since.IsZero() {
+		q.Add("date", request.Since.UTC().Format(time.RFC3339))
+	}
+
+	resp, err := client.Connection.SendWithContext(ctx, http.MethodGet, fleetAgentActionStatusAPI, q, nil, nil)
+	if err != nil {
+		return r, fmt.Errorf("error calling agent activity API: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if err = client.readJSONResponse(resp, &r); err != nil {
+		return r, err
+	}
+
+	if !request.Until.IsZero() {
+		items := r.Items[:0]
+		for _, item := range r.Items {
+			created, err := item.CreatedAt()
+			if err != nil {
+				return r, fmt.Errorf("parsing creation time of action %s: %w", item.ActionID, err)
+			}
+			if created.Before(request.Until) {
+				items = append(items, item)
+			}
+		}
+		r.Items = items
+	}
+	return r, nil
+}
+
+//
+// List Fleet Server Hosts
+//
+
+// FleetServerHost handles JSON data for fleet server info
+type FleetServerHost struct {
+	ID              string   `json:"id"`
+	Name            string   `json:"name"`
+	IsDefault       bool     `json:"is_default"`
+	HostURLs        []string `json:"host_urls"`
+	IsPreconfigured bool     `json:"is_preconfigured"`
+	ProxyID         string   `json:"proxy_id,omitempty"`
+}
+
+// ListFleetServerHostsRequest is currently unused
+type ListFleetServerHostsRequest struct {
+	// For future use
+}
+
+// ListFleetServerHostsResponse is the JSON response for ListFleetServerHosts
+type ListFleetServerHostsResponse struct {
+	Items []FleetServerHost `json:"items"`
+}
+
+// ListFleetServerHosts returns a list of fleet server hosts
+func (client *Client) ListFleetServerHosts(ctx context.Context, _ ListFleetServerHostsRequest) (r ListFleetServerHostsResponse, err error) {
+	resp, err := client.sendGet(ctx, fleetFleetServerHostsAPI, nil)
+	if err != nil {
+		return r, fmt.Errorf("error calling list fleet server hosts API: %w", err)
+	}
+	defer resp.Body.Close()
+
+	err = client.readCachedJSONResponse(resp, &r)
+
+	return r, err
+}
+
+//
+// Get Fleet Server Host
+//
+
+// GetFleetServerHostRequest is the ID for a request via GetFleetServerHost
+type GetFleetServerHostRequest struct {
+	ID string
+}
+
+// GetFleetServerHostResponse is the JSON respose from GetFleetServerHost
+type GetFleetServerHostResponse FleetServerHost
+
+// GetFleetServerHost returns data on a fleet server
+func (client *Client) GetFleetServerHost(ctx context.Context, request GetFleetServerHostRequest) (r GetFleetServerHostResponse, err error) {
+	apiURL := fmt.Sprintf(fleetFleetServerHostAPI, request.ID)
+
+	resp, err := client.sendGet(ctx, apiURL, nil)
+	if err != nil {
+		return r, fmt.Errorf("error calling get fleet server hosts API: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var fleetResp struct {
+		Item GetFleetServerHostResponse `json:"item"`
+	}
+	err = client.readCachedJSONResponse(resp, &fleetResp)
+
+	return fleetResp.Item, err
+}
+
+//
+// Update Fleet Server Host
+//
+
+// UpdateFleetServerHostRequest is the JSON request for updating a fleet server
+// host. Only the fields that are set are changed.
+type UpdateFleetServerHostRequest struct {
+	ID        string   `json:"-"` // ID is not part of the request body send to the Fleet API
+	Name      string   `json:"name,omitempty"`
+	HostURLs  []string `json:"host_urls,omitempty"`
+	IsDefault *bool    `json:"is_default,omitempty"`
+	// ProxyID attaches the proxy with this ID to the fleet server host
+	ProxyID string `json:"proxy_id,omitempty"`
+}
+
+// UpdateFleetServerHost updates a fleet server host
+func (client *Client) UpdateFleetServerHost(ctx context.Context, request UpdateFleetServerHostRequest) (r FleetServerHost, err error) {
+	reqBody, err := client.codec().Marshal(request)
+	if err != nil {
+		return r, fmt.Errorf("unable to marshal update fleet server host request into JSON: %w", err)
+	}
+
+	apiURL := fmt.Sprintf(fleetFleetServerHostAPI, request.ID)
+	resp, err := client.Connection.SendWithContext(ctx, http.MethodPut, apiURL, nil, nil, bytes.NewReader(reqBody))
+	if err != nil {
+		return r, fmt.Errorf("error calling update fleet server host API: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var fleetResp struct {
+		Item FleetServerHost `json:"item"`
+	}
+	err = client.readJSONResponse(resp, &fleetResp)
+	return fleetResp.Item, err
+}
+
+//
+// Create Fleet Server Host
+//
+
+// CreateFleetServerHostRequest is the JSON request for creating a fleet server
+// host
+type CreateFleetServerHostRequest struct {
+	// ID of the fleet server host, Fleet generates one if empty
+	ID       string   `json:"id,omitempty"`
+	Name     string   `json:"name"`
+	HostURLs []string `json:"host_urls"`
+	// IsDefault makes the fleet server host the one used by agent policies
+	// without fleet server host
+	IsDefault bool   `json:"is_default"`
+	ProxyID   string `json:"proxy_id,omitempty"`
+}
+
+// CreateFleetServerHost creates a fleet server host
+func (client *Client) CreateFleetServerHost(ctx context.Context, request CreateFleetServerHostRequest) (r FleetServerHost, err error) {
+	reqBody, err := client.codec().Marshal(request)
+	if err != nil {
+		return r, fmt.Errorf("unable to marshal create fleet server host request into JSON: %w", err)
+	}
+
+	resp, err := client.Connection.SendWithContext(ctx, http.MethodPost, fleetFleetServerHostsAPI, nil, nil, bytes.NewReader(reqBody))
+	if err != nil {
+		return r, fmt.Errorf("error calling create fleet server host API: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var fleetResp struct {
+		Item FleetServerHost `json:"item"`
+	}
+	err = client.readJSONResponse(resp, &fleetResp)
+	return fleetResp.Item, err
+}
+
+//
+// Delete Fleet Server Host
+//
+
+// DeleteFleetServerHostRequest is the ID of the fleet server host to delete
+type DeleteFleetServerHostRequest struct {
+	ID string
+}
+
+// DeleteFleetServerHost deletes a fleet server host. Fleet rejects the
+// deletion of the default and of preconfigured fleet server hosts.
+func (client *Client) DeleteFleetServerHost(ctx context.Context, request DeleteFleetServerHostRequest) error {
+	apiURL := fmt.Sprintf(fleetFleetServerHostAPI, request.ID)
+	resp, err := client.Connection.SendWithContext(ctx, http.MethodDelete, apiURL, nil, nil, nil)
+	if err != nil {
+		return fmt.Errorf("error calling delete fleet server host API: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var deleteResp struct {
+		ID string `json:"id"`
+	}
+	return client.readJSONResponse(resp, &deleteResp)
+}
+
+//
+// Fleet Setup
+//
+
+// FleetSetupResponse is the JSON response of the Fleet setup APIs
+type FleetSetupResponse struct {
+	IsInitialized bool `json:"isInitialized"`
+	// NonFatalErrors are the errors of setup steps that did not prevent the
+	// initialization, e.g. a preconfigured package that failed to install
+	NonFatalErrors []FleetSetupError `json:"nonFatalErrors,omitempty"`
+}
+
+// FleetSetupError is a non fatal error of the Fleet setup
+type FleetSetupError struct {
+	Name    string `json:"name"`
+	Message string `json:"message"`
+}
+
+// Setup initializes Fleet: it installs the default packages and creates the
+// preconfigured policies and outputs. It is idempotent and returns once the
+// setup is done, which avoids waiting for the implicit setup Kibana runs on a
+// fresh instance.
+func (client *Client) Setup(ctx context.Context) (r FleetSetupResponse, err error) {
+	return client.sendSetup(ctx, "Fleet setup", fleetSetupAPI)
+}
+
+// AgentsSetup initializes the parts of Fleet needed to enroll agents, like the
+// Fleet Server service token and the agent policies. It is idempotent.
+func (client *Client) AgentsSetup(ctx context.Context) (r FleetSetupResponse, err error) {
+	return client.sendSetup(ctx, "agents setup", fleetAgentsSetupAPI)
+}
+
+func (client *Client) sendSetup(ctx context.Context, name, apiURL string) (r FleetSetupResponse, err error) {
+	resp, err := client.Connection.SendWithContext(ctx, http.MethodPost, apiURL, nil, nil, nil)
+	if err != nil {
+		return r, fmt.Errorf("error calling %s API: %w", name, err)
+	}
+	defer resp.Body.Close()
+
+	err = client.readJSONResponse(resp, &r)
+	return r, err
+}
+
+//
+// Fleet Settings
+//
+
+// FleetSettings are the global settings of Fleet
+// See https://github.com/elastic/kibana/blob/v8.15.0/x-pack/plugins/fleet/common/openapi/components/schemas/fleet_settings_response.yaml
+type FleetSettings struct {
+	ID                            string                          `json:"id"`
+	HasSeenAddDataNotice          bool                            `json:"has_seen_add_data_notice"`
+	PrereleaseIntegrationsEnabled bool                            `json:"prerelease_integrations_enabled"`
+	DeleteUnenrolledAgents        *DeleteUnenrolledAgentsSettings `json:"delete_unenrolled_agents,omitempty"`
+	// FleetServerHosts is only used by Kibana versions before 8.6, later
+	// versions manage them with the fleet server hosts API.
+	FleetServerHosts []string `json:"fleet_server_hosts,omitempty"`
+	Version          string   `json:"version,omitempty"`
+}
+
+// DeleteUnenrolledAgentsSettings configures the removal of unenrolled agents
+type DeleteUnenrolledAgentsSettings struct {
+	Enabled         bool `json:"enabled"`
+	IsPreconfigured bool `json:"is_preconfigured"`
+}
+
+// UpdateFleetSettingsRequest is the JSON request for updating Fleet settings.
+// Only the fields that are set are changed.
+type UpdateFleetSettingsRequest struct {
+	HasSeenAddDataNotice          *bool                           `json:"has_seen_add_data_notice,omitempty"`
+	PrereleaseIntegrationsEnabled *bool                           `json:"prerelease_integrations_enabled,omitempty"`
+	DeleteUnenrolledAgents        *DeleteUnenrolledAgentsSettings `json:"delete_unenrolled_agents,omitempty"`
+	FleetServerHosts              []string                        `json:"fleet_server_hosts,omitempty"`
+}
+
+// GetFleetSettings returns the global Fleet settings
+func (client *Client) GetFleetSettings(ctx context.Context) (r FleetSettings, err error) {
+	resp, err := client.sendGet(ctx, fleetSettingsAPI, nil)
+	if err != nil {
+		return r, fmt.Errorf("error calling get fleet settings API: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var settingsResp struct {
+		Item FleetSettings `json:"item"`
+	}
+	err = client.readCachedJSONResponse(resp, &settingsResp)
+	return settingsResp.Item, err
+}
+
+// UpdateFleetSettings changes the global Fleet settings and returns the
+// updated settings
+func (client *Client) UpdateFleetSettings(ctx context.Context, request UpdateFleetSettingsRequest) (r FleetSettings, err error) {
+	reqBody, err := client.codec().Marshal(request)
+	if err != nil {
+		return r, fmt.Errorf("unable to marshal update fleet settings request into JSON: %w", err)
+	}
+
+	resp, err := client.Connection.SendWithContext(ctx, http.MethodPut, fleetSettingsAPI, nil, nil, bytes.NewReader(reqBody))
+	if err != nil {
+		return r, fmt.Errorf("error calling update fleet settings API: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var settingsResp struct {
+		Item FleetSettings `json:"item"`
+	}
+	err = client.readJSONResponse(resp, &settingsResp)
+	return settingsResp.Item, err
+}
+
+//
+// Fleet Outputs
+//
+
+// OutputType is the type of a Fleet output
+type OutputType string
+
+// Output types supported by Fleet
+const (
+	OutputTypeElasticsearch OutputType = "elasticsearch"
+	OutputTypeLogstash      OutputType = "logstash"
+	OutputTypeKafka         OutputType = "kafka"
+)
+
+// Output is the JSON of a Fleet output, used by the create and update
+// requests, and returned by the outputs API. Fields apply to all output types
+// unless noted otherwise.
+// See https://github.com/elastic/kibana/blob/v8.12.0/x-pack/plugins/fleet/common/openapi/components/schemas/output_create_request.yaml
+type Output struct {
+	ID                   string     `json:"id,omitempty"`
+	Name                 string     `json:"name"`
+	Type                 OutputType `json:"type"`
+	Hosts                []string   `json:"hosts,omitempty"`
+	IsDefault            bool       `json:"is_default"`
+	IsDefaultMonitoring  bool       `json:"is_default_monitoring"`
+	IsPreconfigured      bool       `json:"is_preconfigured,omitempty"`
+	CASHA256             string     `json:"ca_sha256,omitempty"`
+	CATrustedFingerprint string     `json:"ca_trusted_fingerprint,omitempty"`
+	ConfigYAML           string     `json:"config_yaml,omitempty"`
+	SSL                  *OutputSSL `json:"ssl,omitempty"`
+	// ProxyID is the ID of the proxy used to connect to the output
+	ProxyID string `json:"proxy_id,omitempty"`
+
+	// Kafka outputs only
+	ClientID     string `json:"client_id,omitempty"`
+	Version      string `json:"version,omitempty"`
+	Compression  string `json:"compression,omitempty"`
+	AuthType     string `json:"auth_type,omitempty"`
+	Username     string `json:"username,omitempty"`
+	Password     string `json:"password,omitempty"`
+	Partition    string `json:"partition,omitempty"`
+	Topic        string `json:"topic,omitempty"`
+	RequiredAcks int    `json:"required_acks,omitempty"`
+}
+
+// OutputSSL is the TLS configuration of an output
+type OutputSSL struct {
+	CertificateAuthorities []string `json:"certificate_authorities,omitempty"`
+	Certificate            string   `json:"certificate,omitempty"`
+	Key                    string   `json:"key,omitempty"`
+}
+
+// ListOutputsResponse is the JSON response for ListOutputs
+type ListOutputsResponse struct {
+	Items   []Output `json:"items"`
+	Total   int      `json:"total"`
+	Page    int      `json:"page"`
+	PerPage int      `json:"perPage"`
+}
+
+// ListOutputs returns the outputs configured in Fleet
+func (client *Client) ListOutputs(ctx context.Context) (r ListOutputsResponse, err error) {
+	resp, err := client.sendGet(ctx, fleetOutputsAPI, nil)
+	if err != nil {
+		return r, fmt.Errorf("error calling list outputs API: %w", err)
+	}
+	defer resp.Body.Close()
+
+	err = client.readCachedJSONResponse(resp, &r)
+	return r, err
+}
+
+// CreateOutput creates a new output. If the ID is empty, Fleet generates one.
+func (client *Client) CreateOutput(ctx context.Context, output Output) (r Output, err error) {
+	reqBody, err := client.codec().Marshal(output)
+	if err != nil {
+		return r, fmt.Errorf("unable to marshal create output request into JSON: %w", err)
+	}
+
+	resp, err := client.Connection.SendWithContext(ctx, http.MethodPost, fleetOutputsAPI, nil, nil, bytes.NewReader(reqBody))
+	if err != nil {
+		return r, fmt.Errorf("error calling create output API: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var outputResp struct {
+		Item Output `json:"item"`
+	}
+	err = client.readJSONResponse(resp, &outputResp)
+	return outputResp.Item, err
+}
+
+// UpdateOutput replaces the output with the given ID. The ID of output is
+// ignored.
+func (client *Client) UpdateOutput(ctx context.Context, id string, output Output) (r Output, err error) {
+	output.ID = ""
+	reqBody, err := client.codec().Marshal(output)
+	if err != nil {
+		return r, fmt.Errorf("unable to marshal update output request into JSON: %w", err)
+	}
+
+	apiURL := fmt.Sprintf(fleetOutputAPI, id)
+	resp, err := client.Connection.SendWithContext(ctx, http.MethodPut, apiURL, nil, nil, bytes.NewReader(reqBody))
+	if err != nil {
+		return r, fmt.Errorf("error calling update output API: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var outputResp struct {
+		Item Output `json:"item"`
+	}
+	err = client.readJSONResponse(resp, &outputResp)
+	return outputResp.Item, err
+}
+
+// DeleteOutput deletes the output with the given ID. Fleet refuses to delete
+// the default output.
+func (client *Client) DeleteOutput(ctx context.Context, id string) error {
+	apiURL := fmt.Sprintf(fleetOutputAPI, id)
+	resp, err := client.Connection.SendWithContext(ctx, http.MethodDelete, apiURL, nil, nil, nil)
+	if err != nil {
+		return fmt.Errorf("error calling delete output API: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var deleteResp struct {
+		ID string `json:"id"`
+	}
+	return client.readJSONResponse(resp, &deleteResp)
+}
+
+// OutputHealthState is the health of an output as last reported by the
+// agents using it
+type OutputHealthState string
+
+// Output health states reported by Fleet
+const (
+	OutputHealthHealthy  OutputHealthState = "HEALTHY"
+	OutputHealthDegraded OutputHealthState = "DEGRADED"
+	// OutputHealthUnknown is reported while no agent reported the health of
+	// the output.
+	OutputHealthUnknown OutputHealthState = "UNKNOWN"
+)
+
+// OutputHealth is the JSON response of the output health API
+type OutputHealth struct {
+	State   OutputHealthState `json:"state"`
+	Message string            `json:"message"`
+	// Timestamp of the last health report, empty if there is none
+	Timestamp string `json:"timestamp"`
+}
+
+// GetOutputHealth returns the health of the output with the given ID, as last
+// reported by the agents connecting to it. It is reported for remote
+// Elasticsearch, Logstash and Kafka outputs.
+func (client *Client) GetOutputHealth(ctx context.Context, id string) (r OutputHealth, err error) {
+	if err := client.requireVersion("GetOutputHealth", minVersionOutputHealth); err != nil {
+		return r, err
+	}
+
+	apiURL := fmt.Sprintf(fleetOutputHealthAPI, id)
+	resp, err := client.Connection.SendWithContext(ctx, http.MethodGet, apiURL, nil, nil, nil)
+	if err != nil {
+		return r, fmt.Errorf("error calling output health API: %w", err)
+	}
+	defer resp.Body.Close()
+
+	err = client.readJSONResponse(resp, &r)
+	return r, err
+}
+
+//
+// Fleet Proxies
+//
+
+// Proxy is the JSON of a Fleet proxy, used by the create and update requests,
+// and returned by the proxies API. A proxy is used by agents to connect to
+// fleet server hosts, outputs and download sources that reference its ID.
+// See https://github.com/elastic/kibana/blob/v8.12.0/x-pack/plugins/fleet/common/openapi/components/schemas/proxies.yaml
+type Proxy struct {
+	ID                     string            `json:"id,omitempty"`
+	Name                   string            `json:"name"`
+	URL                    string            `json:"url"`
+	ProxyHeaders           map[string]string `json:"proxy_headers,omitempty"`
+	CertificateAuthorities string            `json:"certificate_authorities,omitempty"`
+	Certificate            string            `json:"certificate,omitempty"`
+	CertificateKey         string            `json:"certificate_key,omitempty"`
+	IsPreconfigured        bool              `json:"is_preconfigured,omitempty"`
+}
+
+// ListProxiesResponse is the JSON response for ListProxies
+type ListProxiesResponse struct {
+	Items   []Proxy `json:"items"`
+	Total   int     `json:"total"`
+	Page    int     `json:"page"`
+	PerPage int     `json:"perPage"`
+}
+
+// ListProxies returns the proxies configured in Fleet
+func (client *Client) ListProxies(ctx context.Context) (r ListProxiesResponse, err error) {
+	resp, err := client.sendGet(ctx, fleetProxiesAPI, nil)
+	if err != nil {
+		return r, fmt.Errorf("error calling list proxies API: %w", err)
+	}
+	defer resp.Body.Close()
+
+	err = client.readCachedJSONResponse(resp, &r)
+	return r, err
+}
+
+// GetProxy returns the proxy with the given ID
+func (client *Client) GetProxy(ctx context.Context, id string) (r Proxy, err error) {
+	apiURL := fmt.Sprintf(fleetProxyAPI, id)
+	resp, err := client.sendGet(ctx, apiURL, nil)
+	if err != nil {
+		return r, fmt.Errorf("error calling get proxy API: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var proxyResp struct {
+		Item Proxy `json:"item"`
+	}
+	err = client.readCachedJSONResponse(resp, &proxyResp)
+	return proxyResp.Item, err
+}
+
+// CreateProxy creates a new proxy. If the ID is empty, Fleet generates one.
+func (client *Client) CreateProxy(ctx context.Context, proxy Proxy) (r Proxy, err error) {
+	reqBody, err := client.codec().Marshal(proxy)
+	if err != nil {
+		return r, fmt.Errorf("unable to marshal create proxy request into JSON: %w", err)
+	}
+
+	resp, err := client.Connection.SendWithContext(ctx, http.MethodPost, fleetProxiesAPI, nil, nil, bytes.NewReader(reqBody))
+	if err != nil {
+		return r, fmt.Errorf("error calling create proxy API: %w", err)
 	}
 	defer resp.Body.Close()
 
-	err = readJSONResponse(resp, &r)
-
-	return r, err
+	var proxyResp struct {
+		Item Proxy `json:"item"`
+	}
+	err = client.readJSONResponse(resp, &proxyResp)
+	return proxyResp.Item, err
 }
 
-//
-// Get Fleet Server Host
-//
-
-// GetFleetServerHostRequest is the ID for a request via GetFleetServerHost
-type GetFleetServerHostRequest struct {
-	ID string
-}
+// UpdateProxy replaces the proxy with the given ID. The ID of proxy is
+// ignored.
+func (client *Client) UpdateProxy(ctx context.Context, id string, proxy Proxy) (r Proxy, err error) {
+	proxy.ID = ""
+	reqBody, err := client.codec().Marshal(proxy)
+	if err != nil {
+		return r, fmt.Errorf("unable to marshal update proxy request into JSON: %w", err)
+	}
 
-// GetFleetServerHostResponse is the JSON respose from GetFleetServerHost
-type GetFleetServerHostResponse FleetServerHost
+	apiURL := fmt.Sprintf(fleetProxyAPI, id)
+	resp, err := client.Connection.SendWithContext(ctx, http.MethodPut, apiURL, nil, nil, bytes.NewReader(reqBody))
+	if err != nil {
+		return r, fmt.Errorf("error calling update proxy API: %w", err)
+	}
+	defer resp.Body.Close()
 
-// GetFleetServerHost returns data on a fleet server
-func (client *Client) GetFleetServerHost(ctx context.Context, request GetFleetServerHostRequest) (r GetFleetServerHostResponse, err error) {
-	apiURL := fmt.Sprintf(fleetFleetServerHostAPI, request.ID)
+	var proxyResp struct {
+		Item Proxy `json:"item"`
+	}
+	err = client.readJSONResponse(resp, &proxyResp)
+	return proxyResp.Item, err
+}
 
-	resp, err := client.Connection.SendWithContext(ctx, http.MethodGet, apiURL, nil, nil, nil)
+// DeleteProxy deletes the proxy with the given ID. Fleet refuses to delete a
+// proxy that is still used.
+func (client *Client) DeleteProxy(ctx context.Context, id string) error {
+	apiURL := fmt.Sprintf(fleetProxyAPI, id)
+	resp, err := client.Connection.SendWithContext(ctx, http.MethodDelete, apiURL, nil, nil, nil)
 	if err != nil {
-		return r, fmt.Errorf("error calling get fleet server hosts API: %w", err)
+		return fmt.Errorf("error calling delete proxy API: %w", err)
 	}
 	defer resp.Body.Close()
 
-	var fleetResp struct {
-		Item GetFleetServerHostResponse `json:"item"`
+	var deleteResp struct {
+		ID string `json:"id"`
 	}
-	err = readJSONResponse(resp, &fleetResp)
-
-	return fleetResp.Item, err
+	return client.readJSONResponse(resp, &deleteResp)
 }
 
 //
@@ -569,6 +2740,7 @@ type PackagePolicy struct {
 	PolicyID    string                      `json:"policy_id"`
 	Name        string                      `json:"name"`
 	Description string                      `json:"description"`
+	Vars        map[string]interface{}      `json:"vars,omitempty"`
 }
 
 // https://www.elastic.co/guide/en/fleet/8.8/fleet-apis.html#delete_package_policy_200_response
@@ -576,10 +2748,11 @@ type DeletePackagePolicyResponse struct {
 	ID string `json:"id"`
 }
 
-// InstallFleetPackage uses the Fleet package policies API install an integration package as specified in the request.
+// CreatePackagePolicy uses the Fleet package policies API to add an
+// integration package to an agent policy as specified in the request.
 // Note that the package policy ID and Name must be globally unique across all installed packages.
-func (client *Client) InstallFleetPackage(ctx context.Context, req PackagePolicyRequest) (r PackagePolicyResponse, err error) {
-	reqBytes, err := json.Marshal(&req)
+func (client *Client) CreatePackagePolicy(ctx context.Context, req PackagePolicyRequest) (r PackagePolicyResponse, err error) {
+	reqBytes, err := client.codec().Marshal(&req)
 	if err != nil {
 		return r, fmt.Errorf("marshalling request json: %w", err)
 	}
@@ -596,14 +2769,48 @@ func (client *Client) InstallFleetPackage(ctx context.Context, req PackagePolicy
 	}
 	defer resp.Body.Close()
 
-	err = readJSONResponse(resp, &r)
+	err = client.readJSONResponse(resp, &r)
 
 	return r, err
 }
 
-// DeleteFleetPackage deletes integration with packagePolicyID from the policy ID
-func (client *Client) DeleteFleetPackage(ctx context.Context, packagePolicyID string) (r DeletePackagePolicyResponse, err error) {
-	u, err := url.JoinPath(fleetPackagePoliciesAPI, packagePolicyID)
+// GetPackagePolicy returns the package policy with the given ID
+func (client *Client) GetPackagePolicy(ctx context.Context, id string) (r PackagePolicyResponse, err error) {
+	apiURL := fmt.Sprintf(fleetPackagePolicyAPI, id)
+	resp, err := client.sendGet(ctx, apiURL, nil)
+	if err != nil {
+		return r, fmt.Errorf("error calling get package policy API: %w", err)
+	}
+	defer resp.Body.Close()
+
+	err = client.readCachedJSONResponse(resp, &r)
+	return r, err
+}
+
+// UpdatePackagePolicy replaces the package policy with the given ID. The ID
+// of the request is ignored.
+func (client *Client) UpdatePackagePolicy(ctx context.Context, id string, req PackagePolicyRequest) (r PackagePolicyResponse, err error) {
+	req.ID = ""
+	reqBytes, err := client.codec().Marshal(&req)
+	if err != nil {
+		return r, fmt.Errorf("unable to marshal update package policy request into JSON: %w", err)
+	}
+
+	apiURL := fmt.Sprintf(fleetPackagePolicyAPI, id)
+	resp, err := client.Connection.SendWithContext(ctx, http.MethodPut, apiURL, nil, nil, bytes.NewReader(reqBytes))
+	if err != nil {
+		return r, fmt.Errorf("error calling update package policy API: %w", err)
+	}
+	defer resp.Body.Close()
+
+	err = client.readJSONResponse(resp, &r)
+	return r, err
+}
+
+// DeletePackagePolicy deletes the package policy with the given ID, removing
+// the integration from its agent policy
+func (client *Client) DeletePackagePolicy(ctx context.Context, id string) (r DeletePackagePolicyResponse, err error) {
+	u, err := url.JoinPath(fleetPackagePoliciesAPI, id)
 	if err != nil {
 		return r, err
 	}
@@ -621,11 +2828,466 @@ func (client *Client) DeleteFleetPackage(ctx context.Context, packagePolicyID st
 	}
 	defer resp.Body.Close()
 
-	err = readJSONResponse(resp, &r)
+	err = client.readJSONResponse(resp, &r)
+
+	return r, err
+}
+
+// InstallFleetPackage uses the Fleet package policies API install an integration package as specified in the request.
+// Note that the package policy ID and Name must be globally unique across all installed packages.
+//
+// Deprecated: use CreatePackagePolicy.
+func (client *Client) InstallFleetPackage(ctx context.Context, req PackagePolicyRequest) (PackagePolicyResponse, error) {
+	return client.CreatePackagePolicy(ctx, req)
+}
+
+// DeleteFleetPackage deletes integration with packagePolicyID from the policy ID
+//
+// Deprecated: use DeletePackagePolicy.
+func (client *Client) DeleteFleetPackage(ctx context.Context, packagePolicyID string) (DeletePackagePolicyResponse, error) {
+	return client.DeletePackagePolicy(ctx, packagePolicyID)
+}
+
+//
+// EPM Packages
+//
+
+// PackageAsset is a Kibana or Elasticsearch asset of an integration package
+type PackageAsset struct {
+	ID   string `json:"id"`
+	Type string `json:"type"`
+}
+
+// PackageStatus is the installation status of an integration package
+type PackageStatus string
+
+// Installation statuses reported by Fleet
+const (
+	PackageStatusInstalled     PackageStatus = "installed"
+	PackageStatusNotInstalled  PackageStatus = "not_installed"
+	PackageStatusInstalling    PackageStatus = "installing"
+	PackageStatusInstallFailed PackageStatus = "install_failed"
+)
+
+// PackageInfo is the metadata of an integration package
+type PackageInfo struct {
+	Name        string        `json:"name"`
+	Title       string        `json:"title"`
+	Version     string        `json:"version"`
+	Description string        `json:"description"`
+	Type        string        `json:"type"`
+	Categories  []string      `json:"categories"`
+	Status      PackageStatus `json:"status"`
+	// InstallationInfo is set by recent Kibana versions if the package is
+	// installed. The installed version can differ from Version.
+	InstallationInfo *PackageInstallationInfo `json:"installationInfo,omitempty"`
+}
+
+// PackageInstallationInfo describes the installed version of a package
+type PackageInstallationInfo struct {
+	Version       string `json:"version"`
+	InstallStatus string `json:"install_status"`
+}
+
+// ListPackagesRequest filters the integration packages to list
+type ListPackagesRequest struct {
+	// Category only lists the packages of this category, e.g. `security`
+	Category string
+	// Prerelease lists the latest prerelease versions of the packages
+	Prerelease bool
+}
+
+// ListPackages returns the integration packages available in the package
+// registry and their installation status
+func (client *Client) ListPackages(ctx context.Context, request ListPackagesRequest) (r []PackageInfo, err error) {
+	var q url.Values
+	if request.Category != "" || request.Prerelease {
+		q = make(url.Values)
+	}
+	if request.Category != "" {
+		q.Add("category", request.Category)
+	}
+	if request.Prerelease {
+		q.Add("prerelease", "true")
+	}
+
+	resp, err := client.sendGet(ctx, fleetEPMPackagesAPI, q)
+	if err != nil {
+		return r, fmt.Errorf("error calling list packages API: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// Kibana versions before 8.0 return the packages in response instead of
+	// items.
+	var listResp struct {
+		Items    []PackageInfo `json:"items"`
+		Response []PackageInfo `json:"response"`
+	}
+	if err = client.readCachedJSONResponse(resp, &listResp); err != nil {
+		return r, err
+	}
+	if listResp.Items != nil {
+		return listResp.Items, nil
+	}
+	return listResp.Response, nil
+}
+
+// GetPackageRequest selects the integration package to read
+type GetPackageRequest struct {
+	Name string
+	// Version of the package. The latest version is returned if empty.
+	Version string
+	// Prerelease returns the latest prerelease version when Version is empty
+	Prerelease bool
+}
+
+// GetPackage returns the metadata of an integration package
+func (client *Client) GetPackage(ctx context.Context, request GetPackageRequest) (r PackageInfo, err error) {
+	apiURL, err := url.JoinPath(fleetEPMPackagesAPI, request.Name, request.Version)
+	if err != nil {
+		return r, err
+	}
+	var q url.Values
+	if request.Prerelease {
+		q = url.Values{"prerelease": []string{"true"}}
+	}
+
+	resp, err := client.sendGet(ctx, apiURL, q)
+	if err != nil {
+		return r, fmt.Errorf("error calling get package API: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var getResp struct {
+		Item     *PackageInfo `json:"item"`
+		Response *PackageInfo `json:"response"`
+	}
+	if err = client.readCachedJSONResponse(resp, &getResp); err != nil {
+		return r, err
+	}
+	switch {
+	case getResp.Item != nil:
+		return *getResp.Item, nil
+	case getResp.Response != nil:
+		return *getResp.Response, nil
+	default:
+		return r, fmt.Errorf("get package API returned no package %s", request.Name)
+	}
+}
+
+// InstallPackageRequest is the request for installing an integration package
+type InstallPackageRequest struct {
+	Name string `json:"-"`
+	// Version to install. The latest version is installed if empty.
+	Version string `json:"-"`
+	// Prerelease allows installing the latest prerelease version when Version
+	// is empty.
+	Prerelease bool `json:"-"`
+	// Force reinstalls the package if it is already installed, and allows
+	// installing unverified packages.
+	Force             bool `json:"force,omitempty"`
+	IgnoreConstraints bool `json:"ignore_constraints,omitempty"`
+}
+
+// InstallPackageResponse is the JSON response for InstallPackage
+type InstallPackageResponse struct {
+	Items []PackageAsset `json:"items"`
+	Meta  struct {
+		InstallSource string `json:"install_source"`
+	} `json:"_meta"`
+}
+
+// UninstallPackageRequest is the request for uninstalling an integration
+// package
+type UninstallPackageRequest struct {
+	Name    string `json:"-"`
+	Version string `json:"-"`
+	// Force uninstalls the package even if it is used by package policies.
+	Force bool `json:"force,omitempty"`
+}
+
+// UninstallPackageResponse is the JSON response for UninstallPackage
+type UninstallPackageResponse struct {
+	Items []PackageAsset `json:"items"`
+}
+
+// InstallPackage installs an integration package and its assets. Packages
+// must be installed before creating package policies using them.
+func (client *Client) InstallPackage(ctx context.Context, request InstallPackageRequest) (r InstallPackageResponse, err error) {
+	apiURL, err := url.JoinPath(fleetEPMPackagesAPI, request.Name, request.Version)
+	if err != nil {
+		return r, err
+	}
+	var q url.Values
+	if request.Prerelease {
+		q = url.Values{"prerelease": []string{"true"}}
+	}
+
+	reqBody, err := client.codec().Marshal(request)
+	if err != nil {
+		return r, fmt.Errorf("unable to marshal install package request into JSON: %w", err)
+	}
+
+	resp, err := client.Connection.SendWithContext(ctx, http.MethodPost, apiURL, q, nil, bytes.NewReader(reqBody))
+	if err != nil {
+		return r, fmt.Errorf("error calling install package API: %w", err)
+	}
+	defer resp.Body.Close()
+
+	err = client.readJSONResponse(resp, &r)
+	return r, err
+}
+
+// UninstallPackage removes an integration package and its assets
+func (client *Client) UninstallPackage(ctx context.Context, request UninstallPackageRequest) (r UninstallPackageResponse, err error) {
+	apiURL, err := url.JoinPath(fleetEPMPackagesAPI, request.Name, request.Version)
+	if err != nil {
+		return r, err
+	}
+
+	reqBody, err := client.codec().Marshal(request)
+	if err != nil {
+		return r, fmt.Errorf("unable to marshal uninstall package request into JSON: %w", err)
+	}
+
+	resp, err := client.Connection.SendWithContext(ctx, http.MethodDelete, apiURL, nil, nil, bytes.NewReader(reqBody))
+	if err != nil {
+		return r, fmt.Errorf("error calling uninstall package API: %w", err)
+	}
+	defer resp.Body.Close()
 
+	err = client.readJSONResponse(resp, &r)
 	return r, err
 }
 
+//
+// Fleet Server Policy
+//
+
+const (
+	fleetServerPackageName = "fleet_server"
+	fleetServerInputType   = "fleet-server"
+)
+
+// FleetServerSettings are the settings of the fleet-server integration, stored
+// as vars of its fleet-server input. Zero values are not sent on update.
+type FleetServerSettings struct {
+	Host           string
+	Port           int
+	MaxAgents      int
+	MaxConnections int
+	// Custom holds the YAML of the custom configuration of Fleet Server.
+	Custom string
+}
+
+// FleetServerPolicy is the fleet-server package policy attached to an agent
+// policy.
+type FleetServerPolicy struct {
+	// ID of the fleet-server package policy.
+	ID        string
+	Name      string
+	Namespace string
+	// PolicyID is the ID of the agent policy the package policy belongs to.
+	PolicyID string
+	Package  PackagePolicyRequestPackage
+	Revision int
+	Settings FleetServerSettings
+	// Inputs are the raw inputs of the package policy. They are sent back on
+	// update so settings not modelled by FleetServerSettings are preserved.
+	Inputs []mapstr.M
+}
+
+// UpdateFleetServerPolicyRequest is the request to update the settings of a
+// fleet-server package policy, usually built from the result of
+// GetFleetServerPolicy.
+type UpdateFleetServerPolicyRequest FleetServerPolicy
+
+type fleetServerPackagePolicy struct {
+	ID        string                      `json:"id,omitempty"`
+	Name      string                      `json:"name"`
+	Namespace string                      `json:"namespace"`
+	PolicyID  string                      `json:"policy_id"`
+	Package   PackagePolicyRequestPackage `json:"package"`
+	Revision  int                         `json:"revision,omitempty"`
+	Inputs    []mapstr.M                  `json:"inputs"`
+}
+
+// GetFleetServerPolicy returns the fleet-server package policy attached to the
+// agent policy with the given ID.
+func (client *Client) GetFleetServerPolicy(ctx context.Context, policyID string) (r FleetServerPolicy, err error) {
+	q := make(url.Values)
+	q.Add("kuery", NewKuery().
+		Field("ingest-package-policies.policy_id", policyID).
+		Field("ingest-package-policies.package.name", fleetServerPackageName).
+		String())
+
+	resp, err := client.sendGet(ctx, fleetPackagePoliciesAPI, q)
+	if err != nil {
+		return r, fmt.Errorf("error calling list package policies API: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var listResp struct {
+		Items []fleetServerPackagePolicy `json:"items"`
+	}
+	if err = client.readCachedJSONResponse(resp, &listResp); err != nil {
+		return r, err
+	}
+
+	for _, item := range listResp.Items {
+		if item.PolicyID == policyID && item.Package.Name == fleetServerPackageName {
+			return newFleetServerPolicy(item), nil
+		}
+	}
+	return r, fmt.Errorf("no %s package policy found for agent policy %s", fleetServerPackageName, policyID)
+}
+
+// UpdateFleetServerPolicy updates the settings of a fleet-server package policy.
+func (client *Client) UpdateFleetServerPolicy(ctx context.Context, request UpdateFleetServerPolicyRequest) (r FleetServerPolicy, err error) {
+	inputs, err := applyFleetServerSettings(request.Inputs, request.Settings)
+	if err != nil {
+		return r, err
+	}
+
+	reqBody, err := client.codec().Marshal(fleetServerPackagePolicy{
+		Name:      request.Name,
+		Namespace: request.Namespace,
+		PolicyID:  request.PolicyID,
+		Package:   request.Package,
+		Inputs:    inputs,
+	})
+	if err != nil {
+		return r, fmt.Errorf("unable to marshal update fleet server policy request into JSON: %w", err)
+	}
+
+	apiURL := fmt.Sprintf(fleetPackagePolicyAPI, request.ID)
+	resp, err := client.Connection.SendWithContext(ctx, http.MethodPut, apiURL, nil, nil, bytes.NewReader(reqBody))
+	if err != nil {
+		return r, fmt.Errorf("error calling update package policy API: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var updateResp struct {
+		Item fleetServerPackagePolicy `json:"item"`
+	}
+	if err = client.readJSONResponse(resp, &updateResp); err != nil {
+		return r, err
+	}
+	return newFleetServerPolicy(updateResp.Item), nil
+}
+
+func newFleetServerPolicy(p fleetServerPackagePolicy) FleetServerPolicy {
+	policy := FleetServerPolicy{
+		ID:        p.ID,
+		Name:      p.Name,
+		Namespace: p.Namespace,
+		PolicyID:  p.PolicyID,
+		Package:   p.Package,
+		Revision:  p.Revision,
+		Inputs:    p.Inputs,
+	}
+
+	input := fleetServerInput(p.Inputs)
+	if input == nil {
+		return policy
+	}
+	policy.Settings.Host, _ = fleetServerVar(input, "host").(string)
+	policy.Settings.Port = fleetServerIntVar(input, "port")
+	policy.Settings.MaxAgents = fleetServerIntVar(input, "max_agents")
+	policy.Settings.MaxConnections = fleetServerIntVar(input, "max_connections")
+	policy.Settings.Custom, _ = fleetServerVar(input, "custom").(string)
+	return policy
+}
+
+// applyFleetServerSettings returns a copy of inputs with the non-zero settings
+// set as vars of the fleet-server input.
+func applyFleetServerSettings(inputs []mapstr.M, settings FleetServerSettings) ([]mapstr.M, error) {
+	out := make([]mapstr.M, len(inputs))
+	for i, input := range inputs {
+		out[i] = input.Clone()
+	}
+
+	input := fleetServerInput(out)
+	if input == nil {
+		input = mapstr.M{
+			"type":    fleetServerInputType,
+			"enabled": true,
+		}
+		out = append(out, input)
+	}
+
+	vars := []struct {
+		name  string
+		value interface{}
+		set   bool
+	}{
+		{"host", settings.Host, settings.Host != ""},
+		{"port", settings.Port, settings.Port != 0},
+		{"max_agents", settings.MaxAgents, settings.MaxAgents != 0},
+		{"max_connections", settings.MaxConnections, settings.MaxConnections != 0},
+		{"custom", settings.Custom, settings.Custom != ""},
+	}
+	for _, v := range vars {
+		if !v.set {
+			continue
+		}
+		if err := setFleetServerVar(input, v.name, v.value); err != nil {
+			return nil, fmt.Errorf("setting fleet-server %s: %w", v.name, err)
+		}
+	}
+	return out, nil
+}
+
+// setFleetServerVar sets a var of the fleet-server input, keeping the list
+// form if the var currently holds a list of values.
+func setFleetServerVar(input mapstr.M, name string, value interface{}) error {
+	key := "vars." + name + ".value"
+	if current, err := input.GetValue(key); err == nil {
+		if _, ok := current.([]interface{}); ok {
+			value = []interface{}{value}
+		}
+	}
+	_, err := input.Put(key, value)
+	return err
+}
+
+func fleetServerInput(inputs []mapstr.M) mapstr.M {
+	for _, input := range inputs {
+		if t, _ := input["type"].(string); t == fleetServerInputType {
+			return input
+		}
+	}
+	return nil
+}
+
+// fleetServerVar returns the value of a var of the fleet-server input. Vars
+// that allow multiple values return their first value.
+func fleetServerVar(input mapstr.M, name string) interface{} {
+	v, err := input.GetValue("vars." + name + ".value")
+	if err != nil {
+		return nil
+	}
+	if values, ok := v.([]interface{}); ok {
+		if len(values) == 0 {
+			return nil
+		}
+		return values[0]
+	}
+	return v
+}
+
+func fleetServerIntVar(input mapstr.M, name string) int {
+	switch v := fleetServerVar(input, name).(type) {
+	case float64:
+		return int(v)
+	case string:
+		i, _ := strconv.Atoi(v)
+		return i
+	default:
+		return 0
+	}
+}
+
 // UninstallTokenResponse uninstall tokens response with resolved token values
 type UninstallTokenResponse struct {
 	Items   []UninstallTokenItem `json:"items"`
@@ -645,14 +3307,32 @@ type uninstallTokenValueResponse struct {
 	Item UninstallTokenItem `json:"item"`
 }
 
-// GetPolicyUninstallTokens Retrieves the the policy uninstall tokens
-func (client *Client) GetPolicyUninstallTokens(ctx context.Context, policyID string) (r UninstallTokenResponse, err error) {
-	// Fetch uninstall token for the policy
-	// /api/fleet/uninstall_tokens?policyId={policyId}&page=1&perPage=1000
+// ListUninstallTokensRequest filters and paginates the uninstall tokens to list
+type ListUninstallTokensRequest struct {
+	// PolicyID only lists the tokens of policies whose ID contains it
+	PolicyID string
+	Page     int
+	PerPage  int
+}
+
+// ListUninstallTokens lists the latest uninstall token of every policy
+// matching the request. The token values are not returned, they are
+// decrypted by GetUninstallToken.
+func (client *Client) ListUninstallTokens(ctx context.Context, request ListUninstallTokensRequest) (r UninstallTokenResponse, err error) {
+	if err := client.requireVersion("ListUninstallTokens", minVersionUninstallTokens); err != nil {
+		return r, err
+	}
+
 	q := make(url.Values)
-	q.Add("policyId", policyID)
-	q.Add("page", "1")
-	q.Add("perPage", "1000")
+	if request.PolicyID != "" {
+		q.Add("policyId", request.PolicyID)
+	}
+	if request.Page > 0 {
+		q.Add("page", strconv.Itoa(request.Page))
+	}
+	if request.PerPage > 0 {
+		q.Add("perPage", strconv.Itoa(request.PerPage))
+	}
 
 	resp, err := client.Connection.SendWithContext(ctx,
 		http.MethodGet,
@@ -662,11 +3342,27 @@ func (client *Client) GetPolicyUninstallTokens(ctx context.Context, policyID str
 		nil,
 	)
 	if err != nil {
-		return r, fmt.Errorf("getting %s, policyID %s: %w", fleetUninstallTokensAPI, policyID, err)
+		return r, fmt.Errorf("getting %s, policyID %s: %w", fleetUninstallTokensAPI, request.PolicyID, err)
 	}
 	defer resp.Body.Close()
 
-	err = readJSONResponse(resp, &r)
+	err = client.readJSONResponse(resp, &r)
+	return r, err
+}
+
+// GetPolicyUninstallTokens Retrieves the the policy uninstall tokens
+func (client *Client) GetPolicyUninstallTokens(ctx context.Context, policyID string) (r UninstallTokenResponse, err error) {
+	if err := client.requireVersion("GetPolicyUninstallTokens", minVersionUninstallTokens); err != nil {
+		return r, err
+	}
+
+	// Fetch uninstall token for the policy
+	// /api/fleet/uninstall_tokens?policyId={policyId}&page=1&perPage=1000
+	r, err = client.ListUninstallTokens(ctx, ListUninstallTokensRequest{
+		PolicyID: policyID,
+		Page:     1,
+		PerPage:  1000,
+	})
 	if err != nil {
 		return r, err
 	}
@@ -685,6 +3381,10 @@ func (client *Client) GetPolicyUninstallTokens(ctx context.Context, policyID str
 
 // GetUninstallToken return uninstall token value for the given token ID
 func (client *Client) GetUninstallToken(ctx context.Context, tokenID string) (r UninstallTokenItem, err error) {
+	if err := client.requireVersion("GetUninstallToken", minVersionUninstallTokens); err != nil {
+		return r, err
+	}
+
 	u, err := url.JoinPath(fleetUninstallTokensAPI, tokenID)
 	if err != nil {
 		return r, err
@@ -704,7 +3404,7 @@ func (client *Client) GetUninstallToken(ctx context.Context, tokenID string) (r
 	defer resp.Body.Close()
 
 	var res uninstallTokenValueResponse
-	err = readJSONResponse(resp, &res)
+	err = client.readJSONResponse(resp, &res)
 	if err != nil {
 		return r, err
 	}
@@ -712,18 +3412,37 @@ func (client *Client) GetUninstallToken(ctx context.Context, tokenID string) (r
 	return res.Item, nil
 }
 
-func readJSONResponse(r *http.Response, v any) error {
-	b, err := io.ReadAll(r.Body)
+func (client *Client) readJSONResponse(r *http.Response, v any) error {
+	b, err := client.readJSONBody(r)
 	if err != nil {
-		return fmt.Errorf("reading response body: %w", err)
+		return err
 	}
+	return client.decodeJSON(b, v)
+}
 
-	if r.StatusCode != http.StatusOK {
-		return extractError(b)
+// readJSONBody returns the JSON body of a successful response. Responses of
+// operations running as a background task return a *TaskPendingError, error
+// responses are returned as *APIError.
+func (client *Client) readJSONBody(r *http.Response) ([]byte, error) {
+	if err := checkAuthChallenge(r); err != nil {
+		return nil, err
 	}
 
-	err = json.Unmarshal(b, v)
+	b, err := readBody(r)
 	if err != nil {
+		return nil, fmt.Errorf("reading response body: %w", err)
+	}
+
+	if r.StatusCode == http.StatusAccepted {
+		return client.resolveTask(b)
+	} else if r.StatusCode != http.StatusOK {
+		return nil, newAPIError(client.codec(), r.StatusCode, b)
+	}
+	return b, nil
+}
+
+func (client *Client) decodeJSON(b []byte, v any) error {
+	if err := client.codec().Unmarshal(b, v); err != nil {
 		return fmt.Errorf("unmarshalling response json: %w", err)
 	}
 	return nil
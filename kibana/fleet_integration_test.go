@@ -272,12 +272,77 @@ func TestFleetPackage(t *testing.T) {
 	require.NoError(t, err)
 }
 
+func TestPackagePolicyKibana(t *testing.T) {
+	cfg := mustGetEnv(t)
+
+	ctx, cn := context.WithCancel(context.Background())
+	defer cn()
+
+	client, err := NewClientWithConfig(&cfg, "", "", "", "")
+	require.NoError(t, err)
+
+	policyUUID := uuid.Must(uuid.NewV4()).String()
+	res, err := client.CreatePolicy(ctx, AgentPolicy{
+		Name:        "test-policy-" + policyUUID,
+		Namespace:   "default",
+		Description: "Test policy " + policyUUID,
+	})
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, client.DeletePolicy(ctx, res.ID))
+	}()
+
+	packagePolicyID := uuid.Must(uuid.NewV4()).String()
+	packagePolicyReq, err := endpointPackagePolicyRequest(t, res.ID, packagePolicyID)
+	require.NoError(t, err)
+
+	// Create, read and update the package policy
+	createRes, err := client.CreatePackagePolicy(ctx, packagePolicyReq)
+	require.NoError(t, err)
+
+	getRes, err := client.GetPackagePolicy(ctx, createRes.Item.ID)
+	require.NoError(t, err)
+	require.Equal(t, createRes.Item.Name, getRes.Item.Name)
+
+	updateRes, err := client.UpdatePackagePolicy(ctx, createRes.Item.ID, PackagePolicyRequest{
+		Name:      getRes.Item.Name,
+		Namespace: getRes.Item.Namespace,
+		PolicyID:  getRes.Item.PolicyID,
+		Package:   getRes.Item.Package,
+		Inputs:    getRes.Item.Inputs,
+	})
+	require.NoError(t, err)
+	require.Greater(t, updateRes.Item.Revision, getRes.Item.Revision)
+
+	// Delete the package policy
+	delRes, err := client.DeletePackagePolicy(ctx, createRes.Item.ID)
+	require.NoError(t, err)
+	require.Equal(t, packagePolicyID, delRes.ID)
+}
+
 func installElasticDefendPackage(t *testing.T, client *Client, policyID, packagePolicyID string) (r PackagePolicyResponse, err error) {
 	t.Helper()
 
 	ctx, cn := context.WithCancel(context.Background())
 	defer cn()
 
+	packagePolicyReq, err := endpointPackagePolicyRequest(t, policyID, packagePolicyID)
+	if err != nil {
+		return r, err
+	}
+
+	pkgResp, err := client.InstallFleetPackage(ctx, packagePolicyReq)
+	if err != nil {
+		t.Logf("Error installing fleet package: %v", err)
+		return r, fmt.Errorf("error installing fleet package: %w", err)
+	}
+	t.Logf("Endpoint package Policy Response:\n%+v", pkgResp)
+	return pkgResp, err
+}
+
+func endpointPackagePolicyRequest(t *testing.T, policyID, packagePolicyID string) (r PackagePolicyRequest, err error) {
+	t.Helper()
+
 	t.Log("Templating endpoint package policy request")
 	tmpl, err := template.New("pkgpolicy").Parse(endpointPackagePolicyTemplate)
 	if err != nil {
@@ -300,19 +365,11 @@ func installElasticDefendPackage(t *testing.T, client *Client, policyID, package
 
 	// Make sure the templated value is actually valid JSON before making the API request.
 	// Using json.Unmarshal will give us the actual syntax error, calling json.Valid() would not.
-	var packagePolicyReq PackagePolicyRequest
-	err = json.Unmarshal(pkgPolicyBuf.Bytes(), &packagePolicyReq)
+	err = json.Unmarshal(pkgPolicyBuf.Bytes(), &r)
 	if err != nil {
 		return r, fmt.Errorf("templated package policy is not valid JSON: %s, %w", pkgPolicyBuf.String(), err)
 	}
-
-	pkgResp, err := client.InstallFleetPackage(ctx, packagePolicyReq)
-	if err != nil {
-		t.Logf("Error installing fleet package: %v", err)
-		return r, fmt.Errorf("error installing fleet package: %w", err)
-	}
-	t.Logf("Endpoint package Policy Response:\n%+v", pkgResp)
-	return pkgResp, err
+	return r, nil
 }
 
 func TestCreateEnrollmentAPIKey(t *testing.T) {
@@ -18,16 +18,26 @@
 package kibana
 
 import (
+	"bytes"
 	"context"
 	_ "embed"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 
 	"github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/mapstr"
 )
 
 var (
@@ -40,6 +50,12 @@ var (
 	//go:embed testdata/fleet_create_policy_response.json
 	fleetCreatePolicyResponse []byte
 
+	//go:embed testdata/fleet_list_policies_response.json
+	fleetListPoliciesResponse []byte
+
+	//go:embed testdata/fleet_list_policies_empty_response.json
+	fleetListPoliciesEmptyResponse []byte
+
 	//go:embed testdata/fleet_get_policy_response.json
 	fleetGetPolicyResponse []byte
 
@@ -54,6 +70,33 @@ var (
 
 	//go:embed testdata/fleet_get_fleet_server_host_response.json
 	fleetGetFleetServerHostResponse []byte
+
+	//go:embed testdata/fleet_list_fleet_server_package_policies_response.json
+	fleetListFleetServerPackagePoliciesResponse []byte
+
+	//go:embed testdata/fleet_update_fleet_server_package_policy_response.json
+	fleetUpdateFleetServerPackagePolicyResponse []byte
+
+	//go:embed testdata/fleet_create_agent_action_response.json
+	fleetCreateAgentActionResponse []byte
+
+	//go:embed testdata/fleet_get_agent_actions_response.json
+	fleetGetAgentActionsResponse []byte
+
+	//go:embed testdata/fleet_list_agent_activity_response.json
+	fleetListAgentActivityResponse []byte
+
+	//go:embed testdata/fleet_bulk_upgrade_partial_response.json
+	fleetBulkUpgradePartialResponse []byte
+
+	//go:embed testdata/fleet_list_outputs_response.json
+	fleetListOutputsResponse []byte
+
+	//go:embed testdata/fleet_get_settings_response.json
+	fleetGetSettingsResponse []byte
+
+	//go:embed testdata/fleet_list_packages_response.json
+	fleetListPackagesResponse []byte
 )
 
 func TestFleetCreatePolicy(t *testing.T) {
@@ -97,6 +140,96 @@ func TestFleetCreatePolicy(t *testing.T) {
 	require.Equal(t, resp.MonitoringEnabled, []MonitoringEnabledOption{MonitoringEnabledLogs, MonitoringEnabledMetrics})
 }
 
+func TestFleetEnsurePolicy(t *testing.T) {
+	const policyID = "a580c680-ea40-11ed-aae7-4b4fd4906b3d"
+
+	desired := EnsurePolicyRequest{AgentPolicy: AgentPolicy{
+		Name:        "test policy",
+		Namespace:   "default",
+		Description: "a policy used for testing",
+		MonitoringEnabled: []MonitoringEnabledOption{
+			MonitoringEnabledLogs,
+			MonitoringEnabledMetrics,
+		},
+	}}
+
+	t.Run("create", func(t *testing.T) {
+		var created bool
+		handler := func(w http.ResponseWriter, r *http.Request) {
+			switch {
+			case r.URL.Path == fleetAgentPoliciesAPI && r.Method == http.MethodGet:
+				require.Equal(t, `ingest-agent-policies.name:"test policy"`, r.URL.Query().Get("kuery"))
+				_, _ = w.Write(fleetListPoliciesEmptyResponse)
+			case r.URL.Path == fleetAgentPoliciesAPI && r.Method == http.MethodPost:
+				created = true
+				_, _ = w.Write(fleetCreatePolicyResponse)
+			default:
+				t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+			}
+		}
+
+		client, err := createTestServerAndClient(handler)
+		require.NoError(t, err)
+
+		resp, wasCreated, err := client.EnsurePolicy(context.Background(), desired)
+		require.NoError(t, err)
+		require.True(t, wasCreated)
+		require.True(t, created)
+		require.Equal(t, policyID, resp.ID)
+	})
+
+	t.Run("update drifted", func(t *testing.T) {
+		var update map[string]interface{}
+		handler := func(w http.ResponseWriter, r *http.Request) {
+			switch {
+			case r.URL.Path == fleetAgentPoliciesAPI && r.Method == http.MethodGet:
+				_, _ = w.Write(fleetListPoliciesResponse)
+			case r.URL.Path == fmt.Sprintf(fleetAgentPolicyAPI, policyID) && r.Method == http.MethodGet:
+				_, _ = fmt.Fprintf(w, `{"item":{"id":%q,"revision":3}}`, policyID)
+			case r.URL.Path == fmt.Sprintf(fleetAgentPolicyAPI, policyID) && r.Method == http.MethodPut:
+				require.NoError(t, json.NewDecoder(r.Body).Decode(&update))
+				_, _ = w.Write(fleetCreatePolicyResponse)
+			default:
+				t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+			}
+		}
+
+		client, err := createTestServerAndClient(handler)
+		require.NoError(t, err)
+
+		resp, wasCreated, err := client.EnsurePolicy(context.Background(), desired)
+		require.NoError(t, err)
+		require.False(t, wasCreated)
+		require.Equal(t, policyID, resp.ID)
+		require.Equal(t, "a policy used for testing", update["description"])
+		require.Equal(t, []interface{}{"logs", "metrics"}, update["monitoring_enabled"])
+	})
+
+	t.Run("unchanged", func(t *testing.T) {
+		handler := func(w http.ResponseWriter, r *http.Request) {
+			switch {
+			case r.URL.Path == fleetAgentPoliciesAPI && r.Method == http.MethodGet:
+				_, _ = w.Write(fleetListPoliciesResponse)
+			default:
+				t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+			}
+		}
+
+		client, err := createTestServerAndClient(handler)
+		require.NoError(t, err)
+
+		// Only set fields are managed, server-set fields are ignored.
+		resp, wasCreated, err := client.EnsurePolicy(context.Background(), EnsurePolicyRequest{AgentPolicy: AgentPolicy{
+			Name:              "test policy",
+			Namespace:         "default",
+			MonitoringEnabled: []MonitoringEnabledOption{MonitoringEnabledLogs},
+		}})
+		require.NoError(t, err)
+		require.False(t, wasCreated)
+		require.Equal(t, 3, resp.Revision)
+	})
+}
+
 func TestFleetGetPolicy(t *testing.T) {
 	const id = "elastic-agent-managed-ep"
 
@@ -175,6 +308,112 @@ func TestFleetUpdatePolicy(t *testing.T) {
 	require.Equal(t, agentFeatures, resp.AgentFeatures)
 }
 
+func TestFleetCopyPolicy(t *testing.T) {
+	const id = "b4cd25b0-f040-11ed-a1b3-373f5d648cd4"
+
+	ctx, cn := context.WithCancel(context.Background())
+	defer cn()
+
+	var req CopyPolicyRequest
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case fmt.Sprintf(fleetAgentPolicyCopyAPI, id):
+			require.Equal(t, http.MethodPost, r.Method)
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+			_, _ = w.Write([]byte(`{"item":{"id":"copy-id","name":"` + req.Name + `","namespace":"default","revision":1}}`))
+		}
+	}
+
+	client, err := createTestServerAndClient(handler)
+	require.NoError(t, err)
+
+	resp, err := client.CopyPolicy(ctx, id, CopyPolicyRequest{Name: "test policy (copy)"})
+	require.NoError(t, err)
+	require.Equal(t, "test policy (copy)", req.Name)
+	require.Equal(t, "copy-id", resp.ID)
+	require.Equal(t, "test policy (copy)", resp.Name)
+	require.Equal(t, "default", resp.Namespace)
+}
+
+func TestFleetGetPolicyFull(t *testing.T) {
+	const id = "b4cd25b0-f040-11ed-a1b3-373f5d648cd4"
+
+	ctx, cn := context.WithCancel(context.Background())
+	defer cn()
+
+	var queries []url.Values
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case fmt.Sprintf(fleetAgentPolicyFullAPI, id):
+			queries = append(queries, r.URL.Query())
+			_, _ = w.Write([]byte(`{"item":{"id":"` + id + `","revision":2,` +
+				`"outputs":{"default":{"type":"elasticsearch","hosts":["https://es:9200"]}},` +
+				`"inputs":[{"id":"logfile-system","type":"logfile","use_output":"default"}]}}`))
+		case fmt.Sprintf(fleetAgentPolicyDownloadAPI, id):
+			queries = append(queries, r.URL.Query())
+			w.Header().Set("Content-Type", "application/x-yaml")
+			_, _ = w.Write([]byte("id: " + id + "\nrevision: 2\n"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"statusCode":404,"error":"Not Found","message":"Agent policy not found"}`))
+		}
+	}
+
+	client, err := createTestServerAndClient(handler)
+	require.NoError(t, err)
+
+	full, err := client.GetPolicyFull(ctx, GetPolicyFullRequest{ID: id, Standalone: true})
+	require.NoError(t, err)
+	hosts, err := full.GetValue("outputs.default.hosts")
+	require.NoError(t, err)
+	require.Equal(t, []interface{}{"https://es:9200"}, hosts)
+	require.Equal(t, url.Values{"standalone": {"true"}}, queries[0])
+
+	yaml, err := client.GetPolicyFullYAML(ctx, GetPolicyFullRequest{ID: id, Kubernetes: true})
+	require.NoError(t, err)
+	require.Equal(t, "id: "+id+"\nrevision: 2\n", string(yaml))
+	require.Equal(t, url.Values{"kubernetes": {"true"}}, queries[1])
+
+	_, err = client.GetPolicyFull(ctx, GetPolicyFullRequest{ID: id, Kubernetes: true})
+	require.ErrorContains(t, err, "use GetPolicyFullYAML")
+
+	_, err = client.GetPolicyFullYAML(ctx, GetPolicyFullRequest{ID: "unknown"})
+	require.True(t, IsNotFound(err), err)
+}
+
+func TestFleetUpdatePolicyExpectedRevision(t *testing.T) {
+	const id = "b4cd25b0-f040-11ed-a1b3-373f5d648cd4"
+
+	ctx, cn := context.WithCancel(context.Background())
+	defer cn()
+
+	// Fleet ignores preconditions on policy updates, the client compares the
+	// revision it reads before updating.
+	var updates int
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == fmt.Sprintf(fleetAgentPolicyAPI, id) && r.Method == http.MethodGet:
+			_, _ = fmt.Fprintf(w, `{"item":{"id":%q,"revision":2}}`, id)
+		case r.URL.Path == fmt.Sprintf(fleetAgentPolicyAPI, id) && r.Method == http.MethodPut:
+			updates++
+			_, _ = w.Write(fleetUpdatePolicyResponse)
+		}
+	}
+
+	client, err := createTestServerAndClient(handler)
+	require.NoError(t, err)
+	require.NotNil(t, client)
+
+	_, err = client.UpdatePolicy(ctx, id, AgentPolicyUpdateRequest{Name: "test-fqdn", ExpectedRevision: 1})
+	require.ErrorIs(t, err, ErrConcurrentModification)
+	require.Zero(t, updates, "a policy at another revision must not be updated")
+
+	resp, err := client.UpdatePolicy(ctx, id, AgentPolicyUpdateRequest{Name: "test-fqdn", ExpectedRevision: 2})
+	require.NoError(t, err)
+	require.Equal(t, id, resp.ID)
+	require.Equal(t, 1, updates)
+}
+
 func TestFleetCreateEnrollmentAPIKey(t *testing.T) {
 	const (
 		id       = "880c7460-a7e4-43df-8fc3-6a9593c6d555"
@@ -211,6 +450,81 @@ func TestFleetCreateEnrollmentAPIKey(t *testing.T) {
 	require.True(t, resp.Active)
 }
 
+func TestFleetListEnrollmentAPIKeysLegacyList(t *testing.T) {
+	ctx, cn := context.WithCancel(context.Background())
+	defer cn()
+
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case fleetEnrollmentAPIKeysAPI:
+			require.Equal(t, "2", r.URL.Query().Get("page"))
+			_, _ = w.Write([]byte(`{"list":[{"id":"key-id","api_key":"secret","active":true,"policy_id":"policy-id"}],"total":1,"page":2,"perPage":20}`))
+		}
+	}
+
+	client, err := createTestServerAndClient(handler)
+	require.NoError(t, err)
+
+	resp, err := client.ListEnrollmentAPIKeys(ctx, ListEnrollmentAPIKeysRequest{Page: 2})
+	require.NoError(t, err)
+	require.Equal(t, []EnrollmentAPIKey{{ID: "key-id", APIKey: "secret", Active: true, PolicyID: "policy-id"}}, resp.Items)
+	require.Equal(t, 2, resp.Page)
+}
+
+func TestFleetCreateEnrollmentAPIKeysForPolicies(t *testing.T) {
+	ctx, cn := context.WithCancel(context.Background())
+	defer cn()
+
+	var mu sync.Mutex
+	var running, maxRunning int
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		running++
+		if running > maxRunning {
+			maxRunning = running
+		}
+		mu.Unlock()
+		defer func() {
+			mu.Lock()
+			running--
+			mu.Unlock()
+		}()
+		time.Sleep(10 * time.Millisecond)
+
+		var req CreateEnrollmentAPIKeyRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		if req.PolicyID == "missing" {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"statusCode":400,"error":"Bad Request","message":"Agent policy missing not found"}`))
+			return
+		}
+		_, _ = fmt.Fprintf(w, `{"item":{"id":"key-%[1]s","active":true,"api_key":"secret-%[1]s","policy_id":%[1]q}}`, req.PolicyID)
+	}
+
+	client, err := createTestServerAndClient(handler)
+	require.NoError(t, err)
+
+	keys, err := client.CreateEnrollmentAPIKeysForPolicies(ctx, CreateEnrollmentAPIKeysForPoliciesRequest{
+		PolicyIDs:   []string{"a", "b", "c"},
+		Concurrency: 2,
+	})
+	require.NoError(t, err)
+	require.Len(t, keys, 3)
+	for _, id := range []string{"a", "b", "c"} {
+		require.Equal(t, id, keys[id].PolicyID)
+		require.Equal(t, "secret-"+id, keys[id].APIKey)
+	}
+	require.LessOrEqual(t, maxRunning, 2)
+
+	keys, err = client.CreateEnrollmentAPIKeysForPolicies(ctx, CreateEnrollmentAPIKeysForPoliciesRequest{
+		PolicyIDs: []string{"a", "missing", "c"},
+	})
+	require.ErrorContains(t, err, "policy missing: Agent policy missing not found")
+	require.Len(t, keys, 2)
+	require.Equal(t, "secret-a", keys["a"].APIKey)
+	require.Equal(t, "secret-c", keys["c"].APIKey)
+}
+
 func TestFleetListAgents(t *testing.T) {
 	ctx, cn := context.WithCancel(context.Background())
 	defer cn()
@@ -238,6 +552,120 @@ func TestFleetListAgents(t *testing.T) {
 	require.Equal(t, "c75d66b1dac5", item.LocalMetadata.Host.Hostname)
 }
 
+func TestFleetForEachAgent(t *testing.T) {
+	ctx, cn := context.WithCancel(context.Background())
+	defer cn()
+
+	const total = 5
+	var pages []string
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != fleetAgentsAPI {
+			return
+		}
+		q := r.URL.Query()
+		require.Equal(t, "status:online", q.Get("kuery"))
+		pages = append(pages, q.Get("page")+"/"+q.Get("perPage"))
+
+		page, err := strconv.Atoi(q.Get("page"))
+		require.NoError(t, err)
+		perPage, err := strconv.Atoi(q.Get("perPage"))
+		require.NoError(t, err)
+
+		resp := ListAgentsResponse{Total: total, Page: page, PerPage: perPage}
+		for i := (page - 1) * perPage; i < page*perPage && i < total; i++ {
+			resp.Items = append(resp.Items, AgentExisting{ID: fmt.Sprintf("agent-%d", i)})
+		}
+		require.NoError(t, json.NewEncoder(w).Encode(resp))
+	}
+
+	client, err := createTestServerAndClient(handler)
+	require.NoError(t, err)
+
+	req := ListAgentsRequest{Kuery: "status:online", Page: 1, PerPage: 2}
+	var ids []string
+	err = client.ForEachAgent(ctx, req, func(agent AgentExisting) error {
+		ids = append(ids, agent.ID)
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, []string{"agent-0", "agent-1", "agent-2", "agent-3", "agent-4"}, ids)
+	require.Equal(t, []string{"1/2", "2/2", "3/2"}, pages)
+
+	first, err := client.ListAgents(ctx, req)
+	require.NoError(t, err)
+	next, ok := first.NextPage(req)
+	require.True(t, ok)
+	require.Equal(t, ListAgentsRequest{Kuery: "status:online", Page: 2, PerPage: 2}, next)
+
+	last, err := client.ListAgents(ctx, ListAgentsRequest{Kuery: "status:online", Page: 3, PerPage: 2})
+	require.NoError(t, err)
+	require.False(t, last.HasNextPage())
+
+	stop := errors.New("stop")
+	pages = nil
+	err = client.ForEachAgent(ctx, req, func(agent AgentExisting) error {
+		return stop
+	})
+	require.ErrorIs(t, err, stop)
+	require.Len(t, pages, 1)
+}
+
+func TestFleetGetAgentsByIDs(t *testing.T) {
+	ctx, cn := context.WithCancel(context.Background())
+	defer cn()
+
+	known := map[string]bool{"a1": true, "a2": true, "a4": true, "a5": true, "a7": true}
+	var kueries []string
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != fleetAgentsAPI {
+			return
+		}
+		kuery := r.URL.Query().Get("kuery")
+		kueries = append(kueries, kuery)
+
+		// Answer in reverse order to check the order of the result.
+		ids := strings.Split(strings.TrimSuffix(strings.TrimPrefix(kuery, "agent.id:("), ")"), " or ")
+		var items []map[string]interface{}
+		for i := len(ids) - 1; i >= 0; i-- {
+			id, err := strconv.Unquote(ids[i])
+			require.NoError(t, err)
+			if known[id] {
+				items = append(items, map[string]interface{}{"id": id, "agent": map[string]string{"id": id}})
+			}
+		}
+		require.Equal(t, strconv.Itoa(len(ids)), r.URL.Query().Get("perPage"))
+		require.NoError(t, json.NewEncoder(w).Encode(map[string]interface{}{"items": items, "total": len(items)}))
+	}
+
+	client, err := createTestServerAndClient(handler)
+	require.NoError(t, err)
+
+	resp, err := client.GetAgentsByIDs(ctx, GetAgentsByIDsRequest{
+		IDs:       []string{"a7", "a1", "a3", "a2", "a1", "a4", "a6", "a5"},
+		ChunkSize: 3,
+	})
+	require.NoError(t, err)
+
+	require.Equal(t, []string{
+		`agent.id:("a7" or "a1" or "a3")`,
+		`agent.id:("a2" or "a4" or "a6")`,
+		`agent.id:("a5")`,
+	}, kueries)
+
+	var ids []string
+	for _, item := range resp.Items {
+		ids = append(ids, item.ID)
+	}
+	require.Equal(t, []string{"a7", "a1", "a2", "a4", "a5"}, ids)
+	require.Equal(t, []string{"a3", "a6"}, resp.Missing)
+
+	kueries = nil
+	resp, err = client.GetAgentsByIDs(ctx, GetAgentsByIDsRequest{})
+	require.NoError(t, err)
+	require.Empty(t, kueries, "no request without IDs")
+	require.Empty(t, resp.Items)
+}
+
 func TestFleetGetAgent(t *testing.T) {
 	const id = "26802301-8996-457a-ab6a-8ea955ef2723"
 
@@ -270,6 +698,180 @@ func TestFleetGetAgent(t *testing.T) {
 	require.Equal(t, "Shaunaks-MBP.attlocal.net", resp.LocalMetadata.Host.Hostname)
 	require.Equal(t, "8196af30-f041-11ed-a1b3-373f5d648cd4", resp.PolicyID)
 	require.Equal(t, 4, resp.PolicyRevision)
+	require.Equal(t, "PERMANENT", resp.Type)
+	require.Equal(t, time.Date(2023, 5, 11, 22, 57, 15, 0, time.UTC), resp.EnrolledAt.UTC())
+	require.Equal(t, time.Date(2023, 5, 11, 23, 2, 15, 0, time.UTC), resp.LastCheckin.UTC())
+	require.Equal(t, "online", resp.LastCheckinStatus)
+	require.Equal(t, "Running", resp.LastCheckinMessage)
+	require.Equal(t, "8.7.1", resp.LocalMetadata.Elastic.Agent.Version)
+	require.True(t, resp.LocalMetadata.Elastic.Agent.Upgradeable)
+	require.Equal(t, "arm64", resp.LocalMetadata.Host.Architecture)
+	require.Equal(t, "darwin", resp.LocalMetadata.OS.Platform)
+
+	require.NotEmpty(t, resp.Components)
+	component := resp.Components[0]
+	require.Equal(t, "log-default", component.ID)
+	require.Equal(t, "log", component.Type)
+	require.Equal(t, "HEALTHY", component.Status)
+	require.Len(t, component.Units, 2)
+	require.Equal(t, "input", component.Units[0].Type)
+	require.Equal(t, "Healthy", component.Units[0].Message)
+}
+
+func TestFleetUpdateAgentTags(t *testing.T) {
+	ctx, cn := context.WithCancel(context.Background())
+	defer cn()
+
+	const id = "agent-id"
+	var bodies []string
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, fmt.Sprintf(fleetAgentAPI, id), r.URL.Path)
+		require.Equal(t, http.MethodPut, r.Method)
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		bodies = append(bodies, string(body))
+
+		var update struct {
+			Tags []string `json:"tags"`
+		}
+		require.NoError(t, json.Unmarshal(body, &update))
+		require.NoError(t, json.NewEncoder(w).Encode(map[string]any{
+			"item": map[string]any{"id": id, "tags": update.Tags},
+		}))
+	}
+
+	client, err := createTestServerAndClient(handler)
+	require.NoError(t, err)
+
+	agent, err := client.UpdateAgentTags(ctx, UpdateAgentTagsRequest{ID: id, Tags: []string{"env:staging", "run-42"}})
+	require.NoError(t, err)
+	require.Equal(t, id, agent.ID)
+	require.Equal(t, []string{"env:staging", "run-42"}, agent.Tags)
+
+	agent, err = client.UpdateAgentTags(ctx, UpdateAgentTagsRequest{ID: id})
+	require.NoError(t, err)
+	require.Empty(t, agent.Tags)
+
+	require.Equal(t, []string{`{"tags":["env:staging","run-42"]}`, `{"tags":[]}`}, bodies)
+}
+
+func TestFleetGetAgentStatusSummary(t *testing.T) {
+	ctx, cn := context.WithCancel(context.Background())
+	defer cn()
+
+	var query url.Values
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case fleetAgentStatusAPI:
+			query = r.URL.Query()
+			_, _ = w.Write([]byte(`{"results":{"total":7,"online":4,"error":1,"offline":1,"updating":1,` +
+				`"inactive":2,"unenrolled":3,"other":0,"events":0,"active":7,"all":12}}`))
+		}
+	}
+
+	client, err := createTestServerAndClient(handler)
+	require.NoError(t, err)
+
+	summary, err := client.GetAgentStatusSummary(ctx, GetAgentStatusSummaryRequest{PolicyID: "policy-id"})
+	require.NoError(t, err)
+	require.Equal(t, url.Values{"policyId": {"policy-id"}}, query)
+	require.Equal(t, AgentStatusSummary{
+		Total:      7,
+		Online:     4,
+		Error:      1,
+		Offline:    1,
+		Updating:   1,
+		Inactive:   2,
+		Unenrolled: 3,
+		Active:     7,
+		All:        12,
+	}, summary)
+}
+
+func TestFleetWaitForAgentStatus(t *testing.T) {
+	ctx, cn := context.WithCancel(context.Background())
+	defer cn()
+
+	var mu sync.Mutex
+	statuses := map[string][]string{
+		"upgraded": {"updating", "updating", "online"},
+		"broken":   {"updating", "error"},
+		"stuck":    {"updating"},
+	}
+	polls := map[string]int{}
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		id := strings.TrimPrefix(r.URL.Path, "/api/fleet/agents/")
+		sequence := statuses[id]
+		status := sequence[len(sequence)-1]
+		if polls[id] < len(sequence) {
+			status = sequence[polls[id]]
+		}
+		polls[id]++
+		_, _ = fmt.Fprintf(w, `{"item":{"id":%q,"active":true,"status":%q}}`, id, status)
+	}
+
+	client, err := createTestServerAndClient(handler)
+	require.NoError(t, err)
+
+	opts := WaitForAgentStatusOptions{
+		Interval: time.Millisecond,
+		FailOn:   []AgentStatus{AgentStatusError, AgentStatusDegraded},
+	}
+
+	agent, err := client.WaitForAgentStatus(ctx, "upgraded", AgentStatusOnline, opts)
+	require.NoError(t, err)
+	require.Equal(t, "online", agent.Status)
+	require.Equal(t, 3, polls["upgraded"])
+
+	agent, err = client.WaitForAgentStatus(ctx, "broken", AgentStatusOnline, opts)
+	require.ErrorContains(t, err, "agent broken is error while waiting for it to be online")
+	require.Equal(t, "error", agent.Status)
+
+	opts.Timeout = 20 * time.Millisecond
+	_, err = client.WaitForAgentStatus(ctx, "stuck", AgentStatusOnline, opts)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.ErrorContains(t, err, "last status updating")
+}
+
+func TestFleetWaitForAgentUpgrade(t *testing.T) {
+	ctx, cn := context.WithCancel(context.Background())
+	defer cn()
+
+	var mu sync.Mutex
+	// Each poll returns the next status and version of the agent.
+	sequence := []struct{ status, version string }{
+		{"online", "8.11.0"},
+		{"updating", "8.11.0"},
+		{"updating", "8.12.0"},
+		{"online", "8.12.0"},
+	}
+	polls := 0
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		state := sequence[len(sequence)-1]
+		if polls < len(sequence) {
+			state = sequence[polls]
+		}
+		polls++
+		_, _ = fmt.Fprintf(w, `{"item":{"id":"agent","active":true,"status":%q,"agent":{"id":"agent","version":%q}}}`, state.status, state.version)
+	}
+
+	client, err := createTestServerAndClient(handler)
+	require.NoError(t, err)
+
+	opts := WaitForAgentStatusOptions{Interval: time.Millisecond}
+	agent, err := client.WaitForAgentUpgrade(ctx, "agent", "8.12.0-SNAPSHOT", opts)
+	require.NoError(t, err)
+	require.Equal(t, "8.12.0", agent.Agent.Version)
+	require.Equal(t, 4, polls)
+
+	opts.Timeout = 20 * time.Millisecond
+	_, err = client.WaitForAgentUpgrade(ctx, "agent", "8.13.0", opts)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.ErrorContains(t, err, "waiting for agent agent to be upgraded to 8.13.0, last status online")
 }
 
 func TestFleetUnEnrollAgent(t *testing.T) {
@@ -298,71 +900,95 @@ func TestFleetUnEnrollAgent(t *testing.T) {
 	require.NotNil(t, resp)
 }
 
-func TestFleetUpgradeAgent(t *testing.T) {
-	const agentID = "f512f36f-bf78-4285-aff0-baeafbcdf21e"
-
+func TestFleetDeleteAgent(t *testing.T) {
 	ctx, cn := context.WithCancel(context.Background())
 	defer cn()
 
+	agents := map[string]bool{"inactive": false, "active": true}
+	var deleted []string
 	handler := func(w http.ResponseWriter, r *http.Request) {
-		switch r.URL.Path {
-		case fmt.Sprintf(fleetUpgradeAgentAPI, agentID):
-			_, _ = w.Write([]byte(`{}`))
+		id := strings.TrimPrefix(r.URL.Path, "/api/fleet/agents/")
+		active, ok := agents[id]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"statusCode":404,"error":"Not Found","message":"Agent ` + id + ` not found"}`))
+			return
+		}
+		switch r.Method {
+		case http.MethodGet:
+			_, _ = fmt.Fprintf(w, `{"item":{"id":%q,"active":%t,"status":"online"}}`, id, active)
+		case http.MethodDelete:
+			deleted = append(deleted, id)
+			delete(agents, id)
+			_, _ = w.Write([]byte(`{"action":"deleted"}`))
 		}
 	}
 
 	client, err := createTestServerAndClient(handler)
 	require.NoError(t, err)
-	require.NotNil(t, client)
 
-	req := UpgradeAgentRequest{
-		ID:      agentID,
-		Version: "8.8.0",
-	}
-	resp, err := client.UpgradeAgent(ctx, req)
+	// Inactive agents are deleted.
+	err = client.DeleteAgent(ctx, DeleteAgentRequest{ID: "inactive"})
+	require.NoError(t, err)
+	require.Equal(t, []string{"inactive"}, deleted)
+
+	// Active agents require Force.
+	err = client.DeleteAgent(ctx, DeleteAgentRequest{ID: "active"})
+	require.ErrorIs(t, err, ErrAgentActive)
+	require.Equal(t, []string{"inactive"}, deleted)
+
+	err = client.DeleteAgent(ctx, DeleteAgentRequest{ID: "active", Force: true})
+	require.NoError(t, err)
+	require.Equal(t, []string{"inactive", "active"}, deleted)
+
+	// Missing agents are only deleted with IgnoreNotFound.
+	err = client.DeleteAgent(ctx, DeleteAgentRequest{ID: "inactive"})
+	require.True(t, IsNotFound(err), err)
+	err = client.DeleteAgent(ctx, DeleteAgentRequest{ID: "active", Force: true})
+	require.True(t, IsNotFound(err), err)
+
+	err = client.DeleteAgent(ctx, DeleteAgentRequest{ID: "inactive", IgnoreNotFound: true})
+	require.NoError(t, err)
+	err = client.DeleteAgent(ctx, DeleteAgentRequest{ID: "active", Force: true, IgnoreNotFound: true})
 	require.NoError(t, err)
-	require.NotNil(t, resp)
 }
 
-func TestFleetListFleetServerHosts(t *testing.T) {
+func TestFleetUnexpectedAuthChallenge(t *testing.T) {
 	ctx, cn := context.WithCancel(context.Background())
 	defer cn()
 
 	handler := func(w http.ResponseWriter, r *http.Request) {
-		switch r.URL.Path {
-		case fleetFleetServerHostsAPI:
-			_, _ = w.Write(fleetListServerHostsResponse)
+		if r.URL.Path != "/login" {
+			http.Redirect(w, r, "/login?next="+url.QueryEscape(r.URL.Path), http.StatusFound)
+			return
 		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_, _ = w.Write([]byte(`<!DOCTYPE html><html><body><form action="/login">Sign in</form></body></html>`))
 	}
 
 	client, err := createTestServerAndClient(handler)
 	require.NoError(t, err)
-	require.NotNil(t, client)
 
-	req := ListFleetServerHostsRequest{}
-	resp, err := client.ListFleetServerHosts(ctx, req)
-	require.NoError(t, err)
-	require.NotNil(t, resp)
+	_, err = client.GetAgent(ctx, GetAgentRequest{ID: "agent-1"})
+	require.ErrorIs(t, err, ErrUnexpectedAuthChallenge)
 
-	require.Len(t, resp.Items, 1)
-	item := resp.Items[0]
-	require.Equal(t, "fleet-default-fleet-server-host", item.ID)
-	require.Equal(t, "Default", item.Name)
-	require.True(t, item.IsDefault)
-	require.Equal(t, []string{"https://fleet-server:8220"}, item.HostURLs)
-	require.True(t, item.IsPreconfigured)
+	var challengeErr *AuthChallengeError
+	require.ErrorAs(t, err, &challengeErr)
+	require.Equal(t, http.StatusOK, challengeErr.StatusCode)
+	require.Contains(t, challengeErr.URL, "/login?next=%2Fapi%2Ffleet%2Fagents%2Fagent-1")
+	require.Contains(t, err.Error(), challengeErr.URL)
 }
 
-func TestFleetGetFleetServerHost(t *testing.T) {
-	const id = "fleet-default-fleet-server-host"
+func TestFleetUpgradeAgent(t *testing.T) {
+	const agentID = "f512f36f-bf78-4285-aff0-baeafbcdf21e"
 
 	ctx, cn := context.WithCancel(context.Background())
 	defer cn()
 
 	handler := func(w http.ResponseWriter, r *http.Request) {
 		switch r.URL.Path {
-		case fmt.Sprintf(fleetFleetServerHostAPI, id):
-			_, _ = w.Write(fleetGetFleetServerHostResponse)
+		case fmt.Sprintf(fleetUpgradeAgentAPI, agentID):
+			_, _ = w.Write([]byte(`{}`))
 		}
 	}
 
@@ -370,8 +996,404 @@ func TestFleetGetFleetServerHost(t *testing.T) {
 	require.NoError(t, err)
 	require.NotNil(t, client)
 
-	req := GetFleetServerHostRequest{
-		ID: id,
+	req := UpgradeAgentRequest{
+		ID:      agentID,
+		Version: "8.8.0",
+	}
+	resp, err := client.UpgradeAgent(ctx, req)
+	require.NoError(t, err)
+	require.NotNil(t, resp)
+}
+
+func TestFleetReassignAgent(t *testing.T) {
+	const agentID = "f512f36f-bf78-4285-aff0-baeafbcdf21e"
+
+	ctx, cn := context.WithCancel(context.Background())
+	defer cn()
+
+	var body map[string]interface{}
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case fmt.Sprintf(fleetReassignAgentAPI, agentID):
+			require.Equal(t, http.MethodPost, r.Method)
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+			_, _ = w.Write([]byte(`{}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}
+
+	client, err := createTestServerAndClient(handler)
+	require.NoError(t, err)
+
+	_, err = client.ReassignAgent(ctx, ReassignAgentRequest{ID: agentID, PolicyID: "new-policy"})
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{"policy_id": "new-policy"}, body)
+}
+
+func TestFleetBulkUpgradeAgents(t *testing.T) {
+	ctx, cn := context.WithCancel(context.Background())
+	defer cn()
+
+	var req struct {
+		Agents  []string `json:"agents"`
+		Version string   `json:"version"`
+	}
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case fleetAgentsBulkUpgradeAPI:
+			require.Equal(t, http.MethodPost, r.Method)
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+			_, _ = w.Write(fleetBulkUpgradePartialResponse)
+		}
+	}
+
+	client, err := createTestServerAndClient(handler)
+	require.NoError(t, err)
+	require.NotNil(t, client)
+
+	agents := []string{
+		"f512f36f-bf78-4285-aff0-baeafbcdf21e",
+		"4ad4fc02-0a1f-4e37-b8d8-4e8f5e9f3d5a",
+		"0b2c6b7e-4d6a-4c0e-9d5f-8e0d5a9c1f7b",
+		"c9a0e2d4-3b7f-4e5a-8f6d-1a2b3c4d5e6f",
+	}
+	result, err := client.BulkUpgradeAgents(ctx, BulkUpgradeAgentsRequest{Agents: BulkAgents{IDs: agents}, Version: "8.12.0"})
+	require.ErrorIs(t, err, ErrPartialBulkFailure)
+	require.ErrorContains(t, err, "2 of 4 agents failed")
+	require.Equal(t, agents, req.Agents)
+	require.Equal(t, "8.12.0", req.Version)
+
+	var partial *PartialBulkFailureError
+	require.ErrorAs(t, err, &partial)
+	require.Equal(t, result, partial.Result)
+
+	require.Equal(t, "6c1a4a0e-77a5-4a8b-9d0c-2e52a4e2a8d1", result.ActionID)
+	require.Equal(t, []string{
+		"0b2c6b7e-4d6a-4c0e-9d5f-8e0d5a9c1f7b",
+		"f512f36f-bf78-4285-aff0-baeafbcdf21e",
+	}, result.Succeeded)
+	require.Equal(t, map[string]string{
+		"4ad4fc02-0a1f-4e37-b8d8-4e8f5e9f3d5a": "Agent 4ad4fc02-0a1f-4e37-b8d8-4e8f5e9f3d5a is not upgradeable",
+		"c9a0e2d4-3b7f-4e5a-8f6d-1a2b3c4d5e6f": "Agent c9a0e2d4-3b7f-4e5a-8f6d-1a2b3c4d5e6f is managed and cannot be upgraded",
+	}, result.Failed)
+}
+
+func TestFleetBulkAgentActionsSucceeded(t *testing.T) {
+	ctx, cn := context.WithCancel(context.Background())
+	defer cn()
+
+	bodies := map[string]map[string]interface{}{}
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		bodies[r.URL.Path] = body
+
+		switch r.URL.Path {
+		case fleetAgentsBulkReassignAPI:
+			_, _ = w.Write([]byte(`{"items":[{"id":"a","success":true},{"id":"b","success":true}]}`))
+		case fleetAgentsBulkUnenrollAPI, fleetAgentsBulkUpdateTagsAPI:
+			_, _ = w.Write([]byte(`{"actionId":"action-id"}`))
+		}
+	}
+
+	client, err := createTestServerAndClient(handler)
+	require.NoError(t, err)
+
+	result, err := client.BulkReassignAgents(ctx, BulkReassignAgentsRequest{Agents: BulkAgents{IDs: []string{"a", "b"}}, PolicyID: "policy"})
+	require.NoError(t, err)
+	require.Equal(t, []string{"a", "b"}, result.Succeeded)
+	require.Empty(t, result.Failed)
+	require.Equal(t, map[string]interface{}{"agents": []interface{}{"a", "b"}, "policy_id": "policy"}, bodies[fleetAgentsBulkReassignAPI])
+
+	result, err = client.BulkUnEnrollAgents(ctx, BulkUnEnrollAgentsRequest{Agents: BulkAgents{Kuery: "status:offline"}, Revoke: true})
+	require.NoError(t, err)
+	require.Equal(t, "action-id", result.ActionID)
+	require.Empty(t, result.Succeeded)
+	require.Equal(t, map[string]interface{}{"agents": "status:offline", "revoke": true}, bodies[fleetAgentsBulkUnenrollAPI])
+
+	result, err = client.BulkUpdateAgentTags(ctx, BulkUpdateAgentTagsRequest{Agents: BulkAgents{IDs: []string{"a"}}, TagsToAdd: []string{"prod"}})
+	require.NoError(t, err)
+	require.Equal(t, "action-id", result.ActionID)
+
+	_, err = client.BulkUpgradeAgents(ctx, BulkUpgradeAgentsRequest{Version: "8.12.0"})
+	require.ErrorContains(t, err, "no agents selected")
+	_, err = client.BulkUpgradeAgents(ctx, BulkUpgradeAgentsRequest{Agents: BulkAgents{IDs: []string{"a"}, Kuery: "status:online"}, Version: "8.12.0"})
+	require.ErrorContains(t, err, "can not be used together")
+	require.NotContains(t, bodies, fleetAgentsBulkUpgradeAPI)
+}
+
+func TestFleetCreateAgentAction(t *testing.T) {
+	const id = "agent-id"
+
+	ctx, cn := context.WithCancel(context.Background())
+	defer cn()
+
+	var body struct {
+		Action struct {
+			Type string                 `json:"type"`
+			Data map[string]interface{} `json:"data"`
+		} `json:"action"`
+	}
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case fmt.Sprintf(fleetAgentActionsAPI, id):
+			require.Equal(t, http.MethodPost, r.Method)
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+			_, _ = w.Write(fleetCreateAgentActionResponse)
+		}
+	}
+
+	client, err := createTestServerAndClient(handler)
+	require.NoError(t, err)
+	require.NotNil(t, client)
+
+	req := CreateAgentActionRequest{
+		AgentID: id,
+		Action: AgentActionPayload{
+			Type: AgentActionSettings,
+			Data: map[string]interface{}{"log_level": "debug"},
+		},
+	}
+	resp, err := client.CreateAgentAction(ctx, req)
+	require.NoError(t, err)
+
+	require.Equal(t, "SETTINGS", body.Action.Type)
+	require.Equal(t, map[string]interface{}{"log_level": "debug"}, body.Action.Data)
+
+	require.Equal(t, "2c2d3a5e-1b7c-4a0c-9a5d-6f1e8c3b4d21", resp.ID)
+	require.Equal(t, id, resp.AgentID)
+	require.Equal(t, AgentActionSettings, resp.Type)
+	require.JSONEq(t, `{"log_level":"debug"}`, string(resp.Data))
+}
+
+func TestFleetGetAgentActions(t *testing.T) {
+	const id = "agent-id"
+
+	ctx, cn := context.WithCancel(context.Background())
+	defer cn()
+
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case fmt.Sprintf(fleetAgentActionsAPI, id):
+			require.Equal(t, http.MethodGet, r.Method)
+			_, _ = w.Write(fleetGetAgentActionsResponse)
+		}
+	}
+
+	client, err := createTestServerAndClient(handler)
+	require.NoError(t, err)
+	require.NotNil(t, client)
+
+	actions, err := client.GetAgentActions(ctx, id)
+	require.NoError(t, err)
+	require.Len(t, actions, 2)
+
+	require.Equal(t, AgentActionSettings, actions[0].Type)
+	require.Equal(t, "COMPLETE", actions[0].Status)
+	require.Equal(t, AgentActionPolicyReassign, actions[1].Type)
+	require.Equal(t, "IN_PROGRESS", actions[1].Status)
+	require.JSONEq(t, `{"policy_id":"new-policy"}`, string(actions[1].Data))
+}
+
+func TestFleetStreamActionProgress(t *testing.T) {
+	const id = "action-id"
+
+	ctx, cn := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cn()
+
+	// Every status is served twice to check that unchanged statuses are not
+	// reported again.
+	progress := []struct {
+		status string
+		acked  int
+		failed int
+	}{
+		{ActionStatusInProgress, 0, 0},
+		{ActionStatusInProgress, 0, 0},
+		{ActionStatusInProgress, 4, 0},
+		{ActionStatusInProgress, 4, 0},
+		{ActionStatusInProgress, 8, 1},
+		{ActionStatusInProgress, 8, 1},
+		{ActionStatusComplete, 9, 1},
+	}
+	var polls int
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case fleetAgentActionStatusAPI:
+			require.Equal(t, http.MethodGet, r.Method)
+			p := progress[polls]
+			if polls < len(progress)-1 {
+				polls++
+			}
+			fmt.Fprintf(w, `{"items":[
+				{"actionId":"other","status":"IN_PROGRESS","nbAgentsActioned":1},
+				{"actionId":%q,"type":"UPGRADE","status":%q,"nbAgentsActioned":10,"nbAgentsActionCreated":10,"nbAgentsAck":%d,"nbAgentsFailed":%d}
+			]}`, id, p.status, p.acked, p.failed)
+		}
+	}
+
+	client, err := createTestServerAndClient(handler, WithActionPollInterval(time.Millisecond))
+	require.NoError(t, err)
+	require.NotNil(t, client)
+
+	var statuses []ActionStatus
+	err = client.StreamActionProgress(ctx, id, func(s ActionStatus) {
+		statuses = append(statuses, s)
+	})
+	require.NoError(t, err)
+
+	require.Len(t, statuses, 4)
+	require.Equal(t, []int{0, 4, 8, 9}, []int{statuses[0].AgentsAcked, statuses[1].AgentsAcked, statuses[2].AgentsAcked, statuses[3].AgentsAcked})
+	require.Equal(t, 10, statuses[0].AgentsInProgress())
+
+	final := statuses[3]
+	require.Equal(t, ActionStatusComplete, final.Status)
+	require.True(t, final.IsTerminal())
+	require.Equal(t, 1, final.AgentsFailed)
+	require.Equal(t, 0, final.AgentsInProgress())
+
+	_, err = client.GetActionStatus(ctx, "missing")
+	require.True(t, IsNotFound(err))
+}
+
+func TestFleetListActionStatusAndCancelAction(t *testing.T) {
+	ctx, cn := context.WithCancel(context.Background())
+	defer cn()
+
+	var query url.Values
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case fleetAgentActionStatusAPI:
+			query = r.URL.Query()
+			_, _ = w.Write([]byte(`{"items":[` +
+				`{"actionId":"upgrade-1","type":"UPGRADE","status":"IN_PROGRESS","nbAgentsActioned":10,"nbAgentsAck":3},` +
+				`{"actionId":"unenroll-1","type":"UNENROLL","status":"COMPLETE","nbAgentsActioned":2,"nbAgentsAck":2}]}`))
+		case fmt.Sprintf(fleetAgentActionCancelAPI, "upgrade-1"):
+			require.Equal(t, http.MethodPost, r.Method)
+			_, _ = w.Write([]byte(`{"item":{"id":"cancel-1","type":"CANCEL","created_at":"2023-11-20T10:00:00.000Z","data":{"target_id":"upgrade-1"}}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"statusCode":404,"error":"Not Found","message":"Action not found"}`))
+		}
+	}
+
+	client, err := createTestServerAndClient(handler)
+	require.NoError(t, err)
+
+	list, err := client.ListActionStatus(ctx, ListActionStatusRequest{Page: 2, PerPage: 2})
+	require.NoError(t, err)
+	require.Equal(t, url.Values{"page": {"2"}, "perPage": {"2"}}, query)
+	require.Len(t, list.Items, 2)
+	require.Equal(t, "upgrade-1", list.Items[0].ActionID)
+	require.Equal(t, 7, list.Items[0].AgentsInProgress())
+	require.True(t, list.Items[1].IsTerminal())
+
+	cancel, err := client.CancelAction(ctx, "upgrade-1")
+	require.NoError(t, err)
+	require.Equal(t, "cancel-1", cancel.ID)
+	require.Equal(t, AgentActionCancel, cancel.Type)
+	require.JSONEq(t, `{"target_id":"upgrade-1"}`, string(cancel.Data))
+
+	_, err = client.CancelAction(ctx, "unknown")
+	require.True(t, IsNotFound(err), err)
+}
+
+func TestFleetListAgentActivity(t *testing.T) {
+	ctx, cn := context.WithCancel(context.Background())
+	defer cn()
+
+	var query url.Values
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case fleetAgentActionStatusAPI:
+			query = r.URL.Query()
+			_, _ = w.Write(fleetListAgentActivityResponse)
+		}
+	}
+
+	client, err := createTestServerAndClient(handler)
+	require.NoError(t, err)
+
+	resp, err := client.ListAgentActivity(ctx, ListAgentActivityRequest{
+		Page:    2,
+		PerPage: 10,
+		Since:   time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC),
+	})
+	require.NoError(t, err)
+	require.Equal(t, "2", query.Get("page"))
+	require.Equal(t, "10", query.Get("perPage"))
+	require.Equal(t, "2024-01-15T00:00:00Z", query.Get("date"))
+
+	require.Len(t, resp.Items, 2)
+	require.Equal(t, "UPGRADE", resp.Items[0].Type)
+	require.Equal(t, "COMPLETE", resp.Items[0].Status)
+	require.Equal(t, 2, resp.Items[0].AgentsTargeted)
+	require.Equal(t, "POLICY_CHANGE", resp.Items[1].Type)
+	require.Equal(t, 5, resp.Items[1].AgentsTargeted)
+
+	created, err := resp.Items[0].CreatedAt()
+	require.NoError(t, err)
+	require.Equal(t, time.Date(2024, 1, 16, 10, 2, 11, 481000000, time.UTC), created)
+
+	// Until is applied by the client.
+	resp, err = client.ListAgentActivity(ctx, ListAgentActivityRequest{
+		Until: time.Date(2024, 1, 16, 0, 0, 0, 0, time.UTC),
+	})
+	require.NoError(t, err)
+	require.Empty(t, query)
+	require.Len(t, resp.Items, 1)
+	require.Equal(t, "policy:7a5b1f10-b45c-11ee-9d3c-2f5a0d6c36a1:2:1", resp.Items[0].ActionID)
+}
+
+func TestFleetListFleetServerHosts(t *testing.T) {
+	ctx, cn := context.WithCancel(context.Background())
+	defer cn()
+
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case fleetFleetServerHostsAPI:
+			_, _ = w.Write(fleetListServerHostsResponse)
+		}
+	}
+
+	client, err := createTestServerAndClient(handler)
+	require.NoError(t, err)
+	require.NotNil(t, client)
+
+	req := ListFleetServerHostsRequest{}
+	resp, err := client.ListFleetServerHosts(ctx, req)
+	require.NoError(t, err)
+	require.NotNil(t, resp)
+
+	require.Len(t, resp.Items, 1)
+	item := resp.Items[0]
+	require.Equal(t, "fleet-default-fleet-server-host", item.ID)
+	require.Equal(t, "Default", item.Name)
+	require.True(t, item.IsDefault)
+	require.Equal(t, []string{"https://fleet-server:8220"}, item.HostURLs)
+	require.True(t, item.IsPreconfigured)
+}
+
+func TestFleetGetFleetServerHost(t *testing.T) {
+	const id = "fleet-default-fleet-server-host"
+
+	ctx, cn := context.WithCancel(context.Background())
+	defer cn()
+
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case fmt.Sprintf(fleetFleetServerHostAPI, id):
+			_, _ = w.Write(fleetGetFleetServerHostResponse)
+		}
+	}
+
+	client, err := createTestServerAndClient(handler)
+	require.NoError(t, err)
+	require.NotNil(t, client)
+
+	req := GetFleetServerHostRequest{
+		ID: id,
 	}
 	resp, err := client.GetFleetServerHost(ctx, req)
 	require.NoError(t, err)
@@ -384,7 +1406,166 @@ func TestFleetGetFleetServerHost(t *testing.T) {
 	require.True(t, resp.IsPreconfigured)
 }
 
-func createTestServerAndClient(handler http.HandlerFunc) (*Client, error) {
+func TestFleetFleetServerHostCRUD(t *testing.T) {
+	ctx, cn := context.WithCancel(context.Background())
+	defer cn()
+
+	hosts := map[string]FleetServerHost{}
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		write := func(h FleetServerHost) {
+			require.NoError(t, json.NewEncoder(w).Encode(map[string]any{"item": h}))
+		}
+		switch {
+		case r.URL.Path == fleetFleetServerHostsAPI && r.Method == http.MethodPost:
+			var h FleetServerHost
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&h))
+			if h.ID == "" {
+				h.ID = "generated-id"
+			}
+			hosts[h.ID] = h
+			write(h)
+		case strings.HasPrefix(r.URL.Path, fleetFleetServerHostsAPI+"/"):
+			id := strings.TrimPrefix(r.URL.Path, fleetFleetServerHostsAPI+"/")
+			h, ok := hosts[id]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				_, _ = w.Write([]byte(`{"statusCode":404,"error":"Not Found","message":"Fleet server ` + id + ` not found"}`))
+				return
+			}
+			switch r.Method {
+			case http.MethodGet:
+				write(h)
+			case http.MethodPut:
+				require.NoError(t, json.NewDecoder(r.Body).Decode(&h))
+				hosts[id] = h
+				write(h)
+			case http.MethodDelete:
+				delete(hosts, id)
+				_, _ = w.Write([]byte(`{"id":"` + id + `"}`))
+			}
+		}
+	}
+
+	client, err := createTestServerAndClient(handler)
+	require.NoError(t, err)
+
+	created, err := client.CreateFleetServerHost(ctx, CreateFleetServerHostRequest{
+		ID:        "test-fleet-server",
+		Name:      "Test",
+		HostURLs:  []string{"https://fleet-server.test:8220"},
+		IsDefault: true,
+	})
+	require.NoError(t, err)
+	require.Equal(t, "test-fleet-server", created.ID)
+	require.True(t, created.IsDefault)
+	require.Equal(t, []string{"https://fleet-server.test:8220"}, created.HostURLs)
+
+	isDefault := false
+	updated, err := client.UpdateFleetServerHost(ctx, UpdateFleetServerHostRequest{ID: created.ID, IsDefault: &isDefault})
+	require.NoError(t, err)
+	require.False(t, updated.IsDefault)
+	require.Equal(t, "Test", updated.Name)
+
+	require.NoError(t, client.DeleteFleetServerHost(ctx, DeleteFleetServerHostRequest{ID: created.ID}))
+	_, err = client.GetFleetServerHost(ctx, GetFleetServerHostRequest{ID: created.ID})
+	require.True(t, IsNotFound(err))
+	require.True(t, IsNotFound(client.DeleteFleetServerHost(ctx, DeleteFleetServerHostRequest{ID: created.ID})))
+}
+
+func TestFleetGetFleetServerPolicy(t *testing.T) {
+	const policyID = "fleet-server-policy"
+
+	ctx, cn := context.WithCancel(context.Background())
+	defer cn()
+
+	var kuery string
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case fleetPackagePoliciesAPI:
+			kuery = r.URL.Query().Get("kuery")
+			_, _ = w.Write(fleetListFleetServerPackagePoliciesResponse)
+		}
+	}
+
+	client, err := createTestServerAndClient(handler)
+	require.NoError(t, err)
+	require.NotNil(t, client)
+
+	resp, err := client.GetFleetServerPolicy(ctx, policyID)
+	require.NoError(t, err)
+
+	require.Contains(t, kuery, policyID)
+	require.Contains(t, kuery, "fleet_server")
+	require.Equal(t, "c7d2c2d2-3f8e-4b4e-a4a6-5c3e4b9e2f10", resp.ID)
+	require.Equal(t, policyID, resp.PolicyID)
+	require.Equal(t, "fleet_server", resp.Package.Name)
+	require.Equal(t, 2, resp.Revision)
+	require.Equal(t, FleetServerSettings{
+		Host:      "0.0.0.0",
+		Port:      8220,
+		MaxAgents: 10000,
+	}, resp.Settings)
+	require.Len(t, resp.Inputs, 1)
+
+	_, err = client.GetFleetServerPolicy(ctx, "unknown-policy")
+	require.Error(t, err)
+}
+
+func TestFleetUpdateFleetServerPolicy(t *testing.T) {
+	const id = "c7d2c2d2-3f8e-4b4e-a4a6-5c3e4b9e2f10"
+
+	ctx, cn := context.WithCancel(context.Background())
+	defer cn()
+
+	var body map[string]interface{}
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case fleetPackagePoliciesAPI:
+			_, _ = w.Write(fleetListFleetServerPackagePoliciesResponse)
+		case fmt.Sprintf(fleetPackagePolicyAPI, id):
+			require.Equal(t, http.MethodPut, r.Method)
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+			_, _ = w.Write(fleetUpdateFleetServerPackagePolicyResponse)
+		}
+	}
+
+	client, err := createTestServerAndClient(handler)
+	require.NoError(t, err)
+	require.NotNil(t, client)
+
+	policy, err := client.GetFleetServerPolicy(ctx, "fleet-server-policy")
+	require.NoError(t, err)
+
+	policy.Settings.Port = 8221
+	policy.Settings.MaxAgents = 500
+	resp, err := client.UpdateFleetServerPolicy(ctx, UpdateFleetServerPolicyRequest(policy))
+	require.NoError(t, err)
+
+	require.Equal(t, "fleet-server-policy", body["policy_id"])
+	require.Equal(t, "fleet_server-1", body["name"])
+	inputs, ok := body["inputs"].([]interface{})
+	require.True(t, ok)
+	require.Len(t, inputs, 1)
+	posted := mapstr.M(inputs[0].(map[string]interface{}))
+	port, err := posted.GetValue("vars.port.value")
+	require.NoError(t, err)
+	require.Equal(t, []interface{}{float64(8221)}, port)
+	maxAgents, err := posted.GetValue("vars.max_agents.value")
+	require.NoError(t, err)
+	require.Equal(t, float64(500), maxAgents)
+	host, err := posted.GetValue("vars.host.value")
+	require.NoError(t, err)
+	require.Equal(t, []interface{}{"0.0.0.0"}, host)
+
+	// The original inputs are left untouched.
+	require.Equal(t, 8220, newFleetServerPolicy(fleetServerPackagePolicy{Inputs: policy.Inputs}).Settings.Port)
+
+	require.Equal(t, 3, resp.Revision)
+	require.Equal(t, 8221, resp.Settings.Port)
+	require.Equal(t, 500, resp.Settings.MaxAgents)
+}
+
+func createTestServerAndClient(handler http.HandlerFunc, opts ...ClientOption) (*Client, error) {
 	kibanaTS := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		switch r.URL.Path {
 		case statusAPI:
@@ -398,5 +1579,646 @@ func createTestServerAndClient(handler http.HandlerFunc) (*Client, error) {
 protocol: http
 host: %s
 `, kibanaTS.Listener.Addr().String())
-	return NewKibanaClient(config.MustNewConfigFrom(cfg), binaryName, v, commit, buildTime)
+	return NewKibanaClient(config.MustNewConfigFrom(cfg), binaryName, v, commit, buildTime, opts...)
+}
+
+func TestFleetPackagePolicyCRUD(t *testing.T) {
+	ctx, cn := context.WithCancel(context.Background())
+	defer cn()
+
+	policies := map[string]PackagePolicy{}
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		write := func(p PackagePolicy) {
+			require.NoError(t, json.NewEncoder(w).Encode(PackagePolicyResponse{Item: p}))
+		}
+
+		if r.URL.Path == fleetPackagePoliciesAPI {
+			require.Equal(t, http.MethodPost, r.Method)
+			var req PackagePolicyRequest
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+			p := PackagePolicy{ID: req.ID, Revision: 1, Enabled: true, Name: req.Name, Namespace: req.Namespace,
+				PolicyID: req.PolicyID, Package: req.Package, Inputs: req.Inputs, Vars: req.Vars}
+			policies[p.ID] = p
+			write(p)
+			return
+		}
+
+		id := strings.TrimPrefix(r.URL.Path, fleetPackagePoliciesAPI+"/")
+		p, ok := policies[id]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"statusCode":404,"error":"Not Found","message":"Package policy ` + id + ` not found"}`))
+			return
+		}
+		switch r.Method {
+		case http.MethodGet:
+			write(p)
+		case http.MethodPut:
+			var body map[string]interface{}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+			require.NotContains(t, body, "id")
+			p.Revision++
+			p.Name, _ = body["name"].(string)
+			p.Vars, _ = body["vars"].(map[string]interface{})
+			policies[id] = p
+			write(p)
+		case http.MethodDelete:
+			delete(policies, id)
+			_, _ = w.Write([]byte(`{"id":"` + id + `"}`))
+		}
+	}
+
+	client, err := createTestServerAndClient(handler)
+	require.NoError(t, err)
+
+	req := PackagePolicyRequest{
+		ID:        "system-1",
+		Name:      "system-1",
+		Namespace: "default",
+		PolicyID:  "policy-id",
+		Package:   PackagePolicyRequestPackage{Name: "system", Version: "1.38.1"},
+	}
+	created, err := client.CreatePackagePolicy(ctx, req)
+	require.NoError(t, err)
+	require.Equal(t, "system-1", created.Item.ID)
+	require.Equal(t, 1, created.Item.Revision)
+
+	got, err := client.GetPackagePolicy(ctx, "system-1")
+	require.NoError(t, err)
+	require.Equal(t, created.Item, got.Item)
+
+	req.Name = "system-renamed"
+	req.Vars = map[string]interface{}{"period": "10s"}
+	updated, err := client.UpdatePackagePolicy(ctx, "system-1", req)
+	require.NoError(t, err)
+	require.Equal(t, 2, updated.Item.Revision)
+	require.Equal(t, "system-renamed", updated.Item.Name)
+	require.Equal(t, "10s", updated.Item.Vars["period"])
+
+	deleted, err := client.DeletePackagePolicy(ctx, "system-1")
+	require.NoError(t, err)
+	require.Equal(t, "system-1", deleted.ID)
+
+	_, err = client.GetPackagePolicy(ctx, "system-1")
+	require.True(t, IsNotFound(err), err)
+}
+
+func TestFleetSettings(t *testing.T) {
+	ctx, cn := context.WithCancel(context.Background())
+	defer cn()
+
+	var body map[string]interface{}
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != fleetSettingsAPI {
+			return
+		}
+		switch r.Method {
+		case http.MethodGet:
+			_, _ = w.Write(fleetGetSettingsResponse)
+		case http.MethodPut:
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+			_, _ = w.Write([]byte(`{"item":{"id":"fleet-default-settings","prerelease_integrations_enabled":true,` +
+				`"delete_unenrolled_agents":{"enabled":true,"is_preconfigured":false}}}`))
+		}
+	}
+
+	client, err := createTestServerAndClient(handler)
+	require.NoError(t, err)
+
+	settings, err := client.GetFleetSettings(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "fleet-default-settings", settings.ID)
+	require.True(t, settings.HasSeenAddDataNotice)
+	require.False(t, settings.PrereleaseIntegrationsEnabled)
+	require.Equal(t, &DeleteUnenrolledAgentsSettings{}, settings.DeleteUnenrolledAgents)
+
+	updated, err := client.UpdateFleetSettings(ctx, UpdateFleetSettingsRequest{
+		PrereleaseIntegrationsEnabled: TRUE,
+		DeleteUnenrolledAgents:        &DeleteUnenrolledAgentsSettings{Enabled: true},
+	})
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{
+		"prerelease_integrations_enabled": true,
+		"delete_unenrolled_agents":        map[string]interface{}{"enabled": true, "is_preconfigured": false},
+	}, body)
+	require.True(t, updated.PrereleaseIntegrationsEnabled)
+	require.True(t, updated.DeleteUnenrolledAgents.Enabled)
+}
+
+func TestFleetSetup(t *testing.T) {
+	ctx, cn := context.WithCancel(context.Background())
+	defer cn()
+
+	var paths []string
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, http.MethodPost, r.Method)
+		paths = append(paths, r.URL.Path)
+		switch r.URL.Path {
+		case fleetSetupAPI:
+			_, _ = w.Write([]byte(`{"isInitialized":true,"nonFatalErrors":[{"name":"Error","message":"Package endpoint could not be installed"}]}`))
+		case fleetAgentsSetupAPI:
+			_, _ = w.Write([]byte(`{"isInitialized":true}`))
+		}
+	}
+
+	client, err := createTestServerAndClient(handler)
+	require.NoError(t, err)
+
+	setup, err := client.Setup(ctx)
+	require.NoError(t, err)
+	require.True(t, setup.IsInitialized)
+	require.Equal(t, []FleetSetupError{{Name: "Error", Message: "Package endpoint could not be installed"}}, setup.NonFatalErrors)
+
+	setup, err = client.AgentsSetup(ctx)
+	require.NoError(t, err)
+	require.True(t, setup.IsInitialized)
+	require.Empty(t, setup.NonFatalErrors)
+
+	require.Equal(t, []string{fleetSetupAPI, fleetAgentsSetupAPI}, paths)
+}
+
+func TestFleetGetOutputHealth(t *testing.T) {
+	ctx, cn := context.WithCancel(context.Background())
+	defer cn()
+
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, http.MethodGet, r.Method)
+		switch r.URL.Path {
+		case fmt.Sprintf(fleetOutputHealthAPI, "logstash-output"):
+			_, _ = w.Write([]byte(`{"state":"DEGRADED","message":"dial tcp 10.0.0.1:5044: connect: connection refused","timestamp":"2024-05-01T10:00:00.000Z"}`))
+		case fmt.Sprintf(fleetOutputHealthAPI, "new-output"):
+			_, _ = w.Write([]byte(`{"state":"UNKNOWN","message":"","timestamp":""}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"statusCode":404,"error":"Not Found","message":"Output not found"}`))
+		}
+	}
+
+	client, err := createTestServerAndClient(handler)
+	require.NoError(t, err)
+
+	health, err := client.GetOutputHealth(ctx, "logstash-output")
+	require.NoError(t, err)
+	require.Equal(t, OutputHealthDegraded, health.State)
+	require.Contains(t, health.Message, "connection refused")
+	require.Equal(t, "2024-05-01T10:00:00.000Z", health.Timestamp)
+
+	health, err = client.GetOutputHealth(ctx, "new-output")
+	require.NoError(t, err)
+	require.Equal(t, OutputHealthUnknown, health.State)
+
+	_, err = client.GetOutputHealth(ctx, "missing")
+	require.True(t, IsNotFound(err))
+}
+
+func TestFleetListOutputs(t *testing.T) {
+	ctx, cn := context.WithCancel(context.Background())
+	defer cn()
+
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case fleetOutputsAPI:
+			_, _ = w.Write(fleetListOutputsResponse)
+		}
+	}
+
+	client, err := createTestServerAndClient(handler)
+	require.NoError(t, err)
+
+	resp, err := client.ListOutputs(ctx)
+	require.NoError(t, err)
+	require.Len(t, resp.Items, 3)
+
+	es := resp.Items[0]
+	require.Equal(t, OutputTypeElasticsearch, es.Type)
+	require.True(t, es.IsDefault)
+	require.True(t, es.IsPreconfigured)
+	require.Equal(t, []string{"https://elasticsearch:9200"}, es.Hosts)
+
+	logstash := resp.Items[1]
+	require.Equal(t, OutputTypeLogstash, logstash.Type)
+	require.NotNil(t, logstash.SSL)
+	require.Len(t, logstash.SSL.CertificateAuthorities, 1)
+
+	kafka := resp.Items[2]
+	require.Equal(t, OutputTypeKafka, kafka.Type)
+	require.Equal(t, "2.6.0", kafka.Version)
+	require.Equal(t, "user_pass", kafka.AuthType)
+	require.Equal(t, "logs", kafka.Topic)
+	require.Equal(t, 1, kafka.RequiredAcks)
+}
+
+func TestFleetOutputCRUD(t *testing.T) {
+	ctx, cn := context.WithCancel(context.Background())
+	defer cn()
+
+	outputs := map[string]Output{}
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		write := func(o Output) {
+			require.NoError(t, json.NewEncoder(w).Encode(map[string]Output{"item": o}))
+		}
+
+		if r.URL.Path == fleetOutputsAPI {
+			require.Equal(t, http.MethodPost, r.Method)
+			var o Output
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&o))
+			if o.ID == "" {
+				o.ID = "generated-id"
+			}
+			outputs[o.ID] = o
+			write(o)
+			return
+		}
+
+		id := strings.TrimPrefix(r.URL.Path, fleetOutputsAPI+"/")
+		if _, ok := outputs[id]; !ok {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"statusCode":404,"error":"Not Found","message":"Output ` + id + ` not found"}`))
+			return
+		}
+		switch r.Method {
+		case http.MethodPut:
+			var body map[string]interface{}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+			require.NotContains(t, body, "id")
+			b, err := json.Marshal(body)
+			require.NoError(t, err)
+			var o Output
+			require.NoError(t, json.Unmarshal(b, &o))
+			o.ID = id
+			outputs[id] = o
+			write(o)
+		case http.MethodDelete:
+			delete(outputs, id)
+			_, _ = w.Write([]byte(`{"id":"` + id + `"}`))
+		}
+	}
+
+	client, err := createTestServerAndClient(handler)
+	require.NoError(t, err)
+
+	created, err := client.CreateOutput(ctx, Output{
+		Name:  "logstash",
+		Type:  OutputTypeLogstash,
+		Hosts: []string{"logstash:5044"},
+	})
+	require.NoError(t, err)
+	require.Equal(t, "generated-id", created.ID)
+
+	created.Type = OutputTypeKafka
+	created.Hosts = []string{"kafka:9092"}
+	created.Topic = "logs"
+	updated, err := client.UpdateOutput(ctx, created.ID, created)
+	require.NoError(t, err)
+	require.Equal(t, created, updated)
+
+	require.NoError(t, client.DeleteOutput(ctx, created.ID))
+	require.Empty(t, outputs)
+
+	err = client.DeleteOutput(ctx, created.ID)
+	require.True(t, IsNotFound(err), err)
+}
+
+func TestFleetDownloadSources(t *testing.T) {
+	ctx, cn := context.WithCancel(context.Background())
+	defer cn()
+
+	const id = "mirror"
+	var updated DownloadSource
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == fleetAgentDownloadSourcesAPI && r.Method == http.MethodGet:
+			_, _ = w.Write([]byte(`{"items":[` +
+				`{"id":"fleet-default-download-source","name":"Elastic Artifacts","host":"https://artifacts.elastic.co/downloads/","is_default":true},` +
+				`{"id":"mirror","name":"Mirror","host":"https://mirror.internal/downloads/","is_default":false,"proxy_id":"proxy"}` +
+				`],"total":2,"page":1,"perPage":10000}`))
+		case r.URL.Path == fmt.Sprintf(fleetAgentDownloadSourceAPI, id) && r.Method == http.MethodPut:
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&updated))
+			_, _ = w.Write([]byte(`{"item":{"id":"mirror","name":"` + updated.Name + `","host":"` + updated.Host + `","is_default":true}}`))
+		case r.URL.Path == fmt.Sprintf(fleetAgentDownloadSourceAPI, id) && r.Method == http.MethodDelete:
+			_, _ = w.Write([]byte(`{"id":"mirror"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}
+
+	client, err := createTestServerAndClient(handler)
+	require.NoError(t, err)
+
+	list, err := client.ListDownloadSources(ctx)
+	require.NoError(t, err)
+	require.Len(t, list.Items, 2)
+	require.True(t, list.Items[0].IsDefault)
+	require.Equal(t, DownloadSourceItem{
+		ID:      "mirror",
+		Name:    "Mirror",
+		Host:    "https://mirror.internal/downloads/",
+		ProxyID: "proxy",
+	}, list.Items[1])
+
+	resp, err := client.UpdateDownloadSource(ctx, id, DownloadSource{
+		Name:      "Mirror",
+		Host:      "https://mirror-2.internal/downloads/",
+		IsDefault: true,
+	})
+	require.NoError(t, err)
+	require.Equal(t, "https://mirror-2.internal/downloads/", updated.Host)
+	require.Equal(t, "https://mirror-2.internal/downloads/", resp.Item.Host)
+	require.True(t, resp.Item.IsDefault)
+
+	require.NoError(t, client.DeleteDownloadSource(ctx, id))
+	require.True(t, IsNotFound(client.DeleteDownloadSource(ctx, "unknown")))
+}
+
+func TestFleetProxies(t *testing.T) {
+	ctx, cn := context.WithCancel(context.Background())
+	defer cn()
+
+	proxies := map[string]Proxy{}
+	bodies := map[string]map[string]interface{}{}
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		writeItem := func(item interface{}) {
+			require.NoError(t, json.NewEncoder(w).Encode(map[string]interface{}{"item": item}))
+		}
+
+		switch {
+		case r.URL.Path == fleetProxiesAPI && r.Method == http.MethodGet:
+			resp := ListProxiesResponse{Page: 1, PerPage: 20}
+			for _, p := range proxies {
+				resp.Items = append(resp.Items, p)
+			}
+			resp.Total = len(resp.Items)
+			require.NoError(t, json.NewEncoder(w).Encode(resp))
+			return
+		case r.URL.Path == fleetProxiesAPI && r.Method == http.MethodPost:
+			var p Proxy
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&p))
+			proxies[p.ID] = p
+			writeItem(p)
+			return
+		case r.URL.Path == fmt.Sprintf(fleetFleetServerHostAPI, "fleet-server"):
+			var body map[string]interface{}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+			bodies[r.URL.Path] = body
+			writeItem(FleetServerHost{ID: "fleet-server", Name: "Fleet Server", ProxyID: body["proxy_id"].(string)})
+			return
+		}
+
+		id := strings.TrimPrefix(r.URL.Path, fleetProxiesAPI+"/")
+		p, ok := proxies[id]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"statusCode":404,"error":"Not Found","message":"Proxy ` + id + ` not found"}`))
+			return
+		}
+		switch r.Method {
+		case http.MethodGet:
+			writeItem(p)
+		case http.MethodPut:
+			var body map[string]interface{}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+			require.NotContains(t, body, "id")
+			p.URL = body["url"].(string)
+			proxies[id] = p
+			writeItem(p)
+		case http.MethodDelete:
+			delete(proxies, id)
+			_, _ = w.Write([]byte(`{"id":"` + id + `"}`))
+		}
+	}
+
+	client, err := createTestServerAndClient(handler)
+	require.NoError(t, err)
+
+	created, err := client.CreateProxy(ctx, Proxy{
+		ID:           "corp-proxy",
+		Name:         "Corporate proxy",
+		URL:          "http://proxy.internal:3128",
+		ProxyHeaders: map[string]string{"X-Team": "agent"},
+	})
+	require.NoError(t, err)
+	require.Equal(t, "corp-proxy", created.ID)
+
+	list, err := client.ListProxies(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []Proxy{created}, list.Items)
+
+	created.URL = "http://proxy-2.internal:3128"
+	updated, err := client.UpdateProxy(ctx, created.ID, created)
+	require.NoError(t, err)
+	require.Equal(t, created, updated)
+
+	got, err := client.GetProxy(ctx, created.ID)
+	require.NoError(t, err)
+	require.Equal(t, "http://proxy-2.internal:3128", got.URL)
+
+	host, err := client.UpdateFleetServerHost(ctx, UpdateFleetServerHostRequest{ID: "fleet-server", ProxyID: created.ID})
+	require.NoError(t, err)
+	require.Equal(t, created.ID, host.ProxyID)
+	require.Equal(t, map[string]interface{}{"proxy_id": "corp-proxy"}, bodies[fmt.Sprintf(fleetFleetServerHostAPI, "fleet-server")])
+
+	require.NoError(t, client.DeleteProxy(ctx, created.ID))
+	_, err = client.GetProxy(ctx, created.ID)
+	require.True(t, IsNotFound(err), err)
+}
+
+func TestFleetUninstallTokens(t *testing.T) {
+	ctx, cn := context.WithCancel(context.Background())
+	defer cn()
+
+	var queries []url.Values
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case fleetUninstallTokensAPI:
+			queries = append(queries, r.URL.Query())
+			_, _ = w.Write([]byte(`{"items":[{"id":"token-1","policy_id":"policy-1","created_at":"2023-11-20T10:00:00.000Z"}],"total":1,"page":1,"perPage":20}`))
+		case fleetUninstallTokensAPI + "/token-1":
+			_, _ = w.Write([]byte(`{"item":{"id":"token-1","policy_id":"policy-1","token":"decrypted","created_at":"2023-11-20T10:00:00.000Z"}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}
+
+	client, err := createTestServerAndClient(handler)
+	require.NoError(t, err)
+
+	list, err := client.ListUninstallTokens(ctx, ListUninstallTokensRequest{PolicyID: "policy-1", PerPage: 20})
+	require.NoError(t, err)
+	require.Equal(t, []UninstallTokenItem{{ID: "token-1", PolicyID: "policy-1", CreatedAt: "2023-11-20T10:00:00.000Z"}}, list.Items)
+	require.Equal(t, url.Values{"policyId": {"policy-1"}, "perPage": {"20"}}, queries[0])
+
+	tokens, err := client.GetPolicyUninstallTokens(ctx, "policy-1")
+	require.NoError(t, err)
+	require.Len(t, tokens.Items, 1)
+	require.Equal(t, "decrypted", tokens.Items[0].Token)
+	require.Equal(t, url.Values{"policyId": {"policy-1"}, "page": {"1"}, "perPage": {"1000"}}, queries[1])
+}
+
+func TestFleetInstallUninstallPackage(t *testing.T) {
+	ctx, cn := context.WithCancel(context.Background())
+	defer cn()
+
+	type call struct {
+		method string
+		path   string
+		query  url.Values
+		body   map[string]interface{}
+	}
+	var calls []call
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, fleetEPMPackagesAPI) {
+			return
+		}
+		var body map[string]interface{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		calls = append(calls, call{r.Method, r.URL.Path, r.URL.Query(), body})
+
+		switch r.Method {
+		case http.MethodPost:
+			_, _ = w.Write([]byte(`{"items":[{"id":"system-logs","type":"index_template"}],"_meta":{"install_source":"registry"}}`))
+		case http.MethodDelete:
+			_, _ = w.Write([]byte(`{"items":[{"id":"system-logs","type":"index_template"}]}`))
+		}
+	}
+
+	client, err := createTestServerAndClient(handler)
+	require.NoError(t, err)
+
+	installed, err := client.InstallPackage(ctx, InstallPackageRequest{Name: "system", Version: "1.38.1", Force: true})
+	require.NoError(t, err)
+	require.Equal(t, []PackageAsset{{ID: "system-logs", Type: "index_template"}}, installed.Items)
+	require.Equal(t, "registry", installed.Meta.InstallSource)
+
+	_, err = client.InstallPackage(ctx, InstallPackageRequest{Name: "endpoint", Prerelease: true})
+	require.NoError(t, err)
+
+	uninstalled, err := client.UninstallPackage(ctx, UninstallPackageRequest{Name: "system", Version: "1.38.1"})
+	require.NoError(t, err)
+	require.Len(t, uninstalled.Items, 1)
+
+	require.Equal(t, []call{
+		{http.MethodPost, "/api/fleet/epm/packages/system/1.38.1", url.Values{}, map[string]interface{}{"force": true}},
+		{http.MethodPost, "/api/fleet/epm/packages/endpoint", url.Values{"prerelease": {"true"}}, map[string]interface{}{}},
+		{http.MethodDelete, "/api/fleet/epm/packages/system/1.38.1", url.Values{}, map[string]interface{}{}},
+	}, calls)
+}
+
+func TestFleetListPackages(t *testing.T) {
+	ctx, cn := context.WithCancel(context.Background())
+	defer cn()
+
+	var query url.Values
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case fleetEPMPackagesAPI:
+			query = r.URL.Query()
+			_, _ = w.Write(fleetListPackagesResponse)
+		}
+	}
+
+	client, err := createTestServerAndClient(handler)
+	require.NoError(t, err)
+
+	packages, err := client.ListPackages(ctx, ListPackagesRequest{Category: "security", Prerelease: true})
+	require.NoError(t, err)
+	require.Equal(t, url.Values{"category": {"security"}, "prerelease": {"true"}}, query)
+	require.Len(t, packages, 2)
+
+	endpoint := packages[0]
+	require.Equal(t, "endpoint", endpoint.Name)
+	require.Equal(t, "Elastic Defend", endpoint.Title)
+	require.Equal(t, "8.12.0", endpoint.Version)
+	require.Equal(t, PackageStatusInstalled, endpoint.Status)
+	require.Equal(t, &PackageInstallationInfo{Version: "8.10.2", InstallStatus: "installed"}, endpoint.InstallationInfo)
+
+	require.Equal(t, PackageStatusNotInstalled, packages[1].Status)
+	require.Nil(t, packages[1].InstallationInfo)
+}
+
+func TestFleetGetPackage(t *testing.T) {
+	ctx, cn := context.WithCancel(context.Background())
+	defer cn()
+
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case fleetEPMPackagesAPI + "/system/1.38.1":
+			_, _ = w.Write([]byte(`{"item":{"name":"system","title":"System","version":"1.38.1","status":"installed"}}`))
+		case fleetEPMPackagesAPI + "/system":
+			// Kibana 7.x
+			_, _ = w.Write([]byte(`{"response":{"name":"system","title":"System","version":"1.20.4","status":"not_installed"}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"statusCode":404,"error":"Not Found","message":"package not found"}`))
+		}
+	}
+
+	client, err := createTestServerAndClient(handler)
+	require.NoError(t, err)
+
+	pkg, err := client.GetPackage(ctx, GetPackageRequest{Name: "system", Version: "1.38.1"})
+	require.NoError(t, err)
+	require.Equal(t, PackageInfo{Name: "system", Title: "System", Version: "1.38.1", Status: PackageStatusInstalled}, pkg)
+
+	pkg, err = client.GetPackage(ctx, GetPackageRequest{Name: "system"})
+	require.NoError(t, err)
+	require.Equal(t, "1.20.4", pkg.Version)
+	require.Equal(t, PackageStatusNotInstalled, pkg.Status)
+
+	_, err = client.GetPackage(ctx, GetPackageRequest{Name: "unknown"})
+	require.True(t, IsNotFound(err), err)
+}
+
+func TestFleetAgentDiagnostics(t *testing.T) {
+	const agentID = "f512f36f-bf78-4285-aff0-baeafbcdf21e"
+
+	ctx, cn := context.WithCancel(context.Background())
+	defer cn()
+
+	archive := bytes.Repeat([]byte("PK\x03\x04diagnostics"), 1024)
+	var requested map[string]interface{}
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case fmt.Sprintf(fleetAgentDiagnosticsAPI, agentID):
+			require.Equal(t, http.MethodPost, r.Method)
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&requested))
+			_, _ = w.Write([]byte(`{"actionId":"action-id"}`))
+		case fmt.Sprintf(fleetAgentUploadsAPI, agentID):
+			_, _ = w.Write([]byte(`{"items":[` +
+				`{"id":"file-1","name":"elastic-agent-diagnostics.zip","filePath":"/api/fleet/agents/files/file-1/elastic-agent-diagnostics.zip",` +
+				`"createTime":"2023-11-20T10:00:00.000Z","status":"READY","actionId":"action-id"},` +
+				`{"id":"file-2","name":"elastic-agent-diagnostics-2.zip","filePath":"","createTime":"2023-11-20T11:00:00.000Z",` +
+				`"status":"AWAITING_UPLOAD","actionId":"action-id-2"}]}`))
+		case fmt.Sprintf(fleetAgentFileAPI, "file-1", "elastic-agent-diagnostics.zip"):
+			w.Header().Set("Content-Type", "application/octet-stream")
+			_, _ = w.Write(archive)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"statusCode":404,"error":"Not Found","message":"File not found"}`))
+		}
+	}
+
+	client, err := createTestServerAndClient(handler)
+	require.NoError(t, err)
+
+	resp, err := client.RequestDiagnostics(ctx, RequestDiagnosticsRequest{ID: agentID, AdditionalMetrics: []string{"CPU"}})
+	require.NoError(t, err)
+	require.Equal(t, "action-id", resp.ActionID)
+	require.Equal(t, map[string]interface{}{"additional_metrics": []interface{}{"CPU"}}, requested)
+
+	uploads, err := client.ListAgentDiagnostics(ctx, agentID)
+	require.NoError(t, err)
+	require.Len(t, uploads, 2)
+	require.Equal(t, DiagnosticsStatusReady, uploads[0].Status)
+
+	var buf bytes.Buffer
+	require.NoError(t, client.DownloadDiagnostics(ctx, uploads[0], &buf))
+	require.Equal(t, archive, buf.Bytes())
+
+	err = client.DownloadDiagnostics(ctx, uploads[1], &buf)
+	require.ErrorContains(t, err, "status is AWAITING_UPLOAD")
+
+	missing := uploads[0]
+	missing.ID = "deleted"
+	err = client.DownloadDiagnostics(ctx, missing, &buf)
+	require.True(t, IsNotFound(err), err)
 }
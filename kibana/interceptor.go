@@ -0,0 +1,73 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package kibana
+
+import (
+	"net/http"
+)
+
+// Interceptor observes and modifies the requests sent to Kibana and their
+// responses, e.g. to add custom headers, refresh credentials or write an
+// audit log. Like Signer, it is called for every request sent, including
+// retries and requests to other hosts.
+type Interceptor interface {
+	// OnRequest is called before the request is sent, after the client set
+	// its headers and before the request is signed. It may modify the
+	// request. An error aborts the request.
+	OnRequest(req *http.Request) error
+	// OnResponse is called with the response, or the error, of the request.
+	// It returns the response and error passed on to the caller, usually
+	// the ones it received. A replaced response must have a body.
+	OnResponse(req *http.Request, resp *http.Response, err error) (*http.Response, error)
+}
+
+// WithInterceptors adds interceptors to the client. OnRequest is called in
+// the order the interceptors are added, OnResponse in the reverse order, so
+// the first interceptor sees the request first and the response last.
+func WithInterceptors(interceptors ...Interceptor) ClientOption {
+	return func(client *Client) {
+		for _, i := range interceptors {
+			if i != nil {
+				client.Connection.interceptors = append(client.Connection.interceptors, i)
+			}
+		}
+	}
+}
+
+// InterceptorFuncs is an Interceptor calling the functions that are set.
+type InterceptorFuncs struct {
+	Request  func(req *http.Request) error
+	Response func(req *http.Request, resp *http.Response, err error) (*http.Response, error)
+}
+
+// OnRequest calls f.Request if it is set.
+func (f InterceptorFuncs) OnRequest(req *http.Request) error {
+	if f.Request == nil {
+		return nil
+	}
+	return f.Request(req)
+}
+
+// OnResponse calls f.Response if it is set, otherwise it returns resp and
+// err unchanged.
+func (f InterceptorFuncs) OnResponse(req *http.Request, resp *http.Response, err error) (*http.Response, error) {
+	if f.Response == nil {
+		return resp, err
+	}
+	return f.Response(req, resp, err)
+}
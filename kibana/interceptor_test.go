@@ -0,0 +1,116 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package kibana
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInterceptors(t *testing.T) {
+	var calls []string
+	recorder := func(name string) Interceptor {
+		return InterceptorFuncs{
+			Request: func(req *http.Request) error {
+				calls = append(calls, name+" request")
+				req.Header.Add("X-Interceptors", name)
+				return nil
+			},
+			Response: func(req *http.Request, resp *http.Response, err error) (*http.Response, error) {
+				calls = append(calls, name+" response")
+				return resp, err
+			},
+		}
+	}
+
+	token := "expired"
+	refresh := InterceptorFuncs{
+		Request: func(req *http.Request) error {
+			req.Header.Set("Authorization", "Bearer "+token)
+			return nil
+		},
+		Response: func(req *http.Request, resp *http.Response, err error) (*http.Response, error) {
+			if err == nil && resp.StatusCode == http.StatusUnauthorized {
+				token = "refreshed"
+			}
+			return resp, err
+		},
+	}
+
+	client, err := createTestServerAndClient(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, []string{"first", "second"}, r.Header.Values("X-Interceptors"))
+		if r.Header.Get("Authorization") != "Bearer refreshed" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte(`{}`))
+	}, WithInterceptors(recorder("first"), nil, recorder("second"), refresh))
+	require.NoError(t, err)
+
+	calls = nil
+	code, _, _ := client.Connection.Request(http.MethodGet, "/api/test", nil, nil, nil)
+	assert.Equal(t, http.StatusUnauthorized, code)
+	assert.Equal(t, []string{"first request", "second request", "second response", "first response"}, calls)
+
+	code, _, err = client.Connection.Request(http.MethodGet, "/api/test", nil, nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code, "the token refreshed on 401 is used by the next request")
+}
+
+func TestInterceptorsReplaceAndAbort(t *testing.T) {
+	var served int
+	client, err := createTestServerAndClient(func(w http.ResponseWriter, r *http.Request) {
+		served++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	require.NoError(t, err)
+
+	errDenied := errors.New("denied")
+	WithInterceptors(InterceptorFuncs{
+		Request: func(req *http.Request) error {
+			if req.URL.Path == "/api/denied" {
+				return errDenied
+			}
+			return nil
+		},
+		Response: func(req *http.Request, resp *http.Response, err error) (*http.Response, error) {
+			resp.Body.Close()
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Header:     http.Header{},
+				Body:       io.NopCloser(strings.NewReader(`{"replaced":true}`)),
+				Request:    req,
+			}, nil
+		},
+	})(client)
+
+	code, body, err := client.Connection.Request(http.MethodGet, "/api/test", nil, nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+	assert.JSONEq(t, `{"replaced":true}`, string(body))
+
+	_, _, err = client.Connection.Request(http.MethodGet, "/api/denied", nil, nil, nil)
+	assert.ErrorIs(t, err, errDenied)
+	assert.Equal(t, 1, served)
+}
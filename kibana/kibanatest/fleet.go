@@ -0,0 +1,689 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Package kibanatest provides a fake Kibana Fleet API to test code using the
+// kibana client without a running Kibana.
+package kibanatest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/elastic/elastic-agent-libs/kibana"
+)
+
+// Version is the Kibana version reported by the fake.
+const Version = "8.15.0"
+
+const (
+	statusAPI                 = "/api/status"
+	fleetAgentPoliciesAPI     = "/api/fleet/agent_policies"
+	fleetAgentPoliciesDelete  = "/api/fleet/agent_policies/delete"
+	fleetAgentsAPI            = "/api/fleet/agents"
+	fleetEnrollmentAPIKeysAPI = "/api/fleet/enrollment_api_keys" //nolint:gosec // no API key being leaked here
+)
+
+// FakeFleet is an HTTP server implementing a stateful subset of the Kibana
+// Fleet API: agent policies can be created, read, updated, listed and deleted,
+// agents can be read, listed, deleted and have their tags replaced, and
+// enrollment API keys can be created, read, listed and revoked. Objects are
+// kept in memory, the test can seed and inspect them.
+//
+// List requests support pagination and KQL queries made of a single
+// `field:value` or `field:("a" or "b")` clause, other queries are rejected.
+//
+// Any endpoint, implemented or not, can be overridden with Handle or
+// HandleJSON. All received requests are recorded and returned by Requests.
+type FakeFleet struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	nextID   int
+	policies []kibana.PolicyResponse
+	agents   []kibana.AgentExisting
+	keys     []kibana.CreateEnrollmentAPIKeyResponse
+	handlers map[route]http.HandlerFunc
+	requests []RecordedRequest
+}
+
+// RecordedRequest is a request received by the fake.
+type RecordedRequest struct {
+	Method string
+	Path   string
+	Query  url.Values
+	Header http.Header
+	Body   []byte
+}
+
+type route struct {
+	method string
+	path   string
+}
+
+// NewFakeFleet starts a FakeFleet. It must be closed with Close.
+func NewFakeFleet() *FakeFleet {
+	f := &FakeFleet{handlers: map[route]http.HandlerFunc{}}
+	f.Server = httptest.NewServer(http.HandlerFunc(f.serveHTTP))
+	return f
+}
+
+// ClientConfig returns the configuration of a client connecting to the fake.
+func (f *FakeFleet) ClientConfig() *kibana.ClientConfig {
+	cfg := kibana.DefaultClientConfig()
+	cfg.Host = f.Listener.Addr().String()
+	return &cfg
+}
+
+// Client returns a client connected to the fake.
+func (f *FakeFleet) Client(opts ...kibana.ClientOption) (*kibana.Client, error) {
+	return kibana.NewClientWithConfig(f.ClientConfig(), "kibanatest", Version, "", "", opts...)
+}
+
+// AddPolicy stores a policy and returns it. An ID is generated if the policy
+// has none.
+func (f *FakeFleet) AddPolicy(policy kibana.AgentPolicy) kibana.PolicyResponse {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.addPolicy(policy)
+}
+
+// AddAgent stores an agent. An ID is generated if the agent has none.
+func (f *FakeFleet) AddAgent(agent kibana.AgentExisting) kibana.AgentExisting {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if agent.ID == "" {
+		agent.ID = f.newID("agent")
+	}
+	if agent.Agent.ID == "" {
+		agent.Agent.ID = agent.ID
+	}
+	f.agents = append(f.agents, agent)
+	return agent
+}
+
+// AddEnrollmentAPIKey stores an enrollment API key. The ID and key are
+// generated if not set.
+func (f *FakeFleet) AddEnrollmentAPIKey(key kibana.CreateEnrollmentAPIKeyResponse) kibana.CreateEnrollmentAPIKeyResponse {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.addEnrollmentAPIKey(key)
+}
+
+// Policies returns the stored policies in creation order.
+func (f *FakeFleet) Policies() []kibana.PolicyResponse {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]kibana.PolicyResponse(nil), f.policies...)
+}
+
+// Agents returns the stored agents in creation order.
+func (f *FakeFleet) Agents() []kibana.AgentExisting {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]kibana.AgentExisting(nil), f.agents...)
+}
+
+// EnrollmentAPIKeys returns the stored enrollment API keys in creation order.
+func (f *FakeFleet) EnrollmentAPIKeys() []kibana.CreateEnrollmentAPIKeyResponse {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]kibana.CreateEnrollmentAPIKeyResponse(nil), f.keys...)
+}
+
+// Handle serves requests to path with handler instead of the fake
+// implementation. An empty method matches all methods; a handler registered
+// for the exact method takes precedence. A nil handler removes the override.
+// Handlers are called without holding the lock of the fake, they may call its
+// methods.
+func (f *FakeFleet) Handle(method, path string, handler http.HandlerFunc) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	key := route{method: method, path: strings.TrimSuffix(path, "/")}
+	if handler == nil {
+		delete(f.handlers, key)
+		return
+	}
+	f.handlers[key] = handler
+}
+
+// HandleJSON answers requests to path with status and v encoded as JSON.
+func (f *FakeFleet) HandleJSON(method, path string, status int, v interface{}) {
+	f.Handle(method, path, func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, status, v)
+	})
+}
+
+// Requests returns the requests received by the fake in the order they were
+// received, including the version request made when a client is created.
+func (f *FakeFleet) Requests() []RecordedRequest {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]RecordedRequest(nil), f.requests...)
+}
+
+// RequestsTo returns the recorded requests with the given method and path. An
+// empty method matches all methods.
+func (f *FakeFleet) RequestsTo(method, path string) []RecordedRequest {
+	var matched []RecordedRequest
+	for _, r := range f.Requests() {
+		if (method == "" || r.Method == method) && r.Path == strings.TrimSuffix(path, "/") {
+			matched = append(matched, r)
+		}
+	}
+	return matched
+}
+
+// ResetRequests forgets the recorded requests.
+func (f *FakeFleet) ResetRequests() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.requests = nil
+}
+
+func (f *FakeFleet) newID(prefix string) string {
+	f.nextID++
+	return fmt.Sprintf("%s-%d", prefix, f.nextID)
+}
+
+func (f *FakeFleet) addPolicy(policy kibana.AgentPolicy) kibana.PolicyResponse {
+	if policy.ID == "" {
+		policy.ID = f.newID("policy")
+	}
+	stored := kibana.PolicyResponse{
+		AgentPolicy:     policy,
+		UpdatedOn:       time.Now().UTC(),
+		UpdatedBy:       "kibanatest",
+		Revision:        1,
+		IsProtected:     policy.IsProtected,
+		PackagePolicies: []map[string]interface{}{},
+	}
+	f.policies = append(f.policies, stored)
+	return stored
+}
+
+func (f *FakeFleet) addEnrollmentAPIKey(key kibana.CreateEnrollmentAPIKeyResponse) kibana.CreateEnrollmentAPIKeyResponse {
+	if key.ID == "" {
+		key.ID = f.newID("enrollment-key")
+	}
+	if key.APIKeyID == "" {
+		key.APIKeyID = key.ID + "-api-key"
+	}
+	if key.APIKey == "" {
+		key.APIKey = "secret-" + key.ID
+	}
+	f.keys = append(f.keys, key)
+	return key
+}
+
+func (f *FakeFleet) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("reading request body: %v", err))
+		return
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	path := strings.TrimSuffix(r.URL.Path, "/")
+
+	f.mu.Lock()
+	f.requests = append(f.requests, RecordedRequest{
+		Method: r.Method,
+		Path:   path,
+		Query:  r.URL.Query(),
+		Header: r.Header.Clone(),
+		Body:   body,
+	})
+	handler, ok := f.handlers[route{method: r.Method, path: path}]
+	if !ok {
+		handler, ok = f.handlers[route{path: path}]
+	}
+	if ok {
+		f.mu.Unlock()
+		handler(w, r)
+		return
+	}
+	defer f.mu.Unlock()
+
+	switch {
+	case path == statusAPI && r.Method == http.MethodGet:
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"version": map[string]interface{}{"number": Version},
+		})
+	case path == fleetAgentPoliciesAPI && r.Method == http.MethodPost:
+		f.createPolicy(w, r)
+	case path == fleetAgentPoliciesAPI && r.Method == http.MethodGet:
+		f.listPolicies(w, r)
+	case path == fleetAgentPoliciesDelete && r.Method == http.MethodPost:
+		f.deletePolicy(w, r)
+	case strings.HasPrefix(path, fleetAgentPoliciesAPI+"/"):
+		f.policy(w, r, strings.TrimPrefix(path, fleetAgentPoliciesAPI+"/"))
+	case path == fleetAgentsAPI && r.Method == http.MethodGet:
+		f.listAgents(w, r)
+	case strings.HasPrefix(path, fleetAgentsAPI+"/"):
+		f.agent(w, r, strings.TrimPrefix(path, fleetAgentsAPI+"/"))
+	case path == fleetEnrollmentAPIKeysAPI && r.Method == http.MethodPost:
+		f.createEnrollmentAPIKey(w, r)
+	case path == fleetEnrollmentAPIKeysAPI && r.Method == http.MethodGet:
+		f.listEnrollmentAPIKeys(w, r)
+	case strings.HasPrefix(path, fleetEnrollmentAPIKeysAPI+"/"):
+		f.enrollmentAPIKey(w, r, strings.TrimPrefix(path, fleetEnrollmentAPIKeysAPI+"/"))
+	default:
+		writeError(w, http.StatusNotFound, fmt.Sprintf("%s %s is not implemented by the fake Fleet API", r.Method, r.URL.Path))
+	}
+}
+
+//
+// Agent Policies
+//
+
+func (f *FakeFleet) createPolicy(w http.ResponseWriter, r *http.Request) {
+	var policy kibana.AgentPolicy
+	if !readJSON(w, r, &policy) {
+		return
+	}
+	if policy.Name == "" || policy.Namespace == "" {
+		writeError(w, http.StatusBadRequest, "name and namespace are required")
+		return
+	}
+	for _, p := range f.policies {
+		if p.Name == policy.Name {
+			writeError(w, http.StatusConflict, fmt.Sprintf("Agent Policy '%s' already exists with name '%s'", p.ID, p.Name))
+			return
+		}
+		if policy.ID != "" && p.ID == policy.ID {
+			writeError(w, http.StatusConflict, fmt.Sprintf("Agent Policy '%s' already exists", p.ID))
+			return
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"item": f.addPolicy(policy)})
+}
+
+func (f *FakeFleet) listPolicies(w http.ResponseWriter, r *http.Request) {
+	match, err := parseKuery(r.URL.Query().Get("kuery"), "ingest-agent-policies.")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	items := []kibana.PolicyResponse{}
+	for _, p := range f.policies {
+		if match(policyFields(p)) {
+			items = append(items, p)
+		}
+	}
+	page, perPage, err := pagination(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	total := len(items)
+	start, end := pageBounds(total, page, perPage)
+	writeJSON(w, http.StatusOK, kibana.ListPoliciesResponse{
+		Items:   items[start:end],
+		Total:   total,
+		Page:    page,
+		PerPage: perPage,
+	})
+}
+
+func (f *FakeFleet) policy(w http.ResponseWriter, r *http.Request, id string) {
+	i := f.policyIndex(id)
+	if i < 0 {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("Agent policy %s not found", id))
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, map[string]interface{}{"item": f.policies[i]})
+	case http.MethodPut:
+		var update kibana.AgentPolicyUpdateRequest
+		if !readJSON(w, r, &update) {
+			return
+		}
+		p := &f.policies[i]
+		p.Name = update.Name
+		p.Namespace = update.Namespace
+		p.Description = update.Description
+		p.MonitoringEnabled = update.MonitoringEnabled
+		p.DataOutputID = update.DataOutputID
+		p.MonitoringOutputID = update.MonitoringOutputID
+		p.FleetServerHostID = update.FleetServerHostID
+		p.DownloadSourceID = update.DownloadSourceID
+		p.UnenrollTimeout = update.UnenrollTimeout
+		p.InactivityTImeout = update.InactivityTImeout
+		p.AgentFeatures = update.AgentFeatures
+		if update.IsProtected != nil {
+			p.AgentPolicy.IsProtected = *update.IsProtected
+			p.IsProtected = *update.IsProtected
+		}
+		p.Revision++
+		p.UpdatedOn = time.Now().UTC()
+		writeJSON(w, http.StatusOK, map[string]interface{}{"item": *p})
+	default:
+		writeError(w, http.StatusMethodNotAllowed, fmt.Sprintf("%s is not allowed on agent policies", r.Method))
+	}
+}
+
+func (f *FakeFleet) deletePolicy(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		AgentPolicyID string `json:"agentPolicyId"`
+	}
+	if !readJSON(w, r, &req) {
+		return
+	}
+	i := f.policyIndex(req.AgentPolicyID)
+	if i < 0 {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("Agent policy %s not found", req.AgentPolicyID))
+		return
+	}
+	for _, a := range f.agents {
+		if a.PolicyID == req.AgentPolicyID && a.Active {
+			writeError(w, http.StatusBadRequest, "Cannot delete an agent policy that is assigned to any active or pending agents")
+			return
+		}
+	}
+	f.policies = append(f.policies[:i], f.policies[i+1:]...)
+	writeJSON(w, http.StatusOK, map[string]interface{}{"id": req.AgentPolicyID})
+}
+
+func (f *FakeFleet) policyIndex(id string) int {
+	for i, p := range f.policies {
+		if p.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+func policyFields(p kibana.PolicyResponse) map[string]string {
+	return map[string]string{
+		"id":          p.ID,
+		"name":        p.Name,
+		"namespace":   p.Namespace,
+		"description": p.Description,
+	}
+}
+
+//
+// Agents
+//
+
+func (f *FakeFleet) listAgents(w http.ResponseWriter, r *http.Request) {
+	match, err := parseKuery(r.URL.Query().Get("kuery"), "fleet-agents.")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	items := []kibana.AgentExisting{}
+	for _, a := range f.agents {
+		if match(agentFields(a)) {
+			items = append(items, a)
+		}
+	}
+	page, perPage, err := pagination(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	total := len(items)
+	start, end := pageBounds(total, page, perPage)
+	writeJSON(w, http.StatusOK, kibana.ListAgentsResponse{
+		Items:   items[start:end],
+		Total:   total,
+		Page:    page,
+		PerPage: perPage,
+	})
+}
+
+func (f *FakeFleet) agent(w http.ResponseWriter, r *http.Request, id string) {
+	i := -1
+	for j, a := range f.agents {
+		if a.ID == id {
+			i = j
+			break
+		}
+	}
+	if i < 0 {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("Agent %s not found", id))
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, map[string]interface{}{"item": f.agents[i]})
+	case http.MethodPut:
+		var update struct {
+			Tags []string `json:"tags"`
+		}
+		if !readJSON(w, r, &update) {
+			return
+		}
+		f.agents[i].Tags = update.Tags
+		writeJSON(w, http.StatusOK, map[string]interface{}{"item": f.agents[i]})
+	case http.MethodDelete:
+		f.agents = append(f.agents[:i], f.agents[i+1:]...)
+		writeJSON(w, http.StatusOK, map[string]interface{}{"action": "deleted"})
+	default:
+		writeError(w, http.StatusMethodNotAllowed, fmt.Sprintf("%s is not allowed on agents", r.Method))
+	}
+}
+
+func agentFields(a kibana.AgentExisting) map[string]string {
+	return map[string]string{
+		"id":                           a.ID,
+		"agent.id":                     a.Agent.ID,
+		"agent.version":                a.Agent.Version,
+		"active":                       strconv.FormatBool(a.Active),
+		"status":                       a.Status,
+		"policy_id":                    a.PolicyID,
+		"local_metadata.host.hostname": a.LocalMetadata.Host.Hostname,
+	}
+}
+
+//
+// Enrollment API Keys
+//
+
+func (f *FakeFleet) createEnrollmentAPIKey(w http.ResponseWriter, r *http.Request) {
+	var req kibana.CreateEnrollmentAPIKeyRequest
+	if !readJSON(w, r, &req) {
+		return
+	}
+	if f.policyIndex(req.PolicyID) < 0 {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("Agent policy %s not found", req.PolicyID))
+		return
+	}
+	key := f.addEnrollmentAPIKey(kibana.CreateEnrollmentAPIKeyResponse{
+		Active:   true,
+		Name:     req.Name,
+		PolicyID: req.PolicyID,
+	})
+	writeJSON(w, http.StatusOK, map[string]interface{}{"item": key})
+}
+
+func (f *FakeFleet) listEnrollmentAPIKeys(w http.ResponseWriter, r *http.Request) {
+	match, err := parseKuery(r.URL.Query().Get("kuery"), "fleet-enrollment-api-keys.")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	items := []kibana.CreateEnrollmentAPIKeyResponse{}
+	for _, k := range f.keys {
+		if match(enrollmentAPIKeyFields(k)) {
+			items = append(items, k)
+		}
+	}
+	page, perPage, err := pagination(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	total := len(items)
+	start, end := pageBounds(total, page, perPage)
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"items":   items[start:end],
+		"total":   total,
+		"page":    page,
+		"perPage": perPage,
+	})
+}
+
+func (f *FakeFleet) enrollmentAPIKey(w http.ResponseWriter, r *http.Request, id string) {
+	i := -1
+	for j, k := range f.keys {
+		if k.ID == id {
+			i = j
+			break
+		}
+	}
+	if i < 0 {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("Enrollment API key %s not found", id))
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, map[string]interface{}{"item": f.keys[i]})
+	case http.MethodDelete:
+		// Like Fleet, revoked keys are kept as inactive.
+		f.keys[i].Active = false
+		writeJSON(w, http.StatusOK, map[string]interface{}{"action": "deleted"})
+	default:
+		writeError(w, http.StatusMethodNotAllowed, fmt.Sprintf("%s is not allowed on enrollment API keys", r.Method))
+	}
+}
+
+func enrollmentAPIKeyFields(k kibana.CreateEnrollmentAPIKeyResponse) map[string]string {
+	return map[string]string{
+		"id":        k.ID,
+		"name":      k.Name,
+		"policy_id": k.PolicyID,
+		"active":    strconv.FormatBool(k.Active),
+	}
+}
+
+//
+// Helpers
+//
+
+var kueryClause = regexp.MustCompile(`^\s*([\w.-]+)\s*:\s*(.+?)\s*$`)
+
+// parseKuery parses a KQL query made of a single clause, `field:value`,
+// `field:"value"` or `field:("a" or "b")`. The prefix is removed from field
+// names. An empty query matches everything.
+func parseKuery(kuery, prefix string) (func(fields map[string]string) bool, error) {
+	if strings.TrimSpace(kuery) == "" {
+		return func(map[string]string) bool { return true }, nil
+	}
+
+	m := kueryClause.FindStringSubmatch(kuery)
+	if m == nil {
+		return nil, fmt.Errorf("unsupported kuery: %s", kuery)
+	}
+	field, expr := strings.TrimPrefix(m[1], prefix), m[2]
+
+	var values []string
+	if strings.HasPrefix(expr, "(") && strings.HasSuffix(expr, ")") {
+		for _, v := range strings.Split(expr[1:len(expr)-1], " or ") {
+			values = append(values, unquote(strings.TrimSpace(v)))
+		}
+	} else {
+		values = []string{unquote(expr)}
+	}
+
+	return func(fields map[string]string) bool {
+		value, ok := fields[field]
+		if !ok {
+			return false
+		}
+		for _, v := range values {
+			if v == value {
+				return true
+			}
+		}
+		return false
+	}, nil
+}
+
+func unquote(s string) string {
+	if u, err := strconv.Unquote(s); err == nil {
+		return u
+	}
+	return s
+}
+
+// pagination returns the page and perPage parameters of r, which default to
+// 1 and 20 like in Kibana.
+func pagination(r *http.Request) (page, perPage int, err error) {
+	page, perPage = 1, 20
+	q := r.URL.Query()
+	if v := q.Get("page"); v != "" {
+		if page, err = strconv.Atoi(v); err != nil || page < 1 {
+			return 0, 0, fmt.Errorf("invalid page: %s", v)
+		}
+	}
+	if v := q.Get("perPage"); v != "" {
+		if perPage, err = strconv.Atoi(v); err != nil || perPage < 1 {
+			return 0, 0, fmt.Errorf("invalid perPage: %s", v)
+		}
+	}
+	return page, perPage, nil
+}
+
+func pageBounds(total, page, perPage int) (start, end int) {
+	start = (page - 1) * perPage
+	if start > total {
+		start = total
+	}
+	end = start + perPage
+	if end > total {
+		end = total
+	}
+	return start, end
+}
+
+func readJSON(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid request body: %v", err))
+		return false
+	}
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// writeError writes an error in the format used by Kibana.
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]interface{}{
+		"statusCode": status,
+		"error":      http.StatusText(status),
+		"message":    message,
+	})
+}
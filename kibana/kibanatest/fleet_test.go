@@ -0,0 +1,208 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package kibanatest
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/elastic-agent-libs/kibana"
+)
+
+func TestFakeFleetPolicies(t *testing.T) {
+	ctx := context.Background()
+	fleet := NewFakeFleet()
+	defer fleet.Close()
+
+	client, err := fleet.Client()
+	require.NoError(t, err)
+
+	created, err := client.CreatePolicy(ctx, kibana.AgentPolicy{Name: "my policy", Namespace: "default"})
+	require.NoError(t, err)
+	assert.NotEmpty(t, created.ID)
+	assert.Equal(t, 1, created.Revision)
+
+	list, err := client.ListPolicies(ctx, kibana.ListPoliciesRequest{})
+	require.NoError(t, err)
+	require.Len(t, list.Items, 1)
+	assert.Equal(t, created.ID, list.Items[0].ID)
+	assert.Equal(t, "my policy", list.Items[0].Name)
+	assert.Equal(t, 1, list.Total)
+
+	_, err = client.CreatePolicy(ctx, kibana.AgentPolicy{Name: "my policy", Namespace: "default"})
+	assert.True(t, kibana.IsConflict(err), err)
+
+	updated, err := client.UpdatePolicy(ctx, created.ID, kibana.AgentPolicyUpdateRequest{
+		Name:             "my policy",
+		Namespace:        "prod",
+		ExpectedRevision: 1,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 2, updated.Revision)
+
+	_, err = client.UpdatePolicy(ctx, created.ID, kibana.AgentPolicyUpdateRequest{
+		Name:             "my policy",
+		Namespace:        "dev",
+		ExpectedRevision: 1,
+	})
+	assert.ErrorIs(t, err, kibana.ErrConcurrentModification)
+
+	policy, created2, err := client.EnsurePolicy(ctx, kibana.EnsurePolicyRequest{
+		AgentPolicy: kibana.AgentPolicy{Name: "my policy", Namespace: "prod"},
+	})
+	require.NoError(t, err)
+	assert.False(t, created2)
+	assert.Equal(t, created.ID, policy.ID)
+
+	require.NoError(t, client.DeletePolicy(ctx, created.ID))
+	list, err = client.ListPolicies(ctx, kibana.ListPoliciesRequest{})
+	require.NoError(t, err)
+	assert.Empty(t, list.Items)
+	assert.Empty(t, fleet.Policies())
+}
+
+func TestFakeFleetAgents(t *testing.T) {
+	ctx := context.Background()
+	fleet := NewFakeFleet()
+	defer fleet.Close()
+
+	policy := fleet.AddPolicy(kibana.AgentPolicy{Name: "seeded", Namespace: "default"})
+	online := kibana.AgentExisting{ID: "agent-a"}
+	online.Status = "online"
+	online.PolicyID = policy.ID
+	fleet.AddAgent(online)
+	offline := kibana.AgentExisting{ID: "agent-b"}
+	offline.Status = "offline"
+	fleet.AddAgent(offline)
+
+	client, err := fleet.Client()
+	require.NoError(t, err)
+
+	list, err := client.ListAgents(ctx, kibana.ListAgentsRequest{Kuery: "status:online"})
+	require.NoError(t, err)
+	require.Len(t, list.Items, 1)
+	assert.Equal(t, "agent-a", list.Items[0].ID)
+
+	agents, err := client.GetAgentsByIDs(ctx, kibana.GetAgentsByIDsRequest{IDs: []string{"agent-b", "agent-c", "agent-a"}})
+	require.NoError(t, err)
+	require.Len(t, agents.Items, 2)
+	assert.Equal(t, "agent-b", agents.Items[0].ID)
+	assert.Equal(t, "agent-a", agents.Items[1].ID)
+	assert.Equal(t, []string{"agent-c"}, agents.Missing)
+
+	tagged, err := client.UpdateAgentTags(ctx, kibana.UpdateAgentTagsRequest{ID: "agent-a", Tags: []string{"ci", "run-42"}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"ci", "run-42"}, tagged.Tags)
+	assert.Equal(t, []string{"ci", "run-42"}, fleet.Agents()[0].Tags)
+
+	key, err := client.CreateEnrollmentAPIKey(ctx, kibana.CreateEnrollmentAPIKeyRequest{Name: "key", PolicyID: policy.ID})
+	require.NoError(t, err)
+	assert.NotEmpty(t, key.APIKey)
+	assert.Equal(t, []kibana.CreateEnrollmentAPIKeyResponse{key}, fleet.EnrollmentAPIKeys())
+
+	require.NoError(t, client.DeleteAgent(ctx, kibana.DeleteAgentRequest{ID: "agent-b"}))
+	_, err = client.GetAgent(ctx, kibana.GetAgentRequest{ID: "agent-b"})
+	assert.True(t, kibana.IsNotFound(err), err)
+	assert.Len(t, fleet.Agents(), 1)
+}
+
+func TestFakeFleetEnrollmentAPIKeys(t *testing.T) {
+	ctx := context.Background()
+	fleet := NewFakeFleet()
+	defer fleet.Close()
+
+	policy := fleet.AddPolicy(kibana.AgentPolicy{Name: "seeded", Namespace: "default"})
+	other := fleet.AddPolicy(kibana.AgentPolicy{Name: "other", Namespace: "default"})
+	fleet.AddEnrollmentAPIKey(kibana.CreateEnrollmentAPIKeyResponse{Name: "other", PolicyID: other.ID, Active: true})
+
+	client, err := fleet.Client()
+	require.NoError(t, err)
+
+	key, err := client.CreateEnrollmentAPIKey(ctx, kibana.CreateEnrollmentAPIKeyRequest{Name: "key", PolicyID: policy.ID})
+	require.NoError(t, err)
+
+	list, err := client.ListEnrollmentAPIKeys(ctx, kibana.ListEnrollmentAPIKeysRequest{Kuery: `policy_id:"` + policy.ID + `"`})
+	require.NoError(t, err)
+	assert.Equal(t, 1, list.Total)
+	require.Len(t, list.Items, 1)
+	assert.Equal(t, key.ID, list.Items[0].ID)
+	assert.Equal(t, key.APIKey, list.Items[0].APIKey)
+
+	all, err := client.ListEnrollmentAPIKeys(ctx, kibana.ListEnrollmentAPIKeysRequest{PerPage: 1, Page: 2})
+	require.NoError(t, err)
+	assert.Equal(t, 2, all.Total)
+	require.Len(t, all.Items, 1)
+	assert.Equal(t, key.ID, all.Items[0].ID)
+
+	require.NoError(t, client.DeleteEnrollmentAPIKey(ctx, key.ID))
+	revoked, err := client.GetEnrollmentAPIKey(ctx, key.ID)
+	require.NoError(t, err)
+	assert.False(t, revoked.Active)
+
+	_, err = client.GetEnrollmentAPIKey(ctx, "unknown")
+	assert.True(t, kibana.IsNotFound(err), err)
+}
+
+func TestFakeFleetOverridesAndRecording(t *testing.T) {
+	ctx := context.Background()
+	fleet := NewFakeFleet()
+	defer fleet.Close()
+
+	client, err := fleet.Client()
+	require.NoError(t, err)
+	fleet.ResetRequests()
+
+	fleet.HandleJSON(http.MethodGet, "/api/fleet/agent_policies/policy-1", http.StatusOK, map[string]interface{}{
+		"item": kibana.PolicyResponse{AgentPolicy: kibana.AgentPolicy{ID: "policy-1", Name: "canned"}},
+	})
+	fleet.Handle("", "/api/fleet/outputs", func(w http.ResponseWriter, r *http.Request) {
+		assert.NotEmpty(t, fleet.Agents(), "handlers may call the fake")
+		http.Error(w, `{"statusCode":503,"error":"Service Unavailable","message":"outputs are down"}`, http.StatusServiceUnavailable)
+	})
+	fleet.AddAgent(kibana.AgentExisting{})
+
+	policy, err := client.GetPolicy(ctx, "policy-1")
+	require.NoError(t, err)
+	assert.Equal(t, "canned", policy.Name)
+
+	_, err = client.ListOutputs(ctx)
+	assert.True(t, kibana.IsServerError(err), err)
+
+	created, err := client.CreatePolicy(ctx, kibana.AgentPolicy{Name: "real", Namespace: "default"})
+	require.NoError(t, err)
+
+	requests := fleet.Requests()
+	require.Len(t, requests, 3)
+	assert.Equal(t, http.MethodGet, requests[0].Method)
+	assert.Equal(t, "/api/fleet/agent_policies/policy-1", requests[0].Path)
+
+	posts := fleet.RequestsTo(http.MethodPost, "/api/fleet/agent_policies")
+	require.Len(t, posts, 1)
+	assert.Equal(t, "application/json", posts[0].Header.Get("Content-Type"))
+	assert.Contains(t, string(posts[0].Body), `"name":"real"`)
+
+	fleet.Handle(http.MethodGet, "/api/fleet/agent_policies/policy-1", nil)
+	_, err = client.GetPolicy(ctx, "policy-1")
+	assert.True(t, kibana.IsNotFound(err), "the fake serves the path again once the override is removed")
+	_, err = client.GetPolicy(ctx, created.ID)
+	assert.NoError(t, err)
+}
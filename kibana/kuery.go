@@ -0,0 +1,127 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package kibana
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Kuery builds KQL queries on the fields of agents, for ListAgents,
+// ForEachAgent and BulkAgents.Kuery. Values are quoted and escaped, so they
+// are matched literally, whatever characters they contain:
+//
+//	kuery := kibana.NewKuery().PolicyID(policyID).Status("online").Tag("ci").String()
+//
+// Clauses are joined with "and". A clause with several values matches any of
+// them.
+type Kuery struct {
+	clauses []string
+}
+
+// NewKuery returns an empty Kuery, it matches all agents.
+func NewKuery() *Kuery {
+	return &Kuery{}
+}
+
+// Field matches agents whose field has one of the values. No clause is added
+// if values is empty.
+func (k *Kuery) Field(field string, values ...string) *Kuery {
+	if len(values) == 0 {
+		return k
+	}
+	if len(values) == 1 {
+		k.clauses = append(k.clauses, field+":"+QuoteKQL(values[0]))
+		return k
+	}
+	quoted := make([]string, len(values))
+	for i, v := range values {
+		quoted[i] = QuoteKQL(v)
+	}
+	k.clauses = append(k.clauses, field+":("+strings.Join(quoted, " or ")+")")
+	return k
+}
+
+// NotField matches agents whose field has none of the values. No clause is
+// added if values is empty.
+func (k *Kuery) NotField(field string, values ...string) *Kuery {
+	if len(values) == 0 {
+		return k
+	}
+	clause := NewKuery().Field(field, values...).String()
+	k.clauses = append(k.clauses, "not "+clause)
+	return k
+}
+
+// AgentID matches agents by ID.
+func (k *Kuery) AgentID(ids ...string) *Kuery {
+	return k.Field("agent.id", ids...)
+}
+
+// PolicyID matches agents enrolled in one of the policies.
+func (k *Kuery) PolicyID(ids ...string) *Kuery {
+	return k.Field("policy_id", ids...)
+}
+
+// Status matches agents in one of the statuses, e.g. online or offline.
+func (k *Kuery) Status(statuses ...string) *Kuery {
+	return k.Field("status", statuses...)
+}
+
+// Tag matches agents having one of the tags.
+func (k *Kuery) Tag(tags ...string) *Kuery {
+	return k.Field("tags", tags...)
+}
+
+// Hostname matches agents running on one of the hosts.
+func (k *Kuery) Hostname(hostnames ...string) *Kuery {
+	return k.Field("local_metadata.host.hostname", hostnames...)
+}
+
+// Version matches agents running one of the versions.
+func (k *Kuery) Version(versions ...string) *Kuery {
+	return k.Field("agent.version", versions...)
+}
+
+// Active matches enrolled agents if active is true, unenrolled agents
+// otherwise.
+func (k *Kuery) Active(active bool) *Kuery {
+	k.clauses = append(k.clauses, "active:"+strconv.FormatBool(active))
+	return k
+}
+
+// String returns the KQL query, empty if no clause was added.
+func (k *Kuery) String() string {
+	return strings.Join(k.clauses, " and ")
+}
+
+// QuoteKQL returns s as a quoted KQL string, matched literally. Backslashes
+// and double quotes are escaped, wildcards and operators lose their meaning.
+func QuoteKQL(s string) string {
+	var b strings.Builder
+	b.Grow(len(s) + 2)
+	b.WriteByte('"')
+	for _, r := range s {
+		if r == '"' || r == '\\' {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	b.WriteByte('"')
+	return b.String()
+}
@@ -0,0 +1,87 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package kibana
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKuery(t *testing.T) {
+	tests := map[string]struct {
+		kuery    *Kuery
+		expected string
+	}{
+		"empty": {
+			kuery:    NewKuery(),
+			expected: "",
+		},
+		"single values": {
+			kuery:    NewKuery().PolicyID("policy-1").Status("online").Tag("ci"),
+			expected: `policy_id:"policy-1" and status:"online" and tags:"ci"`,
+		},
+		"multiple values": {
+			kuery:    NewKuery().Status("online", "degraded").Version("8.15.0"),
+			expected: `status:("online" or "degraded") and agent.version:"8.15.0"`,
+		},
+		"empty values are skipped": {
+			kuery:    NewKuery().Tag().AgentID("agent-1"),
+			expected: `agent.id:"agent-1"`,
+		},
+		"special characters": {
+			kuery:    NewKuery().Hostname(`host "a" or *`, `C:\Users\build`),
+			expected: `local_metadata.host.hostname:("host \"a\" or *" or "C:\\Users\\build")`,
+		},
+		"negation and active": {
+			kuery:    NewKuery().Active(true).NotField("tags", "ephemeral", "test"),
+			expected: `active:true and not tags:("ephemeral" or "test")`,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, tc.kuery.String())
+		})
+	}
+}
+
+func TestQuoteKQL(t *testing.T) {
+	assert.Equal(t, `""`, QuoteKQL(""))
+	assert.Equal(t, `"a:b (c) and d*"`, QuoteKQL("a:b (c) and d*"))
+	assert.Equal(t, `"\\\""`, QuoteKQL(`\"`))
+	assert.Equal(t, `"héllo wörld"`, QuoteKQL("héllo wörld"))
+}
+
+func TestKueryListAgents(t *testing.T) {
+	var kuery string
+	client, err := createTestServerAndClient(func(w http.ResponseWriter, r *http.Request) {
+		kuery = r.URL.Query().Get("kuery")
+		_, _ = w.Write([]byte(`{"items":[],"total":0,"page":1,"perPage":20}`))
+	})
+	require.NoError(t, err)
+
+	_, err = client.ListAgents(context.Background(), ListAgentsRequest{
+		Kuery: NewKuery().Hostname(`web-01 "prod"`).Status("online").String(),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, `local_metadata.host.hostname:"web-01 \"prod\"" and status:"online"`, kuery)
+}
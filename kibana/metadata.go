@@ -0,0 +1,73 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package kibana
+
+import (
+	"context"
+	"net/http"
+	"sync"
+)
+
+// requestIDHeader is the response header holding the id Kibana assigned to
+// the request.
+const requestIDHeader = "X-Request-Id"
+
+// ResponseMetadata holds details of a Kibana response that are useful to
+// correlate a call with the Kibana logs, e.g. when opening a support case.
+type ResponseMetadata struct {
+	StatusCode int
+	// RequestID is the value of the X-Request-Id response header.
+	RequestID string
+	// Header holds all response headers, including the x-found-handling-*
+	// headers set by Elastic Cloud.
+	Header http.Header
+}
+
+type responseMetadataKey struct{}
+
+// WithResponseMetadata returns a context that records the metadata of the
+// responses Kibana sends for requests made with it into meta. If a method
+// sends multiple requests, meta describes the last response received. meta is
+// left unchanged if no response was received.
+//
+// The context can be used by concurrent requests, like the ones sent by
+// CreateEnrollmentAPIKeysForPolicies. meta must only be read once all calls
+// made with the context returned.
+func WithResponseMetadata(ctx context.Context, meta *ResponseMetadata) context.Context {
+	return context.WithValue(ctx, responseMetadataKey{}, &metadataRecorder{meta: meta})
+}
+
+// metadataRecorder serializes the writes of concurrent responses to meta.
+type metadataRecorder struct {
+	mu   sync.Mutex
+	meta *ResponseMetadata
+}
+
+func recordResponseMetadata(ctx context.Context, resp *http.Response) {
+	recorder, ok := ctx.Value(responseMetadataKey{}).(*metadataRecorder)
+	if !ok || recorder.meta == nil {
+		return
+	}
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+	*recorder.meta = ResponseMetadata{
+		StatusCode: resp.StatusCode,
+		RequestID:  resp.Header.Get(requestIDHeader),
+		Header:     resp.Header.Clone(),
+	}
+}
@@ -0,0 +1,100 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package kibana
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResponseMetadata(t *testing.T) {
+	client, err := createTestServerAndClient(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Request-Id", "req-"+r.URL.Path)
+		w.Header().Set("X-Found-Handling-Cluster", "cluster-1")
+		if r.URL.Path == "/api/fleet/agent_policies/missing" {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"statusCode":404,"error":"Not Found","message":"not found"}`))
+			return
+		}
+		_, _ = w.Write(fleetGetPolicyResponse)
+	})
+	require.NoError(t, err)
+
+	t.Run("success", func(t *testing.T) {
+		var meta ResponseMetadata
+		_, err := client.GetPolicy(WithResponseMetadata(context.Background(), &meta), "policy")
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, meta.StatusCode)
+		assert.Equal(t, "req-/api/fleet/agent_policies/policy", meta.RequestID)
+		assert.Equal(t, "cluster-1", meta.Header.Get("X-Found-Handling-Cluster"))
+	})
+
+	t.Run("error", func(t *testing.T) {
+		var meta ResponseMetadata
+		_, err := client.GetPolicy(WithResponseMetadata(context.Background(), &meta), "missing")
+		require.Error(t, err)
+		assert.Equal(t, http.StatusNotFound, meta.StatusCode)
+		assert.Equal(t, "req-/api/fleet/agent_policies/missing", meta.RequestID)
+	})
+
+	t.Run("concurrent calls", func(t *testing.T) {
+		ids := []string{"a", "b", "c", "d"}
+		metas := make([]ResponseMetadata, len(ids))
+		var wg sync.WaitGroup
+		for i, id := range ids {
+			wg.Add(1)
+			go func(i int, id string) {
+				defer wg.Done()
+				_, err := client.GetPolicy(WithResponseMetadata(context.Background(), &metas[i]), id)
+				assert.NoError(t, err)
+			}(i, id)
+		}
+		wg.Wait()
+		for i, id := range ids {
+			assert.Equal(t, "req-/api/fleet/agent_policies/"+id, metas[i].RequestID)
+		}
+	})
+
+	t.Run("concurrent calls sharing a context", func(t *testing.T) {
+		var meta ResponseMetadata
+		ctx := WithResponseMetadata(context.Background(), &meta)
+		ids := []string{"a", "b", "c", "d"}
+		var wg sync.WaitGroup
+		for _, id := range ids {
+			wg.Add(1)
+			go func(id string) {
+				defer wg.Done()
+				_, err := client.GetPolicy(ctx, id)
+				assert.NoError(t, err)
+			}(id)
+		}
+		wg.Wait()
+		assert.Equal(t, http.StatusOK, meta.StatusCode)
+		assert.Contains(t, []string{
+			"req-/api/fleet/agent_policies/a",
+			"req-/api/fleet/agent_policies/b",
+			"req-/api/fleet/agent_policies/c",
+			"req-/api/fleet/agent_policies/d",
+		}, meta.RequestID)
+	})
+}
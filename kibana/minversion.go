@@ -0,0 +1,73 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package kibana
+
+import (
+	"fmt"
+
+	"github.com/elastic/elastic-agent-libs/version"
+)
+
+// Minimum Kibana versions of the Fleet APIs that are not available in all
+// supported stacks.
+var (
+	minVersionDownloadSources = version.MustNew("8.8.0")
+	minVersionUninstallTokens = version.MustNew("8.11.0")
+	minVersionOutputHealth    = version.MustNew("8.11.0")
+)
+
+// ErrServerTooOld is returned, without sending the request, by methods
+// calling an API that the connected Kibana does not provide yet. It is only
+// returned if the check is enabled with WithMinVersionCheck.
+type ErrServerTooOld struct {
+	// Op is the name of the client method.
+	Op       string
+	Required version.V
+	Actual   version.V
+}
+
+func (e ErrServerTooOld) Error() string {
+	return fmt.Sprintf("%s requires Kibana %s or newer, connected to %s", e.Op, e.Required.String(), e.Actual.String())
+}
+
+// WithMinVersionCheck makes methods fail with ErrServerTooOld, instead of
+// calling the API, if the Kibana version read from the status API is older
+// than the version required by the method. Snapshot and pre-release versions
+// satisfy the requirement of their release. The check is skipped if the
+// version is not known because IgnoreVersion is set.
+func WithMinVersionCheck() ClientOption {
+	return func(client *Client) {
+		client.checkMinVersion = true
+	}
+}
+
+// requireVersion returns ErrServerTooOld if the version check is enabled and
+// Kibana is older than required.
+func (client *Client) requireVersion(op string, required *version.V) error {
+	if !client.checkMinVersion {
+		return nil
+	}
+	actual := client.GetVersion()
+	if !actual.IsValid() {
+		return nil
+	}
+	if actual.LessThan(required) {
+		return ErrServerTooOld{Op: op, Required: *required, Actual: actual}
+	}
+	return nil
+}
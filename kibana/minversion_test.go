@@ -0,0 +1,86 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package kibana
+
+import (
+	"context"
+	_ "embed"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/elastic-agent-libs/config"
+)
+
+//go:embed testdata/status_8_6_2_response.json
+var status862Response []byte
+
+func TestMinVersionCheck(t *testing.T) {
+	var apiCalls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == statusAPI {
+			_, _ = w.Write(status862Response)
+			return
+		}
+		apiCalls.Add(1)
+		_, _ = w.Write([]byte(`{"item":{"id":"token-id","token":"secret"}}`))
+	}))
+	defer srv.Close()
+
+	newClient := func(opts ...ClientOption) *Client {
+		cfg := fmt.Sprintf("protocol: http\nhost: %s\n", srv.Listener.Addr().String())
+		client, err := NewKibanaClient(config.MustNewConfigFrom(cfg), binaryName, v, commit, buildTime, opts...)
+		require.NoError(t, err)
+		return client
+	}
+
+	t.Run("server too old", func(t *testing.T) {
+		client := newClient(WithMinVersionCheck())
+
+		_, err := client.GetUninstallToken(context.Background(), "token-id")
+		var tooOld ErrServerTooOld
+		require.True(t, errors.As(err, &tooOld), "expected ErrServerTooOld, got %v", err)
+		assert.Equal(t, "GetUninstallToken", tooOld.Op)
+		assert.Equal(t, "8.11.0", tooOld.Required.String())
+		assert.Equal(t, "8.6.2", tooOld.Actual.String())
+		assert.Zero(t, apiCalls.Load(), "the API must not be called")
+
+		_, err = client.CreateDownloadSource(context.Background(), DownloadSource{})
+		assert.True(t, errors.As(err, &tooOld))
+		_, err = client.ListDownloadSources(context.Background())
+		assert.True(t, errors.As(err, &tooOld))
+		_, err = client.GetOutputHealth(context.Background(), "output-id")
+		assert.True(t, errors.As(err, &tooOld))
+		assert.Zero(t, apiCalls.Load(), "the API must not be called")
+	})
+
+	t.Run("check disabled", func(t *testing.T) {
+		client := newClient()
+
+		token, err := client.GetUninstallToken(context.Background(), "token-id")
+		require.NoError(t, err)
+		assert.Equal(t, "secret", token.Token)
+		assert.EqualValues(t, 1, apiCalls.Load())
+	})
+}
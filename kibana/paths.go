@@ -0,0 +1,68 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package kibana
+
+import (
+	"github.com/elastic/elastic-agent-libs/version"
+)
+
+// fleetEndpoint is a Fleet API operation whose path depends on the Kibana
+// version. Methods calling such an operation resolve its path with fleetPath
+// instead of using a path constant.
+type fleetEndpoint int
+
+const (
+	endpointEnrollmentAPIKeys fleetEndpoint = iota
+	endpointAgentStatus
+)
+
+// versionedPath is the path of an endpoint from a Kibana version on.
+type versionedPath struct {
+	since *version.V
+	path  string
+}
+
+// fleetEndpointPaths lists the paths of the endpoints that moved between
+// Kibana versions, oldest first.
+var fleetEndpointPaths = map[fleetEndpoint][]versionedPath{
+	endpointEnrollmentAPIKeys: {
+		{since: version.MustNew("7.10.0"), path: "/api/fleet/enrollment-api-keys"},
+		{since: version.MustNew("8.0.0"), path: fleetEnrollmentAPIKeysAPI},
+	},
+	endpointAgentStatus: {
+		{since: version.MustNew("7.10.0"), path: "/api/fleet/agent-status"},
+		{since: version.MustNew("8.0.0"), path: fleetAgentStatusAPI},
+	},
+}
+
+// fleetPath returns the path of endpoint in the connected Kibana. The most
+// recent path is returned if the version is not known, e.g. because
+// IgnoreVersion is set, or older than the first listed version, which did
+// not provide the endpoint yet.
+func (client *Client) fleetPath(endpoint fleetEndpoint) string {
+	paths := fleetEndpointPaths[endpoint]
+	actual := client.GetVersion()
+	if actual.IsValid() {
+		for i := len(paths) - 1; i >= 0; i-- {
+			if !actual.LessThan(paths[i].since) {
+				return paths[i].path
+			}
+		}
+	}
+	return paths[len(paths)-1].path
+}
@@ -0,0 +1,82 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package kibana
+
+import (
+	"context"
+	_ "embed"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/version"
+)
+
+//go:embed testdata/status_7_17_9_response.json
+var status7179Response []byte
+
+func TestFleetPathByVersion(t *testing.T) {
+	tests := map[string]struct {
+		status       []byte
+		opts         []ClientOption
+		expectedPath string
+	}{
+		"7.17": {
+			status:       status7179Response,
+			expectedPath: "/api/fleet/enrollment-api-keys",
+		},
+		"8.6": {
+			status:       status862Response,
+			expectedPath: "/api/fleet/enrollment_api_keys",
+		},
+		"version not known": {
+			status:       status7179Response,
+			opts:         []ClientOption{WithServerVersion(version.V{})},
+			expectedPath: "/api/fleet/enrollment_api_keys",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			var paths []string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path == statusAPI {
+					_, _ = w.Write(test.status)
+					return
+				}
+				paths = append(paths, r.URL.Path)
+				_, _ = w.Write([]byte(`{"item":{"id":"key-1","api_key":"secret","policy_id":"policy-1"}}`))
+			}))
+			defer srv.Close()
+
+			cfg := fmt.Sprintf("protocol: http\nhost: %s\n", srv.Listener.Addr().String())
+			client, err := NewKibanaClient(config.MustNewConfigFrom(cfg), binaryName, v, commit, buildTime, test.opts...)
+			require.NoError(t, err)
+
+			key, err := client.CreateEnrollmentAPIKey(context.Background(), CreateEnrollmentAPIKeyRequest{PolicyID: "policy-1"})
+			require.NoError(t, err)
+			assert.Equal(t, "secret", key.APIKey)
+			assert.Equal(t, []string{test.expectedPath}, paths)
+		})
+	}
+}
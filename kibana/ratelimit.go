@@ -0,0 +1,114 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package kibana
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultRetryAfter is the wait before retrying a rate limited request when
+// Kibana does not send a valid Retry-After header.
+const DefaultRetryAfter = time.Second
+
+// RateLimitConfig configures the retries of requests rejected with 429 Too
+// Many Requests. Before every retry the client waits for the duration
+// requested by the Retry-After header of the response.
+type RateLimitConfig struct {
+	// MaxRetries is the number of times a rate limited request is sent
+	// again. Zero disables the handling of rate limits.
+	MaxRetries int `config:"max_retries" yaml:"max_retries,omitempty"`
+	// MaxWait bounds the total time waited for a request. The request is not
+	// retried if Retry-After would exceed it. Zero means no limit.
+	MaxWait time.Duration `config:"max_wait" yaml:"max_wait,omitempty"`
+}
+
+// WithRateLimit retries requests rejected with 429 Too Many Requests after
+// the delay requested by Kibana, overriding the rate_limit settings of the
+// client config. Request bodies are buffered in memory so they can be sent
+// again. Once the budget is exhausted the 429 response is returned to the
+// caller. If WithRetry is also used, 429 responses are only retried here. A
+// MaxRetries <= 0 disables it.
+func WithRateLimit(cfg RateLimitConfig) ClientOption {
+	return func(client *Client) {
+		if cfg.MaxRetries <= 0 {
+			client.Connection.rateLimit = nil
+			return
+		}
+		client.Connection.rateLimit = &cfg
+	}
+}
+
+// sendWithRateLimit sends the request, waiting and sending it again while
+// Kibana answers with 429 Too Many Requests and the budget allows it.
+func (conn *Connection) sendWithRateLimit(ctx context.Context, method, extraPath string,
+	params url.Values, headers http.Header, payload []byte) (*http.Response, error) {
+
+	cfg := conn.rateLimit
+	var waited time.Duration
+	for retry := 0; ; retry++ {
+		resp, err := conn.sendOnce(ctx, method, extraPath, params, headers, payload)
+		if err != nil || resp.StatusCode != http.StatusTooManyRequests || retry >= cfg.MaxRetries {
+			return resp, err
+		}
+
+		wait := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+		if cfg.MaxWait > 0 && waited+wait > cfg.MaxWait {
+			return resp, nil
+		}
+		_, _ = io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+		waited += wait
+	}
+}
+
+// parseRetryAfter returns the wait requested by a Retry-After header, given
+// either in seconds or as an HTTP date. DefaultRetryAfter is returned if the
+// header is missing or invalid.
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return DefaultRetryAfter
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return DefaultRetryAfter
+		}
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		if wait := date.Sub(now); wait > 0 {
+			return wait
+		}
+		return 0
+	}
+	return DefaultRetryAfter
+}
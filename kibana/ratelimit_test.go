@@ -0,0 +1,164 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package kibana
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/elastic-agent-libs/config"
+)
+
+func TestRateLimitRetryAfter(t *testing.T) {
+	var calls atomic.Int32
+	client, err := createTestServerAndClient(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		assert.Equal(t, `{"id":"policy-id"}`, string(body), "the body must be sent on every attempt")
+		if calls.Add(1) < 3 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		_, _ = w.Write([]byte(`{}`))
+	}, WithRateLimit(RateLimitConfig{MaxRetries: 2}))
+	require.NoError(t, err)
+
+	code, _, err := client.Connection.Request(http.MethodPost, "/api/test", nil, nil, strings.NewReader(`{"id":"policy-id"}`))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+	assert.EqualValues(t, 3, calls.Load())
+}
+
+func TestRateLimitBudget(t *testing.T) {
+	var calls atomic.Int32
+	handler := func(retryAfter string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			calls.Add(1)
+			w.Header().Set("Retry-After", retryAfter)
+			w.WriteHeader(http.StatusTooManyRequests)
+			_, _ = w.Write([]byte(`{"statusCode":429,"error":"Too Many Requests","message":"slow down"}`))
+		}
+	}
+
+	t.Run("max retries", func(t *testing.T) {
+		calls.Store(0)
+		client, err := createTestServerAndClient(handler("0"), WithRateLimit(RateLimitConfig{MaxRetries: 2}))
+		require.NoError(t, err)
+
+		_, err = client.GetPolicy(context.Background(), "policy-id")
+		assert.True(t, IsRateLimited(err), "expected a rate limit error, got %v", err)
+		assert.ErrorContains(t, err, "slow down")
+		assert.EqualValues(t, 3, calls.Load())
+	})
+
+	t.Run("max wait", func(t *testing.T) {
+		calls.Store(0)
+		client, err := createTestServerAndClient(handler("60"), WithRateLimit(RateLimitConfig{MaxRetries: 5, MaxWait: 10 * time.Second}))
+		require.NoError(t, err)
+
+		start := time.Now()
+		_, err = client.GetPolicy(context.Background(), "policy-id")
+		assert.True(t, IsRateLimited(err), "expected a rate limit error, got %v", err)
+		assert.Less(t, time.Since(start), 5*time.Second, "Retry-After beyond MaxWait must not be waited for")
+		assert.EqualValues(t, 1, calls.Load())
+	})
+
+	t.Run("canceled", func(t *testing.T) {
+		client, err := createTestServerAndClient(handler("60"), WithRateLimit(RateLimitConfig{MaxRetries: 5}))
+		require.NoError(t, err)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		_, err = client.GetPolicy(ctx, "policy-id")
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+}
+
+func TestRateLimitWithRetry(t *testing.T) {
+	var calls atomic.Int32
+	client, err := createTestServerAndClient(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		assert.Equal(t, `{"id":"policy-id"}`, string(body), "the body must be sent on every attempt")
+		calls.Add(1)
+		w.Header().Set("Retry-After", "0")
+		w.WriteHeader(http.StatusTooManyRequests)
+	},
+		WithRateLimit(RateLimitConfig{MaxRetries: 2}),
+		WithRetry(RetryConfig{MaxAttempts: 3, RetryUnsafeMethods: true}),
+	)
+	require.NoError(t, err)
+
+	code, _, err := client.Connection.Request(http.MethodPost, "/api/test", nil, nil, strings.NewReader(`{"id":"policy-id"}`))
+	assert.Error(t, err)
+	assert.Equal(t, http.StatusTooManyRequests, code)
+	assert.EqualValues(t, 3, calls.Load(), "429 responses must only be retried by the rate limit handling")
+}
+
+func TestRateLimitFromConfig(t *testing.T) {
+	var calls atomic.Int32
+	kibanaTS := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == statusAPI {
+			_, _ = w.Write([]byte(`{"version":{"number":"8.0.0"}}`))
+			return
+		}
+		calls.Add(1)
+		w.Header().Set("Retry-After", "0")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer kibanaTS.Close()
+
+	cfg := config.MustNewConfigFrom(fmt.Sprintf(`
+host: %s
+rate_limit.max_retries: 1
+rate_limit.max_wait: 30s
+`, kibanaTS.Listener.Addr().String()))
+	client, err := NewKibanaClient(cfg, binaryName, v, commit, buildTime)
+	require.NoError(t, err)
+	require.NotNil(t, client.Connection.rateLimit)
+	assert.Equal(t, RateLimitConfig{MaxRetries: 1, MaxWait: 30 * time.Second}, *client.Connection.rateLimit)
+
+	code, _, _ := client.Connection.Request(http.MethodGet, "/api/test", nil, nil, nil)
+	assert.Equal(t, http.StatusTooManyRequests, code)
+	assert.EqualValues(t, 2, calls.Load())
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC)
+	tests := map[string]time.Duration{
+		"":                              DefaultRetryAfter,
+		"invalid":                       DefaultRetryAfter,
+		"-1":                            DefaultRetryAfter,
+		"0":                             0,
+		" 120 ":                         2 * time.Minute,
+		"Tue, 02 Jan 2024 15:04:35 GMT": 30 * time.Second,
+		"Tue, 02 Jan 2024 15:00:00 GMT": 0,
+	}
+	for value, expected := range tests {
+		assert.Equal(t, expected, parseRetryAfter(value, now), "Retry-After: %q", value)
+	}
+}
@@ -0,0 +1,84 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package kibana
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// Request sends req encoded as JSON to the Kibana API at path and decodes the
+// JSON response into a Resp. It can be used to call endpoints the client does
+// not wrap yet:
+//
+//	type upgradeRequest struct {
+//		Version string `json:"version"`
+//	}
+//	resp, err := kibana.Request[upgradeRequest, map[string]any](ctx, client,
+//		http.MethodPost, "/api/fleet/agents/agent-id/upgrade", upgradeRequest{Version: "8.15.0"})
+//
+// No body is sent if Req is an interface type and req is nil, e.g.
+// Request[any, Resp](ctx, client, http.MethodGet, path, nil). path may include a query string. Responses with a status
+// outside of 2xx are returned as *APIError. A 202 Accepted response whose body
+// references a background task returns a *TaskPendingError, like for the other
+// methods of the client, other 202 responses are decoded like a 200. An empty
+// response body leaves Resp as its zero value.
+func Request[Req, Resp any](ctx context.Context, client *Client, method, path string, req Req) (r Resp, err error) {
+	var body io.Reader
+	if any(req) != nil {
+		b, err := client.codec().Marshal(req)
+		if err != nil {
+			return r, fmt.Errorf("unable to marshal %s %s request into JSON: %w", method, path, err)
+		}
+		body = bytes.NewReader(b)
+	}
+
+	resp, err := client.Connection.SendWithContext(ctx, method, path, nil, nil, body)
+	if err != nil {
+		return r, fmt.Errorf("error calling %s %s: %w", method, path, err)
+	}
+	defer resp.Body.Close()
+
+	if err := checkAuthChallenge(resp); err != nil {
+		return r, err
+	}
+	b, err := readBody(resp)
+	if err != nil {
+		return r, fmt.Errorf("reading response body: %w", err)
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusAccepted:
+		if b, err = client.resolveTask(b); err != nil {
+			return r, err
+		}
+	case resp.StatusCode < 200 || resp.StatusCode >= 300:
+		return r, newAPIError(client.codec(), resp.StatusCode, b)
+	}
+
+	if len(bytes.TrimSpace(b)) == 0 {
+		return r, nil
+	}
+	if err := client.codec().Unmarshal(b, &r); err != nil {
+		return r, fmt.Errorf("unmarshalling response json: %w", err)
+	}
+	return r, nil
+}
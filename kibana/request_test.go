@@ -0,0 +1,83 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package kibana
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequest(t *testing.T) {
+	ctx, cn := context.WithCancel(context.Background())
+	defer cn()
+
+	type upgradeRequest struct {
+		Version string `json:"version"`
+	}
+	type upgradeResponse struct {
+		ActionID string `json:"actionId"`
+	}
+
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+
+		switch r.URL.Path {
+		case "/api/fleet/agents/agent-id/upgrade":
+			require.Equal(t, http.MethodPost, r.Method)
+			require.Equal(t, "application/json", r.Header.Get("Content-Type"))
+			require.JSONEq(t, `{"version":"8.15.0"}`, string(body))
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte(`{"actionId":"action-id"}`))
+		case "/api/fleet/agents/agent-id":
+			require.Empty(t, body)
+			require.Equal(t, "true", r.URL.Query().Get("withMetrics"))
+			if r.Method == http.MethodDelete {
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+			_, _ = w.Write([]byte(`{"item":{"id":"agent-id"}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"statusCode":404,"error":"Not Found","message":"no such endpoint"}`))
+		}
+	}
+
+	client, err := createTestServerAndClient(handler)
+	require.NoError(t, err)
+
+	upgrade, err := Request[upgradeRequest, upgradeResponse](ctx, client, http.MethodPost, "/api/fleet/agents/agent-id/upgrade", upgradeRequest{Version: "8.15.0"})
+	require.NoError(t, err)
+	require.Equal(t, "action-id", upgrade.ActionID)
+
+	agent, err := Request[any, map[string]map[string]any](ctx, client, http.MethodGet, "/api/fleet/agents/agent-id?withMetrics=true", nil)
+	require.NoError(t, err)
+	require.Equal(t, "agent-id", agent["item"]["id"])
+
+	deleted, err := Request[any, struct{}](ctx, client, http.MethodDelete, "/api/fleet/agents/agent-id?withMetrics=true", nil)
+	require.NoError(t, err)
+	require.Equal(t, struct{}{}, deleted)
+
+	_, err = Request[any, any](ctx, client, http.MethodGet, "/api/fleet/missing", nil)
+	require.True(t, IsNotFound(err))
+	require.EqualError(t, err, "no such endpoint")
+}
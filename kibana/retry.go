@@ -0,0 +1,222 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package kibana
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// JitterStrategy selects how the backoff between retries is randomized.
+type JitterStrategy int
+
+const (
+	// JitterNone waits exactly the backoff.
+	JitterNone JitterStrategy = iota
+	// JitterFull waits a random duration between 0 and the backoff.
+	JitterFull
+	// JitterEqual waits half the backoff plus a random duration up to the
+	// other half.
+	JitterEqual
+)
+
+// RetryConfig configures the retries of requests failing with a connection
+// error or a retryable status code. Unless RetryableStatusCodes is set, these
+// are 429 Too Many Requests, 502 Bad Gateway, 503 Service Unavailable and 504
+// Gateway Timeout.
+//
+// If rate limit handling is enabled with WithRateLimit, 429 responses are
+// left to it and not retried again.
+//
+// Requests with a method that changes state, like POST, PUT or DELETE, may
+// have been processed before they failed. Unless RetryUnsafeMethods is set,
+// they are only retried if the connection could not be established or Kibana
+// answered with 429 Too Many Requests.
+type RetryConfig struct {
+	// MaxAttempts is the total number of attempts, including the first one.
+	MaxAttempts int
+	// InitialBackoff is the wait before the first retry. It doubles with
+	// every retry, up to MaxBackoff.
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// MaxElapsedTime bounds the time spent on the whole operation. No retry
+	// is started if its backoff would end after MaxElapsedTime, even if
+	// attempts remain. Zero means no limit.
+	MaxElapsedTime time.Duration
+	Jitter         JitterStrategy
+	// RetryableStatusCodes replaces the default list of status codes that
+	// are retried. A nil slice uses the defaults, an empty one only retries
+	// connection errors.
+	RetryableStatusCodes []int
+	// RetryUnsafeMethods retries requests of all methods on every retryable
+	// failure. Only enable it if repeating an operation has no side effect.
+	RetryUnsafeMethods bool
+}
+
+// DefaultRetryConfig returns a RetryConfig making up to 3 attempts within 30
+// seconds, with full jitter.
+func DefaultRetryConfig() RetryConfig {
+	return RetryConfig{
+		MaxAttempts:    3,
+		InitialBackoff: 500 * time.Millisecond,
+		MaxBackoff:     5 * time.Second,
+		MaxElapsedTime: 30 * time.Second,
+		Jitter:         JitterFull,
+	}
+}
+
+// RetryError is returned when a request failed after retries. Err is the
+// error of the last attempt. If the last attempt got a response, Err is an
+// *APIError holding its status code.
+type RetryError struct {
+	Attempts int
+	Elapsed  time.Duration
+	Err      error
+}
+
+func (e *RetryError) Error() string {
+	return fmt.Sprintf("request failed after %d attempts in %s: %v", e.Attempts, e.Elapsed.Round(time.Millisecond), e.Err)
+}
+
+func (e *RetryError) Unwrap() error {
+	return e.Err
+}
+
+// WithRetry retries requests failing with transient errors according to cfg.
+// Request bodies are buffered in memory so they can be sent again. A
+// MaxAttempts <= 1 disables retries.
+func WithRetry(cfg RetryConfig) ClientOption {
+	return func(client *Client) {
+		if cfg.MaxAttempts <= 1 {
+			client.Connection.retry = nil
+			return
+		}
+		client.Connection.retry = &cfg
+	}
+}
+
+// backoff returns the wait before the given retry, starting at 1.
+func (c *RetryConfig) backoff(retry int) time.Duration {
+	b := c.InitialBackoff
+	for i := 1; i < retry && (c.MaxBackoff <= 0 || b < c.MaxBackoff); i++ {
+		b *= 2
+	}
+	if c.MaxBackoff > 0 && b > c.MaxBackoff {
+		b = c.MaxBackoff
+	}
+	if b <= 0 {
+		return 0
+	}
+
+	switch c.Jitter {
+	case JitterFull:
+		return time.Duration(rand.Int63n(int64(b))) //nolint:gosec // jitter does not need a secure random number
+	case JitterEqual:
+		half := b / 2
+		return half + time.Duration(rand.Int63n(int64(b-half))) //nolint:gosec // jitter does not need a secure random number
+	default:
+		return b
+	}
+}
+
+func (c *RetryConfig) isRetryableStatus(code int) bool {
+	if c.RetryableStatusCodes != nil {
+		for _, retryable := range c.RetryableStatusCodes {
+			if code == retryable {
+				return true
+			}
+		}
+		return false
+	}
+	return isRetryableStatus(code)
+}
+
+// shouldRetry returns true if a request with the given method can be sent
+// again after it failed with err or got resp.
+func (c *RetryConfig) shouldRetry(method string, resp *http.Response, err error) bool {
+	replayable := c.RetryUnsafeMethods || isSafeMethod(method)
+	if err != nil {
+		return replayable || isDialError(err)
+	}
+	if !c.isRetryableStatus(resp.StatusCode) {
+		return false
+	}
+	// Kibana rejects rate limited requests before processing them.
+	return replayable || resp.StatusCode == http.StatusTooManyRequests
+}
+
+func isRetryableStatus(code int) bool {
+	switch code {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	default:
+		return false
+	}
+}
+
+// sendWithRetry sends the request, retrying transient failures.
+func (conn *Connection) sendWithRetry(ctx context.Context, method, extraPath string,
+	params url.Values, headers http.Header, payload []byte) (*http.Response, error) {
+
+	cfg := conn.retry
+	start := time.Now()
+	for attempt := 1; ; attempt++ {
+		resp, err := conn.send(ctx, method, extraPath, params, headers, payload)
+		if err != nil && ctx.Err() != nil {
+			return nil, err
+		}
+		// The rate limit handling already waited for and retried the 429
+		// responses it returns.
+		rateLimited := err == nil && resp.StatusCode == http.StatusTooManyRequests && conn.rateLimit != nil
+		if rateLimited || !cfg.shouldRetry(method, resp, err) {
+			if err != nil && attempt > 1 {
+				return nil, &RetryError{Attempts: attempt, Elapsed: time.Since(start), Err: err}
+			}
+			return resp, err
+		}
+
+		if err == nil {
+			b, _ := readBody(resp)
+			resp.Body.Close()
+			apiErr := newAPIError(conn.codec(), resp.StatusCode, b)
+			if apiErr.Err == nil {
+				apiErr.Err = errors.New(resp.Status)
+			}
+			err = apiErr
+		}
+
+		wait := cfg.backoff(attempt)
+		elapsed := time.Since(start)
+		if attempt >= cfg.MaxAttempts || (cfg.MaxElapsedTime > 0 && elapsed+wait > cfg.MaxElapsedTime) {
+			return nil, &RetryError{Attempts: attempt, Elapsed: elapsed, Err: err}
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, &RetryError{Attempts: attempt, Elapsed: time.Since(start), Err: ctx.Err()}
+		case <-timer.C:
+		}
+	}
+}
@@ -0,0 +1,186 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package kibana
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/elastic-agent-libs/config"
+)
+
+func TestRetryMaxElapsedTime(t *testing.T) {
+	var calls atomic.Int32
+	client, err := createTestServerAndClient(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}, WithRetry(RetryConfig{
+		MaxAttempts:    100,
+		InitialBackoff: 20 * time.Millisecond,
+		MaxBackoff:     20 * time.Millisecond,
+		MaxElapsedTime: 200 * time.Millisecond,
+		Jitter:         JitterNone,
+	}))
+	require.NoError(t, err)
+
+	start := time.Now()
+	_, err = client.GetPolicy(context.Background(), "policy-id")
+	elapsed := time.Since(start)
+
+	var retryErr *RetryError
+	require.True(t, errors.As(err, &retryErr), "expected RetryError, got %v", err)
+	assert.EqualValues(t, calls.Load(), retryErr.Attempts)
+	assert.Greater(t, retryErr.Attempts, 1)
+	assert.Less(t, retryErr.Attempts, 100)
+	assert.LessOrEqual(t, retryErr.Elapsed, 200*time.Millisecond)
+	assert.Less(t, elapsed, time.Second)
+	assert.True(t, IsServerError(err))
+	assert.Contains(t, err.Error(), "attempts")
+}
+
+func TestRetryRecovers(t *testing.T) {
+	var calls atomic.Int32
+	client, err := createTestServerAndClient(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		assert.Equal(t, `{"id":"policy-id"}`, string(body), "the body must be sent on every attempt")
+		if calls.Add(1) < 3 {
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		_, _ = w.Write([]byte(`{}`))
+	}, WithRetry(RetryConfig{
+		MaxAttempts:    3,
+		InitialBackoff: time.Millisecond,
+		Jitter:         JitterEqual,
+	}))
+	require.NoError(t, err)
+
+	code, _, err := client.Connection.Request(http.MethodPost, "/api/test", nil, nil, strings.NewReader(`{"id":"policy-id"}`))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+	assert.EqualValues(t, 3, calls.Load())
+}
+
+func TestRetryUnsafeMethods(t *testing.T) {
+	var calls atomic.Int32
+	client, err := createTestServerAndClient(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}, WithRetry(RetryConfig{
+		MaxAttempts:    3,
+		InitialBackoff: time.Millisecond,
+		Jitter:         JitterNone,
+	}))
+	require.NoError(t, err)
+
+	code, _, err := client.Connection.Request(http.MethodPost, "/api/test", nil, nil, strings.NewReader(`{}`))
+	assert.True(t, IsServerError(err))
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.EqualValues(t, 1, calls.Load(), "a POST that may have been processed is not retried")
+
+	WithRetry(RetryConfig{
+		MaxAttempts:        3,
+		InitialBackoff:     time.Millisecond,
+		Jitter:             JitterNone,
+		RetryUnsafeMethods: true,
+	})(client)
+	calls.Store(0)
+	_, _, err = client.Connection.Request(http.MethodPost, "/api/test", nil, nil, strings.NewReader(`{}`))
+	var retryErr *RetryError
+	require.ErrorAs(t, err, &retryErr)
+	assert.EqualValues(t, 3, calls.Load())
+}
+
+func TestRetryConnectionRefused(t *testing.T) {
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+
+	client, err := NewKibanaClient(config.MustNewConfigFrom(fmt.Sprintf(`
+host: %s
+ignoreversion: true
+`, down.Listener.Addr().String())), binaryName, v, commit, buildTime, WithRetry(RetryConfig{
+		MaxAttempts:    3,
+		InitialBackoff: time.Millisecond,
+		Jitter:         JitterNone,
+	}))
+	require.NoError(t, err)
+
+	_, _, err = client.Connection.Request(http.MethodPost, "/api/test", nil, nil, strings.NewReader(`{}`))
+	var retryErr *RetryError
+	require.ErrorAs(t, err, &retryErr)
+	assert.Equal(t, 3, retryErr.Attempts, "a POST that could not connect is retried")
+}
+
+func TestRetryBackoff(t *testing.T) {
+	cfg := RetryConfig{InitialBackoff: 100 * time.Millisecond, MaxBackoff: 300 * time.Millisecond}
+	assert.Equal(t, 100*time.Millisecond, cfg.backoff(1))
+	assert.Equal(t, 200*time.Millisecond, cfg.backoff(2))
+	assert.Equal(t, 300*time.Millisecond, cfg.backoff(3))
+
+	cfg.Jitter = JitterFull
+	for i := 0; i < 100; i++ {
+		assert.Less(t, cfg.backoff(1), 100*time.Millisecond)
+	}
+
+	cfg.Jitter = JitterEqual
+	for i := 0; i < 100; i++ {
+		b := cfg.backoff(1)
+		assert.GreaterOrEqual(t, b, 50*time.Millisecond)
+		assert.Less(t, b, 100*time.Millisecond)
+	}
+}
+
+func TestRetryableStatusCodes(t *testing.T) {
+	var calls atomic.Int32
+	client, err := createTestServerAndClient(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}, WithRetry(RetryConfig{
+		MaxAttempts:          5,
+		InitialBackoff:       time.Millisecond,
+		Jitter:               JitterNone,
+		RetryableStatusCodes: []int{http.StatusInternalServerError},
+	}))
+	require.NoError(t, err)
+
+	code, _, err := client.Connection.Request(http.MethodGet, "/api/test", nil, nil, nil)
+	assert.True(t, IsServerError(err))
+	assert.Equal(t, http.StatusServiceUnavailable, code, "503 is not retried when not listed")
+	assert.EqualValues(t, 2, calls.Load())
+
+	cfg := RetryConfig{}
+	assert.True(t, cfg.isRetryableStatus(http.StatusTooManyRequests))
+	assert.False(t, cfg.isRetryableStatus(http.StatusInternalServerError))
+
+	cfg.RetryableStatusCodes = []int{}
+	assert.False(t, cfg.isRetryableStatus(http.StatusTooManyRequests))
+}
@@ -0,0 +1,286 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package kibana
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"strconv"
+)
+
+const (
+	savedObjectsImportAPI = "/api/saved_objects/_import"
+	savedObjectsExportAPI = "/api/saved_objects/_export"
+
+	savedObjectsResolveImportErrorsAPI = "/api/saved_objects/_resolve_import_errors"
+)
+
+// SavedObjectRef identifies a saved object.
+type SavedObjectRef struct {
+	Type string `json:"type"`
+	ID   string `json:"id"`
+}
+
+// ImportSavedObjectsRequest is the request for ImportSavedObjects.
+type ImportSavedObjectsRequest struct {
+	// Objects is the NDJSON file to import, as produced by
+	// ExportSavedObjects.
+	Objects io.Reader
+	// Overwrite replaces existing objects with the same IDs.
+	Overwrite bool
+	// CreateNewCopies imports the objects with new IDs. It can not be used
+	// with Overwrite.
+	CreateNewCopies bool
+	// CompatibilityMode applies adjustments to avoid conflicts with objects
+	// imported in other spaces. It can not be used with CreateNewCopies.
+	CompatibilityMode bool
+	// Managed, if set, overrides the managed flag of every imported object.
+	// Managed objects can not be edited in the Kibana UI.
+	Managed *bool
+}
+
+// SavedObjectImportError describes an object that could not be imported.
+type SavedObjectImportError struct {
+	ID    string `json:"id"`
+	Type  string `json:"type"`
+	Title string `json:"title"`
+	Error struct {
+		// Type is the kind of error, e.g. conflict, ambiguous_conflict,
+		// missing_references or unsupported_type.
+		Type    string `json:"type"`
+		Message string `json:"message,omitempty"`
+		// DestinationID is the ID of the existing object a conflict is
+		// with, if it differs from ID.
+		DestinationID string `json:"destinationId,omitempty"`
+		// Destinations are the existing objects an ambiguous conflict is
+		// with.
+		Destinations []struct {
+			ID    string `json:"id"`
+			Title string `json:"title"`
+		} `json:"destinations,omitempty"`
+		// References are the missing references.
+		References []SavedObjectRef `json:"references,omitempty"`
+	} `json:"error"`
+}
+
+// SavedObjectImportResult describes an object that was imported.
+type SavedObjectImportResult struct {
+	ID   string `json:"id"`
+	Type string `json:"type"`
+	// DestinationID is the new ID of the object if it was imported as a new
+	// copy.
+	DestinationID string `json:"destinationId,omitempty"`
+	Overwrite     bool   `json:"overwrite,omitempty"`
+	Managed       bool   `json:"managed,omitempty"`
+}
+
+// ImportSavedObjectsResponse is the response of ImportSavedObjects.
+type ImportSavedObjectsResponse struct {
+	Success        bool                      `json:"success"`
+	SuccessCount   int                       `json:"successCount"`
+	SuccessResults []SavedObjectImportResult `json:"successResults"`
+	Errors         []SavedObjectImportError  `json:"errors"`
+}
+
+// ImportSavedObjects imports saved objects, like dashboards and index
+// patterns, from an NDJSON file. If some objects could not be imported the
+// response is returned together with an error listing them.
+func (client *Client) ImportSavedObjects(ctx context.Context, request ImportSavedObjectsRequest) (r ImportSavedObjectsResponse, err error) {
+	if request.Objects == nil {
+		return r, errors.New("no saved objects to import")
+	}
+	if request.CreateNewCopies && (request.Overwrite || request.CompatibilityMode) {
+		return r, errors.New("create new copies can not be used with overwrite or compatibility mode")
+	}
+
+	objects := request.Objects
+	if request.Managed != nil {
+		if objects, err = setManaged(client.codec(), request.Objects, *request.Managed); err != nil {
+			return r, err
+		}
+	}
+
+	params := url.Values{}
+	if request.Overwrite {
+		params.Set("overwrite", "true")
+	}
+	if request.CreateNewCopies {
+		params.Set("createNewCopies", "true")
+	}
+	if request.CompatibilityMode {
+		params.Set("compatibilityMode", "true")
+	}
+
+	r, err = client.sendSavedObjects(ctx, savedObjectsImportAPI, params, objects, nil)
+	if err != nil {
+		return r, err
+	}
+	if !r.Success || len(r.Errors) > 0 {
+		return r, importErrors(r.Errors)
+	}
+	return r, nil
+}
+
+// sendSavedObjects sends the NDJSON objects to the import API at apiPath. If
+// retries is set it is sent with the objects, as expected by the resolve
+// import errors API.
+func (client *Client) sendSavedObjects(ctx context.Context, apiPath string, params url.Values, objects io.Reader, retries []savedObjectRetry) (r ImportSavedObjectsResponse, err error) {
+	buf := &bytes.Buffer{}
+	w := multipart.NewWriter(buf)
+	partHeaders := textproto.MIMEHeader{}
+	partHeaders.Set("Content-Disposition", `form-data; name="file"; filename="export.ndjson"`)
+	partHeaders.Set("Content-Type", "application/ndjson")
+	part, err := w.CreatePart(partHeaders)
+	if err != nil {
+		return r, fmt.Errorf("failed to create multipart writer for saved objects: %w", err)
+	}
+	if _, err = io.Copy(part, objects); err != nil {
+		return r, fmt.Errorf("failed to copy saved objects: %w", err)
+	}
+	if retries != nil {
+		b, err := client.codec().Marshal(retries)
+		if err != nil {
+			return r, fmt.Errorf("unable to marshal saved objects retries into JSON: %w", err)
+		}
+		if err = w.WriteField("retries", string(b)); err != nil {
+			return r, fmt.Errorf("failed to write saved objects retries: %w", err)
+		}
+	}
+	if err = w.Close(); err != nil {
+		return r, fmt.Errorf("failed to close multipart writer for saved objects: %w", err)
+	}
+
+	headers := http.Header{}
+	headers.Set("Content-Type", w.FormDataContentType())
+
+	resp, err := client.Connection.SendWithContext(ctx, http.MethodPost, apiPath, params, headers, buf)
+	if err != nil {
+		return r, fmt.Errorf("error calling import saved objects API: %w", err)
+	}
+	defer resp.Body.Close()
+
+	err = client.readJSONResponse(resp, &r)
+	return r, err
+}
+
+// savedObjectRetry tells the resolve import errors API how to import an
+// object that failed to import.
+type savedObjectRetry struct {
+	Type                    string `json:"type"`
+	ID                      string `json:"id"`
+	Overwrite               bool   `json:"overwrite"`
+	DestinationID           string `json:"destinationId,omitempty"`
+	IgnoreMissingReferences bool   `json:"ignoreMissingReferences,omitempty"`
+}
+
+// importErrors returns an error listing the objects that failed to import.
+func importErrors(importErrs []SavedObjectImportError) error {
+	errs := make([]error, 0, len(importErrs))
+	for _, e := range importErrs {
+		errs = append(errs, fmt.Errorf("%s %s: %s", e.Type, e.ID, e.Error.Type))
+	}
+	return fmt.Errorf("failed to import %d saved objects: %w", len(importErrs), errors.Join(errs...))
+}
+
+// setManaged returns the NDJSON objects with their managed flag set.
+func setManaged(codec JSONCodec, objects io.Reader, managed bool) (io.Reader, error) {
+	out := &bytes.Buffer{}
+	scanner := bufio.NewScanner(objects)
+	scanner.Buffer(nil, 64*1024*1024)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var object map[string]json.RawMessage
+		if err := codec.Unmarshal(line, &object); err != nil {
+			return nil, fmt.Errorf("invalid saved object: %w", err)
+		}
+		// The export details summary is not a saved object.
+		if _, ok := object["exportedCount"]; !ok {
+			object["managed"] = json.RawMessage(strconv.FormatBool(managed))
+		}
+		b, err := codec.Marshal(object)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode saved object: %w", err)
+		}
+		out.Write(b)
+		out.WriteByte('\n')
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read saved objects: %w", err)
+	}
+	return out, nil
+}
+
+// ExportSavedObjectsRequest selects the saved objects to export. Types and
+// Objects can not be used together.
+type ExportSavedObjectsRequest struct {
+	// Types exports all objects of the given types.
+	Types []string `json:"type,omitempty"`
+	// Objects exports the given objects.
+	Objects []SavedObjectRef `json:"objects,omitempty"`
+	// IncludeReferencesDeep also exports the objects referenced by the
+	// exported objects.
+	IncludeReferencesDeep bool `json:"includeReferencesDeep"`
+	// ExcludeExportDetails omits the summary at the end of the export.
+	ExcludeExportDetails bool `json:"excludeExportDetails"`
+}
+
+// ExportSavedObjects exports saved objects as NDJSON, in the format accepted
+// by ImportSavedObjects.
+func (client *Client) ExportSavedObjects(ctx context.Context, request ExportSavedObjectsRequest) ([]byte, error) {
+	if len(request.Types) == 0 && len(request.Objects) == 0 {
+		return nil, errors.New("either types or objects must be set to export saved objects")
+	}
+	if len(request.Types) > 0 && len(request.Objects) > 0 {
+		return nil, errors.New("types and objects can not be used together to export saved objects")
+	}
+
+	reqBody, err := client.codec().Marshal(request)
+	if err != nil {
+		return nil, fmt.Errorf("unable to marshal export saved objects request into JSON: %w", err)
+	}
+
+	resp, err := client.Connection.SendWithContext(ctx, http.MethodPost, savedObjectsExportAPI, nil, nil, bytes.NewReader(reqBody))
+	if err != nil {
+		return nil, fmt.Errorf("error calling export saved objects API: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if err := checkAuthChallenge(resp); err != nil {
+		return nil, err
+	}
+	body, err := readBody(resp)
+	if err != nil {
+		return nil, fmt.Errorf("reading response body: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, newAPIError(client.codec(), resp.StatusCode, body)
+	}
+	return body, nil
+}
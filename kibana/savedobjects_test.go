@@ -0,0 +1,141 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package kibana
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const testSavedObjects = `{"type":"index-pattern","id":"logs-*","attributes":{"title":"logs-*"}}
+{"type":"dashboard","id":"overview","attributes":{"title":"Overview"},"managed":false}
+{"exportedCount":2,"missingRefCount":0,"missingReferences":[]}
+`
+
+func TestImportSavedObjects(t *testing.T) {
+	ctx, cn := context.WithCancel(context.Background())
+	defer cn()
+
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, savedObjectsImportAPI, r.URL.Path)
+		require.Equal(t, http.MethodPost, r.Method)
+		require.Equal(t, "true", r.URL.Query().Get("overwrite"))
+		require.Empty(t, r.URL.Query().Get("createNewCopies"))
+
+		file, header, err := r.FormFile("file")
+		require.NoError(t, err)
+		require.Equal(t, "application/ndjson", header.Header.Get("Content-Type"))
+		b, err := io.ReadAll(file)
+		require.NoError(t, err)
+
+		lines := strings.Split(strings.TrimSpace(string(b)), "\n")
+		require.Len(t, lines, 3)
+		var objects []map[string]any
+		for _, line := range lines {
+			var object map[string]any
+			require.NoError(t, json.Unmarshal([]byte(line), &object))
+			objects = append(objects, object)
+		}
+		require.Equal(t, true, objects[0]["managed"])
+		require.Equal(t, true, objects[1]["managed"])
+		require.NotContains(t, objects[2], "managed", "the export details are not an object")
+
+		_, _ = w.Write([]byte(`{"success":true,"successCount":2,"successResults":[{"type":"index-pattern","id":"logs-*","overwrite":true,"managed":true},{"type":"dashboard","id":"overview","managed":true}]}`))
+	}
+
+	client, err := createTestServerAndClient(handler)
+	require.NoError(t, err)
+
+	managed := true
+	resp, err := client.ImportSavedObjects(ctx, ImportSavedObjectsRequest{
+		Objects:   strings.NewReader(testSavedObjects),
+		Overwrite: true,
+		Managed:   &managed,
+	})
+	require.NoError(t, err)
+	require.True(t, resp.Success)
+	require.Equal(t, 2, resp.SuccessCount)
+	require.Equal(t, []SavedObjectImportResult{
+		{Type: "index-pattern", ID: "logs-*", Overwrite: true, Managed: true},
+		{Type: "dashboard", ID: "overview", Managed: true},
+	}, resp.SuccessResults)
+}
+
+func TestImportSavedObjectsErrors(t *testing.T) {
+	ctx, cn := context.WithCancel(context.Background())
+	defer cn()
+
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"success":false,"successCount":1,"errors":[{"id":"overview","type":"dashboard","title":"Overview","error":{"type":"conflict"}}]}`))
+	}
+
+	client, err := createTestServerAndClient(handler)
+	require.NoError(t, err)
+
+	resp, err := client.ImportSavedObjects(ctx, ImportSavedObjectsRequest{Objects: strings.NewReader(testSavedObjects)})
+	require.ErrorContains(t, err, "dashboard overview: conflict")
+	require.Len(t, resp.Errors, 1)
+	require.Equal(t, "Overview", resp.Errors[0].Title)
+
+	_, err = client.ImportSavedObjects(ctx, ImportSavedObjectsRequest{
+		Objects:         strings.NewReader(testSavedObjects),
+		Overwrite:       true,
+		CreateNewCopies: true,
+	})
+	require.ErrorContains(t, err, "create new copies can not be used with overwrite")
+}
+
+func TestExportSavedObjects(t *testing.T) {
+	ctx, cn := context.WithCancel(context.Background())
+	defer cn()
+
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, savedObjectsExportAPI, r.URL.Path)
+		require.Equal(t, http.MethodPost, r.Method)
+
+		var body map[string]any
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		require.Equal(t, map[string]any{
+			"objects":               []any{map[string]any{"type": "dashboard", "id": "overview"}},
+			"includeReferencesDeep": true,
+			"excludeExportDetails":  false,
+		}, body)
+
+		w.Header().Set("Content-Type", "application/ndjson")
+		_, _ = w.Write([]byte(testSavedObjects))
+	}
+
+	client, err := createTestServerAndClient(handler)
+	require.NoError(t, err)
+
+	objects, err := client.ExportSavedObjects(ctx, ExportSavedObjectsRequest{
+		Objects:               []SavedObjectRef{{Type: "dashboard", ID: "overview"}},
+		IncludeReferencesDeep: true,
+	})
+	require.NoError(t, err)
+	require.Equal(t, testSavedObjects, string(objects))
+
+	_, err = client.ExportSavedObjects(ctx, ExportSavedObjectsRequest{})
+	require.Error(t, err)
+}
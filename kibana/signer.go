@@ -0,0 +1,141 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package kibana
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Signer signs the requests sent to Kibana, e.g. for an API gateway that
+// authenticates requests by their signature. Sign is called for every request
+// sent, including retries and requests to other hosts, after all headers and
+// the body are set. It may add headers, but must leave the body readable,
+// req.GetBody returns a copy of the body if it is not nil.
+type Signer interface {
+	Sign(req *http.Request) error
+}
+
+// WithSigner signs every request sent to Kibana with signer.
+func WithSigner(signer Signer) ClientOption {
+	return func(client *Client) {
+		client.Connection.signer = signer
+	}
+}
+
+// Default headers written by HMACSigner.
+const (
+	DefaultSignatureHeader          = "X-Signature"
+	DefaultSignatureKeyIDHeader     = "X-Signature-Key-Id"
+	DefaultSignatureTimestampHeader = "X-Signature-Timestamp"
+)
+
+// HMACSigner signs requests with HMAC-SHA256. The signature is computed over
+//
+//	METHOD "\n" PATH_AND_QUERY "\n" TIMESTAMP "\n" BODY
+//
+// where TIMESTAMP is the Unix time in seconds, and written hex encoded to the
+// signature header, together with the key ID and timestamp headers.
+type HMACSigner struct {
+	KeyID  string
+	Secret []byte
+
+	// Header names, the defaults are used if empty.
+	SignatureHeader string
+	KeyIDHeader     string
+	TimestampHeader string
+
+	// Now returns the signing time, time.Now if nil.
+	Now func() time.Time
+}
+
+// NewHMACSigner returns an HMACSigner writing the default headers.
+func NewHMACSigner(keyID string, secret []byte) *HMACSigner {
+	return &HMACSigner{KeyID: keyID, Secret: secret}
+}
+
+// Sign adds the signature headers to req.
+func (s *HMACSigner) Sign(req *http.Request) error {
+	body, err := requestBody(req)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now
+	if s.Now != nil {
+		now = s.Now
+	}
+	timestamp := strconv.FormatInt(now().Unix(), 10)
+
+	req.Header.Set(headerOrDefault(s.KeyIDHeader, DefaultSignatureKeyIDHeader), s.KeyID)
+	req.Header.Set(headerOrDefault(s.TimestampHeader, DefaultSignatureTimestampHeader), timestamp)
+	req.Header.Set(headerOrDefault(s.SignatureHeader, DefaultSignatureHeader), HMACSignature(s.Secret, req.Method, req.URL.RequestURI(), timestamp, body))
+	return nil
+}
+
+// HMACSignature returns the hex encoded signature HMACSigner computes for a
+// request. Servers can use it to verify signatures.
+func HMACSignature(secret []byte, method, pathAndQuery, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	fmt.Fprintf(mac, "%s\n%s\n%s\n", method, pathAndQuery, timestamp)
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// requestBody returns the body of req without consuming it. Bodies that can
+// not be copied are read and replaced by an in-memory copy.
+func requestBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+
+	if req.GetBody != nil {
+		rc, err := req.GetBody()
+		if err != nil {
+			return nil, fmt.Errorf("fail to copy the request body: %w", err)
+		}
+		defer rc.Close()
+		return io.ReadAll(rc)
+	}
+
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("fail to read the request body: %w", err)
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(body)), nil
+	}
+	req.ContentLength = int64(len(body))
+	return body, nil
+}
+
+func headerOrDefault(header, def string) string {
+	if header == "" {
+		return def
+	}
+	return header
+}
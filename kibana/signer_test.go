@@ -0,0 +1,103 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package kibana
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHMACSigner(t *testing.T) {
+	secret := []byte("shared-secret")
+
+	var (
+		mu         sync.Mutex
+		timestamps []string
+		failures   int
+	)
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+
+		timestamp := r.Header.Get(DefaultSignatureTimestampHeader)
+		expected := HMACSignature(secret, r.Method, r.URL.RequestURI(), timestamp, body)
+		if r.Header.Get(DefaultSignatureHeader) != expected || r.Header.Get(DefaultSignatureKeyIDHeader) != "key-1" {
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"message":"invalid signature"}`))
+			return
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		timestamps = append(timestamps, timestamp)
+		// The first attempt fails, the retry must be signed again.
+		if failures == 0 {
+			failures++
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte(`{"item":{"id":"policy-1","name":"test","namespace":"default"}}`))
+	}
+
+	var clock int64 = 1700000000
+	signer := NewHMACSigner("key-1", secret)
+	signer.Now = func() time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		clock++
+		return time.Unix(clock, 0)
+	}
+
+	client, err := createTestServerAndClient(handler, WithSigner(signer), WithRetry(RetryConfig{
+		MaxAttempts:        2,
+		InitialBackoff:     time.Millisecond,
+		MaxBackoff:         time.Millisecond,
+		RetryUnsafeMethods: true,
+	}))
+	require.NoError(t, err)
+
+	policy, err := client.CreatePolicy(context.Background(), AgentPolicy{Name: "test", Namespace: "default"})
+	require.NoError(t, err)
+	assert.Equal(t, "policy-1", policy.ID)
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.Len(t, timestamps, 2)
+	assert.NotEqual(t, timestamps[0], timestamps[1], "every attempt is signed")
+}
+
+func TestRequestBodyNotCopyable(t *testing.T) {
+	req, err := http.NewRequest(http.MethodPost, "http://localhost/api", io.NopCloser(strings.NewReader("payload")))
+	require.NoError(t, err)
+	require.Nil(t, req.GetBody)
+
+	require.NoError(t, NewHMACSigner("key", []byte("secret")).Sign(req))
+
+	body, err := io.ReadAll(req.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "payload", string(body), "the body is still readable after signing")
+	assert.NotEmpty(t, req.Header.Get(DefaultSignatureHeader))
+}
@@ -0,0 +1,63 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package kibana
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// TaskPendingError is returned when Kibana accepted an operation to run as a
+// background task, answering 202 Accepted with the ID of the task. The result
+// of the operation is not part of the response.
+//
+// The client does not poll for the result of the task, Fleet does not
+// document an endpoint reporting the status of such tasks.
+type TaskPendingError struct {
+	TaskID string
+}
+
+func (e *TaskPendingError) Error() string {
+	return fmt.Sprintf("operation is running as Kibana task %s", e.TaskID)
+}
+
+// resolveTask handles the body of a 202 Accepted response. A body
+// referencing a task returns a *TaskPendingError, other bodies are returned
+// like the body of a 200 response. An empty body is returned as null, so it
+// decodes to the zero value.
+func (client *Client) resolveTask(body []byte) ([]byte, error) {
+	if len(bytes.TrimSpace(body)) == 0 {
+		return []byte("null"), nil
+	}
+
+	var accepted struct {
+		TaskID    string `json:"taskId"`
+		TaskIDAlt string `json:"task_id"`
+	}
+	if err := client.codec().Unmarshal(body, &accepted); err != nil {
+		return body, nil //nolint:nilerr // not a task reference, decode the body as is
+	}
+	taskID := accepted.TaskID
+	if taskID == "" {
+		taskID = accepted.TaskIDAlt
+	}
+	if taskID == "" {
+		return body, nil
+	}
+	return nil, &TaskPendingError{TaskID: taskID}
+}
@@ -0,0 +1,72 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package kibana
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTaskPending(t *testing.T) {
+	for name, body := range map[string]string{
+		"taskId":  `{"taskId":"task-1"}`,
+		"task_id": `{"task_id":"task-1"}`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			client, err := createTestServerAndClient(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusAccepted)
+				_, _ = w.Write([]byte(body))
+			})
+			require.NoError(t, err)
+
+			_, err = client.CreatePolicy(context.Background(), AgentPolicy{Name: "test"})
+			var pending *TaskPendingError
+			require.ErrorAs(t, err, &pending)
+			assert.Equal(t, "task-1", pending.TaskID)
+		})
+	}
+}
+
+func TestAcceptedWithoutTask(t *testing.T) {
+	tests := map[string]struct {
+		body string
+		want AgentPolicy
+	}{
+		"result": {body: `{"item":{"id":"policy-1","name":"test"}}`, want: AgentPolicy{ID: "policy-1", Name: "test"}},
+		"empty":  {body: ``},
+		"null":   {body: `null`},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			client, err := createTestServerAndClient(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusAccepted)
+				_, _ = w.Write([]byte(tc.body))
+			})
+			require.NoError(t, err)
+
+			policy, err := client.CreatePolicy(context.Background(), AgentPolicy{Name: "test"})
+			require.NoError(t, err)
+			assert.Equal(t, tc.want.ID, policy.ID)
+			assert.Equal(t, tc.want.Name, policy.Name)
+		})
+	}
+}
@@ -0,0 +1,252 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package kibana
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// maxTraceBodyBytes is the number of body bytes written to a trace.
+const maxTraceBodyBytes = 4096
+
+// WithHTTPTrace writes every request sent to Kibana and its response to w,
+// including headers and bodies, while debug logging is enabled for the logger
+// of the client. Credentials are redacted, bodies are cut after 4KiB and
+// binary bodies are only noted. The trace of a response is written once its
+// body is read or closed, streamed bodies like server-sent events are not
+// traced. Each attempt that reaches the transport is traced separately. Meant
+// for debugging only.
+func WithHTTPTrace(w io.Writer) ClientOption {
+	return func(client *Client) {
+		if w == nil || client.Connection.HTTP == nil {
+			return
+		}
+		httpClient := *client.Connection.HTTP
+		rt := httpClient.Transport
+		if rt == nil {
+			rt = http.DefaultTransport
+		}
+		httpClient.Transport = &traceRoundTripper{
+			w:  w,
+			rt: rt,
+			// The logger is read on every request, it can be set by a later
+			// option.
+			enabled: func() bool { return client.log != nil && client.log.IsDebug() },
+		}
+		client.Connection.HTTP = &httpClient
+	}
+}
+
+type traceRoundTripper struct {
+	mu      sync.Mutex // serializes writes of concurrent requests
+	w       io.Writer
+	rt      http.RoundTripper
+	enabled func() bool
+}
+
+func (t *traceRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !t.enabled() {
+		return t.rt.RoundTrip(req)
+	}
+
+	var buf bytes.Buffer
+
+	reqBody, err := drainBody(&req.Body)
+	if err != nil {
+		return nil, err
+	}
+	fmt.Fprintf(&buf, "> %s %s %s\n", req.Method, req.URL.RequestURI(), req.Proto)
+	fmt.Fprintf(&buf, "> Host: %s\n", req.URL.Host)
+	writeTraceHeaders(&buf, "> ", req.Header)
+	writeTraceBody(&buf, req.Header, reqBody, int64(len(reqBody)))
+
+	resp, err := t.rt.RoundTrip(req)
+	if err != nil {
+		fmt.Fprintf(&buf, "! %v\n\n", err)
+		t.write(buf.Bytes())
+		return resp, err
+	}
+
+	fmt.Fprintf(&buf, "< %s %s\n", resp.Proto, resp.Status)
+	writeTraceHeaders(&buf, "< ", resp.Header)
+	switch {
+	case resp.Body == nil || resp.Body == http.NoBody:
+	case isStreamingContent(resp.Header.Get("Content-Type")):
+		buf.WriteString("[streamed body]\n")
+	default:
+		resp.Body = &traceBody{ReadCloser: resp.Body, t: t, buf: buf, header: resp.Header}
+		return resp, nil
+	}
+	buf.WriteByte('\n')
+	t.write(buf.Bytes())
+	return resp, nil
+}
+
+func (t *traceRoundTripper) write(b []byte) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	_, _ = t.w.Write(b)
+}
+
+// traceBody keeps the first maxTraceBodyBytes of a response body while it is
+// read, and writes the trace once the body is read to the end or closed.
+type traceBody struct {
+	io.ReadCloser
+	t      *traceRoundTripper
+	buf    bytes.Buffer // trace of the request and the response headers
+	header http.Header
+	body   []byte
+	size   int64 // bytes read
+	once   sync.Once
+}
+
+func (b *traceBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if keep := maxTraceBodyBytes - len(b.body); keep > 0 {
+		if keep > n {
+			keep = n
+		}
+		b.body = append(b.body, p[:keep]...)
+	}
+	b.size += int64(n)
+	if err != nil {
+		b.flush(err)
+	}
+	return n, err
+}
+
+func (b *traceBody) Close() error {
+	b.flush(nil)
+	return b.ReadCloser.Close()
+}
+
+// flush writes the trace. err is the error that ended reading the body, nil if
+// the body was closed before.
+func (b *traceBody) flush(err error) {
+	b.once.Do(func() {
+		writeTraceBody(&b.buf, b.header, b.body, b.size)
+		switch {
+		case err == nil:
+			fmt.Fprintf(&b.buf, "[body closed after %d bytes]\n", b.size)
+		case !errors.Is(err, io.EOF):
+			fmt.Fprintf(&b.buf, "! reading response body: %v\n", err)
+		}
+		b.buf.WriteByte('\n')
+		b.t.write(b.buf.Bytes())
+	})
+}
+
+// drainBody reads the body and replaces it with an equivalent reader.
+func drainBody(body *io.ReadCloser) ([]byte, error) {
+	if *body == nil || *body == http.NoBody {
+		return nil, nil
+	}
+	b, err := io.ReadAll(*body)
+	(*body).Close()
+	*body = io.NopCloser(bytes.NewReader(b))
+	return b, err
+}
+
+func writeTraceHeaders(buf *bytes.Buffer, prefix string, header http.Header) {
+	keys := make([]string, 0, len(header))
+	for k := range header {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		for _, v := range header[k] {
+			if isSensitiveHeader(k) {
+				v = "[REDACTED]"
+			}
+			fmt.Fprintf(buf, "%s%s: %s\n", prefix, k, v)
+		}
+	}
+	buf.WriteString(prefix + "\n")
+}
+
+func isSensitiveHeader(name string) bool {
+	name = strings.ToLower(name)
+	for _, s := range []string{"authorization", "api-key", "apikey", "cookie", "token"} {
+		if strings.Contains(name, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// writeTraceBody writes body, the start of a body of size bytes.
+func writeTraceBody(buf *bytes.Buffer, header http.Header, body []byte, size int64) {
+	if size == 0 {
+		return
+	}
+	truncated := size > int64(len(body)) || len(body) > maxTraceBodyBytes
+	if len(body) > maxTraceBodyBytes {
+		body = body[:maxTraceBodyBytes]
+	}
+	if !isTextContent(header.Get("Content-Type")) || !validText(body, truncated) {
+		fmt.Fprintf(buf, "[binary body, %d bytes]\n", size)
+		return
+	}
+	if truncated {
+		fmt.Fprintf(buf, "%s...(truncated, %d bytes total)\n", body, size)
+		return
+	}
+	buf.Write(body)
+	buf.WriteByte('\n')
+}
+
+// validText returns true if b is valid UTF-8. A rune cut at the end of a
+// truncated body is ignored.
+func validText(b []byte, truncated bool) bool {
+	for i := 0; truncated && i < utf8.UTFMax-1 && len(b) > 0 && !utf8.Valid(b); i++ {
+		b = b[:len(b)-1]
+	}
+	return utf8.Valid(b)
+}
+
+// isStreamingContent returns true for bodies that are streamed until the
+// server closes them, like server-sent events.
+func isStreamingContent(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	return err == nil && mediaType == "text/event-stream"
+}
+
+func isTextContent(contentType string) bool {
+	if contentType == "" {
+		return true
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return strings.HasPrefix(mediaType, "text/") ||
+		strings.HasSuffix(mediaType, "json") ||
+		strings.HasSuffix(mediaType, "+xml") ||
+		mediaType == "application/xml" ||
+		mediaType == "application/x-www-form-urlencoded"
+}
@@ -0,0 +1,129 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package kibana
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/logp"
+)
+
+func debugLogger() *logp.Logger {
+	return logp.NewLogger("kibana", zap.WrapCore(func(zapcore.Core) zapcore.Core {
+		core, _ := observer.New(zapcore.DebugLevel)
+		return core
+	}))
+}
+
+func TestWithHTTPTrace(t *testing.T) {
+	ctx, cn := context.WithCancel(context.Background())
+	defer cn()
+
+	kibanaTS := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case fleetAgentPoliciesAPI:
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write(fleetCreatePolicyResponse)
+		case "/binary":
+			w.Header().Set("Content-Type", "application/octet-stream")
+			_, _ = w.Write([]byte{0x00, 0xff, 0x10})
+		case "/large":
+			w.Header().Set("Content-Type", "text/plain")
+			_, _ = w.Write([]byte(strings.Repeat("é", maxTraceBodyBytes)))
+		case "/events":
+			w.Header().Set("Content-Type", "text/event-stream")
+			_, _ = w.Write([]byte("data: {}\n\n"))
+		}
+	}))
+	defer kibanaTS.Close()
+
+	var trace bytes.Buffer
+	client, err := NewKibanaClient(config.MustNewConfigFrom(fmt.Sprintf(`
+host: %s
+api_key: secret-key
+ignoreversion: true
+`, kibanaTS.Listener.Addr().String())), binaryName, v, commit, buildTime, WithHTTPTrace(&trace), WithLogger(debugLogger()))
+	require.NoError(t, err)
+
+	policy, err := client.CreatePolicy(ctx, AgentPolicy{Name: "test policy", Namespace: "default"})
+	require.NoError(t, err)
+	assert.NotEmpty(t, policy.ID, "response body must still be readable")
+
+	dump := trace.String()
+	assert.Contains(t, dump, "> POST "+fleetAgentPoliciesAPI+" HTTP/1.1\n")
+	assert.Contains(t, dump, "> Authorization: [REDACTED]\n")
+	assert.NotContains(t, dump, "secret-key")
+	assert.NotContains(t, dump, "c2VjcmV0LWtleQ") // base64 of the API key
+	assert.Contains(t, dump, `"name":"test policy"`)
+	assert.Contains(t, dump, "< HTTP/1.1 200 OK\n")
+	assert.Contains(t, dump, string(fleetCreatePolicyResponse[:40]))
+
+	send := func(path string) {
+		t.Helper()
+		trace.Reset()
+		resp, err := client.Connection.Send(http.MethodGet, path, nil, nil, nil)
+		require.NoError(t, err)
+		_, err = io.Copy(io.Discard, resp.Body)
+		require.NoError(t, err)
+		require.NoError(t, resp.Body.Close())
+	}
+
+	send("/binary")
+	assert.True(t, strings.HasSuffix(trace.String(), "[binary body, 3 bytes]\n\n"), trace.String())
+
+	send("/large")
+	assert.Contains(t, trace.String(), "...(truncated, 8192 bytes total)\n")
+	assert.NotContains(t, trace.String(), "[binary body")
+
+	send("/events")
+	assert.True(t, strings.HasSuffix(trace.String(), "[streamed body]\n\n"), trace.String())
+
+	trace.Reset()
+	resp, err := client.Connection.Send(http.MethodGet, "/large", nil, nil, nil)
+	require.NoError(t, err)
+	_, err = resp.Body.Read(make([]byte, 10))
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	assert.Contains(t, trace.String(), "[body closed after ")
+}
+
+func TestWithHTTPTraceDebugDisabled(t *testing.T) {
+	var trace bytes.Buffer
+	client, err := createTestServerAndClient(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{}`))
+	}, WithHTTPTrace(&trace), WithLogger(logp.NewLogger("kibana")))
+	require.NoError(t, err)
+
+	_, _, err = client.Connection.Request(http.MethodGet, "/api/test", nil, nil, nil)
+	require.NoError(t, err)
+	assert.Empty(t, trace.String(), "nothing is traced without debug logging")
+}
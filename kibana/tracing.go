@@ -0,0 +1,101 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package kibana
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+)
+
+// Tracer starts a span for every API call sent to Kibana. It mirrors the part
+// of the OpenTelemetry trace API used by the client, so an OpenTelemetry
+// tracer can be plugged in with a small adapter without the client depending
+// on the OpenTelemetry modules:
+//
+//	type otelTracer struct{ trace.Tracer }
+//
+//	func (t otelTracer) Start(ctx context.Context, name string) (context.Context, kibana.Span) {
+//		ctx, span := t.Tracer.Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient))
+//		return ctx, otelSpan{span}
+//	}
+//
+// where otelSpan converts SetAttribute to span.SetAttributes and forwards
+// RecordError and End.
+type Tracer interface {
+	// Start starts a span and returns a context holding it. The context is
+	// used to send the request, so an instrumented transport sees the span
+	// as parent.
+	Start(ctx context.Context, name string) (context.Context, Span)
+}
+
+// Span is a span started by a Tracer.
+type Span interface {
+	// SetAttribute sets an attribute of the span. value is a string or an
+	// int.
+	SetAttribute(key string, value any)
+	// RecordError records that the call failed with err.
+	RecordError(err error)
+	// End ends the span, its duration is the duration of the call.
+	End()
+}
+
+// Attributes set on the spans of Kibana API calls. They follow the
+// OpenTelemetry semantic conventions for HTTP clients.
+const (
+	SpanAttributeMethod     = "http.request.method"
+	SpanAttributePath       = "url.path"
+	SpanAttributeServer     = "server.address"
+	SpanAttributeStatusCode = "http.response.status_code"
+)
+
+// WithTracer traces every API call with tracer. A span covers the whole call,
+// including retries and failovers to other hosts. Spans are named
+// "Kibana METHOD" and hold the method, path, server and response status code.
+func WithTracer(tracer Tracer) ClientOption {
+	return func(client *Client) {
+		client.Connection.tracer = tracer
+	}
+}
+
+// startSpan starts the span of an API call if tracing is enabled. The
+// returned function ends it with the outcome of the call.
+func (conn *Connection) startSpan(ctx context.Context, method, extraPath string) (context.Context, func(*http.Response, error)) {
+	if conn.tracer == nil {
+		return ctx, func(*http.Response, error) {}
+	}
+
+	ctx, span := conn.tracer.Start(ctx, "Kibana "+method)
+	span.SetAttribute(SpanAttributeMethod, method)
+	span.SetAttribute(SpanAttributePath, extraPath)
+	if u, err := url.Parse(conn.URL); err == nil {
+		span.SetAttribute(SpanAttributeServer, u.Hostname())
+	}
+	return ctx, func(resp *http.Response, err error) {
+		if resp != nil {
+			span.SetAttribute(SpanAttributeStatusCode, resp.StatusCode)
+			if err == nil && resp.StatusCode >= http.StatusBadRequest {
+				err = &APIError{StatusCode: resp.StatusCode}
+			}
+		}
+		if err != nil {
+			span.RecordError(err)
+		}
+		span.End()
+	}
+}
@@ -0,0 +1,96 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package kibana
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type spanKey struct{}
+
+type testTracer struct {
+	mu    sync.Mutex
+	spans []*testSpan
+}
+
+func (t *testTracer) Start(ctx context.Context, name string) (context.Context, Span) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	span := &testSpan{name: name, attributes: map[string]any{}}
+	t.spans = append(t.spans, span)
+	return context.WithValue(ctx, spanKey{}, span), span
+}
+
+type testSpan struct {
+	name       string
+	attributes map[string]any
+	errs       []error
+	ended      bool
+}
+
+func (s *testSpan) SetAttribute(key string, value any) { s.attributes[key] = value }
+func (s *testSpan) RecordError(err error)              { s.errs = append(s.errs, err) }
+func (s *testSpan) End()                               { s.ended = true }
+
+func TestTracer(t *testing.T) {
+	tracer := &testTracer{}
+	var inSpan bool
+	client, err := createTestServerAndClient(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/missing" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte(`{}`))
+	}, WithTracer(tracer), WithInterceptors(InterceptorFuncs{
+		Request: func(req *http.Request) error {
+			inSpan = req.Context().Value(spanKey{}) != nil
+			return nil
+		},
+	}))
+	require.NoError(t, err)
+	tracer.spans = nil
+
+	_, err = client.Connection.SendWithContext(context.Background(), http.MethodPost, "/api/test", nil, nil, nil)
+	require.NoError(t, err)
+	assert.True(t, inSpan, "the request must be sent with the span context")
+
+	_, _, err = client.Connection.Request(http.MethodGet, "/api/missing", nil, nil, nil)
+	assert.True(t, IsNotFound(err))
+
+	require.Len(t, tracer.spans, 2)
+	span := tracer.spans[0]
+	assert.Equal(t, "Kibana POST", span.name)
+	assert.True(t, span.ended)
+	assert.Empty(t, span.errs)
+	assert.Equal(t, http.MethodPost, span.attributes[SpanAttributeMethod])
+	assert.Equal(t, "/api/test", span.attributes[SpanAttributePath])
+	assert.Equal(t, "127.0.0.1", span.attributes[SpanAttributeServer])
+	assert.Equal(t, http.StatusOK, span.attributes[SpanAttributeStatusCode])
+
+	span = tracer.spans[1]
+	assert.True(t, span.ended)
+	assert.Equal(t, http.StatusNotFound, span.attributes[SpanAttributeStatusCode])
+	require.Len(t, span.errs, 1)
+	assert.True(t, IsNotFound(span.errs[0]))
+}
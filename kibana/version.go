@@ -0,0 +1,80 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package kibana
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/elastic/elastic-agent-libs/version"
+)
+
+// WithVersionPath reads the Kibana version from path instead of the status
+// API. The response must have the format of the status API response.
+func WithVersionPath(path string) ClientOption {
+	return func(client *Client) {
+		client.versionPath = path
+	}
+}
+
+// WithServerVersion sets the Kibana version. The client does not read the
+// version from Kibana.
+func WithServerVersion(v version.V) ClientOption {
+	return func(client *Client) {
+		client.Version = v
+		client.versionKnown = true
+	}
+}
+
+// WithLazyVersion defers reading the Kibana version from the constructor to
+// the first request sent by the client, or the first call to GetVersion.
+// Requests fail while the version can not be read, the next request tries
+// again. It has no effect if IgnoreVersion is set or the version is set with
+// WithServerVersion.
+func WithLazyVersion() ClientOption {
+	return func(client *Client) {
+		client.lazyVersion = true
+	}
+}
+
+// versionProbe reads the Kibana version once it is needed. A nil probe does
+// nothing.
+type versionProbe struct {
+	mu    sync.Mutex
+	done  bool
+	probe func(context.Context) error
+}
+
+// run reads the version if it was not read yet.
+func (p *versionProbe) run(ctx context.Context) error {
+	if p == nil {
+		return nil
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.done {
+		return nil
+	}
+	if err := p.probe(ctx); err != nil {
+		return fmt.Errorf("fail to get the Kibana version: %w", err)
+	}
+	p.done = true
+	return nil
+}
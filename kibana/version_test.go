@@ -0,0 +1,127 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package kibana
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/version"
+)
+
+func TestClientVersionProbe(t *testing.T) {
+	var (
+		mu          sync.Mutex
+		paths       []string
+		healthy     = true
+		healthPath  = "/gateway/health"
+		kibanaReply = `{"version":{"number":"8.12.0","build_snapshot":false}}`
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		paths = append(paths, r.URL.Path)
+		switch r.URL.Path {
+		case statusAPI:
+			w.WriteHeader(http.StatusForbidden)
+		case healthPath:
+			if !healthy {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			_, _ = w.Write([]byte(kibanaReply))
+		default:
+			_, _ = w.Write([]byte(`{}`))
+		}
+	}))
+	defer srv.Close()
+
+	requested := func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		p := paths
+		paths = nil
+		return p
+	}
+	setHealthy := func(h bool) {
+		mu.Lock()
+		defer mu.Unlock()
+		healthy = h
+	}
+	newClient := func(opts ...ClientOption) (*Client, error) {
+		cfg := fmt.Sprintf("protocol: http\nhost: %s\n", srv.Listener.Addr().String())
+		return NewKibanaClient(config.MustNewConfigFrom(cfg), binaryName, v, commit, buildTime, opts...)
+	}
+
+	t.Run("status API not allowed", func(t *testing.T) {
+		_, err := newClient()
+		require.ErrorContains(t, err, "fail to get the Kibana version")
+		assert.Equal(t, []string{statusAPI}, requested())
+	})
+
+	t.Run("custom path", func(t *testing.T) {
+		client, err := newClient(WithVersionPath(healthPath))
+		require.NoError(t, err)
+		got := client.GetVersion()
+		assert.Equal(t, "8.12.0", got.String())
+		assert.Equal(t, []string{healthPath}, requested())
+	})
+
+	t.Run("explicit version", func(t *testing.T) {
+		client, err := newClient(WithServerVersion(*version.MustNew("8.11.1")), WithLazyVersion())
+		require.NoError(t, err)
+		assert.Empty(t, requested(), "no request must be sent at construction")
+		got := client.GetVersion()
+		assert.Equal(t, "8.11.1", got.String())
+
+		_, _, err = client.Request(http.MethodGet, "/api/foo", nil, nil, nil)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"/api/foo"}, requested())
+	})
+
+	t.Run("lazy", func(t *testing.T) {
+		setHealthy(false)
+		client, err := newClient(WithVersionPath(healthPath), WithLazyVersion())
+		require.NoError(t, err)
+		assert.Empty(t, requested(), "no request must be sent at construction")
+		assert.False(t, client.Version.IsValid())
+
+		_, err = client.Connection.SendWithContext(context.Background(), http.MethodGet, "/api/foo", nil, nil, nil)
+		require.ErrorContains(t, err, "fail to get the Kibana version")
+		assert.Equal(t, []string{healthPath}, requested(), "the request must not be sent without version")
+
+		setHealthy(true)
+		_, _, err = client.Request(http.MethodGet, "/api/foo", nil, nil, nil)
+		require.NoError(t, err)
+		assert.Equal(t, []string{healthPath, "/api/foo"}, requested())
+		got := client.GetVersion()
+		assert.Equal(t, "8.12.0", got.String())
+
+		_, _, err = client.Request(http.MethodGet, "/api/bar", nil, nil, nil)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"/api/bar"}, requested(), "the version is only read once")
+	})
+}
@@ -0,0 +1,56 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package kibana
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// Warmup establishes a connection to Kibana ahead of the first request, so
+// that the TLS handshake and protocol negotiation do not delay it. If the
+// version is read lazily (WithLazyVersion) it is read now, otherwise a request
+// is sent to the version path and any response is accepted. The connection is
+// kept in the pool of idle connections of the HTTP client.
+//
+// Warmup is safe to call concurrently, it does nothing once it has succeeded.
+// If it fails the client remains usable and Warmup can be called again.
+func (client *Client) Warmup(ctx context.Context) error {
+	client.warmupMu.Lock()
+	defer client.warmupMu.Unlock()
+	if client.warm {
+		return nil
+	}
+
+	if client.Connection.versionProbe != nil {
+		if err := client.Connection.versionProbe.run(ctx); err != nil {
+			return err
+		}
+	} else {
+		resp, err := client.Connection.sendRequest(ctx, http.MethodGet, client.versionPath, nil, nil, nil)
+		if err != nil {
+			return fmt.Errorf("error warming up the connection to Kibana: %w", err)
+		}
+		// The body must be read for the connection to be reused.
+		_, _, _ = client.Connection.readResponse(resp)
+	}
+
+	client.warm = true
+	return nil
+}
@@ -0,0 +1,106 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package kibana
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/version"
+)
+
+func TestClientWarmup(t *testing.T) {
+	var (
+		connections   atomic.Int32
+		statusCalls   atomic.Int32
+		statusHealthy atomic.Bool
+	)
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == statusAPI {
+			statusCalls.Add(1)
+			if !statusHealthy.Load() {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			_, _ = w.Write([]byte(`{"version":{"number":"8.12.0"}}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	srv.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			connections.Add(1)
+		}
+	}
+	srv.StartTLS()
+	defer srv.Close()
+
+	cfg := fmt.Sprintf("protocol: https\nhost: %s\nssl.verification_mode: none\n", srv.Listener.Addr().String())
+	client, err := NewKibanaClient(config.MustNewConfigFrom(cfg), binaryName, v, commit, buildTime, WithLazyVersion())
+	require.NoError(t, err)
+	assert.Zero(t, connections.Load(), "no connection must be opened at construction")
+
+	// A failed warm-up leaves the client usable.
+	err = client.Warmup(context.Background())
+	require.ErrorContains(t, err, "fail to get the Kibana version")
+	assert.EqualValues(t, 1, statusCalls.Load())
+
+	statusHealthy.Store(true)
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, client.Warmup(context.Background()))
+		}()
+	}
+	wg.Wait()
+	assert.EqualValues(t, 2, statusCalls.Load(), "the version must be read once")
+	assert.EqualValues(t, 1, connections.Load())
+	got := client.GetVersion()
+	assert.Equal(t, "8.12.0", got.String())
+
+	// The first request reuses the warm connection.
+	_, _, err = client.Request(http.MethodGet, "/api/foo", nil, nil, nil)
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, connections.Load())
+	assert.EqualValues(t, 2, statusCalls.Load())
+}
+
+func TestClientWarmupKnownVersion(t *testing.T) {
+	var requests atomic.Int32
+	client, err := createTestServerAndClient(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+	}, WithServerVersion(*version.MustNew("8.12.0")), WithVersionPath("/api/ping"))
+	require.NoError(t, err)
+	assert.Zero(t, requests.Load())
+
+	require.NoError(t, client.Warmup(context.Background()))
+	require.NoError(t, client.Warmup(context.Background()))
+	assert.EqualValues(t, 1, requests.Load())
+}
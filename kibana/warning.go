@@ -0,0 +1,144 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package kibana
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/elastic/elastic-agent-libs/logp"
+)
+
+// WarningHandler is called with the warnings Kibana returned in the Warning
+// response headers of an operation, e.g. for deprecated endpoints. op is the
+// request method and path, like "GET /api/fleet/agents".
+type WarningHandler func(op string, warnings []string)
+
+// WithWarningHandler registers a handler for the warnings returned by Kibana.
+// Handlers registered by multiple options are called in order.
+func WithWarningHandler(handler WarningHandler) ClientOption {
+	return func(client *Client) {
+		client.Connection.addWarningHandler(handler)
+	}
+}
+
+// WithWarningLogger logs the warnings returned by Kibana to log.
+func WithWarningLogger(log *logp.Logger) ClientOption {
+	return WithWarningHandler(func(op string, warnings []string) {
+		log.Warnw("Kibana returned warnings", "kibana.operation", op, "kibana.warnings", warnings)
+	})
+}
+
+func (conn *Connection) addWarningHandler(handler WarningHandler) {
+	if handler == nil {
+		return
+	}
+	prev := conn.warningHandler
+	if prev == nil {
+		conn.warningHandler = handler
+		return
+	}
+	conn.warningHandler = func(op string, warnings []string) {
+		prev(op, warnings)
+		handler(op, warnings)
+	}
+}
+
+func (conn *Connection) handleWarnings(op string, resp *http.Response) {
+	if conn.warningHandler == nil {
+		return
+	}
+	if warnings := parseWarnings(resp.Header.Values("Warning")); len(warnings) > 0 {
+		conn.warningHandler(op, warnings)
+	}
+}
+
+// parseWarnings returns the warn-text of all warnings in the given Warning
+// header values. A header can hold multiple comma separated warnings of the
+// form `299 Kibana-8.12.0 "text" ["date"]`. Values that do not follow this
+// format are returned as is.
+func parseWarnings(values []string) []string {
+	var warnings []string
+	for _, v := range values {
+		parsed, ok := parseWarningValue(v)
+		if !ok {
+			if v = strings.TrimSpace(v); v != "" {
+				warnings = append(warnings, v)
+			}
+			continue
+		}
+		warnings = append(warnings, parsed...)
+	}
+	return warnings
+}
+
+func parseWarningValue(v string) ([]string, bool) {
+	var texts []string
+	for {
+		v = strings.TrimLeft(v, " ,")
+		if v == "" {
+			return texts, len(texts) > 0
+		}
+
+		// warn-code and warn-agent
+		for i := 0; i < 2; i++ {
+			end := strings.IndexByte(v, ' ')
+			if end <= 0 {
+				return nil, false
+			}
+			v = strings.TrimLeft(v[end:], " ")
+		}
+
+		text, rest, ok := parseQuoted(v)
+		if !ok {
+			return nil, false
+		}
+		texts = append(texts, text)
+
+		// optional warn-date
+		v = strings.TrimLeft(rest, " ")
+		if strings.HasPrefix(v, `"`) {
+			if _, v, ok = parseQuoted(v); !ok {
+				return nil, false
+			}
+		}
+	}
+}
+
+// parseQuoted parses the quoted-string at the start of s and returns its
+// unescaped content and the remainder of s.
+func parseQuoted(s string) (text, rest string, ok bool) {
+	if !strings.HasPrefix(s, `"`) {
+		return "", s, false
+	}
+	var b strings.Builder
+	for i := 1; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '\\':
+			i++
+			if i < len(s) {
+				b.WriteByte(s[i])
+			}
+		case '"':
+			return b.String(), s[i+1:], true
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", s, false
+}
@@ -0,0 +1,69 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package kibana
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWarningHandler(t *testing.T) {
+	const id = "elastic-agent-managed-ep"
+
+	ctx, cn := context.WithCancel(context.Background())
+	defer cn()
+
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case fmt.Sprintf(fleetAgentPolicyAPI, id):
+			w.Header().Add("Warning", `299 Kibana-8.12.0 "The agent policies API is deprecated"`)
+			w.Header().Add("Warning", `299 Kibana-8.12.0 "Use \"package_policies\" instead", 299 Kibana-8.12.0 "Removal in 9.0" "Wed, 21 Feb 2024 07:28:00 GMT"`)
+			_, _ = w.Write(fleetGetPolicyResponse)
+		}
+	}
+
+	var ops []string
+	var warnings [][]string
+	client, err := createTestServerAndClient(handler, WithWarningHandler(func(op string, w []string) {
+		ops = append(ops, op)
+		warnings = append(warnings, w)
+	}))
+	require.NoError(t, err)
+
+	policy, err := client.GetPolicy(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, id, policy.ID)
+
+	require.Len(t, ops, 1, "the status request does not return warnings")
+	assert.Equal(t, "GET "+fmt.Sprintf(fleetAgentPolicyAPI, id), ops[0])
+	assert.Equal(t, []string{
+		"The agent policies API is deprecated",
+		`Use "package_policies" instead`,
+		"Removal in 9.0",
+	}, warnings[0])
+}
+
+func TestParseWarnings(t *testing.T) {
+	assert.Equal(t, []string{"not a warning"}, parseWarnings([]string{"not a warning"}))
+	assert.Empty(t, parseWarnings([]string{""}))
+}
@@ -0,0 +1,72 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package logp
+
+import (
+	"go.uber.org/zap/zapcore"
+)
+
+// callerCore removes the caller from entries below a minimum level. zap
+// looks up the caller before the entry reaches the core, so this does not
+// save the cost of the lookup.
+type callerCore struct {
+	core  zapcore.Core
+	level zapcore.Level
+}
+
+// callerWrapper wraps core if the caller is only included from a level above
+// debug.
+func callerWrapper(core zapcore.Core, cfg LevelThresholdConfig) zapcore.Core {
+	if !cfg.Enabled || cfg.Level <= DebugLevel {
+		return core
+	}
+	return &callerCore{core: core, level: cfg.Level.ZapLevel()}
+}
+
+// Enabled returns whether a given logging level is enabled when logging a
+// message.
+func (c *callerCore) Enabled(level zapcore.Level) bool {
+	return c.core.Enabled(level)
+}
+
+// With adds structured context to the Core.
+func (c *callerCore) With(fields []zapcore.Field) zapcore.Core {
+	return &callerCore{core: c.core.With(fields), level: c.level}
+}
+
+// Check determines whether the supplied Entry should be logged.
+func (c *callerCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+// Write writes the entry to the wrapped core, without caller if its level is
+// below the minimum.
+func (c *callerCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	if ent.Level < c.level {
+		ent.Caller = zapcore.EntryCaller{}
+	}
+	return c.core.Write(ent, fields)
+}
+
+// Sync flushes buffered logs (if any).
+func (c *callerCore) Sync() error {
+	return c.core.Sync()
+}
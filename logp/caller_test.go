@@ -0,0 +1,90 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package logp
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestLoggerCallerAndStacktraceLevels(t *testing.T) {
+	require.NoError(t, DevelopmentSetup(ToObserverOutput(), WithCaller(WarnLevel), WithStacktrace(ErrorLevel)))
+
+	log := NewLogger("caller")
+	log.Info("info message")
+	log.Warnw("warn message", "x", 1)
+	log.With("y", 2).Errorf("error message %d", 3)
+
+	logs := ObserverLogs().TakeAll()
+	require.Len(t, logs, 3)
+
+	info, warn, errEntry := logs[0].Entry, logs[1].Entry, logs[2].Entry
+	assert.False(t, info.Caller.Defined, "no caller on info")
+	assert.Empty(t, info.Stack, "no stack trace on info")
+
+	require.True(t, warn.Caller.Defined, "caller on warn")
+	assert.Equal(t, "caller_test.go", filepath.Base(warn.Caller.File), "caller must be the call site")
+	assert.Empty(t, warn.Stack, "no stack trace on warn")
+
+	require.True(t, errEntry.Caller.Defined, "caller on error")
+	assert.Equal(t, "caller_test.go", filepath.Base(errEntry.Caller.File))
+	require.NotEmpty(t, errEntry.Stack, "stack trace on error")
+	assert.True(t, strings.Contains(strings.SplitN(errEntry.Stack, "\n", 2)[0], "TestLoggerCallerAndStacktraceLevels"),
+		"stack trace must start at the call site: %s", errEntry.Stack)
+}
+
+func TestLoggerCallerDefaults(t *testing.T) {
+	require.NoError(t, DevelopmentSetup(ToObserverOutput()))
+
+	NewLogger("caller").Info("info message")
+	NewLogger("caller").Error("error message")
+
+	logs := ObserverLogs().TakeAll()
+	require.Len(t, logs, 2)
+	for _, l := range logs {
+		assert.True(t, l.Caller.Defined)
+		assert.Equal(t, "caller_test.go", filepath.Base(l.Caller.File))
+		assert.Empty(t, l.Stack, "stack traces are disabled by default")
+	}
+}
+
+func TestLoggerCallerOutputs(t *testing.T) {
+	output, outputLogs := observer.New(zapcore.DebugLevel)
+	cfg := DefaultConfig(DefaultEnvironment)
+	cfg.Level = DebugLevel
+	cfg.toObserver = true
+	cfg.Caller = LevelThresholdConfig{Enabled: true, Level: WarnLevel}
+	require.NoError(t, ConfigureWithOutputs(cfg, output))
+
+	log := NewLogger("caller")
+	log.Info("info message")
+	log.Warn("warn message")
+
+	for name, logs := range map[string]*observer.ObservedLogs{"sink": ObserverLogs(), "output": outputLogs} {
+		entries := logs.TakeAll()
+		require.Len(t, entries, 2, name)
+		assert.False(t, entries[0].Caller.Defined, "no caller on info in %s", name)
+		assert.True(t, entries[1].Caller.Defined, "caller on warn in %s", name)
+	}
+}
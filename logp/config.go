@@ -18,6 +18,7 @@
 package logp
 
 import (
+	"io"
 	"time"
 )
 
@@ -26,20 +27,32 @@ import (
 type Config struct {
 	Beat      string   `config:",ignore"`   // Name of the Beat (for default file name).
 	Level     Level    `config:"level"`     // Logging level (error, warning, info, debug).
-	Selectors []string `config:"selectors"` // Selectors for debug level logging.
+	Selectors []string `config:"selectors"` // Selectors for debug level logging. Selectors prefixed with "-" are excluded.
 
 	toObserver  bool
 	toIODiscard bool
+	toWriters   []io.Writer
 	ToStderr    bool `config:"to_stderr" yaml:"to_stderr"`
 	ToSyslog    bool `config:"to_syslog" yaml:"to_syslog"`
 	ToFiles     bool `config:"to_files" yaml:"to_files"`
 	ToEventLog  bool `config:"to_eventlog" yaml:"to_eventlog"`
 
-	Files   FileConfig    `config:"files"`
-	Metrics MetricsConfig `config:"metrics"`
+	Files      FileConfig            `config:"files"`
+	Metrics    MetricsConfig         `config:"metrics"`
+	Truncation FieldTruncationConfig `config:"truncation"`
+
+	// Caller adds the file and line of the call site to events from the
+	// configured level up, Stacktrace adds a stack trace. The caller is
+	// looked up for all events if enabled, it is only omitted from the
+	// events below the level.
+	Caller     LevelThresholdConfig `config:"caller"`
+	Stacktrace LevelThresholdConfig `config:"stacktrace"`
+
+	// DuplicateFields selects how fields sharing a key within one event are
+	// handled, by default they are all encoded.
+	DuplicateFields DuplicateFields `config:"duplicate_fields" yaml:"duplicate_fields"`
 
 	environment Environment
-	addCaller   bool // Adds package and line number info to messages.
 	development bool // Controls how DPanic behaves.
 }
 
@@ -63,6 +76,27 @@ type MetricsConfig struct {
 	Period  time.Duration `config:"period"`
 }
 
+// FieldTruncationConfig limits the size of single field values in log events.
+// Strings and byte slices larger than MaxValueBytes are cut and marked as
+// truncated, maps, slices and objects whose JSON encoding is larger than
+// MaxCollectionBytes are replaced by a summary. A limit of 0 disables it.
+type FieldTruncationConfig struct {
+	MaxValueBytes      int  `config:"max_value_bytes" yaml:"max_value_bytes"`
+	MaxCollectionBytes int  `config:"max_collection_bytes" yaml:"max_collection_bytes"`
+	SkipErrors         bool `config:"skip_errors" yaml:"skip_errors"` // Do not truncate error and higher level events.
+}
+
+// Enabled returns true if any limit is set.
+func (c FieldTruncationConfig) Enabled() bool {
+	return c.MaxValueBytes > 0 || c.MaxCollectionBytes > 0
+}
+
+// LevelThresholdConfig enables a feature for events of Level and above.
+type LevelThresholdConfig struct {
+	Enabled bool  `config:"enabled" yaml:"enabled"`
+	Level   Level `config:"level" yaml:"level"`
+}
+
 const (
 	defaultLevel = InfoLevel
 )
@@ -83,8 +117,9 @@ func DefaultConfig(environment Environment) Config {
 			Enabled: true,
 			Period:  30 * time.Second,
 		},
+		Caller:      LevelThresholdConfig{Enabled: true, Level: DebugLevel},
+		Stacktrace:  LevelThresholdConfig{Enabled: false, Level: ErrorLevel},
 		environment: environment,
-		addCaller:   true,
 	}
 }
 
@@ -21,12 +21,15 @@ import (
 	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"io/ioutil"
 	golog "log"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"sync/atomic"
+	"time"
 	"unsafe"
 
 	"go.uber.org/zap"
@@ -39,19 +42,17 @@ import (
 	"github.com/elastic/elastic-agent-libs/paths"
 )
 
+// syncTimeout bounds how long Sync and Close wait for the outputs to flush
+// buffered entries, so a stalled output cannot block shutdown forever.
+const syncTimeout = 5 * time.Second
+
 var (
 	_log          unsafe.Pointer // Pointer to a coreLogger. Access via atomic.LoadPointer.
 	_defaultGoLog = golog.Writer()
 )
 
 func init() {
-	storeLogger(&coreLogger{
-		selectors:    map[string]struct{}{},
-		rootLogger:   zap.NewNop(),
-		globalLogger: zap.NewNop(),
-		level:        zap.NewAtomicLevel(),
-		logger:       newLogger(zap.NewNop(), ""),
-	})
+	storeLogger(newNopCoreLogger(zap.NewAtomicLevel()))
 }
 
 type coreLogger struct {
@@ -61,6 +62,20 @@ type coreLogger struct {
 	logger       *Logger                // Logger that is the basis for all logp.Loggers.
 	level        zap.AtomicLevel        // The minimum level being printed
 	observedLogs *observer.ObservedLogs // Contains events generated while in observation mode (a testing mode).
+	closer       io.Closer              // Releases the output opened by logp, nil if there is nothing to release.
+
+	closeOnce sync.Once
+	closeErr  error
+}
+
+func newNopCoreLogger(level zap.AtomicLevel) *coreLogger {
+	return &coreLogger{
+		selectors:    map[string]struct{}{},
+		rootLogger:   zap.NewNop(),
+		globalLogger: zap.NewNop(),
+		level:        level,
+		logger:       newLogger(zap.NewNop(), ""),
+	}
 }
 
 // Configure configures the logp package.
@@ -74,6 +89,7 @@ func Configure(cfg Config) error {
 func ConfigureWithOutputs(cfg Config, outputs ...zapcore.Core) error {
 	var (
 		sink         zapcore.Core
+		closer       io.Closer
 		observedLogs *observer.ObservedLogs
 		err          error
 		level        zap.AtomicLevel
@@ -84,33 +100,47 @@ func ConfigureWithOutputs(cfg Config, outputs ...zapcore.Core) error {
 	if cfg.toObserver {
 		sink, observedLogs = observer.New(level)
 	} else {
-		sink, err = createLogOutput(cfg, level)
+		sink, closer, err = createLogOutput(cfg, level)
 	}
 	if err != nil {
 		return fmt.Errorf("failed to build log output: %w", err)
 	}
+	if len(cfg.toWriters) > 0 {
+		cores := []zapcore.Core{sink}
+		for _, w := range cfg.toWriters {
+			cores = append(cores, newCore(buildEncoder(cfg), zapcore.Lock(zapcore.AddSync(w)), level))
+		}
+		sink = newMultiCore(cores...)
+	}
+	sink = truncateWrapper(sink, cfg.Truncation)
+	sink = dedupeWrapper(sink, cfg.DuplicateFields)
 
 	// Default logger is always discard, debug level below will
 	// possibly re-enable it.
 	golog.SetOutput(ioutil.Discard)
 
-	// Enabled selectors when debug is enabled.
+	// Enabled selectors when debug is enabled. Selectors prefixed with "-"
+	// are excluded.
 	selectors := make(map[string]struct{}, len(cfg.Selectors))
 	if cfg.Level.Enabled(DebugLevel) && len(cfg.Selectors) > 0 {
+		included := 0
 		for _, sel := range cfg.Selectors {
-			selectors[strings.TrimSpace(sel)] = struct{}{}
+			sel = strings.TrimSpace(sel)
+			selectors[sel] = struct{}{}
+			if !strings.HasPrefix(sel, excludePrefix) {
+				included++
+			}
 		}
 
-		// Default to all enabled if no selectors are specified.
-		if len(selectors) == 0 {
+		// Default to all enabled if no selectors are specified, or if
+		// selectors are only excluded.
+		if included == 0 {
 			selectors["*"] = struct{}{}
 		}
 
 		// Re-enable the default go logger output when either stdlog
 		// or all selector is enabled.
-		_, stdlogEnabled := selectors["stdlog"]
-		_, allEnabled := selectors["*"]
-		if stdlogEnabled || allEnabled {
+		if selectorEnabled(selectors, "stdlog") {
 			golog.SetOutput(_defaultGoLog)
 		}
 
@@ -118,6 +148,7 @@ func ConfigureWithOutputs(cfg Config, outputs ...zapcore.Core) error {
 	}
 
 	sink = newMultiCore(append(outputs, sink)...)
+	sink = callerWrapper(sink, cfg.Caller)
 	root := zap.New(sink, makeOptions(cfg)...)
 	storeLogger(&coreLogger{
 		selectors:    selectors,
@@ -126,11 +157,14 @@ func ConfigureWithOutputs(cfg Config, outputs ...zapcore.Core) error {
 		logger:       newLogger(root, ""),
 		level:        level,
 		observedLogs: observedLogs,
+		closer:       closer,
 	})
 	return nil
 }
 
-func createLogOutput(cfg Config, enab zapcore.LevelEnabler) (zapcore.Core, error) {
+// createLogOutput builds the configured output. The returned io.Closer releases
+// the resources held by the output and is nil if there is nothing to release.
+func createLogOutput(cfg Config, enab zapcore.LevelEnabler) (zapcore.Core, io.Closer, error) {
 	switch {
 	case cfg.toIODiscard:
 		return makeDiscardOutput(cfg, enab)
@@ -161,7 +195,7 @@ func DevelopmentSetup(options ...Option) error {
 		Level:       DebugLevel,
 		ToStderr:    true,
 		development: true,
-		addCaller:   true,
+		Caller:      LevelThresholdConfig{Enabled: true, Level: DebugLevel},
 	}
 	for _, apply := range options {
 		apply(&cfg)
@@ -187,16 +221,64 @@ func ObserverLogs() *observer.ObservedLogs {
 }
 
 // Sync flushes any buffered log entries. Applications should take care to call
-// Sync before exiting.
+// Sync before exiting. Sync gives up and returns an error if the outputs do not
+// finish flushing within a bounded timeout.
 func Sync() error {
-	return loadLogger().rootLogger.Sync()
+	return loadLogger().sync()
+}
+
+// Close flushes any buffered log entries and releases the outputs opened by
+// logp (log files, syslog and event log handles). Afterwards all log entries
+// are discarded until the package is configured again. Outputs passed to
+// ConfigureWithOutputs are owned by the caller and are flushed, but not
+// closed. It is safe to call Close multiple times.
+func Close() error {
+	l := loadLogger()
+	err := l.close()
+
+	// Only swap in the no-op logger if nobody reconfigured logp in the meantime.
+	atomic.CompareAndSwapPointer(&_log, unsafe.Pointer(l), unsafe.Pointer(newNopCoreLogger(l.level)))
+	return err
+}
+
+func (l *coreLogger) sync() error {
+	done := make(chan error, 1)
+	go func() {
+		done <- l.rootLogger.Sync()
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(syncTimeout):
+		return fmt.Errorf("timeout after %v waiting for log outputs to flush", syncTimeout)
+	}
+}
+
+func (l *coreLogger) close() error {
+	l.closeOnce.Do(func() {
+		var errs []error
+		if err := l.sync(); err != nil {
+			errs = append(errs, err)
+		}
+		if l.closer != nil {
+			if err := l.closer.Close(); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		l.closeErr = errors.Join(errs...)
+	})
+	return l.closeErr
 }
 
 func makeOptions(cfg Config) []zap.Option {
 	var options []zap.Option
-	if cfg.addCaller {
+	if cfg.Caller.Enabled {
 		options = append(options, zap.AddCaller())
 	}
+	if cfg.Stacktrace.Enabled {
+		options = append(options, zap.AddStacktrace(cfg.Stacktrace.Level.ZapLevel()))
+	}
 	if cfg.development {
 		options = append(options, zap.Development())
 	}
@@ -209,34 +291,50 @@ func makeOptions(cfg Config) []zap.Option {
 	return options
 }
 
-func makeStderrOutput(cfg Config, enab zapcore.LevelEnabler) (zapcore.Core, error) {
+func makeStderrOutput(cfg Config, enab zapcore.LevelEnabler) (zapcore.Core, io.Closer, error) {
 	stderr := zapcore.Lock(os.Stderr)
-	return newCore(buildEncoder(cfg), stderr, enab), nil
+	return newCore(buildEncoder(cfg), stderr, enab), nil, nil
 }
 
-func makeDiscardOutput(cfg Config, enab zapcore.LevelEnabler) (zapcore.Core, error) {
+func makeDiscardOutput(cfg Config, enab zapcore.LevelEnabler) (zapcore.Core, io.Closer, error) {
 	discard := zapcore.AddSync(ioutil.Discard)
-	return newCore(buildEncoder(cfg), discard, enab), nil
+	return newCore(buildEncoder(cfg), discard, enab), nil, nil
 }
 
-func makeSyslogOutput(cfg Config, enab zapcore.LevelEnabler) (zapcore.Core, error) {
+func makeSyslogOutput(cfg Config, enab zapcore.LevelEnabler) (zapcore.Core, io.Closer, error) {
 	core, err := newSyslog(buildEncoder(cfg), enab)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
-	return wrappedCore(core), nil
+	closer, _ := core.(io.Closer)
+	return wrappedCore(core), closer, nil
 }
 
-func makeEventLogOutput(cfg Config, enab zapcore.LevelEnabler) (zapcore.Core, error) {
+// errEventLogUnsupported is returned when the eventlog output is configured on
+// a platform other than Windows.
+var errEventLogUnsupported = errors.New("eventlog is only supported on Windows")
+
+// makeEventLogOutput creates the Windows Event Log output. If the event source
+// can not be set up, it falls back to stderr.
+func makeEventLogOutput(cfg Config, enab zapcore.LevelEnabler) (zapcore.Core, io.Closer, error) {
 	core, err := newEventLog(cfg.Beat, buildEncoder(cfg), enab)
 	// nolint: staticcheck,nolintlint // the implementation is OS-specific and some implementations always return errors
 	if err != nil {
-		return nil, err
+		if errors.Is(err, errEventLogUnsupported) {
+			return nil, nil, err
+		}
+		fallback, closer, stderrErr := makeStderrOutput(cfg, enab)
+		if stderrErr != nil {
+			return nil, nil, stderrErr
+		}
+		newLogger(zap.New(fallback), "logp").Warnw("Can not log to the event log, logging to stderr instead", "error", err)
+		return fallback, closer, nil
 	}
-	return wrappedCore(core), nil
+	closer, _ := core.(io.Closer)
+	return wrappedCore(core), closer, nil
 }
 
-func makeFileOutput(cfg Config, enab zapcore.LevelEnabler) (zapcore.Core, error) {
+func makeFileOutput(cfg Config, enab zapcore.LevelEnabler) (zapcore.Core, io.Closer, error) {
 	filename := paths.Resolve(paths.Logs, filepath.Join(cfg.Files.Path, cfg.LogFilename()))
 
 	rotator, err := file.NewFileRotator(filename,
@@ -248,10 +346,10 @@ func makeFileOutput(cfg Config, enab zapcore.LevelEnabler) (zapcore.Core, error)
 		file.RedirectStderr(cfg.Files.RedirectStderr),
 	)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create file rotator: %w", err)
+		return nil, nil, fmt.Errorf("failed to create file rotator: %w", err)
 	}
 
-	return newCore(buildEncoder(cfg), rotator, enab), nil
+	return newCore(buildEncoder(cfg), rotator, enab), rotator, nil
 }
 
 func newCore(enc zapcore.Encoder, ws zapcore.WriteSyncer, enab zapcore.LevelEnabler) zapcore.Core {
@@ -18,13 +18,21 @@
 package logp
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"io/ioutil"
 	golog "log"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 )
 
 func TestLogger(t *testing.T) {
@@ -212,3 +220,75 @@ func TestLoggingECSFields(t *testing.T) {
 		}
 	}
 }
+
+func TestSyncFlushesBufferedOutput(t *testing.T) {
+	var buf bytes.Buffer
+	ws := &zapcore.BufferedWriteSyncer{
+		WS:            zapcore.AddSync(&buf),
+		FlushInterval: time.Hour, // Only flush when asked to.
+	}
+	defer func() { _ = ws.Stop() }()
+
+	cfg := DefaultConfig(DefaultEnvironment)
+	ToDiscardOutput()(&cfg)
+	output := zapcore.NewCore(zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig()), ws, zap.DebugLevel)
+	require.NoError(t, ConfigureWithOutputs(cfg, output))
+
+	logger := NewLogger("tester")
+	for i := 0; i < 10; i++ {
+		logger.Infof("message %d", i)
+	}
+	assert.Zero(t, buf.Len(), "entries should still be buffered")
+
+	require.NoError(t, Sync())
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if assert.Len(t, lines, 10) {
+		for i, line := range lines {
+			assert.Contains(t, line, fmt.Sprintf("message %d", i))
+		}
+	}
+
+	// Close flushes again and is safe to call more than once.
+	require.NoError(t, Close())
+	require.NoError(t, Close())
+
+	// Entries logged through new loggers are discarded after Close.
+	n := buf.Len()
+	NewLogger("tester").Info("after close")
+	require.NoError(t, Sync())
+	assert.Equal(t, n, buf.Len())
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) { return 0, errors.New("write failed") }
+
+func TestToWriter(t *testing.T) {
+	var buf bytes.Buffer
+	cfg := DefaultConfig(DefaultEnvironment)
+	ToDiscardOutput()(&cfg)
+	ToWriter(failingWriter{})(&cfg)
+	ToWriter(&buf)(&cfg)
+	require.NoError(t, Configure(cfg))
+	defer func() { _ = Close() }()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			NewLogger("tester").Infow("message", "n", i)
+		}(i)
+	}
+	wg.Wait()
+	require.NoError(t, Sync())
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 10)
+	for _, line := range lines {
+		var event map[string]interface{}
+		require.NoError(t, json.Unmarshal([]byte(line), &event), line)
+		assert.Equal(t, "message", event["message"])
+		assert.Equal(t, "tester", event["log.logger"])
+	}
+}
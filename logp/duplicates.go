@@ -0,0 +1,259 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package logp
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// DuplicateFields selects how fields sharing a key within one event are
+// handled. Duplicates happen when a field set with With is passed again when
+// logging, and make some JSON consumers fail.
+type DuplicateFields uint8
+
+const (
+	// DuplicateFieldsKeepAll encodes all fields, keys may repeat.
+	DuplicateFieldsKeepAll DuplicateFields = iota
+	// DuplicateFieldsKeepLast keeps the value set last, call site fields
+	// override context fields.
+	DuplicateFieldsKeepLast
+	// DuplicateFieldsKeepFirst keeps the value set first.
+	DuplicateFieldsKeepFirst
+	// DuplicateFieldsWarn keeps the value set last and lists the duplicate
+	// keys in the log.duplicate_fields field.
+	DuplicateFieldsWarn
+)
+
+// duplicateFieldsKey is the field listing the duplicate keys with
+// DuplicateFieldsWarn.
+const duplicateFieldsKey = "log.duplicate_fields"
+
+var duplicateFieldsStrings = map[DuplicateFields]string{
+	DuplicateFieldsKeepAll:   "keep_all",
+	DuplicateFieldsKeepLast:  "keep_last",
+	DuplicateFieldsKeepFirst: "keep_first",
+	DuplicateFieldsWarn:      "warn",
+}
+
+// String returns the name of the mode.
+func (d DuplicateFields) String() string {
+	if s, found := duplicateFieldsStrings[d]; found {
+		return s
+	}
+	return fmt.Sprintf("DuplicateFields(%d)", d)
+}
+
+// Unpack unmarshals a mode name to a DuplicateFields. This implements
+// ucfg.StringUnpacker.
+func (d *DuplicateFields) Unpack(str string) error {
+	str = strings.ToLower(str)
+	for mode, name := range duplicateFieldsStrings {
+		if name == str {
+			*d = mode
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid duplicate_fields mode '%v'", str)
+}
+
+// dedupeCore removes fields with duplicate keys before they are handed to
+// the wrapped core for encoding. Context fields are passed to the wrapped
+// core when added with With, the keys they set are kept to detect the event
+// fields overriding them.
+type dedupeCore struct {
+	// core holds the context fields, base is the core without them.
+	core zapcore.Core
+	base zapcore.Core
+	mode DuplicateFields
+
+	context     []zapcore.Field
+	contextKeys map[fieldScope]struct{}
+	namespaces  int
+}
+
+// dedupeWrapper wraps core if duplicate fields are removed.
+func dedupeWrapper(core zapcore.Core, mode DuplicateFields) zapcore.Core {
+	if mode == DuplicateFieldsKeepAll {
+		return core
+	}
+	return &dedupeCore{core: core, base: core, mode: mode}
+}
+
+// Enabled returns whether a given logging level is enabled when logging a
+// message.
+func (c *dedupeCore) Enabled(level zapcore.Level) bool {
+	return c.core.Enabled(level)
+}
+
+// With adds structured context to the Core.
+func (c *dedupeCore) With(fields []zapcore.Field) zapcore.Core {
+	context := make([]zapcore.Field, 0, len(c.context)+len(fields))
+	context = append(context, c.context...)
+	context = append(context, fields...)
+
+	keys := make(map[fieldScope]struct{}, len(c.contextKeys)+len(fields))
+	for k := range c.contextKeys {
+		keys[k] = struct{}{}
+	}
+	namespaces := c.namespaces
+	for _, f := range fields {
+		keys[fieldScope{namespace: namespaces, key: f.Key}] = struct{}{}
+		if f.Type == zapcore.NamespaceType {
+			namespaces++
+		}
+	}
+
+	return &dedupeCore{
+		core:        c.core.With(fields),
+		base:        c.base,
+		mode:        c.mode,
+		context:     context,
+		contextKeys: keys,
+		namespaces:  namespaces,
+	}
+}
+
+// Check determines whether the supplied Entry should be logged.
+func (c *dedupeCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+// Write removes duplicate fields and writes the entry to the wrapped core.
+// Only if a field overrides a context field, the context is encoded again
+// with the event.
+func (c *dedupeCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	if !c.overridesContext(fields) {
+		return c.core.Write(ent, c.dedupe(fields))
+	}
+
+	all := make([]zapcore.Field, 0, len(c.context)+len(fields))
+	all = append(all, c.context...)
+	all = append(all, fields...)
+	return c.base.Write(ent, c.dedupe(all))
+}
+
+// overridesContext returns true if one of fields has the key of a context
+// field in the same namespace.
+func (c *dedupeCore) overridesContext(fields []zapcore.Field) bool {
+	if len(c.contextKeys) == 0 {
+		return false
+	}
+	namespace := c.namespaces
+	for _, f := range fields {
+		if _, found := c.contextKeys[fieldScope{namespace: namespace, key: f.Key}]; found {
+			return true
+		}
+		if f.Type == zapcore.NamespaceType {
+			namespace++
+		}
+	}
+	return false
+}
+
+// Sync flushes buffered logs (if any).
+func (c *dedupeCore) Sync() error {
+	return c.core.Sync()
+}
+
+// fieldScope identifies a key within the namespace it is set in.
+type fieldScope struct {
+	namespace int
+	key       string
+}
+
+// dedupe returns fields without duplicates. Fields added after a namespace
+// are only compared with each other. fields is returned as is if it has no
+// duplicates.
+func (c *dedupeCore) dedupe(fields []zapcore.Field) []zapcore.Field {
+	if !mayHaveDuplicates(fields) {
+		return fields
+	}
+
+	scopes := make([]fieldScope, len(fields))
+	namespace := 0
+	for i, f := range fields {
+		scopes[i] = fieldScope{namespace: namespace, key: f.Key}
+		if f.Type == zapcore.NamespaceType {
+			namespace++
+		}
+	}
+
+	// keep holds the index of the field to keep for every scoped key.
+	keep := make(map[fieldScope]int, len(fields))
+	var duplicates []string
+	for i, s := range scopes {
+		first, seen := keep[s]
+		if seen && c.mode == DuplicateFieldsKeepFirst {
+			i = first
+		}
+		keep[s] = i
+		if seen {
+			duplicates = append(duplicates, s.key)
+		}
+	}
+	if len(duplicates) == 0 {
+		return fields
+	}
+
+	out := make([]zapcore.Field, 0, len(keep)+1)
+	if c.mode == DuplicateFieldsWarn {
+		sort.Strings(duplicates)
+		out = append(out, zapcore.Field{Key: duplicateFieldsKey, Type: zapcore.StringType, String: strings.Join(dedupeStrings(duplicates), ",")})
+	}
+	for i, s := range scopes {
+		if keep[s] == i {
+			out = append(out, fields[i])
+		}
+	}
+	return out
+}
+
+// mayHaveDuplicates returns false if no two fields have the same key. Short
+// lists are compared pairwise, avoiding allocations in the common case.
+func mayHaveDuplicates(fields []zapcore.Field) bool {
+	const maxPairwise = 16
+	if len(fields) > maxPairwise {
+		return true
+	}
+	for i := 1; i < len(fields); i++ {
+		for j := 0; j < i; j++ {
+			if fields[i].Key == fields[j].Key {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// dedupeStrings removes repeated values from the sorted slice s.
+func dedupeStrings(s []string) []string {
+	out := s[:0]
+	for i, v := range s {
+		if i == 0 || v != s[i-1] {
+			out = append(out, v)
+		}
+	}
+	return out
+}
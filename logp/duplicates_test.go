@@ -0,0 +1,125 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package logp
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestDuplicateFieldsKeepLastJSON(t *testing.T) {
+	var buf bytes.Buffer
+	encoder := zapcore.NewJSONEncoder(zapcore.EncoderConfig{MessageKey: "message"})
+	core := zapcore.NewCore(encoder, zapcore.AddSync(&buf), zapcore.DebugLevel)
+
+	log := zap.New(dedupeWrapper(core, DuplicateFieldsKeepLast)).Sugar()
+	log.With("error", "from context", "a", 1).Infow("message", "error", "from call site")
+
+	line := strings.TrimSpace(buf.String())
+	assert.Equal(t, 1, strings.Count(line, `"error"`), line)
+
+	var event map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(line), &event))
+	assert.Equal(t, "from call site", event["error"])
+	assert.EqualValues(t, 1, event["a"])
+}
+
+func TestDuplicateFieldsModes(t *testing.T) {
+	tests := map[DuplicateFields]map[string]interface{}{
+		DuplicateFieldsKeepAll:   {"x": "last", "y": "y"},
+		DuplicateFieldsKeepLast:  {"x": "last", "y": "y"},
+		DuplicateFieldsKeepFirst: {"x": "first", "y": "y"},
+		DuplicateFieldsWarn:      {"x": "last", "y": "y", "log.duplicate_fields": "x"},
+	}
+	for mode, expected := range tests {
+		t.Run(mode.String(), func(t *testing.T) {
+			require.NoError(t, DevelopmentSetup(ToObserverOutput(), WithDuplicateFields(mode)))
+
+			NewLogger("dedupe").With("x", "first").Infow("message", "x", "middle", "y", "y", "x", "last")
+
+			logs := ObserverLogs().TakeAll()
+			require.Len(t, logs, 1)
+			assert.Equal(t, expected, logs[0].ContextMap())
+			if mode == DuplicateFieldsKeepAll {
+				assert.Len(t, logs[0].Context, 4)
+			} else {
+				assert.Len(t, logs[0].Context, len(expected))
+			}
+		})
+	}
+}
+
+// withCountingCore counts the calls to With.
+type withCountingCore struct {
+	zapcore.Core
+	calls *int
+}
+
+func (c withCountingCore) With(fields []zapcore.Field) zapcore.Core {
+	*c.calls++
+	return withCountingCore{Core: c.Core.With(fields), calls: c.calls}
+}
+
+func TestDuplicateFieldsWith(t *testing.T) {
+	var buf bytes.Buffer
+	encoder := zapcore.NewJSONEncoder(zapcore.EncoderConfig{MessageKey: "message"})
+	withCalls := 0
+	core := withCountingCore{Core: zapcore.NewCore(encoder, zapcore.AddSync(&buf), zapcore.DebugLevel), calls: &withCalls}
+
+	log := zap.New(dedupeWrapper(core, DuplicateFieldsKeepLast)).Sugar().With("a", 1, "b", 2)
+	assert.Equal(t, 1, withCalls, "context fields must be passed to the wrapped core")
+
+	log.Infow("message", "c", 3)
+	log.Infow("message", "a", 4)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 2)
+	for i, want := range []map[string]interface{}{
+		{"message": "message", "a": 1.0, "b": 2.0, "c": 3.0},
+		{"message": "message", "a": 4.0, "b": 2.0},
+	} {
+		assert.Equal(t, 1, strings.Count(lines[i], `"a"`), lines[i])
+		var event map[string]interface{}
+		require.NoError(t, json.Unmarshal([]byte(lines[i]), &event))
+		assert.Equal(t, want, event)
+	}
+}
+
+func TestDuplicateFieldsNamespaces(t *testing.T) {
+	fields := []zapcore.Field{
+		zap.String("id", "outer"),
+		zap.Namespace("nested"),
+		zap.String("id", "inner"),
+	}
+	c := &dedupeCore{mode: DuplicateFieldsKeepLast}
+	assert.Equal(t, fields, c.dedupe(fields), "keys in different namespaces are no duplicates")
+}
+
+func TestDuplicateFieldsUnpack(t *testing.T) {
+	var mode DuplicateFields
+	require.NoError(t, mode.Unpack("Keep_First"))
+	assert.Equal(t, DuplicateFieldsKeepFirst, mode)
+	assert.Error(t, mode.Unpack("drop"))
+}
@@ -20,11 +20,9 @@
 package logp
 
 import (
-	"errors"
-
 	"go.uber.org/zap/zapcore"
 )
 
 func newEventLog(_ string, _ zapcore.Encoder, _ zapcore.LevelEnabler) (zapcore.Core, error) {
-	return nil, errors.New("eventlog is only supported on Windows")
+	return nil, errEventLogUnsupported
 }
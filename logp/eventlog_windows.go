@@ -21,6 +21,7 @@ import (
 	"errors"
 	"fmt"
 	"strings"
+	"sync"
 
 	"go.uber.org/zap/zapcore"
 	"golang.org/x/sys/windows/svc/eventlog"
@@ -34,6 +35,11 @@ const (
 
 const alreadyExistsMsg = "registry key already exists"
 
+var (
+	registeredSourcesMu sync.Mutex
+	registeredSources   = map[string]error{}
+)
+
 type eventLogCore struct {
 	zapcore.LevelEnabler
 	encoder zapcore.Encoder
@@ -47,10 +53,8 @@ func newEventLog(appName string, encoder zapcore.Encoder, enab zapcore.LevelEnab
 	}
 	appName = strings.Title(strings.ToLower(appName))
 
-	if err := eventlog.InstallAsEventCreate(appName, supports); err != nil {
-		if !strings.Contains(err.Error(), alreadyExistsMsg) {
-			return nil, fmt.Errorf("failed to setup eventlog: %w", err)
-		}
+	if err := registerEventSource(appName); err != nil {
+		return nil, err
 	}
 
 	log, err := eventlog.Open(appName)
@@ -65,9 +69,33 @@ func newEventLog(appName string, encoder zapcore.Encoder, enab zapcore.LevelEnab
 	}, nil
 }
 
+// registerEventSource registers appName as event source. Registration is only
+// attempted once per source, later calls return the result of the first one.
+func registerEventSource(appName string) error {
+	registeredSourcesMu.Lock()
+	defer registeredSourcesMu.Unlock()
+
+	if err, done := registeredSources[appName]; done {
+		return err
+	}
+
+	err := eventlog.InstallAsEventCreate(appName, supports)
+	if err != nil && strings.Contains(err.Error(), alreadyExistsMsg) {
+		err = nil
+	}
+	if err != nil {
+		err = fmt.Errorf("failed to setup eventlog: %w", err)
+	}
+	registeredSources[appName] = err
+	return err
+}
+
 func (c *eventLogCore) With(fields []zapcore.Field) zapcore.Core {
 	clone := c.Clone()
 	clone.fields = append(clone.fields, fields...)
+	for _, f := range fields {
+		f.AddTo(clone.encoder)
+	}
 	return clone
 }
 
@@ -101,6 +129,11 @@ func (c *eventLogCore) Sync() error {
 	return nil
 }
 
+// Close closes the handle to the event log.
+func (c *eventLogCore) Close() error {
+	return c.log.Close()
+}
+
 func (c *eventLogCore) Clone() *eventLogCore {
 	clone := *c
 	clone.encoder = c.encoder.Clone()
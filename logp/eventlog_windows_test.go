@@ -0,0 +1,97 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+//go:build windows
+
+package logp
+
+import (
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"golang.org/x/sys/windows/svc/eventlog"
+)
+
+func TestEventLogOutput(t *testing.T) {
+	const source = "Logptest"
+
+	if err := registerEventSource(source); err != nil {
+		t.Skipf("can not register event source, the test must run as administrator: %v", err)
+	}
+	t.Cleanup(func() { _ = eventlog.Remove(source) })
+
+	cfg := DefaultConfig(DefaultEnvironment)
+	cfg.Beat = source
+	cfg.ToEventLog = true
+	cfg.Level = InfoLevel
+
+	core, closer, err := makeEventLogOutput(cfg, zapcore.InfoLevel)
+	require.NoError(t, err)
+	require.NotNil(t, closer, "the event log must be used")
+	defer closer.Close()
+	assert.True(t, core.Enabled(zapcore.WarnLevel))
+	assert.False(t, core.Enabled(zapcore.DebugLevel))
+
+	msg := "event log test " + time.Now().Format(time.RFC3339Nano)
+	logger := newLogger(zap.New(core), "").With("test.id", "eventlog")
+	logger.Warn(msg)
+
+	var out string
+	require.Eventually(t, func() bool {
+		b, err := exec.Command("wevtutil", "qe", "Application",
+			"/q:*[System[Provider[@Name='"+source+"']]]", "/c:1", "/rd:true", "/f:text").Output()
+		out = string(b)
+		return err == nil && strings.Contains(out, msg)
+	}, 10*time.Second, 200*time.Millisecond, "event not found in Application log")
+
+	assert.Contains(t, out, "Warning")
+	assert.Contains(t, out, "eventlog", "With fields must be part of the message")
+}
+
+func TestEventLogOutputFallback(t *testing.T) {
+	r, w, err := os.Pipe()
+	require.NoError(t, err)
+	defer r.Close()
+	stderr := os.Stderr
+	os.Stderr = w
+	defer func() { os.Stderr = stderr }()
+
+	cfg := DefaultConfig(DefaultEnvironment)
+	cfg.Beat = "" // the event source can not be set up
+	cfg.ToEventLog = true
+	cfg.Level = InfoLevel
+
+	core, _, err := makeEventLogOutput(cfg, zapcore.InfoLevel)
+	require.NoError(t, err)
+	newLogger(zap.New(core), "").Info("logged to stderr")
+	os.Stderr = stderr
+	require.NoError(t, w.Close())
+
+	out, err := io.ReadAll(r)
+	require.NoError(t, err)
+	assert.Contains(t, string(out), "Can not log to the event log, logging to stderr instead")
+	assert.Contains(t, string(out), "appName cannot be empty")
+	assert.Contains(t, string(out), "logged to stderr")
+}
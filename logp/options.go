@@ -17,6 +17,8 @@
 
 package logp
 
+import "io"
+
 // Option configures the logp package behavior.
 type Option func(cfg *Config)
 
@@ -28,13 +30,45 @@ func WithLevel(level Level) Option {
 }
 
 // WithSelectors specifies what debug selectors are enabled. If no selectors are
-// specified then they are all enabled.
+// specified then they are all enabled. Selectors prefixed with "-" are
+// disabled, e.g. "*", "-transport" enables all selectors but transport.
 func WithSelectors(selectors ...string) Option {
 	return func(cfg *Config) {
 		cfg.Selectors = append(cfg.Selectors, selectors...)
 	}
 }
 
+// WithFieldTruncation limits the size of single field values in log events.
+// See FieldTruncationConfig.
+func WithFieldTruncation(truncation FieldTruncationConfig) Option {
+	return func(cfg *Config) {
+		cfg.Truncation = truncation
+	}
+}
+
+// WithCaller adds the file and line of the call site to events of level and
+// above.
+func WithCaller(level Level) Option {
+	return func(cfg *Config) {
+		cfg.Caller = LevelThresholdConfig{Enabled: true, Level: level}
+	}
+}
+
+// WithStacktrace adds a stack trace to events of level and above.
+func WithStacktrace(level Level) Option {
+	return func(cfg *Config) {
+		cfg.Stacktrace = LevelThresholdConfig{Enabled: true, Level: level}
+	}
+}
+
+// WithDuplicateFields selects how fields sharing a key within one event are
+// handled.
+func WithDuplicateFields(mode DuplicateFields) Option {
+	return func(cfg *Config) {
+		cfg.DuplicateFields = mode
+	}
+}
+
 // ToObserverOutput specifies that the output should be collected in memory so
 // that they can be read by an observer by calling ObserverLogs().
 func ToObserverOutput() Option {
@@ -44,6 +78,17 @@ func ToObserverOutput() Option {
 	}
 }
 
+// ToWriter adds w as an output, in addition to the configured one. Events
+// are written to w with the encoding of the configured output, one per line.
+// Writes are serialized, w does not need to be safe for concurrent use.
+// Errors writing to w are reported on stderr, they do not stop the other
+// outputs.
+func ToWriter(w io.Writer) Option {
+	return func(cfg *Config) {
+		cfg.toWriters = append(cfg.toWriters, w)
+	}
+}
+
 // ToDiscardOutput configures the logger to write to io.Discard. This is for
 // benchmarking purposes only.
 func ToDiscardOutput() Option {
@@ -21,10 +21,13 @@ import (
 	"go.uber.org/zap/zapcore"
 )
 
+// excludePrefix marks a selector whose debug output is dropped, e.g.
+// "-transport".
+const excludePrefix = "-"
+
 type selectiveCore struct {
-	allSelectors bool
-	selectors    map[string]struct{}
-	core         zapcore.Core
+	selectors map[string]struct{}
+	core      zapcore.Core
 }
 
 // HasSelector returns true if the given selector was explicitly set.
@@ -37,8 +40,29 @@ func selectiveWrapper(core zapcore.Core, selectors map[string]struct{}) zapcore.
 	if len(selectors) == 0 {
 		return core
 	}
+	return &selectiveCore{selectors: selectors, core: core}
+}
+
+// debugEnabled returns whether debug messages of the given selector are
+// logged.
+func (c *selectiveCore) debugEnabled(selector string) bool {
+	return selectorEnabled(c.selectors, selector)
+}
+
+// selectorEnabled returns whether the selectors enable debug messages of the
+// given selector. A selector set explicitly takes precedence over the "*"
+// wildcard, and an exclusion takes precedence over an inclusion at the same
+// level.
+func selectorEnabled(selectors map[string]struct{}, selector string) bool {
+	if _, excluded := selectors[excludePrefix+selector]; excluded {
+		return false
+	}
+	if _, enabled := selectors[selector]; enabled {
+		return true
+	}
+	_, allExcluded := selectors[excludePrefix+"*"]
 	_, allSelectors := selectors["*"]
-	return &selectiveCore{selectors: selectors, core: core, allSelectors: allSelectors}
+	return allSelectors && !allExcluded
 }
 
 // Enabled returns whether a given logging level is enabled when logging a
@@ -61,9 +85,7 @@ func (c *selectiveCore) With(fields []zapcore.Field) zapcore.Core {
 func (c *selectiveCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
 	if c.Enabled(ent.Level) {
 		if ent.Level == zapcore.DebugLevel {
-			if c.allSelectors {
-				return ce.AddCore(ent, c)
-			} else if _, enabled := c.selectors[ent.LoggerName]; enabled {
+			if c.debugEnabled(ent.LoggerName) {
 				return ce.AddCore(ent, c)
 			}
 			return ce
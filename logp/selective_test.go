@@ -54,3 +54,47 @@ func TestLoggerSelectors(t *testing.T) {
 	logs = ObserverLogs().TakeAll()
 	assert.Len(t, logs, 1)
 }
+
+func TestLoggerSelectorsExclude(t *testing.T) {
+	tests := map[string]struct {
+		selectors []string
+		logged    []string
+	}{
+		"wildcard and exclude": {
+			selectors: []string{"*", "-transport"},
+			logged:    []string{"publish", "config"},
+		},
+		"exclude only": {
+			selectors: []string{"-transport"},
+			logged:    []string{"publish", "config"},
+		},
+		"exclude beats include": {
+			selectors: []string{"transport", "-transport", "config"},
+			logged:    []string{"config"},
+		},
+		"explicit include beats wildcard exclude": {
+			selectors: []string{"-*", "publish"},
+			logged:    []string{"publish"},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			require.NoError(t, DevelopmentSetup(WithSelectors(tc.selectors...), ToObserverOutput()))
+
+			for _, selector := range []string{"transport", "publish", "config"} {
+				NewLogger(selector).Debug("debug message")
+			}
+
+			var logged []string
+			for _, entry := range ObserverLogs().TakeAll() {
+				logged = append(logged, entry.LoggerName)
+			}
+			assert.Equal(t, tc.logged, logged)
+
+			// Selectors only apply to debug level logs.
+			NewLogger("transport").Info("info message")
+			assert.Len(t, ObserverLogs().TakeAll(), 1)
+		})
+	}
+}
@@ -93,6 +93,11 @@ func (c *syslogCore) Sync() error {
 	return nil
 }
 
+// Close closes the connection to the syslog daemon.
+func (c *syslogCore) Close() error {
+	return c.writer.Close()
+}
+
 func (c *syslogCore) Clone() *syslogCore {
 	clone := *c
 	clone.encoder = c.encoder.Clone()
@@ -0,0 +1,166 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package logp
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"unicode/utf8"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// truncateCore limits the size of field values before they are handed to the
+// wrapped core for encoding. It keeps an untruncated copy of the context so
+// error events can skip truncation.
+type truncateCore struct {
+	cfg       FieldTruncationConfig
+	core      zapcore.Core // context fields truncated
+	untouched zapcore.Core // context fields as given, only set with SkipErrors
+}
+
+func truncateWrapper(core zapcore.Core, cfg FieldTruncationConfig) zapcore.Core {
+	if !cfg.Enabled() {
+		return core
+	}
+	c := &truncateCore{cfg: cfg, core: core}
+	if cfg.SkipErrors {
+		c.untouched = core
+	}
+	return c
+}
+
+// Enabled returns whether a given logging level is enabled when logging a
+// message.
+func (c *truncateCore) Enabled(level zapcore.Level) bool {
+	return c.core.Enabled(level)
+}
+
+// With adds structured context to the Core.
+func (c *truncateCore) With(fields []zapcore.Field) zapcore.Core {
+	clone := &truncateCore{cfg: c.cfg, core: c.core.With(c.truncate(fields))}
+	if c.untouched != nil {
+		clone.untouched = c.untouched.With(fields)
+	}
+	return clone
+}
+
+// Check determines whether the supplied Entry should be logged.
+func (c *truncateCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+// Write truncates the fields and writes the entry to the wrapped core.
+func (c *truncateCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	if c.untouched != nil && ent.Level >= zapcore.ErrorLevel {
+		return c.untouched.Write(ent, fields)
+	}
+	return c.core.Write(ent, c.truncate(fields))
+}
+
+// Sync flushes buffered logs (if any).
+func (c *truncateCore) Sync() error {
+	return c.core.Sync()
+}
+
+func (c *truncateCore) truncate(fields []zapcore.Field) []zapcore.Field {
+	var out []zapcore.Field
+	for i, f := range fields {
+		if t, ok := c.truncateField(f); ok {
+			if out == nil {
+				out = make([]zapcore.Field, len(fields))
+				copy(out, fields)
+			}
+			out[i] = t
+		}
+	}
+	if out == nil {
+		return fields
+	}
+	return out
+}
+
+// truncateField returns the replacement of f if its value exceeds the limits.
+func (c *truncateCore) truncateField(f zapcore.Field) (zapcore.Field, bool) {
+	switch f.Type {
+	case zapcore.StringType:
+		if c.cfg.MaxValueBytes > 0 && len(f.String) > c.cfg.MaxValueBytes {
+			return stringField(f.Key, truncateString(f.String, c.cfg.MaxValueBytes)), true
+		}
+
+	case zapcore.ByteStringType, zapcore.BinaryType:
+		b, _ := f.Interface.([]byte)
+		if c.cfg.MaxValueBytes > 0 && len(b) > c.cfg.MaxValueBytes {
+			if f.Type == zapcore.BinaryType {
+				return stringField(f.Key, binaryMarker(len(b))), true
+			}
+			return stringField(f.Key, truncateString(string(b), c.cfg.MaxValueBytes)), true
+		}
+
+	case zapcore.ArrayMarshalerType, zapcore.ObjectMarshalerType, zapcore.ReflectType:
+		if c.cfg.MaxCollectionBytes > 0 {
+			return summarizeField(f, c.cfg.MaxCollectionBytes)
+		}
+	}
+	return f, false
+}
+
+// summarizeField replaces maps, slices and objects whose JSON encoding is
+// larger than limit by their kind and length.
+func summarizeField(f zapcore.Field, limit int) (zapcore.Field, bool) {
+	enc := zapcore.NewMapObjectEncoder()
+	f.AddTo(enc)
+	value := enc.Fields[f.Key]
+
+	var length int
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Map, reflect.Slice, reflect.Array:
+		length = rv.Len()
+	default:
+		return f, false
+	}
+
+	b, err := json.Marshal(value)
+	if err != nil || len(b) <= limit {
+		return f, false
+	}
+	return stringField(f.Key, fmt.Sprintf("<%s of length %d>...(truncated %d bytes)", rv.Kind(), length, len(b))), true
+}
+
+// truncateString cuts s to at most limit bytes without splitting a UTF-8
+// sequence and appends a marker with the original length.
+func truncateString(s string, limit int) string {
+	cut := limit
+	for cut > 0 && !utf8.RuneStart(s[cut]) {
+		cut--
+	}
+	return fmt.Sprintf("%s...(truncated %d bytes)", s[:cut], len(s))
+}
+
+func binaryMarker(n int) string {
+	return fmt.Sprintf("...(truncated %d bytes)", n)
+}
+
+func stringField(key, value string) zapcore.Field {
+	return zapcore.Field{Key: key, Type: zapcore.StringType, String: value}
+}
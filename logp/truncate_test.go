@@ -0,0 +1,61 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package logp
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFieldTruncation(t *testing.T) {
+	err := DevelopmentSetup(ToObserverOutput(), WithFieldTruncation(FieldTruncationConfig{
+		MaxValueBytes:      16,
+		MaxCollectionBytes: 64,
+		SkipErrors:         true,
+	}))
+	require.NoError(t, err)
+
+	large := strings.Repeat("a", 1000)
+	list := make([]int, 100)
+
+	logger := NewLogger("tester")
+	logger.Infow("large fields", "payload", large, "list", list, "small", "ok")
+	logger.With("payload", large).Info("large context")
+	logger.With("payload", large).Errorw("error", "list", list)
+
+	logs := ObserverLogs().TakeAll()
+	require.Len(t, logs, 3)
+
+	fields := logs[0].ContextMap()
+	assert.Equal(t, strings.Repeat("a", 16)+"...(truncated 1000 bytes)", fields["payload"])
+	assert.Equal(t, "<slice of length 100>...(truncated 201 bytes)", fields["list"])
+	assert.Equal(t, "ok", fields["small"])
+
+	assert.Equal(t, strings.Repeat("a", 16)+"...(truncated 1000 bytes)", logs[1].ContextMap()["payload"])
+
+	fields = logs[2].ContextMap()
+	assert.Equal(t, large, fields["payload"])
+	assert.Len(t, fields["list"], 100)
+}
+
+func TestTruncateStringUTF8(t *testing.T) {
+	assert.Equal(t, "ab...(truncated 5 bytes)", truncateString("abäc", 3))
+}
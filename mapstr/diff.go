@@ -0,0 +1,84 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package mapstr
+
+import (
+	"reflect"
+)
+
+// Diff compares two events and returns the fields that were added to,
+// removed from and changed in new, keyed by their dotted path. Nested maps
+// are compared field by field, added and removed maps are reported by their
+// fields. All other values, including slices, are compared by deep equality
+// and reported with their value in new, or in old for removed fields. Neither
+// input is modified, the returned maps share values other than maps with them.
+func Diff(old, new M) (added, removed, changed M) {
+	added, removed, changed = M{}, M{}, M{}
+	diff("", old, new, added, removed, changed)
+	return added, removed, changed
+}
+
+func diff(prefix string, old, new, added, removed, changed M) {
+	for k, oldValue := range old {
+		key := prefixKey(prefix, k)
+		newValue, ok := new[k]
+		if !ok {
+			addDiffValue(key, oldValue, removed)
+			continue
+		}
+
+		oldMap, oldIsMap := tryToMapStr(oldValue)
+		newMap, newIsMap := tryToMapStr(newValue)
+		switch {
+		case oldIsMap && newIsMap:
+			diff(key, oldMap, newMap, added, removed, changed)
+		case oldIsMap || newIsMap || !reflect.DeepEqual(oldValue, newValue):
+			changed[key] = cloneValue(newValue)
+		}
+	}
+
+	for k, newValue := range new {
+		if _, ok := old[k]; !ok {
+			addDiffValue(prefixKey(prefix, k), newValue, added)
+		}
+	}
+}
+
+// addDiffValue adds v to out under key, the fields of non-empty maps are
+// added by their dotted path.
+func addDiffValue(key string, v interface{}, out M) {
+	if m, ok := tryToMapStr(v); ok && len(m) > 0 {
+		flatten(key, m, out)
+		return
+	}
+	out[key] = cloneValue(v)
+}
+
+func cloneValue(v interface{}) interface{} {
+	if m, ok := tryToMapStr(v); ok {
+		return m.Clone()
+	}
+	return v
+}
+
+func prefixKey(prefix, key string) string {
+	if prefix == "" {
+		return key
+	}
+	return prefix + "." + key
+}
@@ -0,0 +1,88 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package mapstr
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiff(t *testing.T) {
+	old := M{
+		"message": "hello",
+		"host": M{
+			"name": "host-1",
+			"os":   M{"version": "12"},
+		},
+		"tags":    []string{"a", "b"},
+		"removed": "gone",
+		"process": M{"pid": 1, "args": []string{"-v"}},
+	}
+	new := M{
+		"message": "hello",
+		"host": M{
+			"name": "host-1",
+			"os":   M{"version": "13"},
+			"ip":   "10.0.0.1",
+		},
+		"tags":  []string{"a", "c"},
+		"added": M{"field": true},
+	}
+	oldCopy, newCopy := old.Clone(), new.Clone()
+
+	added, removed, changed := Diff(old, new)
+
+	assert.Equal(t, M{
+		"host.ip":     "10.0.0.1",
+		"added.field": true,
+	}, added)
+	assert.Equal(t, M{
+		"removed":      "gone",
+		"process.pid":  1,
+		"process.args": []string{"-v"},
+	}, removed)
+	assert.Equal(t, M{
+		"host.os.version": "13",
+		"tags":            []string{"a", "c"},
+	}, changed)
+
+	assert.Equal(t, oldCopy, old, "old must not be modified")
+	assert.Equal(t, newCopy, new, "new must not be modified")
+}
+
+func TestDiffTypeChange(t *testing.T) {
+	added, removed, changed := Diff(
+		M{"a": M{"b": 1}, "c": "x"},
+		M{"a": "flat", "c": map[string]interface{}{"d": 2}},
+	)
+
+	assert.Empty(t, added)
+	assert.Empty(t, removed)
+	assert.Equal(t, M{"a": "flat", "c": M{"d": 2}}, changed)
+}
+
+func TestDiffEqual(t *testing.T) {
+	event := M{"a": M{"b": []interface{}{1, "x"}}, "c": 1}
+
+	added, removed, changed := Diff(event, event.Clone())
+
+	assert.Empty(t, added)
+	assert.Empty(t, removed)
+	assert.Empty(t, changed)
+}
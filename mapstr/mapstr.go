@@ -41,6 +41,10 @@ const (
 var (
 	// ErrKeyNotFound indicates that the specified key was not found.
 	ErrKeyNotFound = errors.New("key not found")
+
+	// ErrKeyAmbiguous indicates that a case-insensitive lookup matched
+	// multiple keys that only differ by case.
+	ErrKeyAmbiguous = errors.New("key is ambiguous")
 )
 
 // EventMetadata contains fields and tags that can be added to an event via
@@ -185,6 +189,14 @@ func (m M) GetValue(key string) (interface{}, error) {
 	return v, nil
 }
 
+// GetValueFold gets a value from the map like GetValue, but matches the
+// segments of the dotted key case-insensitively. Keys matching exactly are
+// preferred. If a segment only matches multiple keys that differ by case,
+// ErrKeyAmbiguous is returned.
+func (m M) GetValueFold(key string) (interface{}, error) {
+	return mapFindFold(key, m)
+}
+
 // Put associates the specified value with the specified key. If the map
 // previously contained a mapping for the key, the old value is replaced and
 // returned. The key can be expressed in dot-notation (e.g. x.y) to put a value
@@ -517,3 +529,73 @@ func mapFind(
 		data = v
 	}
 }
+
+// mapFindFold works like mapFind without createMissing, but falls back to a
+// case-insensitive match if a key is not present as is. The keys of a map
+// are only scanned if the exact lookup of the key misses.
+func mapFindFold(key string, data M) (interface{}, error) {
+	// Fast path, the dotted key is present as is.
+	if _, _, v, present, err := mapFind(key, data, false); err == nil && present {
+		return v, nil
+	}
+
+	for {
+		// Fast path, key is present as is.
+		if v, exists := data[key]; exists {
+			return v, nil
+		}
+		k, found, err := foldKey(key, data)
+		if err != nil {
+			return nil, err
+		}
+		if found {
+			return data[k], nil
+		}
+
+		idx := strings.IndexRune(key, '.')
+		if idx < 0 {
+			return nil, ErrKeyNotFound
+		}
+
+		d, exists := data[key[:idx]]
+		if !exists {
+			k, found, err := foldKey(key[:idx], data)
+			if err != nil {
+				return nil, err
+			}
+			if !found {
+				return nil, ErrKeyNotFound
+			}
+			d = data[k]
+		}
+
+		v, err := toMapStr(d)
+		if err != nil {
+			return nil, err
+		}
+
+		// advance to sub-map
+		key = key[idx+1:]
+		data = v
+	}
+}
+
+// foldKey returns the key of data that is equal to key under Unicode case
+// folding.
+func foldKey(key string, data M) (match string, found bool, err error) {
+	var matches []string
+	for k := range data {
+		if strings.EqualFold(k, key) {
+			matches = append(matches, k)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return "", false, nil
+	case 1:
+		return matches[0], true, nil
+	default:
+		sort.Strings(matches)
+		return "", false, fmt.Errorf("%w: '%s' matches %s", ErrKeyAmbiguous, key, strings.Join(matches, ", "))
+	}
+}
@@ -339,6 +339,51 @@ func TestMapStrGetValue(t *testing.T) {
 	}
 }
 
+func TestMapStrGetValueFold(t *testing.T) {
+	m := M{
+		"Host": M{
+			"Name": "server-1",
+			"os":   M{"Family": "linux"},
+		},
+		"agent": M{"id": "1", "ID": "2"},
+		"user":  M{"name": "exact", "NAME": "upper"},
+		"event": M{"kind": "exact"},
+		// Only matched by case folding, would be ambiguous.
+		"EVENT.KIND": "upper",
+		"Event.Kind": "title",
+	}
+	original := m.Clone()
+
+	tests := map[string]struct {
+		key    string
+		output interface{}
+		err    error
+	}{
+		"case mismatch":    {key: "host.name", output: "server-1"},
+		"nested mismatch":  {key: "HOST.OS.family", output: "linux"},
+		"exact match":      {key: "Host.Name", output: "server-1"},
+		"exact match wins": {key: "user.name", output: "exact"},
+		"exact path wins":  {key: "event.kind", output: "exact"},
+		"ambiguous":        {key: "agent.Id", err: ErrKeyAmbiguous},
+		"not found":        {key: "host.ip", err: ErrKeyNotFound},
+		"missing parent":   {key: "cloud.id", err: ErrKeyNotFound},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			v, err := m.GetValueFold(test.key)
+			if test.err != nil {
+				assert.ErrorIs(t, err, test.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.output, v)
+		})
+	}
+
+	assert.Equal(t, original, m)
+}
+
 func TestClone(t *testing.T) {
 	assert := assert.New(t)
 
@@ -0,0 +1,191 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package mapstr
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+)
+
+var (
+	// ErrMissingRequiredField indicates that a field marked as required by a
+	// schema is not present.
+	ErrMissingRequiredField = errors.New("missing required field")
+	// ErrFieldTypeMismatch indicates that a field has a type that does not
+	// match the schema and can not be safely converted.
+	ErrFieldTypeMismatch = errors.New("field type mismatch")
+)
+
+// ValueType is the type a field is expected to have by a schema.
+type ValueType int
+
+const (
+	// StringType accepts strings. Numbers and booleans are converted to
+	// their string representation.
+	StringType ValueType = iota + 1
+	// BoolType accepts booleans and strings parseable by strconv.ParseBool.
+	BoolType
+	// IntType accepts integers, floats without a fractional part and strings
+	// holding an integer. Converted values are stored as int64.
+	IntType
+	// FloatType accepts floats, integers and strings holding a number.
+	// Converted values are stored as float64.
+	FloatType
+)
+
+// String returns the name of the type.
+func (t ValueType) String() string {
+	switch t {
+	case StringType:
+		return "string"
+	case BoolType:
+		return "bool"
+	case IntType:
+		return "int"
+	case FloatType:
+		return "float"
+	default:
+		return fmt.Sprintf("ValueType(%d)", int(t))
+	}
+}
+
+// FieldType describes the expected type of a field and if it must be present.
+type FieldType struct {
+	Type     ValueType
+	Required bool
+}
+
+// ApplySchema validates the fields listed in schema, keyed by their dotted
+// path, and converts values whose type differs from the expected one when
+// the conversion is safe (e.g. the string "true" to a bool). Fields not listed
+// in the schema are left untouched and m is never modified.
+//
+// It returns a copy of m holding the converted values together with all
+// violations found. Violations wrap ErrMissingRequiredField or
+// ErrFieldTypeMismatch.
+func (m M) ApplySchema(schema map[string]FieldType) (M, []error) {
+	out := m.Clone()
+
+	// Sort the keys so violations are reported in a stable order.
+	keys := make([]string, 0, len(schema))
+	for k := range schema {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var errs []error
+	for _, key := range keys {
+		field := schema[key]
+		v, err := out.GetValue(key)
+		if err != nil {
+			if field.Required {
+				errs = append(errs, fmt.Errorf("%w: '%s'", ErrMissingRequiredField, key))
+			}
+			continue
+		}
+
+		converted, ok := convertValue(v, field.Type)
+		if !ok {
+			errs = append(errs, fmt.Errorf("%w: '%s' expected %v but got %T", ErrFieldTypeMismatch, key, field.Type, v))
+			continue
+		}
+		if _, err := out.Put(key, converted); err != nil {
+			errs = append(errs, fmt.Errorf("failed to update '%s': %w", key, err))
+		}
+	}
+	return out, errs
+}
+
+// convertValue returns v converted to the type t and true, or false if the
+// conversion is not safe.
+func convertValue(v interface{}, t ValueType) (interface{}, bool) {
+	switch t {
+	case StringType:
+		switch val := v.(type) {
+		case string:
+			return val, true
+		case bool, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+			return fmt.Sprint(val), true
+		}
+
+	case BoolType:
+		switch val := v.(type) {
+		case bool:
+			return val, true
+		case string:
+			b, err := strconv.ParseBool(val)
+			return b, err == nil
+		}
+
+	case IntType:
+		switch val := v.(type) {
+		case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+			return val, true
+		case float32:
+			return floatToInt(float64(val))
+		case float64:
+			return floatToInt(val)
+		case string:
+			i, err := strconv.ParseInt(val, 10, 64)
+			return i, err == nil
+		}
+
+	case FloatType:
+		switch val := v.(type) {
+		case float32, float64:
+			return val, true
+		case int:
+			return float64(val), true
+		case int8:
+			return float64(val), true
+		case int16:
+			return float64(val), true
+		case int32:
+			return float64(val), true
+		case int64:
+			return float64(val), true
+		case uint:
+			return float64(val), true
+		case uint8:
+			return float64(val), true
+		case uint16:
+			return float64(val), true
+		case uint32:
+			return float64(val), true
+		case uint64:
+			return float64(val), true
+		case string:
+			f, err := strconv.ParseFloat(val, 64)
+			return f, err == nil
+		}
+	}
+	return nil, false
+}
+
+// floatToInt converts f to an int64 if it is integral and in range.
+// math.MaxInt64 is not representable as float64 and rounds up to 2^63, so
+// the upper bound is exclusive.
+func floatToInt(f float64) (interface{}, bool) {
+	if f != math.Trunc(f) || f >= math.MaxInt64 || f < math.MinInt64 {
+		return nil, false
+	}
+	return int64(f), true
+}
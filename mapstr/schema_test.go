@@ -0,0 +1,107 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+//go:build !integration
+
+package mapstr
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplySchema(t *testing.T) {
+	schema := map[string]FieldType{
+		"host.name":       {Type: StringType, Required: true},
+		"event.ingested":  {Type: BoolType},
+		"source.port":     {Type: IntType},
+		"metrics.cpu.pct": {Type: FloatType},
+	}
+
+	t.Run("coerces values", func(t *testing.T) {
+		m := M{
+			"host":    M{"name": "server-1"},
+			"event":   M{"ingested": "true"},
+			"source":  map[string]interface{}{"port": "9200"},
+			"metrics": M{"cpu": M{"pct": 1}},
+			"other":   "untouched",
+		}
+
+		out, errs := m.ApplySchema(schema)
+		require.Empty(t, errs)
+		assert.Equal(t, M{
+			"host":    M{"name": "server-1"},
+			"event":   M{"ingested": true},
+			"source":  M{"port": int64(9200)},
+			"metrics": M{"cpu": M{"pct": float64(1)}},
+			"other":   "untouched",
+		}, out)
+
+		// The original map is not modified.
+		assert.Equal(t, "true", m["event"].(M)["ingested"])
+	})
+
+	t.Run("reports all violations", func(t *testing.T) {
+		m := M{
+			"event":  M{"ingested": "maybe"},
+			"source": M{"port": 1.5},
+		}
+
+		out, errs := m.ApplySchema(schema)
+		require.Len(t, errs, 3)
+		assert.ErrorIs(t, errs[0], ErrFieldTypeMismatch)
+		assert.Contains(t, errs[0].Error(), "event.ingested")
+		assert.ErrorIs(t, errs[1], ErrMissingRequiredField)
+		assert.Contains(t, errs[1].Error(), "host.name")
+		assert.ErrorIs(t, errs[2], ErrFieldTypeMismatch)
+		assert.Contains(t, errs[2].Error(), "source.port")
+		assert.False(t, errors.Is(errs[1], ErrFieldTypeMismatch))
+
+		// Values that can not be converted are left as they are.
+		assert.Equal(t, 1.5, out["source"].(M)["port"])
+	})
+}
+
+func TestFloatToInt(t *testing.T) {
+	tests := map[string]struct {
+		in   float64
+		out  int64
+		fits bool
+	}{
+		"integral":           {in: 42, out: 42, fits: true},
+		"fraction":           {in: 1.5},
+		"2^63":               {in: math.Pow(2, 63)},
+		"largest below 2^63": {in: math.Nextafter(math.Pow(2, 63), 0), out: int64(math.Nextafter(math.Pow(2, 63), 0)), fits: true},
+		"-2^63":              {in: math.MinInt64, out: math.MinInt64, fits: true},
+		"below -2^63":        {in: math.Nextafter(math.MinInt64, math.Inf(-1))},
+		"+Inf":               {in: math.Inf(1)},
+		"NaN":                {in: math.NaN()},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			v, ok := floatToInt(tc.in)
+			assert.Equal(t, tc.fits, ok)
+			if tc.fits {
+				assert.Equal(t, tc.out, v)
+			}
+		})
+	}
+}
@@ -0,0 +1,222 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package mapstr
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// FromStruct converts the struct v, or a pointer to it, into an M without a
+// JSON round-trip. Field names are read from the `mapstr` tag, or the `json`
+// tag if there is none, and default to the Go field name. Both tags support
+// the omitempty option and "-" to skip a field. Nested structs and maps become
+// nested M values, the fields of embedded structs without a name in their tag
+// are added to the outer struct, the outer fields winning on conflicts.
+//
+// Numbers, strings, booleans and time.Time values are stored with their
+// native type. Slices of such values are stored as they are, other slices as
+// []interface{} with converted elements. Unexported fields are skipped.
+// Channels, functions, complex numbers and maps whose keys are not strings
+// are not supported.
+func FromStruct(v interface{}) (M, error) {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			return nil, fmt.Errorf("can not convert nil %s to mapstr.M", rv.Type())
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("can not convert %T to mapstr.M, a struct is required", v)
+	}
+	return structToM("", rv)
+}
+
+func structToM(path string, v reflect.Value) (M, error) {
+	m := M{}
+	var embedded []M
+
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, omitEmpty, skip := fieldTag(field)
+		if skip {
+			continue
+		}
+		value := v.Field(i)
+
+		if field.Anonymous && name == "" {
+			ft := field.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct && ft != timeType {
+				if value.Kind() == reflect.Pointer {
+					if value.IsNil() {
+						continue
+					}
+					value = value.Elem()
+				}
+				inner, err := structToM(path, value)
+				if err != nil {
+					return nil, err
+				}
+				embedded = append(embedded, inner)
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		if omitEmpty && isEmptyValue(value) {
+			continue
+		}
+
+		converted, err := valueToM(joinFieldPath(path, name), value)
+		if err != nil {
+			return nil, err
+		}
+		m[name] = converted
+	}
+
+	for _, inner := range embedded {
+		for k, val := range inner {
+			if _, exists := m[k]; !exists {
+				m[k] = val
+			}
+		}
+	}
+	return m, nil
+}
+
+// fieldTag returns the name and options of the field from its mapstr or json
+// tag.
+func fieldTag(field reflect.StructField) (name string, omitEmpty, skip bool) {
+	tag, ok := field.Tag.Lookup("mapstr")
+	if !ok {
+		tag = field.Tag.Get("json")
+	}
+	if tag == "-" {
+		return "", false, true
+	}
+	name, opts, _ := strings.Cut(tag, ",")
+	for _, opt := range strings.Split(opts, ",") {
+		if opt == "omitempty" {
+			omitEmpty = true
+		}
+	}
+	return name, omitEmpty, false
+}
+
+func valueToM(path string, v reflect.Value) (interface{}, error) {
+	if v.IsValid() && isScalarType(v.Type()) {
+		return v.Interface(), nil
+	}
+
+	switch v.Kind() {
+	case reflect.Invalid:
+		return nil, nil
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return nil, nil
+		}
+		return valueToM(path, v.Elem())
+	case reflect.Struct:
+		return structToM(path, v)
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return nil, fmt.Errorf("unsupported map key type %s for field '%s'", v.Type().Key(), path)
+		}
+		if v.IsNil() {
+			return nil, nil
+		}
+		m := make(M, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			key := iter.Key().String()
+			converted, err := valueToM(joinFieldPath(path, key), iter.Value())
+			if err != nil {
+				return nil, err
+			}
+			m[key] = converted
+		}
+		return m, nil
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return nil, nil
+		}
+		if isScalarType(v.Type().Elem()) {
+			return v.Interface(), nil
+		}
+		s := make([]interface{}, v.Len())
+		for i := range s {
+			converted, err := valueToM(fmt.Sprintf("%s.%d", path, i), v.Index(i))
+			if err != nil {
+				return nil, err
+			}
+			s[i] = converted
+		}
+		return s, nil
+	default:
+		return nil, fmt.Errorf("unsupported type %s for field '%s'", v.Type(), path)
+	}
+}
+
+func isScalarType(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Bool, reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+		return true
+	default:
+		return t == timeType
+	}
+}
+
+// isEmptyValue reports whether v is empty in the sense of the omitempty
+// option of encoding/json.
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64,
+		reflect.Interface, reflect.Pointer:
+		return v.IsZero()
+	default:
+		return false
+	}
+}
+
+func joinFieldPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
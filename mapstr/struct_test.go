@@ -0,0 +1,146 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package mapstr
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type structTestMeta struct {
+	Version int64  `json:"version"`
+	Source  string `json:"source,omitempty"`
+}
+
+type structTestHost struct {
+	Name string   `mapstr:"name" json:"hostname"`
+	IPs  []string `json:"ips,omitempty"`
+}
+
+type structTestEvent struct {
+	structTestMeta
+	Timestamp time.Time              `json:"@timestamp"`
+	Message   string                 `json:"message"`
+	Count     int64                  `json:"count"`
+	Host      *structTestHost        `json:"host"`
+	Services  []structTestHost       `json:"services,omitempty"`
+	Labels    map[string]string      `json:"labels,omitempty"`
+	Extra     map[string]interface{} `mapstr:"extra"`
+	Ignored   string                 `json:"-"`
+	Optional  *structTestHost        `json:"optional,omitempty"`
+	Untagged  bool
+	private   string                     //nolint:unused // unexported fields are skipped
+	Nested    struct{ Level int8 }       `json:"nested"`
+	Fields    M                          `json:"fields,omitempty"`
+	Named     structTestMeta             `json:"meta"`
+	Zero      float64                    `json:"zero,omitempty"`
+	Any       interface{}                `json:"any"`
+	Durations map[string]time.Duration   `json:"durations"`
+	Times     []time.Time                `json:"times,omitempty"`
+	Matrix    [][]structTestMeta         `json:"matrix,omitempty"`
+	Pointers  map[string]*structTestHost `json:"pointers,omitempty"`
+}
+
+func TestFromStruct(t *testing.T) {
+	ts := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	event := &structTestEvent{
+		structTestMeta: structTestMeta{Version: 3},
+		Timestamp:      ts,
+		Message:        "hello",
+		Count:          1 << 40,
+		Host:           &structTestHost{Name: "web-1", IPs: []string{"10.0.0.1"}},
+		Services:       []structTestHost{{Name: "nginx"}},
+		Extra:          map[string]interface{}{"host": structTestHost{Name: "db"}},
+		Ignored:        "ignored",
+		private:        "private",
+		Untagged:       true,
+		Named:          structTestMeta{Version: 1, Source: "file"},
+		Any:            &structTestMeta{Version: 2},
+		Durations:      map[string]time.Duration{"timeout": time.Second},
+	}
+	event.Nested.Level = 2
+
+	m, err := FromStruct(event)
+	require.NoError(t, err)
+
+	assert.Equal(t, M{
+		"version":    int64(3),
+		"@timestamp": ts,
+		"message":    "hello",
+		"count":      int64(1 << 40),
+		"host":       M{"name": "web-1", "ips": []string{"10.0.0.1"}},
+		"services":   []interface{}{M{"name": "nginx"}},
+		"extra":      M{"host": M{"name": "db"}},
+		"Untagged":   true,
+		"nested":     M{"Level": int8(2)},
+		"meta":       M{"version": int64(1), "source": "file"},
+		"any":        M{"version": int64(2)},
+		"durations":  M{"timeout": time.Second},
+	}, m)
+
+	// Native types are preserved.
+	ts2, err := m.GetValue("@timestamp")
+	require.NoError(t, err)
+	assert.IsType(t, time.Time{}, ts2)
+	count, err := m.GetValue("count")
+	require.NoError(t, err)
+	assert.IsType(t, int64(0), count)
+	name, err := m.GetValue("host.name")
+	require.NoError(t, err)
+	assert.Equal(t, "web-1", name)
+
+	// The outer fields win over embedded ones.
+	type outer struct {
+		structTestMeta
+		Version string `json:"version"`
+	}
+	m, err = FromStruct(outer{structTestMeta: structTestMeta{Version: 1, Source: "a"}, Version: "v2"})
+	require.NoError(t, err)
+	assert.Equal(t, M{"version": "v2", "source": "a"}, m)
+}
+
+func TestFromStructErrors(t *testing.T) {
+	_, err := FromStruct(nil)
+	assert.ErrorContains(t, err, "a struct is required")
+
+	_, err = FromStruct((*structTestHost)(nil))
+	assert.ErrorContains(t, err, "can not convert nil *mapstr.structTestHost")
+
+	_, err = FromStruct(map[string]interface{}{})
+	assert.ErrorContains(t, err, "a struct is required")
+
+	_, err = FromStruct(struct {
+		Inner struct {
+			Notify chan int `json:"notify"`
+		} `json:"inner"`
+	}{})
+	assert.EqualError(t, err, "unsupported type chan int for field 'inner.notify'")
+
+	_, err = FromStruct(struct {
+		Codes map[int]string `json:"codes"`
+	}{})
+	assert.EqualError(t, err, "unsupported map key type int for field 'codes'")
+
+	_, err = FromStruct(struct {
+		Values []interface{} `json:"values"`
+	}{Values: []interface{}{1, func() {}}})
+	assert.EqualError(t, err, "unsupported type func() for field 'values.1'")
+}
@@ -0,0 +1,178 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package mapstr
+
+import (
+	"sync"
+)
+
+// SyncM is a M that is safe for concurrent use. Maps passed to or returned
+// from its methods are copied, so they can not be used to modify the wrapped
+// map without holding the lock. Other values, like slices, are shared and
+// must not be modified after being stored. The zero value is an empty map
+// ready to use.
+type SyncM struct {
+	mu sync.RWMutex
+	m  M
+}
+
+// NewSyncM creates a SyncM holding a copy of m.
+func NewSyncM(m M) *SyncM {
+	if m == nil {
+		return &SyncM{}
+	}
+	return &SyncM{m: m.Clone()}
+}
+
+// Put associates the specified value with the specified key. If the map
+// previously contained a mapping for the key, the old value is replaced and
+// returned. See M.Put.
+func (s *SyncM) Put(key string, value interface{}) (interface{}, error) {
+	if m, ok := tryToMapStr(value); ok {
+		value = m.Clone()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.m == nil {
+		s.m = M{}
+	}
+	return s.m.Put(key, value)
+}
+
+// GetValue gets a value from the map. Maps are returned as copies. See
+// M.GetValue.
+func (s *SyncM) GetValue(key string) (interface{}, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	v, err := s.m.GetValue(key)
+	if m, ok := tryToMapStr(v); ok {
+		return m.Clone(), err
+	}
+	return v, err
+}
+
+// HasKey returns true if the key exists. See M.HasKey.
+func (s *SyncM) HasKey(key string) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.m.HasKey(key)
+}
+
+// Delete deletes the given key from the map. See M.Delete.
+func (s *SyncM) Delete(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.m.Delete(key)
+}
+
+// Update copies all the key-value pairs from d to this map. See M.Update.
+func (s *SyncM) Update(d M) {
+	d = d.Clone()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.m == nil {
+		s.m = M{}
+	}
+	s.m.Update(d)
+}
+
+// DeepUpdate recursively copies the key-value pairs from d to this map. See
+// M.DeepUpdate.
+func (s *SyncM) DeepUpdate(d M) {
+	d = d.Clone()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.m == nil {
+		s.m = M{}
+	}
+	s.m.DeepUpdate(d)
+}
+
+// Len returns the number of top-level keys.
+func (s *SyncM) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.m)
+}
+
+// Clone returns a copy of the map like M.Clone. Inner maps are copied, other
+// values are shared with the SyncM.
+func (s *SyncM) Clone() M {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.m.Clone()
+}
+
+// Snapshot returns a consistent deep copy of the map. Unlike Clone, maps
+// nested in slices and the slices themselves are copied as well, so the
+// snapshot shares no mutable state with the SyncM.
+func (s *SyncM) Snapshot() M {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return deepCopyMap(s.m)
+}
+
+// String returns the map as JSON.
+func (s *SyncM) String() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.m.String()
+}
+
+func deepCopyMap(m M) M {
+	result := make(M, len(m))
+	for k, v := range m {
+		result[k] = deepCopyValue(v)
+	}
+	return result
+}
+
+func deepCopyValue(v interface{}) interface{} {
+	switch val := v.(type) {
+	case M:
+		return deepCopyMap(val)
+	case map[string]interface{}:
+		return map[string]interface{}(deepCopyMap(val))
+	case []M:
+		s := make([]M, len(val))
+		for i, m := range val {
+			s[i] = deepCopyMap(m)
+		}
+		return s
+	case []map[string]interface{}:
+		s := make([]map[string]interface{}, len(val))
+		for i, m := range val {
+			s[i] = deepCopyMap(m)
+		}
+		return s
+	case []interface{}:
+		s := make([]interface{}, len(val))
+		for i, e := range val {
+			s[i] = deepCopyValue(e)
+		}
+		return s
+	case []string:
+		return append([]string(nil), val...)
+	default:
+		return v
+	}
+}
@@ -0,0 +1,103 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package mapstr
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSyncMConcurrentAccess(t *testing.T) {
+	const (
+		workers = 8
+		keys    = 100
+	)
+
+	s := NewSyncM(M{"host": M{"name": "test"}})
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(3)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < keys; i++ {
+				_, err := s.Put(fmt.Sprintf("worker%d.key%d", w, i), i)
+				assert.NoError(t, err)
+			}
+		}(w)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < keys; i++ {
+				_, _ = s.GetValue(fmt.Sprintf("worker%d", w))
+				_, _ = s.GetValue("host.name")
+			}
+		}(w)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < keys; i++ {
+				c := s.Clone()
+				// Copies can be modified without synchronization.
+				c.Put("host.name", "modified") //nolint:errcheck // can't fail
+				_ = s.Snapshot()
+			}
+		}()
+	}
+	wg.Wait()
+
+	snapshot := s.Snapshot()
+	assert.Equal(t, "test", snapshot["host"].(M)["name"])
+	for w := 0; w < workers; w++ {
+		worker, ok := snapshot[fmt.Sprintf("worker%d", w)].(M)
+		require.True(t, ok)
+		require.Len(t, worker, keys)
+		for i := 0; i < keys; i++ {
+			assert.Equal(t, i, worker[fmt.Sprintf("key%d", i)])
+		}
+	}
+	assert.Equal(t, workers+1, s.Len())
+}
+
+func TestSyncMCopies(t *testing.T) {
+	var s SyncM
+
+	inner := M{"a": 1}
+	_, err := s.Put("inner", inner)
+	require.NoError(t, err)
+	inner["a"] = 2
+
+	v, err := s.GetValue("inner")
+	require.NoError(t, err)
+	assert.Equal(t, M{"a": 1}, v)
+	v.(M)["a"] = 3
+
+	_, err = s.Put("list", []interface{}{M{"b": 1}})
+	require.NoError(t, err)
+	snapshot := s.Snapshot()
+	snapshot["list"].([]interface{})[0].(M)["b"] = 2
+
+	assert.Equal(t, M{"inner": M{"a": 1}, "list": []interface{}{M{"b": 1}}}, s.Clone())
+
+	require.NoError(t, s.Delete("inner"))
+	ok, err := s.HasKey("inner")
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
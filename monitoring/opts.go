@@ -23,6 +23,7 @@ type Option func(options) options
 type options struct {
 	publishExpvar bool
 	mode          Mode
+	counter       bool
 }
 
 var defaultOptions = options{
@@ -53,6 +54,14 @@ func DoNotReport(o options) options {
 	return o
 }
 
+// Counter marks metrics as monotonic counters, RateSnapshot reports their per
+// second rate instead of their value. Passed to a registry, it marks all
+// metrics added to it.
+func Counter(o options) options {
+	o.counter = true
+	return o
+}
+
 func varOpts(regOpts *options, opts []Option) *options {
 	if regOpts != nil && len(opts) == 0 {
 		return regOpts
@@ -0,0 +1,93 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package monitoring
+
+import "time"
+
+// TimedSnapshot is a flat snapshot together with the time it was collected
+// and the names of the metrics it contains that are counters.
+type TimedSnapshot struct {
+	FlatSnapshot
+	Time     time.Time
+	Counters map[string]struct{}
+}
+
+// CollectTimedSnapshot collects a flattened snapshot of a metrics tree
+// starting with the given registry, recording the current time and the
+// metrics created with the Counter option.
+func CollectTimedSnapshot(r *Registry, mode Mode, expvar bool) TimedSnapshot {
+	if r == nil {
+		r = Default
+	}
+
+	s := TimedSnapshot{
+		FlatSnapshot: CollectFlatSnapshot(r, mode, expvar),
+		Time:         time.Now(),
+		Counters:     map[string]struct{}{},
+	}
+	r.collectCounters("", mode, s.Counters)
+	return s
+}
+
+// RateSnapshot derives the per second rate of the counters in cur since prev.
+// Counters are reported as rates in Floats, all other metrics are passed
+// through unchanged. A counter that decreased is assumed to have been reset,
+// its rate is computed from zero. Counters missing in prev are left out, as
+// are all counters if cur was not collected after prev.
+func RateSnapshot(prev, cur TimedSnapshot) FlatSnapshot {
+	out := MakeFlatSnapshot()
+	for k, v := range cur.Bools {
+		out.Bools[k] = v
+	}
+	for k, v := range cur.Strings {
+		out.Strings[k] = v
+	}
+	for k, v := range cur.StringSlices {
+		out.StringSlices[k] = v
+	}
+
+	elapsed := cur.Time.Sub(prev.Time).Seconds()
+	rate := func(name string, now, before float64, ok bool) {
+		if !ok || elapsed <= 0 {
+			return
+		}
+		delta := now - before
+		if delta < 0 {
+			delta = now
+		}
+		out.Floats[name] = delta / elapsed
+	}
+
+	for k, v := range cur.Ints {
+		if _, isCounter := cur.Counters[k]; !isCounter {
+			out.Ints[k] = v
+			continue
+		}
+		before, ok := prev.Ints[k]
+		rate(k, float64(v), float64(before), ok)
+	}
+	for k, v := range cur.Floats {
+		if _, isCounter := cur.Counters[k]; !isCounter {
+			out.Floats[k] = v
+			continue
+		}
+		before, ok := prev.Floats[k]
+		rate(k, v, before, ok)
+	}
+	return out
+}
@@ -0,0 +1,74 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package monitoring
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateSnapshot(t *testing.T) {
+	r := NewRegistry()
+	events := NewUint(r, "output.events.total", Counter)
+	bytes := NewFloat(r, "output.bytes", Counter)
+	active := NewInt(r, "output.events.active")
+	NewString(r, "output.name").Set("elasticsearch")
+
+	events.Set(100)
+	bytes.Set(1000)
+	active.Set(5)
+	prev := CollectTimedSnapshot(r, Full, false)
+	assert.Equal(t, map[string]struct{}{"output.events.total": {}, "output.bytes": {}}, prev.Counters)
+
+	events.Set(300)
+	bytes.Set(1500)
+	active.Set(7)
+	cur := CollectTimedSnapshot(r, Full, false)
+	cur.Time = prev.Time.Add(10 * time.Second)
+
+	rates := RateSnapshot(prev, cur)
+	assert.Equal(t, map[string]int64{"output.events.active": 7}, rates.Ints)
+	assert.Equal(t, map[string]float64{"output.events.total": 20, "output.bytes": 50}, rates.Floats)
+	assert.Equal(t, map[string]string{"output.name": "elasticsearch"}, rates.Strings)
+
+	// The counter was reset and counted 50 events since.
+	events.Set(50)
+	next := CollectTimedSnapshot(r, Full, false)
+	next.Time = cur.Time.Add(5 * time.Second)
+
+	rates = RateSnapshot(cur, next)
+	assert.Equal(t, 10.0, rates.Floats["output.events.total"])
+	assert.Equal(t, 0.0, rates.Floats["output.bytes"])
+
+	// Without elapsed time no rates can be derived.
+	rates = RateSnapshot(next, next)
+	assert.Empty(t, rates.Floats)
+	assert.Equal(t, map[string]int64{"output.events.active": 7}, rates.Ints)
+}
+
+func TestRateSnapshotCounterRegistry(t *testing.T) {
+	r := NewRegistry()
+	counters := r.NewRegistry("counters", Counter)
+	NewInt(counters, "a").Set(1)
+	NewInt(r, "gauge").Set(1)
+
+	s := CollectTimedSnapshot(r, Full, false)
+	assert.Equal(t, map[string]struct{}{"counters.a": {}}, s.Counters)
+}
@@ -39,6 +39,7 @@ type Registry struct {
 type entry struct {
 	Var
 	Mode
+	counter bool
 }
 
 // Var interface required for every metric to implement.
@@ -78,12 +79,39 @@ func (r *Registry) doVisit(mode Mode, vs Visitor) {
 				continue
 			}
 		}
+		if e, ok := v.Var.(expirable); ok && e.expired() {
+			continue
+		}
 
 		vs.OnKey(key)
 		v.Var.Visit(mode, vs)
 	}
 }
 
+// collectCounters adds the full names of the counters visible in mode to out.
+func (r *Registry) collectCounters(prefix string, mode Mode, out map[string]struct{}) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for key, v := range r.entries {
+		name := key
+		if prefix != "" {
+			name = prefix + "." + key
+		}
+		if reg, isReg := v.Var.(*Registry); isReg {
+			reg.collectCounters(name, mode, out)
+			continue
+		}
+		if v.Mode > mode || !v.counter {
+			continue
+		}
+		if e, ok := v.Var.(expirable); ok && e.expired() {
+			continue
+		}
+		out[name] = struct{}{}
+	}
+}
+
 // NewRegistry creates and register a new registry
 func (r *Registry) NewRegistry(name string, opts ...Option) *Registry {
 	v := &Registry{
@@ -169,7 +197,7 @@ func (r *Registry) addNames(names []string, v Var, opts *options) error {
 			return fmt.Errorf("name %v already used", name)
 		}
 
-		r.entries[name] = entry{v, opts.mode}
+		r.entries[name] = entry{v, opts.mode, opts.counter}
 		return nil
 	}
 
@@ -188,7 +216,7 @@ func (r *Registry) addNames(names []string, v Var, opts *options) error {
 		return err
 	}
 
-	r.entries[name] = entry{sub, sub.opts.mode}
+	r.entries[name] = entry{sub, sub.opts.mode, false}
 	return nil
 }
 
@@ -199,7 +227,7 @@ func (r *Registry) find(name string) (entry, error) {
 func (r *Registry) findNames(names []string) (entry, error) {
 	switch len(names) {
 	case 0:
-		return entry{r, r.opts.mode}, nil
+		return entry{r, r.opts.mode, false}, nil
 	case 1:
 		r.mu.RLock()
 		defer r.mu.RUnlock()
@@ -0,0 +1,194 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package monitoring
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/elastic/elastic-agent-libs/atomic"
+)
+
+// expirable is implemented by variables that are left out of snapshots once
+// their value is stale.
+type expirable interface {
+	expired() bool
+}
+
+// TimedGauge is an integer gauge that expires if it is not updated within its
+// TTL. Expired gauges are not reported, so values of connections or other
+// resources that went away do not linger. A TimedGaugeReaper removes gauges
+// that have been expired for long from their registry, updating a removed
+// gauge registers it again.
+type TimedGauge struct {
+	value   atomic.Int64
+	updated atomic.Int64 // unix nanoseconds of the last update
+	ttl     time.Duration
+	now     func() time.Time
+
+	mu     sync.Mutex // serializes updates with reaping
+	reaped bool
+	reg    *Registry
+	name   string
+	opts   *options
+}
+
+// NewTimedGauge creates and registers a new timed gauge. A ttl <= 0 disables
+// expiry.
+func NewTimedGauge(r *Registry, name string, ttl time.Duration, opts ...Option) *TimedGauge {
+	existingVar, r := setupMetric(r, name, opts)
+	if existingVar != nil {
+		cast, ok := existingVar.(*TimedGauge)
+		if ok {
+			return cast
+		} else {
+			panicErr(fmt.Errorf("variable name %s was first registered as a %T, tried to register as TimedGauge", name, existingVar))
+		}
+	}
+
+	v := &TimedGauge{
+		ttl:  ttl,
+		now:  time.Now,
+		reg:  r,
+		name: name,
+		opts: varOpts(r.opts, opts),
+	}
+	v.updated.Store(v.now().UnixNano())
+	// Not published to expvar, as expvar variables can not expire.
+	addVar(r, name, opts, v, nil)
+	return v
+}
+
+// Get returns the current value and whether it is still fresh.
+func (v *TimedGauge) Get() (int64, bool) {
+	return v.value.Load(), !v.expired()
+}
+
+// Set sets the value and refreshes the gauge.
+func (v *TimedGauge) Set(value int64) {
+	v.update(func() { v.value.Store(value) })
+}
+
+// Add adds delta to the value and refreshes the gauge.
+func (v *TimedGauge) Add(delta int64) {
+	v.update(func() { v.value.Add(delta) })
+}
+
+// Inc increments the value and refreshes the gauge.
+func (v *TimedGauge) Inc() { v.Add(1) }
+
+// Dec decrements the value and refreshes the gauge.
+func (v *TimedGauge) Dec() { v.Add(-1) }
+
+func (v *TimedGauge) Visit(_ Mode, vs Visitor) { vs.OnInt(v.value.Load()) }
+
+func (v *TimedGauge) update(fn func()) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	fn()
+	v.updated.Store(v.now().UnixNano())
+	if v.reaped {
+		// Ignore errors, the name might have been taken by another variable in
+		// the meantime.
+		if err := v.reg.addNames(strings.Split(v.name, "."), v, v.opts); err == nil {
+			v.reaped = false
+		}
+	}
+}
+
+func (v *TimedGauge) expired() bool {
+	return v.staleFor(v.ttl)
+}
+
+func (v *TimedGauge) staleFor(d time.Duration) bool {
+	return d > 0 && v.now().UnixNano()-v.updated.Load() > int64(d)
+}
+
+// reap removes the gauge from its registry if it was not updated for maxAge.
+func (v *TimedGauge) reap(maxAge time.Duration) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if v.reaped || !v.staleFor(maxAge) || v.reg.Get(v.name) != Var(v) {
+		return
+	}
+	v.reg.Remove(v.name)
+	v.reaped = true
+}
+
+// TimedGaugeReaper periodically removes timed gauges that were not updated
+// for a while from a registry and its sub-registries.
+type TimedGaugeReaper struct {
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+// StartTimedGaugeReaper starts removing the timed gauges of r that were not
+// updated for maxAge, checking every interval.
+func StartTimedGaugeReaper(r *Registry, interval, maxAge time.Duration) *TimedGaugeReaper {
+	rp := &TimedGaugeReaper{done: make(chan struct{})}
+	rp.wg.Add(1)
+	go func() {
+		defer rp.wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-rp.done:
+				return
+			case <-ticker.C:
+				for _, g := range timedGauges(r) {
+					g.reap(maxAge)
+				}
+			}
+		}
+	}()
+	return rp
+}
+
+// Stop stops the reaper and waits for it to finish.
+func (rp *TimedGaugeReaper) Stop() {
+	close(rp.done)
+	rp.wg.Wait()
+}
+
+// timedGauges collects the timed gauges of r and its sub-registries. The
+// registry locks are not held while reaping, as updating a gauge locks the
+// gauge before the registry.
+func timedGauges(r *Registry) []*TimedGauge {
+	r.mu.RLock()
+	var gauges []*TimedGauge
+	var subs []*Registry
+	for _, e := range r.entries {
+		switch v := e.Var.(type) {
+		case *TimedGauge:
+			gauges = append(gauges, v)
+		case *Registry:
+			subs = append(subs, v)
+		}
+	}
+	r.mu.RUnlock()
+
+	for _, sub := range subs {
+		gauges = append(gauges, timedGauges(sub)...)
+	}
+	return gauges
+}
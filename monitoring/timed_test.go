@@ -0,0 +1,72 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package monitoring
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTimedGauge(t *testing.T) {
+	var mu sync.Mutex
+	now := time.Now()
+	clock := func() time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		return now
+	}
+	advance := func(d time.Duration) {
+		mu.Lock()
+		defer mu.Unlock()
+		now = now.Add(d)
+	}
+
+	reg := NewRegistry()
+	g := NewTimedGauge(reg, "conn.a.in_flight", time.Minute)
+	g.now = clock
+	g.Set(5)
+
+	snapshot := CollectFlatSnapshot(reg, Full, false)
+	assert.Equal(t, int64(5), snapshot.Ints["conn.a.in_flight"])
+
+	advance(2 * time.Minute)
+	value, fresh := g.Get()
+	assert.Equal(t, int64(5), value)
+	assert.False(t, fresh)
+	snapshot = CollectFlatSnapshot(reg, Full, false)
+	assert.NotContains(t, snapshot.Ints, "conn.a.in_flight", "stale gauges are not reported")
+
+	reaper := StartTimedGaugeReaper(reg, time.Millisecond, 10*time.Minute)
+	defer reaper.Stop()
+
+	time.Sleep(10 * time.Millisecond)
+	require.NotNil(t, reg.Get("conn.a.in_flight"), "gauges are only reaped after maxAge")
+
+	advance(10 * time.Minute)
+	require.Eventually(t, func() bool {
+		return reg.Get("conn") == nil
+	}, time.Second, time.Millisecond, "dead gauge must be reaped")
+
+	g.Set(7)
+	snapshot = CollectFlatSnapshot(reg, Full, false)
+	assert.Equal(t, int64(7), snapshot.Ints["conn.a.in_flight"], "updating a reaped gauge registers it again")
+}
@@ -45,6 +45,9 @@ type Config struct {
 	TLS     *tlscommon.TLSConfig
 	Timeout time.Duration
 	Stats   IOStatser
+	// LocalAddr is the local IP outbound connections are bound to, see
+	// ParseLocalAddress. The default route is used if nil.
+	LocalAddr net.IP
 }
 
 func NewClient(c Config, network, host string, defaultPort int) (*Client, error) {
@@ -18,6 +18,7 @@
 package httpcommon
 
 import (
+	"net"
 	"net/http"
 	"time"
 
@@ -42,6 +43,11 @@ type HTTPTransportSettings struct {
 
 	IdleConnTimeout time.Duration `config:"idle_connection_timeout" yaml:"idle_connection_timeout,omitempty" json:"idle_connection_timeout,omitempty"`
 
+	// LocalAddress is the local IP outbound connections are bound to, e.g. to
+	// egress from a specific interface on multi-homed hosts. It is ignored if
+	// a dialer is passed with WithBaseDialer.
+	LocalAddress string `config:"local_address" yaml:"local_address,omitempty" json:"local_address,omitempty"`
+
 	// Add more settings:
 	//  - DisableKeepAlive
 	//  - MaxIdleConns
@@ -67,8 +73,9 @@ type (
 	TransportOption interface{ sealTransportOption() }
 
 	extraSettings struct {
-		logger *logp.Logger
-		http2  bool
+		logger  *logp.Logger
+		http2   bool
+		timings func(RequestTimings)
 	}
 
 	dialerOption interface {
@@ -163,9 +170,11 @@ func (settings *HTTPTransportSettings) Unpack(cfg *config.C) error {
 		TLS             *tlscommon.Config `config:"ssl"`
 		Timeout         time.Duration     `config:"timeout"`
 		IdleConnTimeout time.Duration     `config:"idle_connection_timeout"`
+		LocalAddress    string            `config:"local_address"`
 	}{
 		Timeout:         settings.Timeout,
 		IdleConnTimeout: settings.IdleConnTimeout,
+		LocalAddress:    settings.LocalAddress,
 	}
 
 	if err := cfg.Unpack(&tmp); err != nil {
@@ -187,6 +196,7 @@ func (settings *HTTPTransportSettings) Unpack(cfg *config.C) error {
 		Timeout:         tmp.Timeout,
 		Proxy:           proxy,
 		IdleConnTimeout: tmp.IdleConnTimeout,
+		LocalAddress:    tmp.LocalAddress,
 	}
 	return nil
 }
@@ -212,7 +222,21 @@ func (settings *HTTPTransportSettings) RoundTripper(opts ...TransportOption) (ht
 	}
 
 	if dialer == nil {
-		dialer = transport.NetDialer(settings.Timeout)
+		var localIP net.IP
+		if settings.LocalAddress != "" {
+			var err error
+			if localIP, err = transport.ParseLocalAddress(settings.LocalAddress); err != nil {
+				return nil, err
+			}
+		}
+		switch {
+		case extra.timings != nil:
+			dialer = transport.TimedNetDialer(settings.Timeout, localIP)
+		case localIP != nil:
+			dialer = transport.LocalAddrNetDialer(settings.Timeout, localIP)
+		default:
+			dialer = transport.NetDialer(settings.Timeout)
+		}
 	}
 
 	tls, err := tlscommon.LoadTLSConfig(settings.TLS)
@@ -242,6 +266,9 @@ func (settings *HTTPTransportSettings) RoundTripper(opts ...TransportOption) (ht
 	} else {
 		rt = settings.httpRoundTripper(tls, dialer, tlsDialer, opts...)
 	}
+	if extra.timings != nil {
+		rt = &timingRoundTripper{rt: rt, observer: extra.timings}
+	}
 
 	for _, opt := range opts {
 		if rtOpt, ok := opt.(roundTripperOption); ok {
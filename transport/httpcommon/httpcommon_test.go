@@ -18,12 +18,16 @@
 package httpcommon
 
 import (
+	"net"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/require"
 
 	"github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/transport"
 	"github.com/elastic/elastic-agent-libs/transport/tlscommon"
 )
 
@@ -78,6 +82,12 @@ ssl:
 				Timeout:         5 * time.Second,
 			},
 		},
+		"localAddress": {
+			input: `
+local_address: 127.0.0.1
+`,
+			expected: HTTPTransportSettings{LocalAddress: "127.0.0.1"},
+		},
 	}
 	for name, tc := range tests {
 		t.Run(name, func(t *testing.T) {
@@ -92,3 +102,46 @@ ssl:
 		})
 	}
 }
+
+func TestLocalAddress(t *testing.T) {
+	// Linux routes all of 127.0.0.0/8 to the loopback interface, other
+	// systems only 127.0.0.1 unless configured otherwise.
+	const localIP = "127.0.0.2"
+	if _, err := transport.ParseLocalAddress(localIP); err != nil {
+		t.Skipf("%s is not available: %v", localIP, err)
+	}
+
+	var remoteAddr string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		remoteAddr = r.RemoteAddr
+	}))
+	defer srv.Close()
+
+	settings := DefaultHTTPTransportSettings()
+	settings.LocalAddress = localIP + ":0"
+	client, err := settings.Client()
+	require.NoError(t, err)
+
+	resp, err := client.Get(srv.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	host, _, err := net.SplitHostPort(remoteAddr)
+	require.NoError(t, err)
+	require.Equal(t, localIP, host)
+}
+
+func TestLocalAddressInvalid(t *testing.T) {
+	for name, addr := range map[string]string{
+		"not an IP":    "eth0",
+		"port not 0":   "127.0.0.1:8080",
+		"not assigned": "192.0.2.1", // TEST-NET-1, never assigned
+	} {
+		t.Run(name, func(t *testing.T) {
+			settings := DefaultHTTPTransportSettings()
+			settings.LocalAddress = addr
+			_, err := settings.Client()
+			require.ErrorContains(t, err, addr)
+		})
+	}
+}
@@ -0,0 +1,219 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package httpcommon
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+
+	"github.com/elastic/elastic-agent-libs/monitoring"
+)
+
+// HostLimiter bounds the number of in-flight requests per destination host.
+// A single HostLimiter can be shared by independently created clients via
+// WithHostLimiter, so together they never exceed the limit. Requests over the
+// limit wait until a slot is free or their context is done.
+type HostLimiter struct {
+	limit int
+
+	mu    sync.Mutex
+	hosts map[string]*hostSlots
+
+	inFlight *monitoring.Int
+	waiting  *monitoring.Int
+}
+
+// hostSlots holds the in-flight requests to a host. It is removed from the
+// limiter once no request holds or waits for a slot.
+type hostSlots struct {
+	sem chan struct{}
+	// refs counts the requests holding or waiting for a slot, it is
+	// protected by the mutex of the limiter.
+	refs int
+}
+
+// hostLimiterMetrics are the names of the metrics reported by a HostLimiter.
+var hostLimiterMetrics = []string{"limit", "in_flight", "waiting", "hosts"}
+
+// NewHostLimiter creates a HostLimiter allowing at most limit in-flight
+// requests per host. If reg is not nil the limit, the total number of
+// in-flight and waiting requests and the in-flight requests per host are
+// reported to it. Every limiter needs its own registry, an error is returned
+// if reg already holds one of the metrics.
+func NewHostLimiter(limit int, reg *monitoring.Registry) (*HostLimiter, error) {
+	if limit < 1 {
+		limit = 1
+	}
+	l := &HostLimiter{
+		limit: limit,
+		hosts: map[string]*hostSlots{},
+	}
+
+	if reg == nil {
+		reg = monitoring.NewRegistry()
+	}
+	for _, name := range hostLimiterMetrics {
+		if reg.Get(name) != nil {
+			return nil, fmt.Errorf("monitoring registry already holds the host limiter metric '%s'", name)
+		}
+	}
+	monitoring.NewInt(reg, "limit").Set(int64(limit))
+	l.inFlight = monitoring.NewInt(reg, "in_flight")
+	l.waiting = monitoring.NewInt(reg, "waiting")
+	monitoring.NewFunc(reg, "hosts", l.reportHosts)
+	return l, nil
+}
+
+// Limit returns the maximum number of in-flight requests per host.
+func (l *HostLimiter) Limit() int {
+	return l.limit
+}
+
+// InFlight returns the number of in-flight requests to host.
+func (l *HostLimiter) InFlight(host string) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if slots, ok := l.hosts[host]; ok {
+		return len(slots.sem)
+	}
+	return 0
+}
+
+// ref returns the slots of host, counting a reference to them.
+func (l *HostLimiter) ref(host string) *hostSlots {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	slots, ok := l.hosts[host]
+	if !ok {
+		slots = &hostSlots{sem: make(chan struct{}, l.limit)}
+		l.hosts[host] = slots
+	}
+	slots.refs++
+	return slots
+}
+
+// unref drops a reference to the slots of host, removing them if it was the
+// last one.
+func (l *HostLimiter) unref(host string, slots *hostSlots) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	slots.refs--
+	if slots.refs == 0 {
+		delete(l.hosts, host)
+	}
+}
+
+func (l *HostLimiter) acquire(req *http.Request) (release func(), err error) {
+	host := req.URL.Host
+	slots := l.ref(host)
+
+	select {
+	case slots.sem <- struct{}{}:
+	default:
+		l.waiting.Inc()
+		select {
+		case slots.sem <- struct{}{}:
+		case <-req.Context().Done():
+			err = req.Context().Err()
+		}
+		l.waiting.Dec()
+		if err != nil {
+			l.unref(host, slots)
+			return nil, err
+		}
+	}
+
+	l.inFlight.Inc()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			<-slots.sem
+			l.inFlight.Dec()
+			l.unref(host, slots)
+		})
+	}, nil
+}
+
+func (l *HostLimiter) reportHosts(_ monitoring.Mode, V monitoring.Visitor) {
+	l.mu.Lock()
+	hosts := make(map[string]int, len(l.hosts))
+	for host, slots := range l.hosts {
+		hosts[host] = len(slots.sem)
+	}
+	l.mu.Unlock()
+
+	names := make([]string, 0, len(hosts))
+	for host := range hosts {
+		names = append(names, host)
+	}
+	sort.Strings(names)
+
+	V.OnRegistryStart()
+	defer V.OnRegistryFinished()
+	for _, host := range names {
+		monitoring.ReportInt(V, host, int64(hosts[host]))
+	}
+}
+
+type limitedRoundTripper struct {
+	limiter *HostLimiter
+	rt      http.RoundTripper
+}
+
+func (rt *limitedRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	release, err := rt.limiter.acquire(req)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := rt.rt.RoundTrip(req)
+	if err != nil || resp.Body == nil {
+		release()
+		return resp, err
+	}
+
+	// The request stays in flight until the response body is consumed.
+	resp.Body = &releaseOnClose{ReadCloser: resp.Body, release: release}
+	return resp, nil
+}
+
+type releaseOnClose struct {
+	io.ReadCloser
+	release func()
+}
+
+func (b *releaseOnClose) Close() error {
+	err := b.ReadCloser.Close()
+	b.release()
+	return err
+}
+
+// WithHostLimiter bounds the number of in-flight requests per destination host
+// using the given limiter. A request is in flight until its response body is
+// closed.
+func WithHostLimiter(l *HostLimiter) TransportOption {
+	return WithModRoundtripper(func(rt http.RoundTripper) http.RoundTripper {
+		if l == nil {
+			return rt
+		}
+		return &limitedRoundTripper{limiter: l, rt: rt}
+	})
+}
@@ -0,0 +1,122 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package httpcommon
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/elastic-agent-libs/monitoring"
+)
+
+func TestHostLimiterSharedAcrossClients(t *testing.T) {
+	const limit = 2
+
+	var current, peak atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := current.Add(1)
+		defer current.Add(-1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	reg := monitoring.NewRegistry()
+	limiter, err := NewHostLimiter(limit, reg)
+	require.NoError(t, err)
+
+	settings := DefaultHTTPTransportSettings()
+	clients := make([]*http.Client, 2)
+	for i := range clients {
+		c, err := settings.Client(WithHostLimiter(limiter))
+		require.NoError(t, err)
+		clients[i] = c
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(c *http.Client) {
+			defer wg.Done()
+			resp, err := c.Get(server.URL)
+			if !assert.NoError(t, err) {
+				return
+			}
+			_, _ = io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}(clients[i%len(clients)])
+	}
+	wg.Wait()
+
+	assert.LessOrEqual(t, peak.Load(), int64(limit))
+
+	snapshot := monitoring.CollectFlatSnapshot(reg, monitoring.Full, false)
+	assert.Equal(t, int64(limit), snapshot.Ints["limit"])
+	assert.Equal(t, int64(0), snapshot.Ints["in_flight"])
+	assert.Equal(t, int64(0), snapshot.Ints["waiting"])
+	assert.Empty(t, limiter.hosts, "idle hosts must be removed")
+
+	_, err = NewHostLimiter(limit, reg)
+	assert.ErrorContains(t, err, "already holds the host limiter metric 'limit'")
+}
+
+func TestHostLimiterContextCancel(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	limiter, err := NewHostLimiter(1, nil)
+	require.NoError(t, err)
+	client, err := DefaultHTTPTransportSettings().Client(WithHostLimiter(limiter))
+	require.NoError(t, err)
+
+	go func() {
+		resp, err := client.Get(server.URL)
+		if err == nil {
+			resp.Body.Close()
+		}
+	}()
+	require.Eventually(t, func() bool {
+		return limiter.InFlight(server.Listener.Addr().String()) == 1
+	}, time.Second, time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+	require.NoError(t, err)
+	_, err = client.Do(req) //nolint:bodyclose // the request never gets a response
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+}
@@ -0,0 +1,133 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package httpcommon
+
+import (
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+
+	metrics "github.com/rcrowley/go-metrics"
+
+	"github.com/elastic/elastic-agent-libs/monitoring"
+	"github.com/elastic/elastic-agent-libs/monitoring/adapter"
+	"github.com/elastic/elastic-agent-libs/transport"
+)
+
+// RequestTimings are the durations of the phases of an HTTP request.
+type RequestTimings struct {
+	// Reused is true if the request was sent over a connection used by a
+	// previous request. DNS, Connect and TLSHandshake are zero then.
+	Reused       bool
+	DNS          time.Duration
+	Connect      time.Duration
+	TLSHandshake time.Duration
+	// TimeToFirstByte is the time from sending the request, including
+	// establishing the connection, to receiving the first byte of the response.
+	TimeToFirstByte time.Duration
+}
+
+// WithRequestTimings reports the timings of every request that receives a
+// response to observer. The phases of establishing the connection are only
+// measured if no dialer is set with WithBaseDialer. The observer is called
+// synchronously and must be safe for concurrent use.
+func WithRequestTimings(observer func(RequestTimings)) TransportOption {
+	return extraOptionFunc(func(s *extraSettings) {
+		s.timings = observer
+	})
+}
+
+// timingRoundTripper traces requests to measure their RequestTimings.
+type timingRoundTripper struct {
+	rt       http.RoundTripper
+	observer func(RequestTimings)
+}
+
+func (rt *timingRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	var (
+		mu      sync.Mutex
+		timings RequestTimings
+	)
+	start := time.Now()
+	trace := &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			mu.Lock()
+			defer mu.Unlock()
+			timings.Reused = info.Reused
+			if info.Reused {
+				return
+			}
+			if ct, ok := transport.ConnTimingsOf(info.Conn); ok {
+				timings.DNS = ct.DNS
+				timings.Connect = ct.Connect
+				timings.TLSHandshake = ct.TLSHandshake
+			}
+		},
+		GotFirstResponseByte: func() {
+			mu.Lock()
+			defer mu.Unlock()
+			timings.TimeToFirstByte = time.Since(start)
+		},
+	}
+
+	resp, err := rt.rt.RoundTrip(req.WithContext(httptrace.WithClientTrace(req.Context(), trace)))
+	if err != nil {
+		return resp, err
+	}
+
+	mu.Lock()
+	observed := timings
+	mu.Unlock()
+	rt.observer(observed)
+	return resp, nil
+}
+
+// RequestTimingsMetrics returns an observer for WithRequestTimings that
+// records the timings in the registry name of parent. The durations of the
+// DNS lookup, connect and TLS handshake of new connections and the time to
+// first byte of all requests are recorded as histograms in microseconds,
+// requests over new and reused connections are counted.
+func RequestTimingsMetrics(parent *monitoring.Registry, name string) func(RequestTimings) {
+	reg := adapter.GetGoMetrics(parent, name, adapter.Accept)
+	histogram := func(name string) metrics.Histogram {
+		return metrics.GetOrRegisterHistogram(name, reg, metrics.NewUniformSample(1024))
+	}
+	var (
+		dns          = histogram("dns")
+		connect      = histogram("connect")
+		tlsHandshake = histogram("tls_handshake")
+		ttfb         = histogram("ttfb")
+		newConns     = metrics.GetOrRegisterCounter("connections.new", reg)
+		reusedConns  = metrics.GetOrRegisterCounter("connections.reused", reg)
+	)
+
+	return func(t RequestTimings) {
+		ttfb.Update(t.TimeToFirstByte.Microseconds())
+		if t.Reused {
+			reusedConns.Inc(1)
+			return
+		}
+		newConns.Inc(1)
+		dns.Update(t.DNS.Microseconds())
+		connect.Update(t.Connect.Microseconds())
+		if t.TLSHandshake > 0 {
+			tlsHandshake.Update(t.TLSHandshake.Microseconds())
+		}
+	}
+}
@@ -0,0 +1,87 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package httpcommon
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/elastic-agent-libs/monitoring"
+	"github.com/elastic/elastic-agent-libs/transport/tlscommon"
+)
+
+func TestRequestTimings(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	var (
+		mu       sync.Mutex
+		observed []RequestTimings
+	)
+	reg := monitoring.NewRegistry()
+	metrics := RequestTimingsMetrics(reg, "timings")
+
+	settings := DefaultHTTPTransportSettings()
+	settings.TLS = &tlscommon.Config{VerificationMode: tlscommon.VerifyNone}
+	client, err := settings.Client(WithRequestTimings(func(timings RequestTimings) {
+		mu.Lock()
+		defer mu.Unlock()
+		observed = append(observed, timings)
+		metrics(timings)
+	}))
+	require.NoError(t, err)
+
+	for i := 0; i < 2; i++ {
+		resp, err := client.Get(srv.URL)
+		require.NoError(t, err)
+		_, _ = io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.Len(t, observed, 2)
+
+	first := observed[0]
+	assert.False(t, first.Reused)
+	assert.Positive(t, first.Connect)
+	assert.Positive(t, first.TLSHandshake)
+	assert.Positive(t, first.TimeToFirstByte)
+	assert.Greater(t, first.TimeToFirstByte, first.TLSHandshake, "the time to first byte includes the handshake")
+
+	second := observed[1]
+	assert.True(t, second.Reused, "the connection must be reused")
+	assert.Zero(t, second.DNS)
+	assert.Zero(t, second.Connect)
+	assert.Zero(t, second.TLSHandshake)
+	assert.Positive(t, second.TimeToFirstByte)
+
+	snapshot := monitoring.CollectFlatSnapshot(reg, monitoring.Full, false)
+	assert.EqualValues(t, 1, snapshot.Ints["timings.connections.new"])
+	assert.EqualValues(t, 1, snapshot.Ints["timings.connections.reused"])
+	assert.EqualValues(t, 1, snapshot.Ints["timings.tls_handshake.count"])
+	assert.EqualValues(t, 2, snapshot.Ints["timings.ttfb.count"])
+}
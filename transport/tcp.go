@@ -32,6 +32,52 @@ func NetDialer(timeout time.Duration) Dialer {
 }
 
 func TestNetDialer(d testing.Driver, timeout time.Duration) Dialer {
+	return netDialer(d, timeout, nil, false)
+}
+
+// LocalAddrNetDialer creates a Dialer like NetDialer, binding all outbound
+// connections to the local IP address localIP. Only the resolved addresses of
+// the same IP family as localIP are dialed.
+func LocalAddrNetDialer(timeout time.Duration, localIP net.IP) Dialer {
+	return netDialer(testing.NullDriver, timeout, localIP, false)
+}
+
+// TimedNetDialer creates a Dialer like NetDialer, or LocalAddrNetDialer if
+// localIP is set, that records the duration of the DNS lookup and of the
+// connect in the connections it creates. TLS dialers forwarding to it record
+// the duration of the handshake. See ConnTimingsOf.
+func TimedNetDialer(timeout time.Duration, localIP net.IP) Dialer {
+	return netDialer(testing.NullDriver, timeout, localIP, true)
+}
+
+// ParseLocalAddress parses the local address to bind outbound connections
+// to. The address is an IP, optionally with port 0, like "10.0.0.2",
+// "10.0.0.2:0" or "[fe80::1]:0". An error is returned if the address is
+// invalid or can not be bound to on this host.
+func ParseLocalAddress(address string) (net.IP, error) {
+	host := address
+	if h, port, err := net.SplitHostPort(address); err == nil {
+		if port != "0" {
+			return nil, fmt.Errorf("invalid local address '%s': port must be 0", address)
+		}
+		host = h
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return nil, fmt.Errorf("invalid local address '%s': not an IP address", address)
+	}
+
+	// Make sure the address is assigned to this host.
+	l, err := net.Listen("tcp", net.JoinHostPort(ip.String(), "0"))
+	if err != nil {
+		return nil, fmt.Errorf("can not bind to local address '%s': %w", address, err)
+	}
+	_ = l.Close()
+	return ip, nil
+}
+
+func netDialer(d testing.Driver, timeout time.Duration, localIP net.IP, timed bool) Dialer {
 	return DialerFunc(func(network, address string) (net.Conn, error) {
 		switch network {
 		case "tcp", "tcp4", "tcp6", "udp", "udp4", "udp6":
@@ -45,7 +91,9 @@ func TestNetDialer(d testing.Driver, timeout time.Duration) Dialer {
 		if err != nil {
 			return nil, err
 		}
+		start := time.Now()
 		addresses, err := net.LookupHost(host)
+		dnsDuration := time.Since(start)
 		d.Fatal("dns lookup", err)
 		d.Info("addresses", strings.Join(addresses, ", "))
 		if err != nil {
@@ -53,9 +101,22 @@ func TestNetDialer(d testing.Driver, timeout time.Duration) Dialer {
 			return nil, err
 		}
 
-		// dial via host IP by randomized iteration of known IPs
 		dialer := &net.Dialer{Timeout: timeout}
-		return DialWith(dialer, network, host, addresses, port)
+		if localIP != nil {
+			dialer.LocalAddr = localAddr(network, localIP)
+			addresses = sameFamily(addresses, localIP)
+		}
+
+		// dial via host IP by randomized iteration of known IPs
+		if !timed {
+			return DialWith(dialer, network, host, addresses, port)
+		}
+		start = time.Now()
+		conn, err := DialWith(dialer, network, host, addresses, port)
+		if err != nil {
+			return nil, err
+		}
+		return &timedConn{Conn: conn, timings: ConnTimings{DNS: dnsDuration, Connect: time.Since(start)}}, nil
 	})
 }
 
@@ -71,3 +132,23 @@ func TestUnixDialer(d testing.Driver, timeout time.Duration, sockFile string) Di
 		return net.DialTimeout("unix", sockFile, timeout)
 	})
 }
+
+func localAddr(network string, ip net.IP) net.Addr {
+	if strings.HasPrefix(network, "udp") {
+		return &net.UDPAddr{IP: ip}
+	}
+	return &net.TCPAddr{IP: ip}
+}
+
+// sameFamily returns the addresses of the same IP family as ip, a connection
+// can not be made from an IPv4 address to an IPv6 one and vice versa.
+func sameFamily(addresses []string, ip net.IP) []string {
+	isV4 := ip.To4() != nil
+	filtered := make([]string, 0, len(addresses))
+	for _, addr := range addresses {
+		if a := net.ParseIP(addr); a != nil && (a.To4() != nil) == isV4 {
+			filtered = append(filtered, addr)
+		}
+	}
+	return filtered
+}
@@ -0,0 +1,71 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package transport
+
+import (
+	"net"
+	"time"
+)
+
+// ConnTimings are the durations of the phases of establishing a connection.
+type ConnTimings struct {
+	DNS          time.Duration
+	Connect      time.Duration
+	TLSHandshake time.Duration // zero if the connection does not use TLS
+}
+
+// timedConn is a connection created by a TimedNetDialer.
+type timedConn struct {
+	net.Conn
+	timings ConnTimings
+}
+
+// NetConn returns the underlying connection.
+func (c *timedConn) NetConn() net.Conn {
+	return c.Conn
+}
+
+// ConnTimingsOf returns the timings recorded for the connection c. c is
+// either created by a TimedNetDialer or wraps such a connection, like TLS
+// connections and the connections of StatsDialer and LoggingDialer do. ok is
+// false if no timings were recorded.
+func ConnTimingsOf(c net.Conn) (timings ConnTimings, ok bool) {
+	if tc := findTimedConn(c); tc != nil {
+		return tc.timings, true
+	}
+	return ConnTimings{}, false
+}
+
+// findTimedConn unwraps c until it finds a timedConn.
+func findTimedConn(c net.Conn) *timedConn {
+	for c != nil {
+		switch v := c.(type) {
+		case *timedConn:
+			return v
+		case *statsConn:
+			c = v.Conn
+		case *loggingConn:
+			c = v.Conn
+		case interface{ NetConn() net.Conn }:
+			c = v.NetConn()
+		default:
+			return nil
+		}
+	}
+	return nil
+}
@@ -174,12 +174,16 @@ func tlsDialWith(
 		d.Info("security", "server's certificate chain verification is enabled")
 	}
 
+	start := time.Now()
 	err = conn.Handshake()
 	d.Fatal("handshake", err)
 	if err != nil {
 		_ = conn.Close()
 		return nil, err
 	}
+	if tc := findTimedConn(socket); tc != nil {
+		tc.timings.TLSHandshake = time.Since(start)
+	}
 
 	// remove timeout if handshake was subject to timeout:
 	if withTimeout {
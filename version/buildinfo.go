@@ -0,0 +1,174 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package version
+
+import (
+	"fmt"
+	"strings"
+)
+
+const snapshotSuffix = "-SNAPSHOT"
+
+// Parse parses a version string like New, additionally accepting a trailing
+// -SNAPSHOT qualifier, e.g. 8.12.0-SNAPSHOT or 8.12.0-beta1-SNAPSHOT. The
+// SNAPSHOT qualifier is not part of Meta, String returns the full input.
+func Parse(version string) (*V, error) {
+	base := version
+	if hasSnapshotSuffix(version) {
+		base = version[:len(version)-len(snapshotSuffix)]
+	}
+	v, err := New(base)
+	if err != nil {
+		return nil, err
+	}
+	v.version = version
+	return v, nil
+}
+
+func hasSnapshotSuffix(version string) bool {
+	return len(version) > len(snapshotSuffix) &&
+		strings.EqualFold(version[len(version)-len(snapshotSuffix):], snapshotSuffix)
+}
+
+// BuildInfo holds the build metadata of a binary.
+type BuildInfo struct {
+	Version   string // major.minor.bugfix
+	Qualifier string // Pre-release qualifier, e.g. beta1. Empty for releases.
+	Commit    string
+	BuildTime string
+
+	snapshot bool
+}
+
+// NewBuildInfo creates a BuildInfo from the raw version, commit and build time
+// strings. The version may carry a qualifier and a -SNAPSHOT suffix, e.g.
+// 8.12.0-beta1-SNAPSHOT.
+func NewBuildInfo(version, commit, buildTime string) (BuildInfo, error) {
+	v, err := Parse(version)
+	if err != nil {
+		return BuildInfo{}, err
+	}
+	return BuildInfo{
+		Version:   fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Bugfix),
+		Qualifier: v.Meta,
+		Commit:    commit,
+		BuildTime: buildTime,
+		snapshot:  hasSnapshotSuffix(version),
+	}, nil
+}
+
+// MustNewBuildInfo is like NewBuildInfo but panics if the version is invalid.
+func MustNewBuildInfo(version, commit, buildTime string) BuildInfo {
+	b, err := NewBuildInfo(version, commit, buildTime)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+// Snapshot returns true for snapshot builds.
+func (b BuildInfo) Snapshot() bool {
+	return b.snapshot
+}
+
+// FullVersion returns the version including qualifier and snapshot suffix,
+// e.g. 8.12.0-beta1-SNAPSHOT.
+func (b BuildInfo) FullVersion() string {
+	v := b.Version
+	if b.Qualifier != "" {
+		v += "-" + b.Qualifier
+	}
+	if b.snapshot {
+		v += snapshotSuffix
+	}
+	return v
+}
+
+// String returns the full version followed by the commit and build time, if
+// known, e.g. "8.12.0-SNAPSHOT (commit: 1a2b3c, built: 2024-01-10T12:00:00Z)".
+func (b BuildInfo) String() string {
+	var details []string
+	if b.Commit != "" {
+		details = append(details, "commit: "+b.Commit)
+	}
+	if b.BuildTime != "" {
+		details = append(details, "built: "+b.BuildTime)
+	}
+	if len(details) == 0 {
+		return b.FullVersion()
+	}
+	return b.FullVersion() + " (" + strings.Join(details, ", ") + ")"
+}
+
+// Compare compares the versions of two builds and returns -1, 0 or +1. The
+// major, minor and bugfix numbers are compared in order. For equal numbers a
+// pre-release sorts before the release and a snapshot before the build it
+// precedes. Commit and build time are not taken into account. An invalid
+// Version compares like 0.0.0.
+func (b BuildInfo) Compare(o BuildInfo) int {
+	bv, ov := b.numbers(), o.numbers()
+	for _, d := range []int{
+		bv.Major - ov.Major,
+		bv.Minor - ov.Minor,
+		bv.Bugfix - ov.Bugfix,
+	} {
+		if d != 0 {
+			return sign(d)
+		}
+	}
+
+	switch {
+	case b.Qualifier == o.Qualifier:
+	case b.Qualifier == "":
+		return 1
+	case o.Qualifier == "":
+		return -1
+	default:
+		return strings.Compare(b.Qualifier, o.Qualifier)
+	}
+
+	switch {
+	case b.snapshot == o.snapshot:
+		return 0
+	case b.snapshot:
+		return -1
+	default:
+		return 1
+	}
+}
+
+// numbers parses the major, minor and bugfix numbers from Version.
+func (b BuildInfo) numbers() V {
+	v, err := New(b.Version)
+	if err != nil {
+		return V{}
+	}
+	return *v
+}
+
+// LessThan returns true if b is a strictly older version than o.
+func (b BuildInfo) LessThan(o BuildInfo) bool {
+	return b.Compare(o) < 0
+}
+
+func sign(d int) int {
+	if d < 0 {
+		return -1
+	}
+	return 1
+}
@@ -0,0 +1,118 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package version
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	v, err := Parse("8.12.1-SNAPSHOT")
+	require.NoError(t, err)
+	assert.Equal(t, V{Major: 8, Minor: 12, Bugfix: 1, version: "8.12.1-SNAPSHOT"}, *v)
+
+	v, err = Parse("8.12.1-beta1-SNAPSHOT")
+	require.NoError(t, err)
+	assert.Equal(t, "beta1", v.Meta)
+	assert.Equal(t, "8.12.1-beta1-SNAPSHOT", v.String())
+
+	_, err = Parse("SNAPSHOT")
+	assert.Error(t, err)
+}
+
+func TestBuildInfo(t *testing.T) {
+	tests := map[string]struct {
+		version, commit, buildTime string
+		full, str                  string
+		qualifier                  string
+		snapshot                   bool
+	}{
+		"release": {
+			version: "8.12.0", commit: "1a2b3c", buildTime: "2024-01-10T12:00:00Z",
+			full: "8.12.0", str: "8.12.0 (commit: 1a2b3c, built: 2024-01-10T12:00:00Z)",
+		},
+		"snapshot": {
+			version: "8.12.0-SNAPSHOT", commit: "1a2b3c",
+			full: "8.12.0-SNAPSHOT", str: "8.12.0-SNAPSHOT (commit: 1a2b3c)",
+			snapshot: true,
+		},
+		"qualified snapshot": {
+			version: "8.12.0-beta1-SNAPSHOT",
+			full:    "8.12.0-beta1-SNAPSHOT", str: "8.12.0-beta1-SNAPSHOT",
+			qualifier: "beta1", snapshot: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			b, err := NewBuildInfo(tc.version, tc.commit, tc.buildTime)
+			require.NoError(t, err)
+			assert.Equal(t, "8.12.0", b.Version)
+			assert.Equal(t, tc.qualifier, b.Qualifier)
+			assert.Equal(t, tc.snapshot, b.Snapshot())
+			assert.Equal(t, tc.full, b.FullVersion())
+			assert.Equal(t, tc.str, b.String())
+		})
+	}
+
+	_, err := NewBuildInfo("8.12", "", "")
+	assert.Error(t, err)
+}
+
+func TestBuildInfoCompare(t *testing.T) {
+	ordered := []string{
+		"8.11.4",
+		"8.12.0-alpha1",
+		"8.12.0-beta1-SNAPSHOT",
+		"8.12.0-beta1",
+		"8.12.0-SNAPSHOT",
+		"8.12.0",
+		"8.12.1",
+		"9.0.0",
+	}
+	for i := range ordered {
+		for j := range ordered {
+			a := MustNewBuildInfo(ordered[i], "a", "")
+			b := MustNewBuildInfo(ordered[j], "b", "")
+			expected := 0
+			if i < j {
+				expected = -1
+			} else if i > j {
+				expected = 1
+			}
+			assert.Equal(t, expected, a.Compare(b), "%s vs %s", ordered[i], ordered[j])
+		}
+	}
+	assert.True(t, MustNewBuildInfo("8.11.0", "", "").LessThan(MustNewBuildInfo("8.12.0", "", "")))
+}
+
+func TestBuildInfoCompareLiterals(t *testing.T) {
+	release := BuildInfo{Version: "8.12.0"}
+	beta := BuildInfo{Version: "8.12.0", Qualifier: "beta1"}
+	older := BuildInfo{Version: "8.11.4"}
+
+	assert.Equal(t, 1, release.Compare(older))
+	assert.Equal(t, -1, older.Compare(release))
+	assert.Equal(t, -1, beta.Compare(release))
+	assert.Equal(t, 1, beta.Compare(older))
+	assert.Equal(t, 0, release.Compare(MustNewBuildInfo("8.12.0", "1a2b3c", "")))
+	assert.True(t, BuildInfo{Version: "8.9.0"}.LessThan(BuildInfo{Version: "8.10.0"}))
+}